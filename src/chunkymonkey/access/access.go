@@ -0,0 +1,65 @@
+// Package access decides who may log in to the server, with ban lists and a
+// whitelist that are stored as JSON files.
+package access
+
+import (
+	"net"
+	"time"
+)
+
+// Lists holds the ban lists and whitelist that are checked when players log
+// in.
+type Lists struct {
+	Names     *BanList
+	Ips       *BanList
+	Whitelist *Whitelist
+}
+
+// LoadLists loads the ban lists for player names and IP addresses, and the
+// whitelist, from their files.
+func LoadLists(namesFile, ipsFile, whitelistFile string) (lists *Lists, err error) {
+	lists = new(Lists)
+	if lists.Names, err = LoadBanList(namesFile); err != nil {
+		return nil, err
+	}
+	if lists.Ips, err = LoadBanList(ipsFile); err != nil {
+		return nil, err
+	}
+	if lists.Whitelist, err = LoadWhitelist(whitelistFile); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// Banned returns the ban in force on the player name or IP address, or nil if
+// there is none.
+func (lists *Lists) Banned(name, ip string) *Ban {
+	now := time.Now()
+	if ban := lists.Names.Banned(name, now); ban != nil {
+		return ban
+	}
+	return lists.Ips.Banned(ip, now)
+}
+
+// CheckLogin returns nil if the player may log in from the IP address. The
+// error otherwise is a *BanError or ErrNotWhitelisted, and its message is for
+// the player.
+func (lists *Lists) CheckLogin(name, ip string) error {
+	if ban := lists.Banned(name, ip); ban != nil {
+		return &BanError{ban}
+	}
+	if !lists.Whitelist.Allowed(name) {
+		return ErrNotWhitelisted
+	}
+	return nil
+}
+
+// AddrIp returns the IP address part of a network address, as used for the
+// keys of IP address bans.
+func AddrIp(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,120 @@
+package access
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ban describes why and until when a player name or IP address is banned.
+type Ban struct {
+	Reason string
+	// Source is the name of the player that made the ban.
+	Source  string
+	Created time.Time
+	// Expires is the time that the ban ends, or the zero time for a permanent
+	// ban.
+	Expires time.Time
+}
+
+// Active returns true if the ban is in force at the given time.
+func (ban *Ban) Active(now time.Time) bool {
+	return ban.Expires.IsZero() || now.Before(ban.Expires)
+}
+
+// Message describes the ban to the banned player.
+func (ban *Ban) Message() string {
+	msg := "You are banned from this server"
+	if !ban.Expires.IsZero() {
+		msg += " until " + ban.Expires.Local().Format("Jan 2 15:04")
+	}
+	if ban.Reason != "" {
+		msg += ": " + ban.Reason
+	}
+	return msg + "."
+}
+
+// BanError is returned when a player is refused because of a ban.
+type BanError struct {
+	Ban *Ban
+}
+
+func (err *BanError) Error() string {
+	return err.Ban.Message()
+}
+
+// BanList is a persistent list of bans, keyed by player name or IP address.
+// Player names are not case sensitive. It is safe for concurrent use.
+type BanList struct {
+	filename string
+	lock     sync.Mutex
+	bans     map[string]*Ban
+}
+
+// LoadBanList loads the ban list from the file, which is written whenever the
+// list changes. A missing file is an empty list.
+func LoadBanList(filename string) (*BanList, error) {
+	list := &BanList{
+		filename: filename,
+		bans:     make(map[string]*Ban),
+	}
+
+	if err := LoadJsonFile(filename, &list.bans); err != nil {
+		return nil, fmt.Errorf("Error loading ban list %s: %v", filename, err)
+	}
+
+	return list, nil
+}
+
+func banKey(key string) string {
+	return strings.ToLower(key)
+}
+
+// Add bans the name or address, replacing any existing ban on it.
+func (list *BanList) Add(key string, ban *Ban) error {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+
+	list.bans[banKey(key)] = ban
+	return list.save()
+}
+
+// Remove lifts the ban on the name or address. It returns false if there was
+// no ban.
+func (list *BanList) Remove(key string) (bool, error) {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+
+	key = banKey(key)
+	if _, ok := list.bans[key]; !ok {
+		return false, nil
+	}
+	delete(list.bans, key)
+	return true, list.save()
+}
+
+// Banned returns the ban in force on the name or address at the given time,
+// or nil if there is none.
+func (list *BanList) Banned(key string, now time.Time) *Ban {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+
+	if ban, ok := list.bans[banKey(key)]; ok && ban.Active(now) {
+		return ban
+	}
+	return nil
+}
+
+// save writes the list, leaving out bans that have expired. It must be called
+// with the lock held.
+func (list *BanList) save() error {
+	now := time.Now()
+	for key, ban := range list.bans {
+		if !ban.Active(now) {
+			delete(list.bans, key)
+		}
+	}
+
+	return SaveJsonFile(list.filename, list.bans)
+}
@@ -0,0 +1,91 @@
+package access
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestBanList(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+	filename := path.Join(dirPath, "banned.json")
+
+	list, err := LoadBanList(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	bans := map[string]*Ban{
+		"Griefer":  {Reason: "griefing", Source: "admin"},
+		"spammer":  {Expires: now.Add(time.Hour)},
+		"expired":  {Expires: now.Add(-time.Hour)},
+		"10.0.0.1": {},
+	}
+	for key, ban := range bans {
+		if err = list.Add(key, ban); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The list is reloaded to check that it was saved.
+	if list, err = LoadBanList(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key    string
+		at     time.Time
+		banned bool
+	}{
+		{"griefer", now, true},
+		{"GRIEFER", now.Add(1000 * time.Hour), true},
+		{"spammer", now, true},
+		{"spammer", now.Add(2 * time.Hour), false},
+		{"expired", now, false},
+		{"10.0.0.1", now, true},
+		{"10.0.0.2", now, false},
+		{"someone", now, false},
+	}
+	for _, test := range tests {
+		ban := list.Banned(test.key, test.at)
+		if banned := ban != nil; banned != test.banned {
+			t.Errorf("Banned(%q) = %v, want banned=%t", test.key, ban, test.banned)
+		}
+	}
+
+	if ban := list.Banned("griefer", now); ban == nil || ban.Reason != "griefing" || ban.Source != "admin" {
+		t.Errorf("griefer's ban = %+v, want reason and source kept", ban)
+	}
+
+	if removed, err := list.Remove("Griefer"); !removed || err != nil {
+		t.Errorf("Remove(Griefer) = %t, %v, want true, nil", removed, err)
+	}
+	if removed, _ := list.Remove("griefer"); removed {
+		t.Errorf("Remove(griefer) = true after it was removed")
+	}
+	if list.Banned("griefer", now) != nil {
+		t.Errorf("griefer still banned after Remove")
+	}
+}
+
+func TestBan_Message(t *testing.T) {
+	tests := []struct {
+		ban  Ban
+		want string
+	}{
+		{Ban{}, "You are banned from this server."},
+		{Ban{Reason: "griefing"}, "You are banned from this server: griefing."},
+	}
+	for _, test := range tests {
+		if got := test.ban.Message(); got != test.want {
+			t.Errorf("%+v.Message() = %q, want %q", test.ban, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package access
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// LoadJsonFile decodes the JSON file into value. A missing file leaves value
+// unchanged, and is not an error.
+func LoadJsonFile(filename string, value interface{}) error {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(value)
+}
+
+// SaveJsonFile encodes value as JSON to a temporary file which then replaces
+// the file, so that a failed write doesn't lose the previous contents.
+func SaveJsonFile(filename string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpFilename := filename + ".tmp"
+	file, err := os.OpenFile(tmpFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+
+	return os.Rename(tmpFilename, filename)
+}
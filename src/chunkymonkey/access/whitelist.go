@@ -0,0 +1,122 @@
+package access
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrNotWhitelisted = errors.New("You are not on the whitelist.")
+
+// Whitelist is a persistent list of the player names that may log in while
+// it is enabled. Player names are not case sensitive. It is safe for
+// concurrent use.
+type Whitelist struct {
+	filename string
+	lock     sync.Mutex
+	enabled  bool
+	names    map[string]bool
+}
+
+// whitelistFile is how a Whitelist is stored.
+type whitelistFile struct {
+	Enabled bool
+	Names   []string
+}
+
+// LoadWhitelist loads the whitelist from the file, which is written whenever
+// the whitelist changes. A missing file is an empty, disabled whitelist.
+func LoadWhitelist(filename string) (*Whitelist, error) {
+	var stored whitelistFile
+	if err := LoadJsonFile(filename, &stored); err != nil {
+		return nil, fmt.Errorf("Error loading whitelist %s: %v", filename, err)
+	}
+
+	whitelist := &Whitelist{
+		filename: filename,
+		enabled:  stored.Enabled,
+		names:    make(map[string]bool),
+	}
+	for _, name := range stored.Names {
+		whitelist.names[strings.ToLower(name)] = true
+	}
+
+	return whitelist, nil
+}
+
+// Enabled returns true if only the names on the whitelist may log in.
+func (whitelist *Whitelist) Enabled() bool {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	return whitelist.enabled
+}
+
+// SetEnabled turns enforcement of the whitelist on or off.
+func (whitelist *Whitelist) SetEnabled(enabled bool) error {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	whitelist.enabled = enabled
+	return whitelist.save()
+}
+
+// Add puts the name on the whitelist.
+func (whitelist *Whitelist) Add(name string) error {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	whitelist.names[strings.ToLower(name)] = true
+	return whitelist.save()
+}
+
+// Remove takes the name off the whitelist. It returns false if the name
+// wasn't on it.
+func (whitelist *Whitelist) Remove(name string) (bool, error) {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	name = strings.ToLower(name)
+	if !whitelist.names[name] {
+		return false, nil
+	}
+	delete(whitelist.names, name)
+	return true, whitelist.save()
+}
+
+// Names returns the names on the whitelist in order.
+func (whitelist *Whitelist) Names() []string {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	names := make([]string, 0, len(whitelist.names))
+	for name := range whitelist.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Allowed returns true if the whitelist is disabled or the name is on it.
+func (whitelist *Whitelist) Allowed(name string) bool {
+	whitelist.lock.Lock()
+	defer whitelist.lock.Unlock()
+
+	return !whitelist.enabled || whitelist.names[strings.ToLower(name)]
+}
+
+// save must be called with the lock held.
+func (whitelist *Whitelist) save() error {
+	stored := whitelistFile{
+		Enabled: whitelist.enabled,
+		Names:   make([]string, 0, len(whitelist.names)),
+	}
+	for name := range whitelist.names {
+		stored.Names = append(stored.Names, name)
+	}
+	sort.Strings(stored.Names)
+
+	return SaveJsonFile(whitelist.filename, &stored)
+}
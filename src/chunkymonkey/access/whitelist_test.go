@@ -0,0 +1,118 @@
+package access
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWhitelist(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+	filename := path.Join(dirPath, "whitelist.json")
+
+	whitelist, err := LoadWhitelist(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if whitelist.Enabled() || !whitelist.Allowed("anyone") {
+		t.Errorf("new whitelist should be disabled")
+	}
+
+	for _, name := range []string{"Bob", "alice"} {
+		if err = whitelist.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = whitelist.SetEnabled(true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The whitelist is reloaded to check that it was saved.
+	if whitelist, err = LoadWhitelist(filename); err != nil {
+		t.Fatal(err)
+	}
+	if !whitelist.Enabled() {
+		t.Errorf("whitelist not enabled after reloading")
+	}
+	if got, want := whitelist.Names(), []string{"alice", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		allowed bool
+	}{
+		{"alice", true},
+		{"BOB", true},
+		{"eve", false},
+	}
+	for _, test := range tests {
+		if got := whitelist.Allowed(test.name); got != test.allowed {
+			t.Errorf("Allowed(%q) = %t, want %t", test.name, got, test.allowed)
+		}
+	}
+
+	if removed, err := whitelist.Remove("bob"); !removed || err != nil {
+		t.Errorf("Remove(bob) = %t, %v, want true, nil", removed, err)
+	}
+	if whitelist.Allowed("bob") {
+		t.Errorf("bob allowed after Remove")
+	}
+}
+
+func TestLists_CheckLogin(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+
+	lists, err := LoadLists(path.Join(dirPath, "names.json"), path.Join(dirPath, "ips.json"), path.Join(dirPath, "whitelist.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists.Names.Add("griefer", &Ban{})
+	lists.Ips.Add("10.0.0.1", &Ban{Expires: time.Now().Add(time.Hour)})
+	lists.Whitelist.Add("alice")
+	lists.Whitelist.Add("griefer")
+
+	tests := []struct {
+		name, ip  string
+		whitelist bool
+		want      string
+	}{
+		{"alice", "10.0.0.2", false, ""},
+		{"bob", "10.0.0.2", false, ""},
+		{"griefer", "10.0.0.2", false, "ban"},
+		{"alice", "10.0.0.1", false, "ban"},
+		{"alice", "10.0.0.2", true, ""},
+		{"bob", "10.0.0.2", true, "whitelist"},
+		{"griefer", "10.0.0.2", true, "ban"},
+	}
+	for _, test := range tests {
+		lists.Whitelist.SetEnabled(test.whitelist)
+
+		var got string
+		switch err := lists.CheckLogin(test.name, test.ip); {
+		case err == nil:
+		case err == ErrNotWhitelisted:
+			got = "whitelist"
+		default:
+			if _, ok := err.(*BanError); ok {
+				got = "ban"
+			} else {
+				got = err.Error()
+			}
+		}
+		if got != test.want {
+			t.Errorf("CheckLogin(%q, %q) with whitelist=%t refused for %q, want %q", test.name, test.ip, test.whitelist, got, test.want)
+		}
+	}
+}
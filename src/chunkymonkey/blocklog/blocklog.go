@@ -0,0 +1,244 @@
+// The blocklog package records the changes that players make to blocks, so
+// that they can be inspected and undone later.
+//
+// Changes are appended to one log file per region of the world, with one line
+// per change. The log files are never rewritten.
+//
+// Only the block ID and data of each change are logged, not the contents of
+// tile entities. Undoing the destruction of a chest, furnace or dispenser
+// restores it empty, as its contents were dropped when it was destroyed.
+package blocklog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	. "chunkymonkey/types"
+)
+
+// regionEdgeShift is the log2 of the width of the region covered by each log
+// file in chunks. It matches the region files of the world itself.
+const regionEdgeShift = 5
+
+// Change is a single change of a block by a player.
+type Change struct {
+	Time       time.Time
+	Player     string
+	Block      BlockXyz
+	OldBlockId BlockId
+	OldData    byte
+	NewBlockId BlockId
+	NewData    byte
+}
+
+func (change *Change) String() string {
+	return fmt.Sprintf(
+		"%s %s %d %d %d %d %d %d %d",
+		change.Time.UTC().Format(time.RFC3339Nano), change.Player,
+		change.Block.X, change.Block.Y, change.Block.Z,
+		change.OldBlockId, change.OldData,
+		change.NewBlockId, change.NewData)
+}
+
+// parseChange parses a line of a log file, as written by Change.String.
+func parseChange(line string) (change Change, err error) {
+	var timeStr string
+	_, err = fmt.Sscanf(
+		line, "%s %s %d %d %d %d %d %d %d",
+		&timeStr, &change.Player,
+		&change.Block.X, &change.Block.Y, &change.Block.Z,
+		&change.OldBlockId, &change.OldData,
+		&change.NewBlockId, &change.NewData)
+	if err != nil {
+		return
+	}
+
+	change.Time, err = time.Parse(time.RFC3339Nano, timeStr)
+	return
+}
+
+// Query selects changes from the log. The zero value of each field matches all
+// changes.
+type Query struct {
+	// Player only matches changes made by the named player.
+	Player string
+	// Radius only matches changes to blocks within Radius blocks of Center
+	// along each axis.
+	Center BlockXyz
+	Radius BlockCoord
+	// Since only matches changes made at or after the time.
+	Since time.Time
+}
+
+// Matches returns true if the change is selected by the query.
+func (query *Query) Matches(change *Change) bool {
+	if query.Player != "" && change.Player != query.Player {
+		return false
+	}
+	if query.Radius > 0 {
+		if absCoord(change.Block.X-query.Center.X) > query.Radius ||
+			absCoord(BlockCoord(change.Block.Y)-BlockCoord(query.Center.Y)) > query.Radius ||
+			absCoord(change.Block.Z-query.Center.Z) > query.Radius {
+			return false
+		}
+	}
+	if !query.Since.IsZero() && change.Time.Before(query.Since) {
+		return false
+	}
+	return true
+}
+
+func absCoord(c BlockCoord) BlockCoord {
+	if c < 0 {
+		return -c
+	}
+	return c
+}
+
+// BlockLog appends changes to the log files in a directory, and searches them.
+// It is safe to use from multiple goroutines.
+type BlockLog struct {
+	dirPath string
+	lock    sync.Mutex
+	files   map[ChunkXz]*os.File // Open log files, by region.
+}
+
+// Dir returns the directory of the block log of a dimension of the world
+// stored at worldPath. As with the world's regions, the logs of dimensions
+// other than DimensionNormal are kept in their own DIM directories.
+func Dir(worldPath string, dimension DimensionId) string {
+	if dimension == DimensionNormal {
+		return path.Join(worldPath, "blocklog")
+	}
+	return path.Join(worldPath, fmt.Sprintf("DIM%d", dimension), "blocklog")
+}
+
+// Open opens the block log stored in dirPath, creating the directory if
+// needed.
+func Open(dirPath string) (blockLog *BlockLog, err error) {
+	if err = os.MkdirAll(dirPath, 0777); err != nil {
+		return
+	}
+
+	return &BlockLog{
+		dirPath: dirPath,
+		files:   make(map[ChunkXz]*os.File),
+	}, nil
+}
+
+// regionFor returns the region that contains the block.
+func regionFor(blockLoc *BlockXyz) ChunkXz {
+	chunkLoc := blockLoc.ToChunkXz()
+	return ChunkXz{chunkLoc.X >> regionEdgeShift, chunkLoc.Z >> regionEdgeShift}
+}
+
+func (blockLog *BlockLog) regionPath(region ChunkXz) string {
+	return path.Join(blockLog.dirPath, fmt.Sprintf("r.%d.%d.log", region.X, region.Z))
+}
+
+// Record appends the change to the log.
+func (blockLog *BlockLog) Record(change *Change) (err error) {
+	blockLog.lock.Lock()
+	defer blockLog.lock.Unlock()
+
+	region := regionFor(&change.Block)
+	file, ok := blockLog.files[region]
+	if !ok {
+		file, err = os.OpenFile(blockLog.regionPath(region), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+		if err != nil {
+			return
+		}
+		blockLog.files[region] = file
+	}
+
+	_, err = io.WriteString(file, change.String()+"\n")
+	return
+}
+
+// Search returns the changes that match the query, oldest first.
+func (blockLog *BlockLog) Search(query *Query) (changes []Change, err error) {
+	var filePaths []string
+	if query.Radius > 0 {
+		minCorner := BlockXyz{query.Center.X - query.Radius, 0, query.Center.Z - query.Radius}
+		maxCorner := BlockXyz{query.Center.X + query.Radius, 0, query.Center.Z + query.Radius}
+		minRegion, maxRegion := regionFor(&minCorner), regionFor(&maxCorner)
+		var region ChunkXz
+		for region.X = minRegion.X; region.X <= maxRegion.X; region.X++ {
+			for region.Z = minRegion.Z; region.Z <= maxRegion.Z; region.Z++ {
+				filePaths = append(filePaths, blockLog.regionPath(region))
+			}
+		}
+	} else if filePaths, err = filepath.Glob(path.Join(blockLog.dirPath, "r.*.log")); err != nil {
+		return
+	}
+
+	for _, filePath := range filePaths {
+		if err = searchFile(filePath, query, &changes); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Stable(byTime(changes))
+
+	return
+}
+
+// searchFile appends the changes in the log file that match the query to
+// changes.
+func searchFile(filePath string, query *Query, changes *[]Change) (err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			// Ignore any partial line that is still being written.
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		change, err := parseChange(line)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filePath, err)
+		}
+		if query.Matches(&change) {
+			*changes = append(*changes, change)
+		}
+	}
+}
+
+// Close closes the log files.
+func (blockLog *BlockLog) Close() (err error) {
+	blockLog.lock.Lock()
+	defer blockLog.lock.Unlock()
+
+	for region, file := range blockLog.files {
+		if closeErr := file.Close(); closeErr != nil {
+			err = closeErr
+		}
+		delete(blockLog.files, region)
+	}
+
+	return
+}
+
+type byTime []Change
+
+func (changes byTime) Len() int           { return len(changes) }
+func (changes byTime) Less(i, j int) bool { return changes[i].Time.Before(changes[j].Time) }
+func (changes byTime) Swap(i, j int)      { changes[i], changes[j] = changes[j], changes[i] }
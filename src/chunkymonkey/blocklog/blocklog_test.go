@@ -0,0 +1,83 @@
+package blocklog
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "chunkymonkey/types"
+)
+
+func TestBlockLog(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "blocklog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+
+	blockLog, err := Open(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockLog.Close()
+
+	start := time.Date(2011, 9, 1, 12, 0, 0, 0, time.UTC)
+	changes := []Change{
+		{start, "alice", BlockXyz{0, 64, 0}, 0, 0, 1, 0},
+		{start.Add(time.Second), "bob", BlockXyz{10, 64, -10}, 1, 0, 0, 0},
+		{start.Add(2 * time.Second), "alice", BlockXyz{1000, 70, 1000}, 0, 0, 35, 14},
+		{start.Add(3 * time.Second), "alice", BlockXyz{-5, 10, 3}, 3, 0, 0, 0},
+	}
+	for i := range changes {
+		if err := blockLog.Record(&changes[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []int
+	}{
+		{"everything", Query{}, []int{0, 1, 2, 3}},
+		{"by player", Query{Player: "alice"}, []int{0, 2, 3}},
+		{"within radius", Query{Center: BlockXyz{0, 64, 0}, Radius: 10}, []int{0, 1}},
+		{"player within radius", Query{Player: "alice", Center: BlockXyz{0, 64, 0}, Radius: 60}, []int{0, 3}},
+		{"since", Query{Since: start.Add(2 * time.Second)}, []int{2, 3}},
+		{"nobody", Query{Player: "carol"}, nil},
+	}
+
+	for _, test := range tests {
+		got, err := blockLog.Search(&test.query)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %d changes, want %d: %v", test.name, len(got), len(test.want), got)
+			continue
+		}
+		for i, index := range test.want {
+			if want := &changes[index]; !got[i].Time.Equal(want.Time) || got[i].String() != want.String() {
+				t.Errorf("%s: change %d = %v, want %v", test.name, i, &got[i], want)
+			}
+		}
+	}
+}
+
+func TestDir(t *testing.T) {
+	tests := []struct {
+		dimension DimensionId
+		want      string
+	}{
+		{DimensionNormal, "world/blocklog"},
+		{DimensionNether, "world/DIM-1/blocklog"},
+	}
+
+	for _, test := range tests {
+		if got := Dir("world", test.dimension); got != test.want {
+			t.Errorf("Dir(%q, %d) = %q, want %q", "world", test.dimension, got, test.want)
+		}
+	}
+}
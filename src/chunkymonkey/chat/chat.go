@@ -0,0 +1,245 @@
+// Package chat checks and logs the chat messages that players send, and holds
+// the mutes and players' ignore lists that decide who may send and who
+// receives them.
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"chunkymonkey/access"
+	"chunkymonkey/config"
+)
+
+// PermColor is the permission node that lets a player use color codes, such
+// as "&c", in their messages.
+const PermColor = "chat.color"
+
+var ErrFlood = errors.New("You are sending messages too quickly.")
+
+// LengthError is returned for a message that is too long.
+type LengthError struct {
+	MaxLength int
+}
+
+func (err *LengthError) Error() string {
+	return fmt.Sprintf("Your message is longer than %d characters.", err.MaxLength)
+}
+
+// MutedError is returned for a message from a muted player.
+type MutedError struct {
+	Mute *access.Ban
+}
+
+func (err *MutedError) Error() string {
+	msg := "You are muted"
+	if !err.Mute.Expires.IsZero() {
+		msg += " until " + err.Mute.Expires.Local().Format("Jan 2 15:04")
+	}
+	if err.Mute.Reason != "" {
+		msg += ": " + err.Mute.Reason
+	}
+	return msg + "."
+}
+
+// Service checks chat messages against the mutes and limits, logs them, and
+// keeps track of whom players reply to. Player names are not case sensitive.
+// It is safe for concurrent use.
+type Service struct {
+	maxLength   int
+	floodCount  int
+	floodPeriod time.Duration
+
+	mutes   *access.BanList
+	ignores *ignoreLists
+	logFile *rotatingFile // nil if chat is not logged.
+	logger  *log.Logger
+
+	lock    sync.Mutex
+	sent    map[string][]time.Time // The times of each player's recent messages.
+	replyTo map[string]string
+}
+
+// NewService loads the mutes and ignore lists, and opens the chat log, named
+// by the configuration.
+func NewService(cfg *config.ChatConfig) (service *Service, err error) {
+	service = &Service{
+		maxLength:   cfg.MaxLength,
+		floodCount:  cfg.FloodMessages,
+		floodPeriod: time.Duration(cfg.FloodSeconds) * time.Second,
+		sent:        make(map[string][]time.Time),
+		replyTo:     make(map[string]string),
+	}
+
+	if service.mutes, err = access.LoadBanList(cfg.Mutes); err != nil {
+		return nil, err
+	}
+	if service.ignores, err = loadIgnoreLists(cfg.Ignores); err != nil {
+		return nil, err
+	}
+	if cfg.Log != "" {
+		if service.logFile, err = openRotatingFile(cfg.Log, cfg.LogMaxSize, cfg.LogKeep); err != nil {
+			return nil, err
+		}
+		service.logger = log.New(service.logFile, "", log.LstdFlags)
+	}
+
+	return service, nil
+}
+
+// Close closes the chat log.
+func (service *Service) Close() error {
+	if service.logFile == nil {
+		return nil
+	}
+	return service.logFile.Close()
+}
+
+func nameKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// Prepare checks that the named player may send the message now, and returns
+// the message with its color codes translated if allowColor is true, or
+// removed otherwise. The error, if any, is for the player.
+func (service *Service) Prepare(name, message string, allowColor bool) (string, error) {
+	return service.prepare(name, message, allowColor, time.Now())
+}
+
+func (service *Service) prepare(name, message string, allowColor bool, now time.Time) (string, error) {
+	if mute := service.mutes.Banned(name, now); mute != nil {
+		return "", &MutedError{mute}
+	}
+	if utf8.RuneCountInString(message) > service.maxLength {
+		return "", &LengthError{service.maxLength}
+	}
+	if !service.allowMessage(name, now) {
+		return "", ErrFlood
+	}
+	return Sanitize(message, allowColor), nil
+}
+
+// allowMessage records a message from the player at the given time, and
+// returns false if it is over the flood limit. Refused messages count
+// towards the limit, so players must stop sending to be heard again.
+func (service *Service) allowMessage(name string, now time.Time) bool {
+	if service.floodCount == 0 {
+		return true
+	}
+
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	key := nameKey(name)
+	sent := service.sent[key]
+	// Only the times within the period are kept.
+	since := now.Add(-service.floodPeriod)
+	for len(sent) > 0 && !sent[0].After(since) {
+		sent = sent[1:]
+	}
+	allowed := len(sent) < service.floodCount
+	if len(sent) == service.floodCount {
+		sent = sent[1:]
+	}
+	service.sent[key] = append(sent, now)
+	return allowed
+}
+
+// Forget discards what is kept about the named player while they are logged
+// in, such as the times of their recent messages.
+func (service *Service) Forget(name string) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	delete(service.sent, nameKey(name))
+	delete(service.replyTo, nameKey(name))
+}
+
+// Log writes a line of chat to the chat log, without its color codes.
+func (service *Service) Log(line string) {
+	if service.logger != nil {
+		service.logger.Print(Sanitize(line, false))
+	}
+}
+
+// Mutes returns the list of muted players.
+func (service *Service) Mutes() *access.BanList {
+	return service.mutes
+}
+
+// Ignore adds ignored to the named player's ignore list.
+func (service *Service) Ignore(name, ignored string) error {
+	return service.ignores.add(name, ignored)
+}
+
+// Unignore takes ignored off the named player's ignore list. It returns false
+// if they weren't on it.
+func (service *Service) Unignore(name, ignored string) (bool, error) {
+	return service.ignores.remove(name, ignored)
+}
+
+// Ignoring returns true if the named player ignores messages from sender.
+func (service *Service) Ignoring(name, sender string) bool {
+	return service.ignores.contains(name, sender)
+}
+
+// Ignored returns the named player's ignore list in order.
+func (service *Service) Ignored(name string) []string {
+	return service.ignores.names(name)
+}
+
+// SetReplyTo records that the named player's replies go to the player to.
+func (service *Service) SetReplyTo(name, to string) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	service.replyTo[nameKey(name)] = to
+}
+
+// ReplyTo returns the player that the named player's replies go to, or "" if
+// there is none.
+func (service *Service) ReplyTo(name string) string {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	return service.replyTo[nameKey(name)]
+}
+
+// Sanitize removes the color codes, such as "§c", from the message. If
+// allowColor is true, they are kept, and "&" codes such as "&c" are turned
+// into color codes. A "§" that doesn't start a color code is always removed,
+// as clients crash on one at the end of a message, as are control characters.
+func Sanitize(message string, allowColor bool) string {
+	runes := []rune(message)
+	result := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if unicode.IsControl(r) {
+			continue
+		}
+		if r == '§' || (r == '&' && allowColor) {
+			if i+1 < len(runes) && isColorCode(runes[i+1]) {
+				if allowColor {
+					result = append(result, '§', runes[i+1])
+				}
+				i++
+				continue
+			}
+			if r == '§' {
+				continue
+			}
+		}
+		result = append(result, r)
+	}
+	return string(result)
+}
+
+func isColorCode(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f')
+}
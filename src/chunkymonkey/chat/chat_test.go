@@ -0,0 +1,216 @@
+package chat
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"chunkymonkey/access"
+	"chunkymonkey/config"
+)
+
+func newTestService(t *testing.T, dirPath string) *Service {
+	service, err := NewService(&config.ChatConfig{
+		MaxLength:     10,
+		FloodMessages: 2,
+		FloodSeconds:  10,
+		Mutes:         path.Join(dirPath, "mutes.json"),
+		Ignores:       path.Join(dirPath, "ignores.json"),
+		Log:           path.Join(dirPath, "chat.log"),
+		LogMaxSize:    1000,
+		LogKeep:       1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func TestPrepare(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+	service := newTestService(t, dirPath)
+	defer service.Close()
+
+	now := time.Now()
+	service.Mutes().Add("muted", &access.Ban{Reason: "spam"})
+	service.Mutes().Add("wasMuted", &access.Ban{Expires: now.Add(-time.Minute)})
+
+	tests := []struct {
+		name    string
+		message string
+		at      time.Duration
+		want    string
+		err     string
+	}{
+		{"bob", "hi", 0, "hi", ""},
+		{"bob", "&chi §", 0, "&chi ", ""},
+		{"bob", "again", 0, "", ErrFlood.Error()},
+		{"bob", "later", 5 * time.Second, "", ErrFlood.Error()},
+		{"bob", "later", 6 * time.Second, "", ErrFlood.Error()},
+		// Refused messages count towards the limit.
+		{"bob", "later", 11 * time.Second, "", ErrFlood.Error()},
+		{"bob", "later", 30 * time.Second, "later", ""},
+		{"alice", "hello", 0, "hello", ""},
+		{"alice", "12345678901", time.Minute, "", "Your message is longer than 10 characters."},
+		{"alice", "ééééééééé", 2 * time.Minute, "ééééééééé", ""},
+		{"MUTED", "hi", 0, "", "You are muted: spam."},
+		{"wasMuted", "hi", 0, "hi", ""},
+	}
+	for _, test := range tests {
+		got, err := service.prepare(test.name, test.message, false, now.Add(test.at))
+		if errStr := errString(err); got != test.want || errStr != test.err {
+			t.Errorf("prepare(%q, %q) at +%v = %q, %q, want %q, %q", test.name, test.message, test.at, got, errStr, test.want, test.err)
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		message    string
+		allowColor bool
+		want       string
+	}{
+		{"plain", false, "plain"},
+		{"§cred", false, "red"},
+		{"§cred", true, "§cred"},
+		{"&cred & white", false, "&cred & white"},
+		{"&cred & white", true, "§cred & white"},
+		{"&Cred", true, "&Cred"},
+		{"end§", true, "end"},
+		{"§§c", false, ""},
+		{"§xbad", true, "xbad"},
+		{"two\nlines\x00", false, "twolines"},
+		{"§\x07c", true, "c"},
+	}
+	for _, test := range tests {
+		if got := Sanitize(test.message, test.allowColor); got != test.want {
+			t.Errorf("Sanitize(%q, %t) = %q, want %q", test.message, test.allowColor, got, test.want)
+		}
+	}
+}
+
+func TestIgnores(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+	service := newTestService(t, dirPath)
+
+	for _, ignored := range []string{"Spammer", "troll", "other"} {
+		if err = service.Ignore("Bob", ignored); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if removed, err := service.Unignore("bob", "OTHER"); !removed || err != nil {
+		t.Errorf("Unignore(other) = %t, %v, want true", removed, err)
+	}
+	if removed, _ := service.Unignore("bob", "nobody"); removed {
+		t.Errorf("Unignore(nobody) = true, want false")
+	}
+	service.Close()
+
+	// The service is reopened to check that the ignore lists were saved.
+	service = newTestService(t, dirPath)
+	defer service.Close()
+
+	if got, want := service.Ignored("BOB"), []string{"spammer", "troll"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Ignored(bob) = %v, want %v", got, want)
+	}
+	tests := []struct {
+		name, sender string
+		ignoring     bool
+	}{
+		{"bob", "spammer", true},
+		{"bob", "Troll", true},
+		{"bob", "other", false},
+		{"spammer", "bob", false},
+	}
+	for _, test := range tests {
+		if got := service.Ignoring(test.name, test.sender); got != test.ignoring {
+			t.Errorf("Ignoring(%q, %q) = %t, want %t", test.name, test.sender, got, test.ignoring)
+		}
+	}
+}
+
+func TestLog(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+	filename := path.Join(dirPath, "chat.log")
+
+	service := newTestService(t, dirPath)
+	service.Log("<bob> §chello")
+	service.Close()
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := string(data); !strings.HasSuffix(line, " <bob> hello\n") {
+		t.Errorf("chat log = %q, want a line ending in \"<bob> hello\"", line)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+	filename := path.Join(dirPath, "test.log")
+
+	rf, err := openRotatingFile(filename, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n", "a long line\n"} {
+		if _, err = rf.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rf.Close()
+
+	// The file is reopened to check that it appends.
+	if rf, err = openRotatingFile(filename, 100, 2); err != nil {
+		t.Fatal(err)
+	}
+	rf.Write([]byte("six\n"))
+	rf.Close()
+
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"test.log", "a long line\nsix\n"},
+		{"test.log.1", "four\nfive\n"},
+		{"test.log.2", "three\n"},
+		{"test.log.3", ""},
+	}
+	for _, test := range tests {
+		data, err := ioutil.ReadFile(path.Join(dirPath, test.filename))
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		if got := string(data); got != test.want {
+			t.Errorf("%s = %q, want %q", test.filename, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"chunkymonkey/access"
+)
+
+// ignoreLists is the persistent ignore lists of all players, keyed by the
+// player's name. It is safe for concurrent use.
+type ignoreLists struct {
+	filename string
+	lock     sync.Mutex
+	lists    map[string]map[string]bool
+}
+
+// loadIgnoreLists loads the ignore lists from the file, which is written
+// whenever they change. A missing file has no ignore lists. The file holds a
+// list of ignored names for each player.
+func loadIgnoreLists(filename string) (*ignoreLists, error) {
+	ignores := &ignoreLists{
+		filename: filename,
+		lists:    make(map[string]map[string]bool),
+	}
+
+	stored := make(map[string][]string)
+	if err := access.LoadJsonFile(filename, &stored); err != nil {
+		return nil, fmt.Errorf("Error loading ignore lists %s: %v", filename, err)
+	}
+
+	for name, ignored := range stored {
+		for _, ignoredName := range ignored {
+			ignores.list(name)[nameKey(ignoredName)] = true
+		}
+	}
+
+	return ignores, nil
+}
+
+// list returns the named player's ignore list, creating it if needed. It must
+// be called with the lock held, or before the ignoreLists is shared.
+func (ignores *ignoreLists) list(name string) map[string]bool {
+	key := nameKey(name)
+	list, ok := ignores.lists[key]
+	if !ok {
+		list = make(map[string]bool)
+		ignores.lists[key] = list
+	}
+	return list
+}
+
+func (ignores *ignoreLists) add(name, ignored string) error {
+	ignores.lock.Lock()
+	defer ignores.lock.Unlock()
+
+	ignores.list(name)[nameKey(ignored)] = true
+	return ignores.save()
+}
+
+func (ignores *ignoreLists) remove(name, ignored string) (bool, error) {
+	ignores.lock.Lock()
+	defer ignores.lock.Unlock()
+
+	list := ignores.lists[nameKey(name)]
+	ignored = nameKey(ignored)
+	if !list[ignored] {
+		return false, nil
+	}
+	delete(list, ignored)
+	if len(list) == 0 {
+		delete(ignores.lists, nameKey(name))
+	}
+	return true, ignores.save()
+}
+
+func (ignores *ignoreLists) contains(name, ignored string) bool {
+	ignores.lock.Lock()
+	defer ignores.lock.Unlock()
+
+	return ignores.lists[nameKey(name)][nameKey(ignored)]
+}
+
+func (ignores *ignoreLists) names(name string) []string {
+	ignores.lock.Lock()
+	defer ignores.lock.Unlock()
+
+	return sortedNames(ignores.lists[nameKey(name)])
+}
+
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// save writes the ignore lists to the file. It must be called with the lock
+// held.
+func (ignores *ignoreLists) save() error {
+	stored := make(map[string][]string, len(ignores.lists))
+	for name, list := range ignores.lists {
+		stored[name] = sortedNames(list)
+	}
+	return access.SaveJsonFile(ignores.filename, stored)
+}
@@ -0,0 +1,111 @@
+package chat
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rotatingFile is a log file that is rotated once it reaches a size. The
+// file is renamed with the suffix ".1", the previous ".1" to ".2" and so on,
+// keeping up to a number of old files. It is safe for concurrent use.
+type rotatingFile struct {
+	filename string
+	maxSize  int64
+	keep     int
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+// openRotatingFile opens the file for appending, creating it if needed.
+func openRotatingFile(filename string, maxSize int64, keep int) (*rotatingFile, error) {
+	rf := &rotatingFile{
+		filename: filename,
+		maxSize:  maxSize,
+		keep:     keep,
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	file, err := os.OpenFile(rf.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rf.file = file
+	rf.size = info.Size()
+	return nil
+}
+
+// Write appends data to the file, first rotating it if data would take it
+// past the size limit.
+func (rf *rotatingFile) Write(data []byte) (int, error) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.size > 0 && rf.size+int64(len(data)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(data)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate moves the old files along, dropping the oldest, and starts a new
+// file. It must be called with the lock held.
+func (rf *rotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	rf.file = nil
+
+	if rf.keep == 0 {
+		if err := os.Remove(rf.filename); err != nil {
+			return err
+		}
+	} else {
+		for i := rf.keep - 1; i > 0; i-- {
+			err := os.Rename(rf.oldFilename(i), rf.oldFilename(i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(rf.filename, rf.oldFilename(1)); err != nil {
+			return err
+		}
+	}
+
+	return rf.open()
+}
+
+func (rf *rotatingFile) oldFilename(n int) string {
+	return fmt.Sprintf("%s.%d", rf.filename, n)
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
@@ -18,19 +18,27 @@ type IChunkStoreForeground interface {
 	WriteChunk(writer IChunkWriter) error
 }
 
+// iFlusher is implemented by an IChunkStoreForeground that writes chunks in
+// the background.
+type iFlusher interface {
+	Flush()
+}
+
 // ChunkService adapts an IChunkStoreForeground (which can only be accessed
 // from one goroutine) to an IChunkStore.
 type ChunkService struct {
-	store  IChunkStoreForeground
-	reads  chan readRequest
-	writes chan IChunkWriter
+	store   IChunkStoreForeground
+	reads   chan readRequest
+	writes  chan IChunkWriter
+	flushes chan chan bool
 }
 
 func NewChunkService(store IChunkStoreForeground) (s *ChunkService) {
 	return &ChunkService{
-		store:  store,
-		reads:  make(chan readRequest),
-		writes: make(chan IChunkWriter),
+		store:   store,
+		reads:   make(chan readRequest),
+		writes:  make(chan IChunkWriter),
+		flushes: make(chan chan bool),
 	}
 }
 
@@ -44,6 +52,13 @@ func (s *ChunkService) Serve() {
 			if err := s.store.WriteChunk(writer); err != nil {
 				log.Printf("Could not write chunk at %#v: %v", writer.ChunkLoc(), err)
 			}
+		case done := <-s.flushes:
+			// Writes are performed in the order that they are received, so any
+			// submitted before the flush have been written by now.
+			if flusher, ok := s.store.(iFlusher); ok {
+				flusher.Flush()
+			}
+			close(done)
 		}
 	}
 }
@@ -70,3 +85,9 @@ func (s *ChunkService) Writer() IChunkWriter {
 func (s *ChunkService) WriteChunk(writer IChunkWriter) {
 	s.writes <- writer
 }
+
+func (s *ChunkService) Flush() {
+	done := make(chan bool)
+	s.flushes <- done
+	<-done
+}
@@ -60,3 +60,9 @@ func (s *MultiStore) WriteChunk(writer IChunkWriter) error {
 	s.writeStore.WriteChunk(writer)
 	return nil
 }
+
+func (s *MultiStore) Flush() {
+	if s.writeStore != nil {
+		s.writeStore.Flush()
+	}
+}
@@ -24,6 +24,9 @@ type IChunkStore interface {
 	// Submits the set chunk data for writing. The chunk writer must not be
 	// altered any further after calling this.
 	WriteChunk(writer IChunkWriter)
+
+	// Flush waits until all chunks submitted by WriteChunk have been written.
+	Flush()
 }
 
 type IChunkReader interface {
@@ -0,0 +1,316 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// errUsage is returned when arguments are missing or left over. The usage
+// string for the command is shown instead of the error.
+var errUsage = errors.New("bad command usage")
+
+// An Arg is one argument in the schema of a command.
+type Arg interface {
+	// Usage returns the argument as shown in the command's usage string,
+	// without the surrounding "<>" or "[]".
+	Usage() string
+
+	// Parse parses the argument from the start of words, which is never empty
+	// for required arguments. It returns the value and the number of words
+	// used. The error, if any, is shown to the player.
+	Parse(words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (value interface{}, used int, err error)
+}
+
+// Args holds the values parsed for the arguments of a command, in the order
+// that they were declared. Optional arguments that were not given are nil.
+type Args []interface{}
+
+// parseArgs parses words, the text following the command trigger, against
+// the schema.
+func parseArgs(schema []Arg, words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (Args, error) {
+	args := make(Args, len(schema))
+	for i, arg := range schema {
+		if _, optional := arg.(OptionalArg); !optional && len(words) == 0 {
+			return nil, errUsage
+		}
+		value, used, err := arg.Parse(words, sender, game)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+		words = words[used:]
+	}
+	if len(words) > 0 {
+		return nil, errUsage
+	}
+	return args, nil
+}
+
+// usage generates the usage string for a command from its schema.
+func usage(trigger string, schema []Arg) string {
+	parts := []string{trigger}
+	for _, arg := range schema {
+		if _, optional := arg.(OptionalArg); optional {
+			parts = append(parts, "["+arg.Usage()+"]")
+		} else {
+			parts = append(parts, "<"+arg.Usage()+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// OptionalArg is an argument that may be left out at the end of the command
+// line, in which case its value is nil.
+type OptionalArg struct {
+	Arg
+}
+
+func (arg OptionalArg) Parse(words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (interface{}, int, error) {
+	if len(words) == 0 {
+		return nil, 0, nil
+	}
+	return arg.Arg.Parse(words, sender, game)
+}
+
+// OneOfArg is an argument that can take any of several forms. The first that
+// parses is used, otherwise the error from the first is returned.
+type OneOfArg []Arg
+
+func (arg OneOfArg) Usage() string {
+	usages := make([]string, len(arg))
+	for i, form := range arg {
+		usages[i] = form.Usage()
+	}
+	return strings.Join(usages, "|")
+}
+
+func (arg OneOfArg) Parse(words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (value interface{}, used int, err error) {
+	for i, form := range arg {
+		v, u, e := form.Parse(words, sender, game)
+		if e == nil {
+			return v, u, nil
+		}
+		if i == 0 {
+			err = e
+		}
+	}
+	return nil, 0, err
+}
+
+// WordArg is a single word, such as a name. Its value is a string.
+type WordArg struct {
+	Name string
+}
+
+func (arg WordArg) Usage() string {
+	return arg.Name
+}
+
+func (arg WordArg) Parse(words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (interface{}, int, error) {
+	return words[0], 1, nil
+}
+
+// TextArg is the rest of the command line. Its value is a string.
+type TextArg struct {
+	Name string
+}
+
+func (arg TextArg) Usage() string {
+	return arg.Name
+}
+
+func (arg TextArg) Parse(words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (interface{}, int, error) {
+	return strings.Join(words, " "), len(words), nil
+}
+
+// ChoiceArg is one of a fixed set of words. Its value is a string.
+type ChoiceArg struct {
+	Choices []string
+}
+
+func (arg ChoiceArg) Usage() string {
+	return strings.Join(arg.Choices, "|")
+}
+
+func (arg ChoiceArg) Parse(words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (interface{}, int, error) {
+	for _, choice := range arg.Choices {
+		if words[0] == choice {
+			return choice, 1, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("'%s' is not one of %s", words[0], arg.Usage())
+}
+
+// PlayerArg is the name of a player who is logged in. Its value is the
+// player's gamerules.IPlayerClient.
+type PlayerArg struct {
+	Name string
+}
+
+func (arg PlayerArg) Usage() string {
+	return arg.Name
+}
+
+func (arg PlayerArg) Parse(words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (interface{}, int, error) {
+	player := game.PlayerByName(words[0])
+	if player == nil {
+		return nil, 0, fmt.Errorf("'%s' is not logged in", words[0])
+	}
+	return player, 1, nil
+}
+
+// ItemArg is an item type, given by its ID or by its name with underscores
+// in place of spaces, such as "iron_shovel". Its value is a
+// gamerules.ItemType.
+type ItemArg struct {
+	Name string
+}
+
+func (arg ItemArg) Usage() string {
+	return arg.Name
+}
+
+func (arg ItemArg) Parse(words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (interface{}, int, error) {
+	if id, err := strconv.Atoi(words[0]); err == nil {
+		if itemType, ok := game.ItemTypeById(id); ok {
+			return itemType, 1, nil
+		}
+		return nil, 0, fmt.Errorf("'%s' is not a valid item id", words[0])
+	}
+
+	name := strings.Replace(words[0], "_", " ", -1)
+	for _, itemType := range gamerules.Items {
+		if strings.EqualFold(itemType.Name, name) {
+			return *itemType, 1, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("'%s' is not a known item", words[0])
+}
+
+// IntArg is an integer from Min to Max inclusive. A zero Max means that
+// there is no upper limit. Its value is an int.
+type IntArg struct {
+	Name     string
+	Min, Max int
+}
+
+func (arg IntArg) Usage() string {
+	return arg.Name
+}
+
+func (arg IntArg) Parse(words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (interface{}, int, error) {
+	value, err := strconv.Atoi(words[0])
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s must be a number", arg.Name)
+	}
+	if arg.Max == 0 && value < arg.Min {
+		return nil, 0, fmt.Errorf("%s must be at least %d", arg.Name, arg.Min)
+	}
+	if arg.Max != 0 && (value < arg.Min || value > arg.Max) {
+		return nil, 0, fmt.Errorf("%s must be from %d to %d", arg.Name, arg.Min, arg.Max)
+	}
+	return value, 1, nil
+}
+
+// DurationArg is a positive duration such as "30m", "12h" or "7d". Its value
+// is a time.Duration. A word that is not a duration is not used, so that it
+// can be parsed as the next argument, and the value is nil.
+type DurationArg struct {
+	Name string
+}
+
+func (arg DurationArg) Usage() string {
+	return arg.Name
+}
+
+func (arg DurationArg) Parse(words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (interface{}, int, error) {
+	if len(words) > 0 {
+		if duration, ok := parseDuration(words[0]); ok {
+			return duration, 1, nil
+		}
+	}
+	return nil, 0, nil
+}
+
+// parseDuration parses a duration as for time.ParseDuration, but also
+// accepting a number of days such as "7d". It only accepts positive
+// durations.
+func parseDuration(text string) (duration time.Duration, ok bool) {
+	if strings.HasSuffix(text, "d") {
+		days, err := strconv.Atoi(text[:len(text)-1])
+		if err != nil {
+			return 0, false
+		}
+		duration = time.Duration(days) * 24 * time.Hour
+	} else {
+		var err error
+		if duration, err = time.ParseDuration(text); err != nil {
+			return 0, false
+		}
+	}
+	return duration, duration > 0
+}
+
+// maxCoordsXz is the furthest from the origin that CoordsArg allows x and z
+// to be.
+const maxCoordsXz = 30000000
+
+// CoordsArg is a position given as three words, x, y and z. Each is either
+// absolute, or relative to the sender's position when it starts with "~",
+// such as "~" or "~-5". x and z must be within maxCoordsXz of the origin, and
+// y is clamped to the height of the world. Its value is an AbsXyz.
+type CoordsArg struct{}
+
+func (arg CoordsArg) Usage() string {
+	return "x y z"
+}
+
+func (arg CoordsArg) Parse(words []string, sender gamerules.IPlayerClient, game gamerules.IGame) (interface{}, int, error) {
+	if len(words) < 3 {
+		return nil, 0, errors.New("Coordinates need x, y and z")
+	}
+
+	origin, _ := sender.PositionLook()
+	var pos AbsXyz
+	coords := []struct {
+		name   string
+		origin AbsCoord
+		value  *AbsCoord
+	}{
+		{"x", origin.X, &pos.X},
+		{"y", origin.Y, &pos.Y},
+		{"z", origin.Z, &pos.Z},
+	}
+	for i, coord := range coords {
+		text := words[i]
+		if strings.HasPrefix(text, "~") {
+			*coord.value = coord.origin
+			text = text[1:]
+			if text == "" {
+				continue
+			}
+		}
+		offset, err := strconv.ParseFloat(text, 64)
+		if err != nil || math.IsNaN(offset) || math.IsInf(offset, 0) {
+			return nil, 0, fmt.Errorf("%s must be a number, or ~ for your own", coord.name)
+		}
+		*coord.value += AbsCoord(offset)
+	}
+
+	if math.Abs(float64(pos.X)) > maxCoordsXz || math.Abs(float64(pos.Z)) > maxCoordsXz {
+		return nil, 0, fmt.Errorf("x and z must be from %d to %d", -maxCoordsXz, maxCoordsXz)
+	}
+	if pos.Y < MinYCoord {
+		pos.Y = MinYCoord
+	} else if pos.Y > ChunkSizeY {
+		pos.Y = ChunkSizeY
+	}
+	return pos, 3, nil
+}
@@ -4,18 +4,27 @@ import (
 	"chunkymonkey/gamerules"
 )
 
-// A CommandCallback takes the name of the player invoking the command, any
-// text supplied after the trigger for the command, and an interface via which
-// game-wide 'actions' can be taken.
-type CommandCallback func(player gamerules.IPlayerClient, args string, game gamerules.IGame)
+// A CommandCallback takes the player invoking the command, the values parsed
+// for the command's arguments, and an interface via which game-wide 'actions'
+// can be taken.
+type CommandCallback func(player gamerules.IPlayerClient, args Args, game gamerules.IGame)
 
 type Command struct {
 	Trigger     string          // The initial text eg. "give".
 	Description string          // A description of what the command does.
-	Usage       string          // A usage string for the command.
+	Usage       string          // A usage string for the command, generated from Args.
+	Permission  string          // The permission node needed to run the command eg. "command.give".
+	Args        []Arg           // The schema that the command's arguments are parsed with.
 	Callback    CommandCallback // This function will be called if a Message begins with the CommandPrefix and the Trigger.
 }
 
-func NewCommand(trigger, desc, usage string, callback CommandCallback) *Command {
-	return &Command{Trigger: trigger, Description: desc, Usage: usage, Callback: callback}
+func NewCommand(trigger, desc, permission string, args []Arg, callback CommandCallback) *Command {
+	return &Command{
+		Trigger:     trigger,
+		Description: desc,
+		Usage:       usage(trigger, args),
+		Permission:  permission,
+		Args:        args,
+		Callback:    callback,
+	}
 }
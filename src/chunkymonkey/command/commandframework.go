@@ -2,6 +2,7 @@ package command
 
 import (
 	"errors"
+	"log"
 	"strings"
 
 	"chunkymonkey/gamerules"
@@ -9,6 +10,8 @@ import (
 
 var ErrCmdExists = errors.New("The command already exists.")
 
+const msgNoPermission = "You do not have permission to use this command."
+
 // The CommandFramework handles all message based commands.
 // It uses channels to safly handle multiple calls.
 type CommandFramework struct {
@@ -20,8 +23,8 @@ type CommandFramework struct {
 func NewCommandFramework(prefix string) *CommandFramework {
 	cf := &CommandFramework{prefix: prefix}
 	cmds := getCommands()
-	commandHelp := NewCommand(helpCmd, helpDesc, helpUsage, func(player gamerules.IPlayerClient, msg string, game gamerules.IGame) {
-		cmdHelp(player, msg, cf, game)
+	commandHelp := NewCommand(helpCmd, helpDesc, helpPermission, helpArgs, func(player gamerules.IPlayerClient, args Args, game gamerules.IGame) {
+		cmdHelp(player, args, cf, game)
 	})
 	cmds[helpCmd] = commandHelp
 	cmds[helpShortCmd] = commandHelp
@@ -37,13 +40,44 @@ func (cf *CommandFramework) Commands() map[string]*Command {
 	return cf.cmds
 }
 
+// Allowed returns true if the player has the permission node for the
+// command. A Console has all permissions.
+func (cf *CommandFramework) Allowed(player gamerules.IPlayerClient, cmd *Command) bool {
+	return hasPermission(player, cmd.Permission)
+}
+
+// hasPermission returns true if the player has the permission node. A
+// Console has all permissions.
+func hasPermission(player gamerules.IPlayerClient, node string) bool {
+	if _, ok := player.(*Console); ok {
+		return true
+	}
+	if gamerules.Permissions == nil {
+		return false
+	}
+	return gamerules.Permissions.UserPermissions(player.Name()).Has(node)
+}
+
 func (cf *CommandFramework) Process(player gamerules.IPlayerClient, message string, game gamerules.IGame) {
-	if len(message) < 2 || message[0:len(cf.prefix)] != cf.prefix {
+	if len(message) <= len(cf.prefix) || !strings.HasPrefix(message, cf.prefix) {
 		return
 	}
-	attr := strings.Split(message, " ")
-	trigger := attr[0][1:]
+	words := strings.Fields(message)
+	trigger := words[0][len(cf.prefix):]
 	if cmd, ok := cf.cmds[trigger]; ok {
-		cmd.Callback(player, message, game)
+		if !cf.Allowed(player, cmd) {
+			log.Printf("Player %q denied command without permission %q: %s", player.Name(), cmd.Permission, message)
+			player.EchoMessage(msgNoPermission)
+			return
+		}
+		args, err := parseArgs(cmd.Args, words[1:], player, game)
+		if err == errUsage {
+			player.EchoMessage("Usage: " + cf.prefix + cmd.Usage)
+			return
+		} else if err != nil {
+			player.EchoMessage(err.Error())
+			return
+		}
+		cmd.Callback(player, args, game)
 	}
 }
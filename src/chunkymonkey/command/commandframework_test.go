@@ -1,56 +1,219 @@
 package command
 
 import (
+	"reflect"
+	"strings"
 	"testing"
-
-	"gomock.googlecode.com/hg/gomock"
+	"time"
 
 	"chunkymonkey/gamerules"
-	"testmatcher"
+	. "chunkymonkey/types"
 )
 
 func TestCommandFramework(t *testing.T) {
-	mockCtrl := gomock.NewController(t)
-	defer mockCtrl.Finish()
-
-	itemType1 := gamerules.ItemType{1, "1", 64, 0, 0}
+	setTestPermissions(t, `{"thePlayer": {"permissions": ["command.*"]}}`)
+	defer func() { gamerules.Permissions = nil }()
 
-	mockGame := gamerules.NewMockIGame(mockCtrl)
-	mockPlayer := gamerules.NewMockIPlayerClient(mockCtrl)
-	mockOther := gamerules.NewMockIPlayerClient(mockCtrl)
+	itemType1 := gamerules.ItemType{Id: 1, Name: "1", MaxStack: 64}
+	gamerules.Items = gamerules.ItemTypeMap{1: &itemType1}
+	defer func() { gamerules.Items = nil }()
 
 	cf := NewCommandFramework("/")
 
-	mockGame.EXPECT().BroadcastMessage("§dthis is a broadcast")
-	cf.Process(mockPlayer, "/say this is a broadcast", mockGame)
-
-	mockGame.EXPECT().PlayerByName("thePlayer").Return(mockPlayer)
-	mockGame.EXPECT().ItemTypeById(1).Return(itemType1, true)
-	mockPlayer.EXPECT().EchoMessage("Giving 64 of '1' to thePlayer")
-	mockPlayer.EXPECT().GiveItem(gamerules.Slot{1, 64, 0})
-	cf.Process(mockPlayer, "/give thePlayer 1 64", mockGame)
-
-	mockGame.EXPECT().PlayerByName("otherPlayer")
-	mockPlayer.EXPECT().EchoMessage("'otherPlayer' is not logged in")
-	cf.Process(mockPlayer, "/give otherPlayer 1 64", mockGame)
-
-	mockGame.EXPECT().PlayerByName("otherPlayer").Return(mockOther)
-	mockGame.EXPECT().ItemTypeById(1).Return(gamerules.ItemType{}, false)
-	mockPlayer.EXPECT().EchoMessage("'1' is not a valid item id")
-	cf.Process(mockPlayer, "/give otherPlayer 1 64", mockGame)
-
-	mockGame.EXPECT().PlayerByName("otherPlayer").Return(mockOther)
-	mockGame.EXPECT().ItemTypeById(1).Return(itemType1, true)
-	mockPlayer.EXPECT().EchoMessage("Cannot give more than 512 items at once")
-	cf.Process(mockPlayer, "/give otherPlayer 1 513", mockGame)
-
-	mockPlayer.EXPECT().EchoMessage(&testmatcher.StringPrefix{"Commands:"})
-	cf.Process(mockPlayer, "/help", mockGame)
-
-	gomock.InOrder(
-		mockPlayer.EXPECT().EchoMessage("Command: /help"),
-		mockPlayer.EXPECT().EchoMessage("Usage: help|?"),
-		mockPlayer.EXPECT().EchoMessage("Description: Shows a list of all commands."),
-	)
-	cf.Process(mockPlayer, "/help help", mockGame)
+	tests := []struct {
+		message    string
+		want       []string
+		given      []gamerules.Slot
+		otherGiven []gamerules.Slot
+		broadcasts []string
+	}{
+		{
+			message:    "/say this is a broadcast",
+			broadcasts: []string{"§dthis is a broadcast"},
+		},
+		{
+			message: "/give thePlayer 1 64",
+			want:    []string{"Giving 64 of '1' to thePlayer"},
+			given:   []gamerules.Slot{{1, 64, 0}},
+		},
+		{
+			message:    "/give otherPlayer 1 100",
+			want:       []string{"Giving 100 of '1' to otherPlayer"},
+			otherGiven: []gamerules.Slot{{1, 64, 0}, {1, 36, 0}},
+		},
+		{
+			message: "/give nobody 1 64",
+			want:    []string{"'nobody' is not logged in"},
+		},
+		{
+			message: "/give otherPlayer 2 64",
+			want:    []string{"'2' is not a valid item id"},
+		},
+		{
+			message: "/give otherPlayer 1 513",
+			want:    []string{"quantity must be from 1 to 512"},
+		},
+		{
+			message: "/help help",
+			want:    []string{"Command: /help", "Usage: /help [command]", "Description: " + helpDesc},
+		},
+	}
+
+	for _, test := range tests {
+		player := &testPlayer{name: "thePlayer"}
+		other := &testPlayer{name: "otherPlayer"}
+		game := &frameworkTestGame{
+			argsTestGame: argsTestGame{
+				players: map[string]gamerules.IPlayerClient{"thePlayer": player, "otherPlayer": other},
+				items:   map[int]gamerules.ItemType{1: itemType1},
+			},
+		}
+
+		cf.Process(player, test.message, game)
+
+		if !reflect.DeepEqual(player.messages, test.want) {
+			t.Errorf("Process(%q) echoed %q, want %q", test.message, player.messages, test.want)
+		}
+		if !reflect.DeepEqual(player.given, test.given) {
+			t.Errorf("Process(%q) gave the player %v, want %v", test.message, player.given, test.given)
+		}
+		if !reflect.DeepEqual(other.given, test.otherGiven) {
+			t.Errorf("Process(%q) gave the other player %v, want %v", test.message, other.given, test.otherGiven)
+		}
+		if !reflect.DeepEqual(game.broadcasts, test.broadcasts) {
+			t.Errorf("Process(%q) broadcast %q, want %q", test.message, game.broadcasts, test.broadcasts)
+		}
+	}
+
+	player := &testPlayer{name: "thePlayer"}
+	cf.Process(player, "/help", new(frameworkTestGame))
+	if len(player.messages) != 1 || !strings.HasPrefix(player.messages[0], "Commands:") {
+		t.Errorf("Process(/help) echoed %q, want the list of commands", player.messages)
+	}
+}
+
+// frameworkTestGame records broadcasts, and looks up players and items.
+type frameworkTestGame struct {
+	argsTestGame
+	broadcasts []string
+}
+
+func (game *frameworkTestGame) BroadcastMessage(msg string) {
+	game.broadcasts = append(game.broadcasts, msg)
+}
+
+// argsTestGame looks up players and items for parsing arguments.
+// Unimplemented methods of the embedded interface panic if called.
+type argsTestGame struct {
+	gamerules.IGame
+	players map[string]gamerules.IPlayerClient
+	items   map[int]gamerules.ItemType
+}
+
+func (game *argsTestGame) PlayerByName(name string) gamerules.IPlayerClient {
+	if player, ok := game.players[name]; ok {
+		return player
+	}
+	return nil
+}
+
+func (game *argsTestGame) ItemTypeById(id int) (gamerules.ItemType, bool) {
+	itemType, ok := game.items[id]
+	return itemType, ok
+}
+
+func TestParseArgs(t *testing.T) {
+	sender := &testPlayer{name: "sender", pos: AbsXyz{10, 64, -10}}
+	other := &testPlayer{name: "other"}
+	stone := gamerules.ItemType{Id: 1, Name: "stone", MaxStack: 64}
+	ironShovel := gamerules.ItemType{Id: 256, Name: "iron shovel", MaxStack: 1}
+	gamerules.Items = gamerules.ItemTypeMap{1: &stone, 256: &ironShovel}
+	defer func() { gamerules.Items = nil }()
+	game := &argsTestGame{
+		players: map[string]gamerules.IPlayerClient{"sender": sender, "other": other},
+		items:   map[int]gamerules.ItemType{1: stone, 256: ironShovel},
+	}
+
+	tests := []struct {
+		schema []Arg
+		words  []string
+		want   Args
+		err    string
+	}{
+		// Counts of words.
+		{nil, nil, Args{}, ""},
+		{nil, []string{"extra"}, nil, errUsage.Error()},
+		{[]Arg{WordArg{"name"}}, nil, nil, errUsage.Error()},
+		{[]Arg{WordArg{"name"}, OptionalArg{WordArg{"other"}}}, []string{"a"}, Args{"a", nil}, ""},
+		{[]Arg{WordArg{"name"}, OptionalArg{WordArg{"other"}}}, []string{"a", "b"}, Args{"a", "b"}, ""},
+		{[]Arg{TextArg{"message"}}, []string{"hello", "there"}, Args{"hello there"}, ""},
+		// Players.
+		{[]Arg{PlayerArg{"player"}}, []string{"other"}, Args{other}, ""},
+		{[]Arg{PlayerArg{"player"}}, []string{"nobody"}, nil, "'nobody' is not logged in"},
+		// Items.
+		{[]Arg{ItemArg{"item"}}, []string{"1"}, Args{stone}, ""},
+		{[]Arg{ItemArg{"item"}}, []string{"Iron_Shovel"}, Args{ironShovel}, ""},
+		{[]Arg{ItemArg{"item"}}, []string{"2"}, nil, "'2' is not a valid item id"},
+		{[]Arg{ItemArg{"item"}}, []string{"dirt"}, nil, "'dirt' is not a known item"},
+		// Integers.
+		{[]Arg{IntArg{"count", 1, 10}}, []string{"10"}, Args{10}, ""},
+		{[]Arg{IntArg{"count", 1, 10}}, []string{"11"}, nil, "count must be from 1 to 10"},
+		{[]Arg{IntArg{"count", 1, 0}}, []string{"1000"}, Args{1000}, ""},
+		{[]Arg{IntArg{"count", 1, 0}}, []string{"0"}, nil, "count must be at least 1"},
+		{[]Arg{IntArg{"count", 1, 10}}, []string{"x"}, nil, "count must be a number"},
+		// Choices.
+		{[]Arg{ChoiceArg{[]string{"on", "off"}}}, []string{"off"}, Args{"off"}, ""},
+		{[]Arg{ChoiceArg{[]string{"on", "off"}}}, []string{"up"}, nil, "'up' is not one of on|off"},
+		// Durations are skipped if the word isn't one.
+		{[]Arg{OptionalArg{DurationArg{"duration"}}, OptionalArg{TextArg{"reason"}}}, []string{"7d", "griefing"}, Args{7 * 24 * time.Hour, "griefing"}, ""},
+		{[]Arg{OptionalArg{DurationArg{"duration"}}, OptionalArg{TextArg{"reason"}}}, []string{"griefing"}, Args{nil, "griefing"}, ""},
+		// Coordinates.
+		{[]Arg{CoordsArg{}}, []string{"1", "2.5", "-3"}, Args{AbsXyz{1, 2.5, -3}}, ""},
+		{[]Arg{CoordsArg{}}, []string{"~", "~5", "~-0.5"}, Args{AbsXyz{10, 69, -10.5}}, ""},
+		{[]Arg{CoordsArg{}}, []string{"1", "2"}, nil, "Coordinates need x, y and z"},
+		{[]Arg{CoordsArg{}}, []string{"1", "up", "3"}, nil, "y must be a number, or ~ for your own"},
+		{[]Arg{CoordsArg{}}, []string{"NaN", "64", "0"}, nil, "x must be a number, or ~ for your own"},
+		{[]Arg{CoordsArg{}}, []string{"0", "Inf", "0"}, nil, "y must be a number, or ~ for your own"},
+		{[]Arg{CoordsArg{}}, []string{"0", "64", "-Inf"}, nil, "z must be a number, or ~ for your own"},
+		{[]Arg{CoordsArg{}}, []string{"1e308", "64", "0"}, nil, "x and z must be from -30000000 to 30000000"},
+		{[]Arg{CoordsArg{}}, []string{"0", "64", "~-30000011"}, nil, "x and z must be from -30000000 to 30000000"},
+		{[]Arg{CoordsArg{}}, []string{"30000000", "1e308", "-30000000"}, Args{AbsXyz{30000000, 128, -30000000}}, ""},
+		{[]Arg{CoordsArg{}}, []string{"0", "-5", "0"}, Args{AbsXyz{0, 0, 0}}, ""},
+		// Alternatives.
+		{[]Arg{OneOfArg{PlayerArg{"player"}, CoordsArg{}}}, []string{"other"}, Args{other}, ""},
+		{[]Arg{OneOfArg{PlayerArg{"player"}, CoordsArg{}}}, []string{"~", "0", "~"}, Args{AbsXyz{10, 0, -10}}, ""},
+		{[]Arg{OneOfArg{PlayerArg{"player"}, CoordsArg{}}}, []string{"nobody"}, nil, "'nobody' is not logged in"},
+	}
+
+	for _, test := range tests {
+		got, err := parseArgs(test.schema, test.words, sender, game)
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.err || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseArgs(%v, %q) = %v, %q, want %v, %q", test.schema, test.words, got, errMsg, test.want, test.err)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		trigger string
+		schema  []Arg
+		want    string
+	}{
+		{killCmd, nil, "kill"},
+		{helpCmd, helpArgs, "help [command]"},
+		{tpCmd, tpArgs, "tp <player1> <player2|x y z>"},
+		{giveCmd, giveArgs, "give <player> <item> [quantity] [data]"},
+		{banCmd, banArgs, "ban <player|ip> [duration] [reason]"},
+		{whitelistCmd, whitelistArgs, "whitelist <on|off|list|add|remove> [player]"},
+	}
+
+	for _, test := range tests {
+		if got := usage(test.trigger, test.schema); got != test.want {
+			t.Errorf("usage(%q) = %q, want %q", test.trigger, got, test.want)
+		}
+	}
 }
@@ -2,75 +2,90 @@ package command
 
 import (
 	"fmt"
+	"math"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"chunkymonkey/access"
+	"chunkymonkey/blocklog"
+	"chunkymonkey/chat"
+	"chunkymonkey/config"
 	"chunkymonkey/gamerules"
 	. "chunkymonkey/types"
+	"chunkymonkey/weather"
 	"log"
 )
 
 func getCommands() map[string]*Command {
 	cmds := map[string]*Command{}
-	cmds[sayCmd] = NewCommand(sayCmd, sayDesc, sayUsage, cmdSay)
-	cmds[tpCmd] = NewCommand(tpCmd, tpDesc, tpUsage, cmdTp)
-	cmds[killCmd] = NewCommand(killCmd, killDesc, killUsage, cmdKill)
-	cmds[tellCmd] = NewCommand(tellCmd, tellDesc, tellUsage, cmdTell)
-	cmds[giveCmd] = NewCommand(giveCmd, giveDesc, giveUsage, cmdGive)
+	cmds[sayCmd] = NewCommand(sayCmd, sayDesc, sayPermission, sayArgs, cmdSay)
+	cmds[tpCmd] = NewCommand(tpCmd, tpDesc, tpPermission, tpArgs, cmdTp)
+	cmds[killCmd] = NewCommand(killCmd, killDesc, killPermission, nil, cmdKill)
+	cmds[tellCmd] = NewCommand(tellCmd, tellDesc, tellPermission, tellArgs, cmdTell)
+	commandReply := NewCommand(replyCmd, replyDesc, replyPermission, replyArgs, cmdReply)
+	cmds[replyCmd] = commandReply
+	cmds[replyShortCmd] = commandReply
+	cmds[meCmd] = NewCommand(meCmd, meDesc, mePermission, meArgs, cmdMe)
+	cmds[ignoreCmd] = NewCommand(ignoreCmd, ignoreDesc, ignorePermission, ignoreArgs, cmdIgnore)
+	cmds[unignoreCmd] = NewCommand(unignoreCmd, unignoreDesc, unignorePermission, unignoreArgs, cmdUnignore)
+	cmds[muteCmd] = NewCommand(muteCmd, muteDesc, mutePermission, muteArgs, cmdMute)
+	cmds[unmuteCmd] = NewCommand(unmuteCmd, unmuteDesc, unmutePermission, unmuteArgs, cmdUnmute)
+	cmds[giveCmd] = NewCommand(giveCmd, giveDesc, givePermission, giveArgs, cmdGive)
+	cmds[inspectCmd] = NewCommand(inspectCmd, inspectDesc, inspectPermission, inspectArgs, cmdInspect)
+	cmds[rollbackCmd] = NewCommand(rollbackCmd, rollbackDesc, rollbackPermission, rollbackArgs, cmdRollback)
+	cmds[banCmd] = NewCommand(banCmd, banDesc, banPermission, banArgs, cmdBan)
+	cmds[unbanCmd] = NewCommand(unbanCmd, unbanDesc, unbanPermission, unbanArgs, cmdUnban)
+	cmds[kickCmd] = NewCommand(kickCmd, kickDesc, kickPermission, kickArgs, cmdKick)
+	cmds[whitelistCmd] = NewCommand(whitelistCmd, whitelistDesc, whitelistPermission, whitelistArgs, cmdWhitelist)
+	cmds[weatherCmd] = NewCommand(weatherCmd, weatherDesc, weatherPermission, weatherArgs, cmdWeather)
+	cmds[worldCmd] = NewCommand(worldCmd, worldDesc, worldPermission, worldArgs, cmdWorld)
 	return cmds
 }
 
-const msgNotImplemented = "We are sorry. This command is not yet implemented."
 const msgUnknownItem = "Unknown item ID"
 
 // say message
 const sayCmd = "say"
-const sayUsage = "say <message>"
 const sayDesc = "Broadcasts a message to all players without showing a player name. The message is colored pink."
+const sayPermission = "command.say"
 
-func cmdSay(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
-	args := strings.Split(message, " ")
-	if len(args) < 2 {
-		player.EchoMessage(sayUsage)
-		return
-	}
-	msg := strings.Join(args[1:], " ")
-	cmdHandler.BroadcastMessage("§d" + msg)
+var sayArgs = []Arg{TextArg{"message"}}
+
+func cmdSay(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	cmdHandler.BroadcastMessage("§d" + args[0].(string))
 }
 
-// tp player1 player2
+// tp player1 player2|x y z
 
 const tpCmd = "tp"
-const tpUsage = "tp <player1> <player2>"
-const tpDesc = "Teleports player1 to player2."
-
-func cmdTp(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
-	args := strings.Split(message, " ")
-	if len(args) < 3 {
-		player.EchoMessage(tpUsage)
-		return
+const tpDesc = "Teleports player1 to player2, or to coordinates. Coordinates starting with ~ are relative to your position."
+const tpPermission = "command.tp"
+
+var tpArgs = []Arg{PlayerArg{"player1"}, OneOfArg{PlayerArg{"player2"}, CoordsArg{}}}
+
+func cmdTp(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	teleportee := args[0].(gamerules.IPlayerClient)
+
+	var pos AbsXyz
+	var look LookDegrees
+	var destName string
+	switch destination := args[1].(type) {
+	case gamerules.IPlayerClient:
+		pos, look = destination.PositionLook()
+		// TODO: Remove this hack or figure out what needs to happen instead
+		pos.Y += 1.63
+		destName = destination.Name()
+	case AbsXyz:
+		pos = destination
+		_, look = teleportee.PositionLook()
+		destName = fmt.Sprintf("%.2f, %.2f, %.2f", pos.X, pos.Y, pos.Z)
 	}
 
-	teleportee := cmdHandler.PlayerByName(args[1])
-	destination := cmdHandler.PlayerByName(args[2])
-	if teleportee == nil {
-		msg := fmt.Sprintf("'%s' is not logged in", args[1])
-		player.EchoMessage(msg)
-		return
-	}
-	if destination == nil {
-		msg := fmt.Sprintf("'%s' is not logged in", args[2])
-		player.EchoMessage(msg)
-		return
-	}
-
-	pos, look := destination.PositionLook()
-
-	// TODO: Remove this hack or figure out what needs to happen instead
-	pos.Y += 1.63
-
-	teleportee.EchoMessage(fmt.Sprintf("Hold still! You are being teleported to %s", args[2]))
-	msg := fmt.Sprintf("Teleporting %s to %s at (%.2f, %.2f, %.2f)", args[1], args[2], pos.X, pos.Y, pos.Z)
+	teleportee.EchoMessage(fmt.Sprintf("Hold still! You are being teleported to %s", destName))
+	msg := fmt.Sprintf("Teleporting %s to %s at (%.2f, %.2f, %.2f)", teleportee.Name(), destName, pos.X, pos.Y, pos.Z)
 	log.Printf("Message: %s", msg)
 	player.EchoMessage(msg)
 
@@ -79,127 +94,271 @@ func cmdTp(player gamerules.IPlayerClient, message string, cmdHandler gamerules.
 
 // /kill
 const killCmd = "kill"
-const killUsage = "kill"
 const killDesc = "Inflicts damage to self. Useful when lost or stuck."
+const killPermission = "command.kill"
 
-func cmdKill(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
-	// TODO inflict damage to player
-	player.EchoMessage(msgNotImplemented)
+func cmdKill(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	player.Damage(math.MaxInt16, DamageCauseSuicide)
 }
 
 // /tell player message
 const tellCmd = "tell"
-const tellUsage = "tell <player> <message>"
-const tellDesc = "Tells a player a message."
+const tellDesc = "Tells a player a message that only they see."
+const tellPermission = "command.tell"
+
+var tellArgs = []Arg{PlayerArg{"player"}, TextArg{"message"}}
+
+func cmdTell(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	sendPrivate(player, args[0].(gamerules.IPlayerClient), args[1].(string), cmdHandler)
+}
 
-func cmdTell(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
-	args := strings.Split(message, " ")
-	if len(args) < 3 {
-		player.EchoMessage(tellUsage)
+// /reply message
+const replyShortCmd = "r"
+const replyCmd = "reply"
+const replyDesc = "Tells a message to the player that you last exchanged a message with."
+const replyPermission = "command.reply"
+
+var replyArgs = []Arg{TextArg{"message"}}
+
+func cmdReply(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	name := cmdHandler.Chat().ReplyTo(player.Name())
+	if name == "" {
+		player.EchoMessage("Nobody has sent you a message to reply to.")
 		return
 	}
-	/* TODO Get player to send message, too
-	player := args[1]
-	message := strings.Join(args[2:], " ")
-	*/
-	player.EchoMessage(msgNotImplemented)
+	target := cmdHandler.PlayerByName(name)
+	if target == nil {
+		player.EchoMessage(fmt.Sprintf("'%s' is not logged in", name))
+		return
+	}
+	sendPrivate(player, target, args[0].(string), cmdHandler)
 }
 
-const helpShortCmd = "?"
-const helpCmd = "help"
-const helpUsage = "help|?"
-const helpDesc = "Shows a list of all commands."
-const msgUnknownCommand = "Command not available."
+// sendPrivate sends a private message from player to target, unless target
+// ignores them. Their replies then go to each other.
+func sendPrivate(player, target gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
+	chatService := cmdHandler.Chat()
+	text, err := chatService.Prepare(player.Name(), message, hasPermission(player, chat.PermColor))
+	if err != nil {
+		player.EchoMessage(err.Error())
+		return
+	}
+
+	chatService.Log(fmt.Sprintf("[%s -> %s] %s", player.Name(), target.Name(), text))
+	player.EchoMessage(fmt.Sprintf("§7[me -> %s] %s", target.Name(), text))
+	chatService.SetReplyTo(player.Name(), target.Name())
+	// The sender isn't told that they are ignored.
+	if !chatService.Ignoring(target.Name(), player.Name()) {
+		target.EchoMessage(fmt.Sprintf("§7[%s -> me] %s", player.Name(), text))
+		chatService.SetReplyTo(target.Name(), player.Name())
+	}
+}
+
+// /me action
+const meCmd = "me"
+const meDesc = "Describes an action that you take, such as \"/me waves\"."
+const mePermission = "command.me"
 
-func cmdHelp(player gamerules.IPlayerClient, message string, cmdFramework *CommandFramework, cmdHandler gamerules.IGame) {
-	args := strings.Split(message, " ")
-	if len(args) > 2 {
-		player.EchoMessage(helpUsage)
+var meArgs = []Arg{TextArg{"action"}}
+
+func cmdMe(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	text, err := cmdHandler.Chat().Prepare(player.Name(), args[0].(string), hasPermission(player, chat.PermColor))
+	if err != nil {
+		player.EchoMessage(err.Error())
 		return
 	}
-	cmds := cmdFramework.Commands()
-	if len(args) == 2 {
-		cmd := args[1]
-		if command, ok := cmds[cmd]; ok {
-			player.EchoMessage("Command: " + cmdFramework.Prefix() + command.Trigger)
-			player.EchoMessage("Usage: " + command.Usage)
-			player.EchoMessage("Description: " + command.Description)
-			return
+	cmdHandler.SendChat(player.Name(), fmt.Sprintf("* %s %s", player.Name(), text))
+}
+
+// /ignore [player]
+const ignoreCmd = "ignore"
+const ignoreDesc = "Hides chat and messages from a player, or shows whom you ignore."
+const ignorePermission = "command.ignore"
+
+var ignoreArgs = []Arg{OptionalArg{WordArg{"player"}}}
+
+func cmdIgnore(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	chatService := cmdHandler.Chat()
+	name, ok := args[0].(string)
+	if !ok {
+		ignored := chatService.Ignored(player.Name())
+		if len(ignored) == 0 {
+			player.EchoMessage("You are not ignoring anyone.")
+		} else {
+			player.EchoMessage("Ignoring: " + strings.Join(ignored, ", "))
 		}
-		player.EchoMessage(msgUnknownCommand)
 		return
 	}
-	var resp string
-	if len(cmds) == 0 {
-		resp = "No commands available."
-	} else {
-		resp = "Commands:"
-		for trigger, _ := range cmds {
-			resp += " " + trigger + ","
-		}
-		resp = resp[:len(resp)-1]
+
+	if strings.EqualFold(name, player.Name()) {
+		player.EchoMessage("You can't ignore yourself.")
+		return
 	}
-	player.EchoMessage(resp)
+	if err := chatService.Ignore(player.Name(), name); err != nil {
+		log.Printf("Failed to save ignore lists: %v", err)
+		player.EchoMessage("Failed to save your ignore list.")
+		return
+	}
+	player.EchoMessage("Ignoring " + name)
 }
 
-const giveCmd = "give"
-const giveUsage = "give <player> <item ID> [<quantity> [<data>]]"
-const giveDesc = "Gives x amount of y items to player."
+// /unignore player
+const unignoreCmd = "unignore"
+const unignoreDesc = "Stops ignoring a player."
+const unignorePermission = "command.unignore"
 
-func cmdGive(player gamerules.IPlayerClient, message string, cmdHandler gamerules.IGame) {
-	args := strings.Split(message, " ")
-	if len(args) < 3 || len(args) > 5 {
-		player.EchoMessage(giveUsage)
+var unignoreArgs = []Arg{WordArg{"player"}}
+
+func cmdUnignore(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	name := args[0].(string)
+	removed, err := cmdHandler.Chat().Unignore(player.Name(), name)
+	if err != nil {
+		log.Printf("Failed to save ignore lists: %v", err)
+		player.EchoMessage("Failed to save your ignore list.")
+		return
+	}
+	if !removed {
+		player.EchoMessage(fmt.Sprintf("You are not ignoring '%s'", name))
 		return
 	}
-	args = args[1:]
+	player.EchoMessage("No longer ignoring " + name)
+}
 
-	// Check to make sure this is a valid player name (at the moment command
-	// is being run, the player may log off before command completes).
-	target := cmdHandler.PlayerByName(args[0])
+// /mute player [duration] [reason]
+const muteCmd = "mute"
+const muteDesc = "Stops a player from chatting, for a duration such as 30m, 12h or 7d if given."
+const mutePermission = "command.mute"
 
-	if target == nil {
-		msg := fmt.Sprintf("'%s' is not logged in", args[0])
-		player.EchoMessage(msg)
+var muteArgs = []Arg{WordArg{"player"}, OptionalArg{DurationArg{"duration"}}, OptionalArg{TextArg{"reason"}}}
+
+func cmdMute(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	name := args[0].(string)
+	mute := &access.Ban{
+		Source:  player.Name(),
+		Created: time.Now(),
+	}
+	if duration, ok := args[1].(time.Duration); ok {
+		mute.Expires = mute.Created.Add(duration)
+	}
+	if reason, ok := args[2].(string); ok {
+		mute.Reason = reason
+	}
+
+	if err := cmdHandler.Chat().Mutes().Add(name, mute); err != nil {
+		log.Printf("Failed to save mutes: %v", err)
+		player.EchoMessage("Failed to save the mutes.")
 		return
 	}
 
-	itemNum, err := strconv.Atoi(args[1])
-	itemType, ok := cmdHandler.ItemTypeById(itemNum)
-	if err != nil || !ok {
-		msg := fmt.Sprintf("'%s' is not a valid item id", args[1])
-		player.EchoMessage(msg)
+	msg := "Muted " + name
+	if !mute.Expires.IsZero() {
+		msg += " until " + mute.Expires.Local().Format("Jan 2 15:04")
+	}
+	log.Printf("Message: %s (by %s)", msg, player.Name())
+	player.EchoMessage(msg)
+
+	if target := cmdHandler.PlayerByName(name); target != nil {
+		target.EchoMessage((&chat.MutedError{Mute: mute}).Error())
+	}
+}
+
+// /unmute player
+const unmuteCmd = "unmute"
+const unmuteDesc = "Lets a muted player chat again."
+const unmutePermission = "command.unmute"
+
+var unmuteArgs = []Arg{WordArg{"player"}}
+
+func cmdUnmute(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	name := args[0].(string)
+	removed, err := cmdHandler.Chat().Mutes().Remove(name)
+	if err != nil {
+		log.Printf("Failed to save mutes: %v", err)
+		player.EchoMessage("Failed to save the mutes.")
+		return
+	}
+	if !removed {
+		player.EchoMessage(fmt.Sprintf("'%s' is not muted", name))
 		return
 	}
 
-	quantity := 1
-	if len(args) >= 3 {
-		quantity, err = strconv.Atoi(args[2])
-		if err != nil {
-			player.EchoMessage(giveUsage)
-			return
-		}
+	msg := "Unmuted " + name
+	log.Printf("Message: %s (by %s)", msg, player.Name())
+	player.EchoMessage(msg)
 
-		if quantity > 512 {
-			msg := "Cannot give more than 512 items at once"
-			player.EchoMessage(msg)
+	if target := cmdHandler.PlayerByName(name); target != nil {
+		target.EchoMessage("You may chat again.")
+	}
+}
+
+const helpShortCmd = "?"
+const helpCmd = "help"
+const helpDesc = "Shows a list of all commands, or how to use a command."
+const helpPermission = "command.help"
+const msgUnknownCommand = "Command not available."
+
+var helpArgs = []Arg{OptionalArg{WordArg{"command"}}}
+
+func cmdHelp(player gamerules.IPlayerClient, args Args, cmdFramework *CommandFramework, cmdHandler gamerules.IGame) {
+	cmds := cmdFramework.Commands()
+	if cmd, ok := args[0].(string); ok {
+		if command, ok := cmds[cmd]; ok && cmdFramework.Allowed(player, command) {
+			player.EchoMessage("Command: " + cmdFramework.Prefix() + command.Trigger)
+			player.EchoMessage("Usage: " + cmdFramework.Prefix() + command.Usage)
+			player.EchoMessage("Description: " + command.Description)
 			return
 		}
+		player.EchoMessage(msgUnknownCommand)
+		return
+	}
+	// Only the commands that the player may run are listed.
+	var triggers []string
+	for trigger, command := range cmds {
+		if cmdFramework.Allowed(player, command) {
+			triggers = append(triggers, trigger)
+		}
 	}
+	if len(triggers) == 0 {
+		player.EchoMessage("No commands available.")
+		return
+	}
+	sort.Strings(triggers)
+	player.EchoMessage("Commands: " + strings.Join(triggers, ", "))
+}
 
+const giveCmd = "give"
+const giveDesc = "Gives x amount of y items to player. The item is given by ID or name, such as iron_shovel."
+const givePermission = "command.give"
+const giveMaxQuantity = 512
+
+var giveArgs = []Arg{
+	PlayerArg{"player"},
+	ItemArg{"item"},
+	OptionalArg{IntArg{"quantity", 1, giveMaxQuantity}},
+	OptionalArg{IntArg{"data", 0, math.MaxInt16}},
+}
+
+func cmdGive(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	target := args[0].(gamerules.IPlayerClient)
+	itemType := args[1].(gamerules.ItemType)
+	quantity := 1
+	if q, ok := args[2].(int); ok {
+		quantity = q
+	}
 	data := 0
-	if len(args) >= 4 {
-		data, err = strconv.Atoi(args[2])
-		if err != nil {
-			player.EchoMessage(giveUsage)
-			return
-		}
+	if d, ok := args[3].(int); ok {
+		data = d
 	}
 
 	// Perform the actual give
-	msg := fmt.Sprintf("Giving %d of '%s' to %s", quantity, itemType.Name, args[0])
+	msg := fmt.Sprintf("Giving %d of '%s' to %s", quantity, itemType.Name, target.Name())
 	player.EchoMessage(msg)
 
+	if player != target {
+		msg = fmt.Sprintf("%s gave you %d of '%s'", player.Name(), quantity, itemType.Name)
+		target.EchoMessage(msg)
+	}
+
 	maxStack := int(itemType.MaxStack)
 
 	for quantity > 0 {
@@ -216,9 +375,356 @@ func cmdGive(player gamerules.IPlayerClient, message string, cmdHandler gamerule
 		target.GiveItem(item)
 		quantity -= count
 	}
+}
 
-	if player != target {
-		msg = fmt.Sprintf("%s gave you %d of '%s'", player, quantity, itemType.Name)
-		target.EchoMessage(msg)
+// /inspect [radius]
+const inspectCmd = "inspect"
+const inspectDesc = "Shows who last changed the blocks around you."
+const inspectPermission = "command.inspect"
+const inspectDefaultRadius = 5
+const inspectMaxChanges = 10
+
+var inspectArgs = []Arg{OptionalArg{IntArg{"radius", 1, 0}}}
+
+func cmdInspect(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	radius := inspectDefaultRadius
+	if r, ok := args[0].(int); ok {
+		radius = r
+	}
+
+	blockLog := cmdHandler.BlockLog(player)
+	if blockLog == nil {
+		player.EchoMessage(blockLogMissingMsg)
+		return
+	}
+
+	pos, _ := player.PositionLook()
+	query := blocklog.Query{
+		Center: *pos.ToBlockXyz(),
+		Radius: BlockCoord(radius),
+	}
+	changes, err := blockLog.BlockChanges(&query)
+	if err != nil {
+		log.Printf("Failed to search block log: %v", err)
+		player.EchoMessage("Failed to search the block log.")
+		return
+	}
+
+	if len(changes) == 0 {
+		player.EchoMessage(fmt.Sprintf("No changes within %d blocks.", radius))
+		return
+	}
+	if len(changes) > inspectMaxChanges {
+		changes = changes[len(changes)-inspectMaxChanges:]
+	}
+	for i := range changes {
+		change := &changes[i]
+		player.EchoMessage(fmt.Sprintf(
+			"%s %s: %s -> %s at %d, %d, %d",
+			change.Time.Local().Format("Jan 2 15:04:05"), change.Player,
+			blockName(change.OldBlockId), blockName(change.NewBlockId),
+			change.Block.X, change.Block.Y, change.Block.Z))
+	}
+}
+
+// blockLogMissingMsg is shown to players in a world or dimension whose changes
+// to blocks aren't logged.
+const blockLogMissingMsg = "Changes to blocks aren't logged here."
+
+func blockName(blockId BlockId) string {
+	if blockType, ok := gamerules.Blocks.Get(blockId); ok {
+		return blockType.Name
+	}
+	return strconv.Itoa(int(blockId))
+}
+
+// /rollback player radius [minutes]
+const rollbackCmd = "rollback"
+const rollbackDesc = "Undoes a player's changes to blocks within radius of you (0 for anywhere), and within the last minutes if given. Chest contents aren't restored."
+const rollbackPermission = "command.rollback"
+
+var rollbackArgs = []Arg{WordArg{"player"}, IntArg{"radius", 0, 0}, OptionalArg{IntArg{"minutes", 1, 0}}}
+
+func cmdRollback(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	name := args[0].(string)
+	radius := args[1].(int)
+	minutes := 0
+	if m, ok := args[2].(int); ok {
+		minutes = m
+	}
+	if radius == 0 && minutes == 0 {
+		player.EchoMessage("Give a radius or a number of minutes to limit the rollback.")
+		return
+	}
+
+	blockLog := cmdHandler.BlockLog(player)
+	if blockLog == nil {
+		player.EchoMessage(blockLogMissingMsg)
+		return
+	}
+
+	query := blocklog.Query{Player: name}
+	if radius > 0 {
+		pos, _ := player.PositionLook()
+		query.Center = *pos.ToBlockXyz()
+		query.Radius = BlockCoord(radius)
+	}
+	if minutes > 0 {
+		query.Since = time.Now().Add(-time.Duration(minutes) * time.Minute)
+	}
+
+	changes, err := blockLog.BlockChanges(&query)
+	if err != nil {
+		log.Printf("Failed to search block log: %v", err)
+		player.EchoMessage("Failed to search the block log.")
+		return
+	}
+
+	if len(changes) == 0 {
+		player.EchoMessage(fmt.Sprintf("No changes by %s to roll back.", name))
+		return
+	}
+
+	msg := fmt.Sprintf("Rolling back %d changes by %s", len(changes), name)
+	log.Printf("Message: %s", msg)
+	player.EchoMessage(msg)
+	blockLog.RevertBlockChanges(player.Name(), changes)
+}
+
+// /ban player|ip [duration] [reason]
+const banCmd = "ban"
+const banDesc = "Bans a player or IP address, for a duration such as 30m, 12h or 7d if given, and kicks them."
+const banPermission = "command.ban"
+
+var banArgs = []Arg{WordArg{"player|ip"}, OptionalArg{DurationArg{"duration"}}, OptionalArg{TextArg{"reason"}}}
+
+func cmdBan(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	ban := &access.Ban{
+		Source:  player.Name(),
+		Created: time.Now(),
+	}
+	if duration, ok := args[1].(time.Duration); ok {
+		ban.Expires = ban.Created.Add(duration)
+	}
+	if reason, ok := args[2].(string); ok {
+		ban.Reason = reason
+	}
+
+	list, key := banListFor(cmdHandler.AccessLists(), args[0].(string))
+	if err := list.Add(key, ban); err != nil {
+		log.Printf("Failed to save ban list: %v", err)
+		player.EchoMessage("Failed to save the ban list.")
+		return
+	}
+
+	msg := "Banned " + key
+	if !ban.Expires.IsZero() {
+		msg += " until " + ban.Expires.Local().Format("Jan 2 15:04")
+	}
+	log.Printf("Message: %s (by %s)", msg, player.Name())
+	player.EchoMessage(msg)
+
+	cmdHandler.KickBanned()
+}
+
+// /unban player|ip
+const unbanCmd = "unban"
+const unbanDesc = "Lifts the ban on a player or IP address."
+const unbanPermission = "command.unban"
+
+var unbanArgs = []Arg{WordArg{"player|ip"}}
+
+func cmdUnban(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	list, key := banListFor(cmdHandler.AccessLists(), args[0].(string))
+	removed, err := list.Remove(key)
+	if err != nil {
+		log.Printf("Failed to save ban list: %v", err)
+		player.EchoMessage("Failed to save the ban list.")
+		return
+	}
+	if !removed {
+		player.EchoMessage(fmt.Sprintf("'%s' is not banned", key))
+		return
+	}
+
+	msg := "Unbanned " + key
+	log.Printf("Message: %s (by %s)", msg, player.Name())
+	player.EchoMessage(msg)
+}
+
+// banListFor returns the ban list for the player name or IP address, and the
+// key for it in the list.
+func banListFor(lists *access.Lists, target string) (*access.BanList, string) {
+	if ip := net.ParseIP(target); ip != nil {
+		return lists.Ips, ip.String()
+	}
+	return lists.Names, target
+}
+
+// /kick player [reason]
+const kickCmd = "kick"
+const kickDesc = "Disconnects a player."
+const kickPermission = "command.kick"
+const kickDefaultReason = "Kicked by an operator."
+
+var kickArgs = []Arg{WordArg{"player"}, OptionalArg{TextArg{"reason"}}}
+
+func cmdKick(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	name := args[0].(string)
+	reason := kickDefaultReason
+	if r, ok := args[1].(string); ok {
+		reason = r
+	}
+
+	if !cmdHandler.KickPlayer(name, reason) {
+		player.EchoMessage(fmt.Sprintf("'%s' is not logged in", name))
+		return
+	}
+
+	msg := "Kicked " + name
+	log.Printf("Message: %s (by %s)", msg, player.Name())
+	player.EchoMessage(msg)
+}
+
+// /whitelist on|off|list|add player|remove player
+const whitelistCmd = "whitelist"
+const whitelistDesc = "Turns the whitelist on or off, or shows, adds to or removes from the players on it."
+const whitelistPermission = "command.whitelist"
+
+var whitelistArgs = []Arg{ChoiceArg{[]string{"on", "off", "list", "add", "remove"}}, OptionalArg{WordArg{"player"}}}
+
+func cmdWhitelist(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	action := args[0].(string)
+	name, hasName := args[1].(string)
+	if needsName := action == "add" || action == "remove"; needsName && !hasName {
+		player.EchoMessage(fmt.Sprintf("Give the player to %s.", action))
+		return
+	} else if !needsName && hasName {
+		player.EchoMessage(fmt.Sprintf("'%s' does not take a player.", action))
+		return
+	}
+
+	whitelist := cmdHandler.AccessLists().Whitelist
+
+	var msg string
+	var err error
+	switch action {
+	case "on":
+		err = whitelist.SetEnabled(true)
+		msg = "The whitelist is on"
+	case "off":
+		err = whitelist.SetEnabled(false)
+		msg = "The whitelist is off"
+	case "list":
+		names := whitelist.Names()
+		if len(names) == 0 {
+			player.EchoMessage("The whitelist is empty.")
+		} else {
+			player.EchoMessage("Whitelist: " + strings.Join(names, ", "))
+		}
+		return
+	case "add":
+		err = whitelist.Add(name)
+		msg = fmt.Sprintf("Added %s to the whitelist", name)
+	case "remove":
+		var removed bool
+		if removed, err = whitelist.Remove(name); err == nil && !removed {
+			player.EchoMessage(fmt.Sprintf("'%s' is not on the whitelist", name))
+			return
+		}
+		msg = fmt.Sprintf("Removed %s from the whitelist", name)
+	}
+
+	if err != nil {
+		log.Printf("Failed to save whitelist: %v", err)
+		player.EchoMessage("Failed to save the whitelist.")
+		return
+	}
+
+	log.Printf("Message: %s (by %s)", msg, player.Name())
+	player.EchoMessage(msg)
+}
+
+// /weather [clear|rain|thunder] [duration]
+const weatherCmd = "weather"
+const weatherDesc = "Shows the weather, or changes it for a duration such as 10m if given."
+const weatherPermission = "command.weather"
+
+var weatherArgs = []Arg{OptionalArg{ChoiceArg{[]string{"clear", "rain", "thunder"}}}, OptionalArg{DurationArg{"duration"}}}
+
+func cmdWeather(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	name, ok := args[0].(string)
+	if !ok {
+		player.EchoMessage("The weather is " + cmdHandler.Weather().String())
+		return
+	}
+	kind, _ := weather.ParseKind(name)
+
+	var duration Ticks
+	msg := "Changing the weather to " + name
+	if d, ok := args[1].(time.Duration); ok {
+		duration = Ticks(d / time.Second * TicksPerSecond)
+		msg += " for " + d.String()
+	}
+	log.Printf("Message: %s (by %s)", msg, player.Name())
+	player.EchoMessage(msg)
+	cmdHandler.SetWeather(kind, duration)
+}
+
+// /world [name]
+const worldCmd = "world"
+const worldDesc = "Shows the world that you are in and the worlds that you may go to, or goes to the named world."
+const worldPermission = "command.world"
+
+var worldArgs = []Arg{OptionalArg{WordArg{"world"}}}
+
+func cmdWorld(player gamerules.IPlayerClient, args Args, cmdHandler gamerules.IGame) {
+	current, ok := cmdHandler.PlayerWorld(player.Name())
+	if !ok {
+		player.EchoMessage("You are not in a world.")
+		return
+	}
+
+	// Any player may go to the main world, which is listed first.
+	worlds := cmdHandler.Worlds()
+	allowed := []string{worlds[0]}
+	for _, name := range worlds[1:] {
+		if hasPermission(player, config.WorldPermission(name)) {
+			allowed = append(allowed, name)
+		}
+	}
+
+	name, ok := args[0].(string)
+	if !ok {
+		player.EchoMessage(fmt.Sprintf("You are in world %s. Worlds: %s", current, strings.Join(allowed, ", ")))
+		return
+	}
+
+	switch {
+	case !containsString(worlds, name):
+		player.EchoMessage(fmt.Sprintf("There is no world '%s'.", name))
+		return
+	case !containsString(allowed, name):
+		player.EchoMessage(fmt.Sprintf("You do not have permission to go to world %s.", name))
+		return
+	case name == current:
+		player.EchoMessage(fmt.Sprintf("You are already in world %s.", name))
+		return
+	}
+
+	if !cmdHandler.SendToWorld(player.Name(), name) {
+		player.EchoMessage(fmt.Sprintf("Failed to go to world %s.", name))
+		return
+	}
+	log.Printf("Message: %s went to world %s", player.Name(), name)
+	player.EchoMessage("Going to world " + name)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,245 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"chunkymonkey/chat"
+	"chunkymonkey/config"
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+	"chunkymonkey/weather"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		text     string
+		duration time.Duration
+		ok       bool
+	}{
+		{"30m", 30 * time.Minute, true},
+		{"12h", 12 * time.Hour, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"0d", 0, false},
+		{"-1h", 0, false},
+		{"d", 0, false},
+		{"griefing", 0, false},
+	}
+
+	for _, test := range tests {
+		duration, ok := parseDuration(test.text)
+		if ok != test.ok || (ok && duration != test.duration) {
+			t.Errorf("parseDuration(%q) = %v, %t, want %v, %t", test.text, duration, ok, test.duration, test.ok)
+		}
+	}
+}
+
+// chatTestGame looks up players, and records the lines of chat sent.
+type chatTestGame struct {
+	argsTestGame
+	chat  *chat.Service
+	lines []string
+}
+
+func (game *chatTestGame) Chat() *chat.Service {
+	return game.chat
+}
+
+func (game *chatTestGame) SendChat(from string, line string) {
+	game.lines = append(game.lines, line)
+}
+
+func TestChatCommands(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+	chatService, err := chat.NewService(&config.ChatConfig{
+		MaxLength: 100,
+		Mutes:     path.Join(dirPath, "mutes.json"),
+		Ignores:   path.Join(dirPath, "ignores.json"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setTestPermissions(t, `{
+		"alice": {"permissions": ["command.*", "chat.color"]},
+		"bob": {"permissions": ["command.*"]},
+		"carol": {"permissions": ["command.*"]}
+	}`)
+	defer func() { gamerules.Permissions = nil }()
+	players := map[string]*testPlayer{
+		"alice": {name: "alice"},
+		"bob":   {name: "bob"},
+		"carol": {name: "carol"},
+	}
+	game := &chatTestGame{chat: chatService}
+	game.players = make(map[string]gamerules.IPlayerClient)
+	for name, player := range players {
+		game.players[name] = player
+	}
+	cf := NewCommandFramework("/")
+
+	tests := []struct {
+		player   string
+		message  string
+		messages map[string][]string
+		lines    []string
+	}{
+		{"bob", "/reply hi", map[string][]string{"bob": {"Nobody has sent you a message to reply to."}}, nil},
+		{"alice", "/tell bob &chello", map[string][]string{
+			"alice": {"§7[me -> bob] §chello"},
+			"bob":   {"§7[alice -> me] §chello"},
+		}, nil},
+		{"bob", "/r &chi", map[string][]string{
+			"bob":   {"§7[me -> alice] &chi"},
+			"alice": {"§7[bob -> me] &chi"},
+		}, nil},
+		{"alice", "/me waves", nil, []string{"* alice waves"}},
+		{"carol", "/ignore", map[string][]string{"carol": {"You are not ignoring anyone."}}, nil},
+		{"carol", "/ignore Carol", map[string][]string{"carol": {"You can't ignore yourself."}}, nil},
+		{"carol", "/ignore Alice", map[string][]string{"carol": {"Ignoring Alice"}}, nil},
+		{"carol", "/ignore", map[string][]string{"carol": {"Ignoring: alice"}}, nil},
+		// The sender isn't told that they are ignored.
+		{"alice", "/tell carol psst", map[string][]string{"alice": {"§7[me -> carol] psst"}}, nil},
+		{"carol", "/unignore bob", map[string][]string{"carol": {"You are not ignoring 'bob'"}}, nil},
+		{"carol", "/unignore alice", map[string][]string{"carol": {"No longer ignoring alice"}}, nil},
+		{"alice", "/mute bob spamming", map[string][]string{
+			"alice": {"Muted bob"},
+			"bob":   {"You are muted: spamming."},
+		}, nil},
+		{"bob", "/me waves", map[string][]string{"bob": {"You are muted: spamming."}}, nil},
+		{"bob", "/tell alice hi", map[string][]string{"bob": {"You are muted: spamming."}}, nil},
+		{"alice", "/unmute bob", map[string][]string{
+			"alice": {"Unmuted bob"},
+			"bob":   {"You may chat again."},
+		}, nil},
+		{"alice", "/unmute bob", map[string][]string{"alice": {"'bob' is not muted"}}, nil},
+		{"bob", "/me waves", nil, []string{"* bob waves"}},
+	}
+	for _, test := range tests {
+		for _, player := range players {
+			player.messages = nil
+		}
+		game.lines = nil
+
+		cf.Process(players[test.player], test.message, game)
+
+		for name, player := range players {
+			if want := test.messages[name]; !reflect.DeepEqual(player.messages, want) {
+				t.Errorf("%s %q: %s got messages %q, want %q", test.player, test.message, name, player.messages, want)
+			}
+		}
+		if !reflect.DeepEqual(game.lines, test.lines) {
+			t.Errorf("%s %q: sent chat %q, want %q", test.player, test.message, game.lines, test.lines)
+		}
+	}
+}
+
+// weatherTestGame records the weather set.
+type weatherTestGame struct {
+	gamerules.IGame
+	kind     weather.Kind
+	duration Ticks
+}
+
+func (game *weatherTestGame) Weather() weather.Kind {
+	return game.kind
+}
+
+func (game *weatherTestGame) SetWeather(kind weather.Kind, duration Ticks) {
+	game.kind = kind
+	game.duration = duration
+}
+
+func TestCmdWeather(t *testing.T) {
+	tests := []struct {
+		args     Args
+		message  string
+		kind     weather.Kind
+		duration Ticks
+	}{
+		{Args{nil, nil}, "The weather is clear", weather.Clear, 0},
+		{Args{"rain", nil}, "Changing the weather to rain", weather.Rain, 0},
+		{Args{"thunder", 90 * time.Second}, "Changing the weather to thunder for 1m30s", weather.Thunder, 1800},
+	}
+	for _, test := range tests {
+		player := &testPlayer{name: "admin"}
+		game := &weatherTestGame{}
+		cmdWeather(player, test.args, game)
+		if len(player.messages) != 1 || player.messages[0] != test.message {
+			t.Errorf("weather %v: got messages %q, want %q", test.args, player.messages, test.message)
+		}
+		if game.kind != test.kind || game.duration != test.duration {
+			t.Errorf("weather %v: set %v for %d, want %v for %d", test.args, game.kind, game.duration, test.kind, test.duration)
+		}
+	}
+}
+
+// worldTestGame hosts worlds, and records the players sent to them.
+type worldTestGame struct {
+	gamerules.IGame
+	worlds  []string
+	current map[string]string
+	sent    []string
+}
+
+func (game *worldTestGame) Worlds() []string {
+	return game.worlds
+}
+
+func (game *worldTestGame) PlayerWorld(player string) (string, bool) {
+	world, ok := game.current[player]
+	return world, ok
+}
+
+func (game *worldTestGame) SendToWorld(player string, world string) bool {
+	game.sent = append(game.sent, player+" "+world)
+	return true
+}
+
+func TestCmdWorld(t *testing.T) {
+	setTestPermissions(t, `{
+		"admin": {"permissions": ["command.*", "world.*"]},
+		"builder": {"permissions": ["command.world", "world.creative"]},
+		"user": {"permissions": ["command.world"]}
+	}`)
+	defer func() { gamerules.Permissions = nil }()
+
+	tests := []struct {
+		player  string
+		args    Args
+		message string
+		sent    []string
+	}{
+		{"user", Args{nil}, "You are in world main. Worlds: main", nil},
+		{"builder", Args{nil}, "You are in world main. Worlds: main, creative", nil},
+		{"admin", Args{nil}, "You are in world creative. Worlds: main, creative, pvp", nil},
+		{"user", Args{"creative"}, "You do not have permission to go to world creative.", nil},
+		{"builder", Args{"creative"}, "Going to world creative", []string{"builder creative"}},
+		{"builder", Args{"nowhere"}, "There is no world 'nowhere'.", nil},
+		{"admin", Args{"creative"}, "You are already in world creative.", nil},
+		{"admin", Args{"main"}, "Going to world main", []string{"admin main"}},
+	}
+	for _, test := range tests {
+		player := &testPlayer{name: test.player}
+		game := &worldTestGame{
+			worlds:  []string{"main", "creative", "pvp"},
+			current: map[string]string{"admin": "creative", "builder": "main", "user": "main"},
+		}
+		cmdWorld(player, test.args, game)
+		if len(player.messages) != 1 || player.messages[0] != test.message {
+			t.Errorf("%s world %v: got messages %q, want %q", test.player, test.args, player.messages, test.message)
+		}
+		if !reflect.DeepEqual(game.sent, test.sent) {
+			t.Errorf("%s world %v: sent %q, want %q", test.player, test.args, game.sent, test.sent)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package command
+
+import (
+	"strings"
+	"sync"
+
+	"chunkymonkey/gamerules"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+const msgUnknownConsoleCommand = "Unknown command. Type help for a list of commands."
+
+// consoleEntityId is the EntityId of consoles. EntityManager never allocates
+// negative IDs, so it is not the ID of anything in the world.
+const consoleEntityId = EntityId(-1)
+
+// Console runs commands on behalf of an operator outside of the game, such as
+// on the server's standard input or over RCON. It implements IPlayerClient so
+// that commands can be run with it as the sender, and has all permissions.
+// Calls that only make sense for players in the world do nothing.
+type Console struct {
+	name    string
+	runLock sync.Mutex // Held while a command runs.
+	lock    sync.Mutex // Guards output.
+	output  []string
+}
+
+// NewConsole creates a Console, named as the sender of the commands that it
+// runs.
+func NewConsole(name string) *Console {
+	return &Console{name: name}
+}
+
+// Run processes the command line, which need not start with the command
+// prefix, and returns the messages that the command echoed back.
+func (console *Console) Run(cf *CommandFramework, game gamerules.IGame, line string) []string {
+	console.runLock.Lock()
+	defer console.runLock.Unlock()
+
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	if !strings.HasPrefix(line, cf.Prefix()) {
+		line = cf.Prefix() + line
+	}
+
+	trigger := strings.Fields(line)[0][len(cf.Prefix()):]
+	if _, ok := cf.Commands()[trigger]; !ok {
+		return []string{msgUnknownConsoleCommand}
+	}
+
+	console.lock.Lock()
+	console.output = nil
+	console.lock.Unlock()
+
+	cf.Process(console, line, game)
+
+	console.lock.Lock()
+	defer console.lock.Unlock()
+	output := console.output
+	console.output = nil
+	return output
+}
+
+func (console *Console) String() string {
+	return console.name
+}
+
+func (console *Console) GetEntityId() EntityId {
+	return consoleEntityId
+}
+
+func (console *Console) Name() string {
+	return console.name
+}
+
+func (console *Console) EchoMessage(msg string) {
+	console.lock.Lock()
+	defer console.lock.Unlock()
+	console.output = append(console.output, msg)
+}
+
+func (console *Console) PositionLook() (AbsXyz, LookDegrees) {
+	return AbsXyz{}, LookDegrees{}
+}
+
+func (console *Console) TransmitPacket(packet []byte) {}
+
+func (console *Console) NotifyChunkLoad() {}
+
+func (console *Console) InventorySubscribed(block BlockXyz, invTypeId InvTypeId, slots []proto.WindowSlot) {
+}
+
+func (console *Console) InventorySlotUpdate(block BlockXyz, slot gamerules.Slot, slotId SlotId) {}
+
+func (console *Console) InventoryProgressUpdate(block BlockXyz, prgBarId PrgBarId, value PrgBarValue) {
+}
+
+func (console *Console) InventoryCursorUpdate(block BlockXyz, cursor gamerules.Slot) {}
+
+func (console *Console) InventoryTxState(block BlockXyz, txId TxId, accepted bool) {}
+
+func (console *Console) InventoryUnsubscribed(block BlockXyz) {}
+
+func (console *Console) PlaceHeldItem(target BlockXyz, wasHeld gamerules.Slot) {}
+
+func (console *Console) OfferItem(fromChunk ChunkXz, entityId EntityId, item gamerules.Slot) {}
+
+func (console *Console) GiveItemAtPosition(atPosition AbsXyz, item gamerules.Slot) {}
+
+func (console *Console) GiveItem(item gamerules.Slot) {}
+
+func (console *Console) SetPositionLook(position AbsXyz, look LookDegrees) {}
+
+func (console *Console) Damage(amount Health, cause DamageCause) {}
+
+func (console *Console) Hit(attackerPos AbsXyz, amount Health, cause DamageCause) {}
+
+func (console *Console) SetAir(air Ticks) {}
+
+func (console *Console) SetFire(fire Ticks) {}
+
+func (console *Console) ResetFall() {}
+
+func (console *Console) EnterPortal() {}
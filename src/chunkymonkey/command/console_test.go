@@ -0,0 +1,55 @@
+package command
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"chunkymonkey/gamerules"
+)
+
+// consoleTestGame records broadcasts. Unimplemented methods of the embedded
+// interface panic if called.
+type consoleTestGame struct {
+	gamerules.IGame
+	broadcasts []string
+}
+
+func (game *consoleTestGame) BroadcastMessage(msg string) {
+	game.broadcasts = append(game.broadcasts, msg)
+}
+
+func (game *consoleTestGame) KickPlayer(name string, reason string) bool {
+	return false
+}
+
+func TestConsole(t *testing.T) {
+	cf := NewCommandFramework("/")
+	game := new(consoleTestGame)
+	console := NewConsole("console")
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"say hello", nil},
+		{"/say again", nil},
+		{"nosuch", []string{msgUnknownConsoleCommand}},
+		// Permissions aren't looked up for the console.
+		{"kick bob", []string{"'bob' is not logged in"}},
+	}
+	for _, test := range tests {
+		if got := console.Run(cf, game, test.line); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Run(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+
+	if want := []string{"§dhello", "§dagain"}; !reflect.DeepEqual(game.broadcasts, want) {
+		t.Errorf("broadcasts = %q, want %q", game.broadcasts, want)
+	}
+
+	if got := console.Run(cf, game, "help"); len(got) != 1 || !strings.HasPrefix(got[0], "Commands:") {
+		t.Errorf("Run(help) = %q, want list of commands", got)
+	}
+}
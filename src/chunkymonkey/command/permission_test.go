@@ -0,0 +1,83 @@
+package command
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"chunkymonkey/gamerules"
+	"chunkymonkey/permission"
+	. "chunkymonkey/types"
+)
+
+// setTestPermissions grants the named users the permission nodes.
+func setTestPermissions(t *testing.T, users string) {
+	permissions, err := permission.LoadJsonPermission(strings.NewReader(users), strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamerules.Permissions = permissions
+}
+
+// testPlayer records the messages echoed to it, and the items given to it.
+// Unimplemented methods of the embedded interface panic if called.
+type testPlayer struct {
+	gamerules.IPlayerClient
+	name     string
+	pos      AbsXyz
+	messages []string
+	given    []gamerules.Slot
+}
+
+func (player *testPlayer) Name() string {
+	return player.name
+}
+
+func (player *testPlayer) EchoMessage(msg string) {
+	player.messages = append(player.messages, msg)
+}
+
+func (player *testPlayer) GiveItem(item gamerules.Slot) {
+	player.given = append(player.given, item)
+}
+
+func (player *testPlayer) PositionLook() (AbsXyz, LookDegrees) {
+	return player.pos, LookDegrees{}
+}
+
+func TestCommandFramework_Permissions(t *testing.T) {
+	setTestPermissions(t, `{
+		"admin": {"permissions": ["command.*"]},
+		"user": {"permissions": ["command.help", "command.tell"]},
+		"nobody": {}
+	}`)
+	defer func() { gamerules.Permissions = nil }()
+
+	cf := NewCommandFramework("/")
+	game := new(consoleTestGame)
+
+	tests := []struct {
+		name    string
+		message string
+		want    []string
+	}{
+		{"user", "/say hello", []string{msgNoPermission}},
+		{"nobody", "/help", []string{msgNoPermission}},
+		{"user", "/help", []string{"Commands: ?, help, tell"}},
+		{"user", "/help tell", []string{"Command: /tell", "Usage: /tell <player> <message>", "Description: " + tellDesc}},
+		{"user", "/help give", []string{msgUnknownCommand}},
+		{"admin", "/help give", []string{"Command: /give", "Usage: /give <player> <item> [quantity] [data]", "Description: " + giveDesc}},
+		{"admin", "/say hello", nil},
+	}
+	for _, test := range tests {
+		player := &testPlayer{name: test.name}
+		cf.Process(player, test.message, game)
+		if !reflect.DeepEqual(player.messages, test.want) {
+			t.Errorf("%s: Process(%q) echoed %q, want %q", test.name, test.message, player.messages, test.want)
+		}
+	}
+
+	if want := []string{"§dhello"}; !reflect.DeepEqual(game.broadcasts, want) {
+		t.Errorf("broadcasts = %q, want %q", game.broadcasts, want)
+	}
+}
@@ -0,0 +1,453 @@
+// The config package loads the server configuration, which is stored as JSON
+// in server.json by default.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	. "chunkymonkey/types"
+)
+
+// Config is the configuration of the whole server.
+type Config struct {
+	Network     NetworkConfig
+	World       WorldConfig
+	Auth        AuthConfig
+	Rules       RulesConfig
+	Permissions PermissionsConfig
+	Access      AccessConfig
+	Rcon        RconConfig
+	Chat        ChatConfig
+	Shards      ShardsConfig
+	// Worlds are the worlds that are hosted besides World, the main world.
+	// They are run with the same settings as World.
+	Worlds []ExtraWorldConfig
+	// CommandPrefix is the text that starts a chat message that is a command.
+	CommandPrefix string
+}
+
+// NetworkConfig configures how clients connect to the server.
+type NetworkConfig struct {
+	// Addr is the address:port that the game is served on.
+	Addr string
+	// HttpAddr is the address:port that HTTP diagnostics and the JSON status
+	// API are served on.
+	HttpAddr string
+	// HttpToken must be given to the HTTP API requests that change the game,
+	// such as kicking players. They are refused if it is empty.
+	HttpToken      string
+	ServerDesc     string
+	MaintenanceMsg string // If set, logins are disallowed.
+	MaxPlayers     int
+	// ReservedSlots is how many of the MaxPlayers slots can only be taken by
+	// players with the "login.reserved" permission.
+	ReservedSlots int
+	// ViewDistance is the radius in chunks around each player that they
+	// receive, from MinChunkRadius to MaxChunkRadius.
+	ViewDistance ChunkCoord
+}
+
+// WorldConfig configures the world and how it is run.
+type WorldConfig struct {
+	// Name is what the world is called by the /world command.
+	Name string
+	// Path is the directory that the world is stored in.
+	Path string
+	// SaveInterval is the number of seconds between saves of chunks.
+	SaveInterval int
+	// MaxHostileMobs and MaxPassiveMobs are the numbers of mobs that spawn
+	// naturally within each shard.
+	MaxHostileMobs int
+	MaxPassiveMobs int
+	// UnloadDelay is the number of seconds that a chunk stays loaded after it
+	// stops being used, before it is saved and unloaded.
+	UnloadDelay int
+	// SpawnRadius is the radius in chunks of the area around the spawn point
+	// that is always kept loaded.
+	SpawnRadius ChunkCoord
+}
+
+// ExtraWorldConfig names a world that is hosted besides the main world.
+// Players need the permission "world.<Name>" to go to it.
+type ExtraWorldConfig struct {
+	Name string
+	Path string
+}
+
+// validWorldName matches the names that worlds may have, which are used in
+// permission nodes.
+var validWorldName = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+// worldPermissionBuild is the permission node "world.build" to change blocks,
+// which no world may be named after.
+const worldPermissionBuild = "build"
+
+// WorldPermission returns the permission node that players need to go to the
+// named world, other than the main world.
+func WorldPermission(name string) string {
+	return "world." + name
+}
+
+// SaveTicks returns the number of ticks between saves of chunks.
+func (world *WorldConfig) SaveTicks() Ticks {
+	return Ticks(world.SaveInterval) * TicksPerSecond
+}
+
+// UnloadTicks returns the number of ticks that unused chunks stay loaded.
+func (world *WorldConfig) UnloadTicks() Ticks {
+	return Ticks(world.UnloadDelay) * TicksPerSecond
+}
+
+// AuthConfig configures the authentication of players.
+type AuthConfig struct {
+	// Enabled is false to let players connect without checking that they are
+	// logged in to minecraft.net.
+	Enabled bool
+	// CheckServerUrl is the URL that checks if a player is logged in.
+	CheckServerUrl string
+}
+
+// RulesConfig names the files that define the game rules.
+type RulesConfig struct {
+	Blocks  string
+	Items   string
+	Recipes string
+	Furnace string
+}
+
+// PermissionsConfig names the files that define the permissions of users.
+type PermissionsConfig struct {
+	Users  string
+	Groups string
+}
+
+// AccessConfig names the files that the ban lists and whitelist are stored
+// in. They are written by the /ban, /unban and /whitelist commands.
+type AccessConfig struct {
+	BannedNames string
+	BannedIps   string
+	Whitelist   string
+}
+
+// RconConfig configures the remote console, which runs commands with all
+// permissions for operators that log in with the password.
+type RconConfig struct {
+	// Addr is the address:port that the remote console is served on. The
+	// remote console is disabled if it is empty.
+	Addr     string
+	Password string
+}
+
+// ChatConfig configures the limits on chat, and the files that chat is
+// logged to and that mutes and players' ignore lists are stored in.
+type ChatConfig struct {
+	// MaxLength is the longest message, in characters, that players may send.
+	MaxLength int
+	// Players may send at most FloodMessages messages in any FloodSeconds
+	// seconds. There is no limit if FloodMessages is 0.
+	FloodMessages int
+	FloodSeconds  int
+	// Mutes and Ignores name the files that the muted players and the
+	// players' ignore lists are stored in. They are written by the /mute,
+	// /unmute, /ignore and /unignore commands.
+	Mutes   string
+	Ignores string
+	// Log is the file that chat is logged to, if set. Once it reaches
+	// LogMaxSize bytes it is renamed to Log.1, and so on up to LogKeep old
+	// files.
+	Log        string
+	LogMaxSize int64
+	LogKeep    int
+}
+
+// ShardsConfig configures which processes host which shards. If Hosts is
+// empty, the frontend hosts all of the shards itself.
+type ShardsConfig struct {
+	// Addr is the address:port that the frontend serves the shards that it
+	// hosts on, for shard servers to connect to. It must be set if Hosts is not
+	// empty.
+	Addr string
+	// Hosts are the shard servers. Each shard is hosted by the first entry
+	// that contains it, or by the frontend if there is none.
+	Hosts []ShardHostConfig
+	// Secret is given by each process when it connects to another for its
+	// shards. It must be set if Hosts is not empty.
+	Secret string
+}
+
+// ShardHostConfig assigns a rectangle of shards to a shard server. The
+// rectangle must be made of whole regions, as each region file must only be
+// written by one process.
+type ShardHostConfig struct {
+	// Addr is the address:port of the shard server.
+	Addr string
+	// The corners of the rectangle, inclusive.
+	MinX, MinZ ShardCoord
+	MaxX, MaxZ ShardCoord
+}
+
+// shardsPerRegion is the width of a region file in shards.
+const shardsPerRegion = 32 / ShardSize
+
+// entityIdsPerHost is the number of EntityIds that each process can create.
+const entityIdsPerHost = 1 << 24
+
+func (host *ShardHostConfig) contains(loc ShardXz) bool {
+	return loc.X >= host.MinX && loc.X <= host.MaxX && loc.Z >= host.MinZ && loc.Z <= host.MaxZ
+}
+
+// HostAddr returns the address of the process that hosts the shard.
+func (shards *ShardsConfig) HostAddr(loc ShardXz) string {
+	for i := range shards.Hosts {
+		if shards.Hosts[i].contains(loc) {
+			return shards.Hosts[i].Addr
+		}
+	}
+	return shards.Addr
+}
+
+// hostAddrs returns the addresses of the processes that host shards, the
+// frontend first, without repeats.
+func (shards *ShardsConfig) hostAddrs() []string {
+	addrs := []string{shards.Addr}
+	seen := map[string]bool{shards.Addr: true}
+	for i := range shards.Hosts {
+		if addr := shards.Hosts[i].Addr; !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// EntityIdRange returns the range of EntityIds, inclusive, that the process
+// serving on addr creates. They don't overlap with any other process.
+func (shards *ShardsConfig) EntityIdRange(addr string) (first, last EntityId) {
+	addrs := shards.hostAddrs()
+	index := 0
+	for index < len(addrs) && addrs[index] != addr {
+		index++
+	}
+	first = EntityId(index * entityIdsPerHost)
+	return first, first + entityIdsPerHost - 1
+}
+
+// EntityIdHost returns the address of the process that created entityId, or
+// "" if none did.
+func (shards *ShardsConfig) EntityIdHost(entityId EntityId) string {
+	addrs := shards.hostAddrs()
+	if index := int(entityId / entityIdsPerHost); entityId >= 0 && index < len(addrs) {
+		return addrs[index]
+	}
+	return ""
+}
+
+func (shards *ShardsConfig) check() error {
+	if len(shards.Hosts) == 0 {
+		return nil
+	}
+	if shards.Addr == "" {
+		return errors.New("Shards.Addr must be set when there are Shards.Hosts")
+	}
+	if shards.Secret == "" {
+		return errors.New("Shards.Secret must be set when there are Shards.Hosts")
+	}
+
+	addrs := map[string]bool{shards.Addr: true}
+	for i := range shards.Hosts {
+		host := &shards.Hosts[i]
+		switch {
+		case host.Addr == "":
+			return fmt.Errorf("Shards.Hosts[%d].Addr must be set", i)
+		case host.Addr == shards.Addr:
+			return fmt.Errorf("Shards.Hosts[%d].Addr must differ from Shards.Addr", i)
+		case host.MinX > host.MaxX || host.MinZ > host.MaxZ:
+			return fmt.Errorf("Shards.Hosts[%d] has no shards", i)
+		case !regionAligned(host.MinX, host.MaxX) || !regionAligned(host.MinZ, host.MaxZ):
+			return fmt.Errorf("Shards.Hosts[%d] must cover whole regions of %dx%d shards", i, shardsPerRegion, shardsPerRegion)
+		}
+		addrs[host.Addr] = true
+	}
+
+	if maxHosts := (math.MaxInt32 + 1) / entityIdsPerHost; len(addrs) > maxHosts {
+		return fmt.Errorf("Shards.Hosts has more than %d shard servers", maxHosts-1)
+	}
+
+	return nil
+}
+
+// checkWorlds checks that the extra worlds have valid names and paths, which
+// differ from each other and from those of the main world.
+func (config *Config) checkWorlds() error {
+	names := map[string]bool{config.World.Name: true}
+	paths := map[string]bool{config.World.Path: true}
+	for i := range config.Worlds {
+		world := &config.Worlds[i]
+		switch {
+		case !validWorldName.MatchString(world.Name) || world.Name == worldPermissionBuild:
+			return fmt.Errorf("Worlds[%d].Name must be letters, digits, _ and -, and not %q, got %q", i, worldPermissionBuild, world.Name)
+		case names[world.Name]:
+			return fmt.Errorf("Worlds[%d].Name %q is already used", i, world.Name)
+		case world.Path == "":
+			return fmt.Errorf("Worlds[%d].Path must be set", i)
+		case paths[world.Path]:
+			return fmt.Errorf("Worlds[%d].Path %q is already used", i, world.Path)
+		}
+		names[world.Name] = true
+		paths[world.Path] = true
+	}
+	return nil
+}
+
+func regionAligned(min, max ShardCoord) bool {
+	return min%shardsPerRegion == 0 && (max+1)%shardsPerRegion == 0
+}
+
+// Default returns the configuration used for any settings that are missing
+// from the configuration file.
+func Default() *Config {
+	return &Config{
+		Network: NetworkConfig{
+			Addr:         ":25565",
+			HttpAddr:     ":25566",
+			ServerDesc:   "Chunkymonkey Minecraft server",
+			MaxPlayers:   16,
+			ViewDistance: ChunkRadius,
+		},
+		World: WorldConfig{
+			Name:           "world",
+			Path:           "world",
+			SaveInterval:   60,
+			MaxHostileMobs: 70,
+			MaxPassiveMobs: 15,
+			UnloadDelay:    30,
+			SpawnRadius:    4,
+		},
+		Auth: AuthConfig{
+			Enabled:        true,
+			CheckServerUrl: "http://www.minecraft.net/game/checkserver.jsp",
+		},
+		Rules: RulesConfig{
+			Blocks:  "blocks.json",
+			Items:   "items.json",
+			Recipes: "recipes.json",
+			Furnace: "furnace.json",
+		},
+		Permissions: PermissionsConfig{
+			Users:  "users.json",
+			Groups: "groups.json",
+		},
+		Access: AccessConfig{
+			BannedNames: "banned-players.json",
+			BannedIps:   "banned-ips.json",
+			Whitelist:   "whitelist.json",
+		},
+		Chat: ChatConfig{
+			MaxLength:     100,
+			FloodMessages: 5,
+			FloodSeconds:  10,
+			Mutes:         "muted-players.json",
+			Ignores:       "ignores.json",
+			Log:           "chat.log",
+			LogMaxSize:    10 << 20,
+			LogKeep:       5,
+		},
+		CommandPrefix: "/",
+	}
+}
+
+// Load reads the configuration from reader, starting from the defaults, and
+// checks that it is valid.
+func Load(reader io.Reader) (config *Config, err error) {
+	config = Default()
+
+	decoder := json.NewDecoder(reader)
+	if err = decoder.Decode(config); err != nil {
+		return nil, err
+	}
+
+	if err = config.Check(); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func LoadFromFile(filename string) (config *Config, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	return Load(file)
+}
+
+// Check tests that the configuration is valid, returning nil if it is.
+func (config *Config) Check() error {
+	switch {
+	case config.Network.Addr == "":
+		return errors.New("Network.Addr must be set")
+	case config.Network.MaxPlayers < 1:
+		return fmt.Errorf("Network.MaxPlayers must be at least 1, got %d", config.Network.MaxPlayers)
+	case config.Network.ReservedSlots < 0 || config.Network.ReservedSlots > config.Network.MaxPlayers:
+		return fmt.Errorf("Network.ReservedSlots must be from 0 to Network.MaxPlayers, got %d", config.Network.ReservedSlots)
+	case config.Network.ViewDistance < MinChunkRadius || config.Network.ViewDistance > MaxChunkRadius:
+		return fmt.Errorf("Network.ViewDistance must be from %d to %d, got %d", MinChunkRadius, MaxChunkRadius, config.Network.ViewDistance)
+	case !validWorldName.MatchString(config.World.Name) || config.World.Name == worldPermissionBuild:
+		return fmt.Errorf("World.Name must be letters, digits, _ and -, and not %q, got %q", worldPermissionBuild, config.World.Name)
+	case config.World.Path == "":
+		return errors.New("World.Path must be set")
+	case config.World.SaveInterval < 1:
+		return fmt.Errorf("World.SaveInterval must be at least 1, got %d", config.World.SaveInterval)
+	case config.World.MaxHostileMobs < 0 || config.World.MaxPassiveMobs < 0:
+		return errors.New("World.MaxHostileMobs and World.MaxPassiveMobs must not be negative")
+	case config.World.UnloadDelay < 1:
+		return fmt.Errorf("World.UnloadDelay must be at least 1, got %d", config.World.UnloadDelay)
+	case config.World.SpawnRadius < 0:
+		return fmt.Errorf("World.SpawnRadius must not be negative, got %d", config.World.SpawnRadius)
+	case config.Rules.Blocks == "" || config.Rules.Items == "" || config.Rules.Recipes == "" || config.Rules.Furnace == "":
+		return errors.New("Rules must name the Blocks, Items, Recipes and Furnace files")
+	case config.Permissions.Users == "" || config.Permissions.Groups == "":
+		return errors.New("Permissions must name the Users and Groups files")
+	case config.Access.BannedNames == "" || config.Access.BannedIps == "" || config.Access.Whitelist == "":
+		return errors.New("Access must name the BannedNames, BannedIps and Whitelist files")
+	case config.Rcon.Addr != "" && config.Rcon.Password == "":
+		return errors.New("Rcon.Password must be set when Rcon.Addr is set")
+	case config.Chat.MaxLength < 1:
+		return fmt.Errorf("Chat.MaxLength must be at least 1, got %d", config.Chat.MaxLength)
+	case config.Chat.FloodMessages < 0 || (config.Chat.FloodMessages > 0 && config.Chat.FloodSeconds < 1):
+		return errors.New("Chat.FloodMessages must not be negative, and Chat.FloodSeconds must be at least 1 if it is set")
+	case config.Chat.Mutes == "" || config.Chat.Ignores == "":
+		return errors.New("Chat must name the Mutes and Ignores files")
+	case config.Chat.Log != "" && (config.Chat.LogMaxSize < 1 || config.Chat.LogKeep < 0):
+		return errors.New("Chat.LogMaxSize must be at least 1 and Chat.LogKeep must not be negative when Chat.Log is set")
+	case config.CommandPrefix == "" || strings.IndexFunc(config.CommandPrefix, unicode.IsSpace) >= 0:
+		return fmt.Errorf("CommandPrefix must be set and not contain spaces, got %q", config.CommandPrefix)
+	}
+
+	if err := config.checkWorlds(); err != nil {
+		return err
+	}
+
+	if err := config.Shards.check(); err != nil {
+		return err
+	}
+
+	if config.Auth.Enabled {
+		if _, err := url.Parse(config.Auth.CheckServerUrl); err != nil || config.Auth.CheckServerUrl == "" {
+			return fmt.Errorf("Auth.CheckServerUrl must be a URL, got %q", config.Auth.CheckServerUrl)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{"empty", `{}`, false},
+		{"partial", `{"Network": {"Addr": ":1234"}, "CommandPrefix": "!"}`, false},
+		{"bad json", `{"Network": `, true},
+		{"bad type", `{"World": {"SaveInterval": "often"}}`, true},
+		{"no addr", `{"Network": {"Addr": ""}}`, true},
+		{"no players", `{"Network": {"MaxPlayers": 0}}`, true},
+		{"reserved slots", `{"Network": {"MaxPlayers": 10, "ReservedSlots": 2}}`, false},
+		{"too many reserved slots", `{"Network": {"MaxPlayers": 10, "ReservedSlots": 11}}`, true},
+		{"negative reserved slots", `{"Network": {"ReservedSlots": -1}}`, true},
+		{"short view", `{"Network": {"ViewDistance": 1}}`, true},
+		{"long view", `{"Network": {"ViewDistance": 16}}`, true},
+		{"longest view", `{"Network": {"ViewDistance": 15}}`, false},
+		{"no save interval", `{"World": {"SaveInterval": 0}}`, true},
+		{"negative mobs", `{"World": {"MaxHostileMobs": -1}}`, true},
+		{"no unload delay", `{"World": {"UnloadDelay": 0}}`, true},
+		{"negative spawn radius", `{"World": {"SpawnRadius": -1}}`, true},
+		{"no spawn area", `{"World": {"SpawnRadius": 0}}`, false},
+		{"bad world name", `{"World": {"Name": "my world"}}`, true},
+		{"world named build", `{"World": {"Name": "build"}}`, true},
+		{"extra worlds", `{"Worlds": [{"Name": "creative", "Path": "creative"}, {"Name": "old-map", "Path": "maps/old"}]}`, false},
+		{"bad extra world name", `{"Worlds": [{"Name": "a.b", "Path": "creative"}]}`, true},
+		{"duplicate world name", `{"Worlds": [{"Name": "world", "Path": "creative"}]}`, true},
+		{"no extra world path", `{"Worlds": [{"Name": "creative"}]}`, true},
+		{"duplicate world path", `{"Worlds": [{"Name": "creative", "Path": "a"}, {"Name": "pvp", "Path": "a"}]}`, true},
+		{"no rules file", `{"Rules": {"Blocks": ""}}`, true},
+		{"no users file", `{"Permissions": {"Users": ""}}`, true},
+		{"no ban list file", `{"Access": {"BannedIps": ""}}`, true},
+		{"rcon", `{"Rcon": {"Addr": ":25575", "Password": "secret"}}`, false},
+		{"rcon without password", `{"Rcon": {"Addr": ":25575"}}`, true},
+		{"no chat length", `{"Chat": {"MaxLength": 0}}`, true},
+		{"no flood limit", `{"Chat": {"FloodMessages": 0, "FloodSeconds": 0}}`, false},
+		{"no flood period", `{"Chat": {"FloodSeconds": 0}}`, true},
+		{"no mutes file", `{"Chat": {"Mutes": ""}}`, true},
+		{"no chat log", `{"Chat": {"Log": "", "LogMaxSize": 0}}`, false},
+		{"no chat log size", `{"Chat": {"LogMaxSize": 0}}`, true},
+		{"no prefix", `{"CommandPrefix": ""}`, true},
+		{"prefix with space", `{"CommandPrefix": "! "}`, true},
+		{"prefix with tab", `{"CommandPrefix": "\t/"}`, true},
+		{"no auth url", `{"Auth": {"CheckServerUrl": ""}}`, true},
+		{"auth disabled", `{"Auth": {"Enabled": false, "CheckServerUrl": ""}}`, false},
+		{"shard host", `{"Shards": {"Addr": ":26000", "Secret": "s", "Hosts": [{"Addr": ":26001", "MinX": -2, "MaxX": 1, "MinZ": 0, "MaxZ": 1}]}}`, false},
+		{"no frontend shard addr", `{"Shards": {"Secret": "s", "Hosts": [{"Addr": ":26001", "MaxX": 1, "MaxZ": 1}]}}`, true},
+		{"no shard secret", `{"Shards": {"Addr": ":26000", "Hosts": [{"Addr": ":26001", "MaxX": 1, "MaxZ": 1}]}}`, true},
+		{"no shard host addr", `{"Shards": {"Addr": ":26000", "Secret": "s", "Hosts": [{"MaxX": 1, "MaxZ": 1}]}}`, true},
+		{"shard host is frontend", `{"Shards": {"Addr": ":26000", "Secret": "s", "Hosts": [{"Addr": ":26000", "MaxX": 1, "MaxZ": 1}]}}`, true},
+		{"no shards", `{"Shards": {"Addr": ":26000", "Secret": "s", "Hosts": [{"Addr": ":26001", "MinX": 2, "MaxX": 1, "MaxZ": 1}]}}`, true},
+		{"part region", `{"Shards": {"Addr": ":26000", "Secret": "s", "Hosts": [{"Addr": ":26001", "MinX": -1, "MaxX": 1, "MaxZ": 1}]}}`, true},
+	}
+
+	for _, test := range tests {
+		_, err := Load(strings.NewReader(test.json))
+		if test.wantErr && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		} else if !test.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
+func TestLoadKeepsDefaults(t *testing.T) {
+	config, err := Load(strings.NewReader(`{"Network": {"Addr": ":1234"}, "CommandPrefix": "!"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Default()
+	want.Network.Addr = ":1234"
+	want.CommandPrefix = "!"
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("got %+v, want %+v", config, want)
+	}
+}
+
+func TestShardsConfig(t *testing.T) {
+	shards := &ShardsConfig{
+		Addr: "frontend",
+		Hosts: []ShardHostConfig{
+			{"a", 0, 0, 1, 1},
+			{"b", -2, 0, -1, 1},
+			{"a", 2, 0, 3, 1},
+		},
+	}
+
+	hostTests := []struct {
+		loc  ShardXz
+		want string
+	}{
+		{ShardXz{0, 0}, "a"},
+		{ShardXz{1, 1}, "a"},
+		{ShardXz{3, 0}, "a"},
+		{ShardXz{-1, 1}, "b"},
+		{ShardXz{0, 2}, "frontend"},
+		{ShardXz{-3, 0}, "frontend"},
+	}
+	for _, test := range hostTests {
+		if got := shards.HostAddr(test.loc); got != test.want {
+			t.Errorf("HostAddr(%v) = %q, want %q", test.loc, got, test.want)
+		}
+	}
+
+	rangeTests := []struct {
+		addr string
+		want EntityId
+	}{
+		{"frontend", 0},
+		{"a", entityIdsPerHost},
+		{"b", 2 * entityIdsPerHost},
+	}
+	for _, test := range rangeTests {
+		first, last := shards.EntityIdRange(test.addr)
+		if first != test.want || last != test.want+entityIdsPerHost-1 {
+			t.Errorf("EntityIdRange(%q) = %d, %d, want %d, %d", test.addr, first, last, test.want, test.want+entityIdsPerHost-1)
+		}
+		if got := shards.EntityIdHost(first); got != test.addr {
+			t.Errorf("EntityIdHost(%d) = %q, want %q", first, got, test.addr)
+		}
+		if got := shards.EntityIdHost(last); got != test.addr {
+			t.Errorf("EntityIdHost(%d) = %q, want %q", last, got, test.addr)
+		}
+	}
+
+	for _, entityId := range []EntityId{-1, 3 * entityIdsPerHost} {
+		if got := shards.EntityIdHost(entityId); got != "" {
+			t.Errorf("EntityIdHost(%d) = %q, want none", entityId, got)
+		}
+	}
+}
+
+// TestLoadServerJson checks the server.json in the top level directory, when
+// the tests are run from there.
+func TestLoadServerJson(t *testing.T) {
+	if _, err := os.Stat("server.json"); err != nil {
+		t.Skip("server.json not found")
+	}
+	if _, err := LoadFromFile("server.json"); err != nil {
+		t.Errorf("Error loading server.json: %v", err)
+	}
+}
@@ -6,15 +6,13 @@ import (
 	"log"
 	"net"
 
+	"chunkymonkey/access"
 	. "chunkymonkey/entity"
 	"chunkymonkey/gamerules"
 	"chunkymonkey/player"
 	"chunkymonkey/proto"
 	"chunkymonkey/server_auth"
-	"chunkymonkey/shardserver"
 	. "chunkymonkey/types"
-	"chunkymonkey/worldstore"
-	"nbt"
 )
 
 // TODO Refactor this more simply after a good re-working of the chunkymonkey/proto package.
@@ -33,10 +31,12 @@ var (
 	clientErrLoginGeneral = errors.New("Login error.")
 	clientErrAuthFailed   = errors.New("Minecraft authentication failed.")
 	clientErrUserData     = errors.New("Error reading user data. Please contact the server administrator.")
+	clientErrServerFull   = errors.New("The server is full.")
 
 	loginErrorConnType    = errors.New("unknown/bad connection type")
 	loginErrorMaintenance = errors.New("server under maintenance")
 	loginErrorServerList  = errors.New("server list poll")
+	loginErrorServerFull  = errors.New("server full")
 )
 
 type GameInfo struct {
@@ -44,10 +44,9 @@ type GameInfo struct {
 	maxPlayerCount int
 	serverDesc     string
 	maintenanceMsg string
+	viewDistance   ChunkCoord
 	serverId       string
-	shardManager   *shardserver.LocalShardManager
 	entityManager  *EntityManager
-	worldStore     *worldstore.WorldStore
 	authserver     server_auth.IAuthenticator
 }
 
@@ -166,6 +165,11 @@ func (l *pktHandler) handleLogin(conn net.Conn) (err, clientErr error) {
 		return
 	}
 
+	if clientErr = l.gameInfo.game.accessLists.CheckLogin(l.username, access.AddrIp(conn.RemoteAddr())); clientErr != nil {
+		err = fmt.Errorf("Player %q refused: %v", l.username, clientErr)
+		return
+	}
+
 	// Load player permissions.
 	permissions := gamerules.Permissions.UserPermissions(l.username)
 	if !permissions.Has("login") {
@@ -204,15 +208,26 @@ func (l *pktHandler) handleLogin(conn net.Conn) (err, clientErr error) {
 		return
 	}
 
+	if !l.gameInfo.game.reserveSlot(permissions.Has("login.reserved")) {
+		err = loginErrorServerFull
+		clientErr = clientErrServerFull
+		return
+	}
+	defer func() {
+		if err != nil {
+			l.gameInfo.game.releaseSlot()
+		}
+	}()
+
 	entityId := l.gameInfo.entityManager.NewEntity()
 
-	var playerData *nbt.Compound
-	if playerData, err = l.gameInfo.game.worldStore.PlayerData(l.username); err != nil {
+	w, playerData, err := l.gameInfo.game.loginWorld(l.username, permissions)
+	if err != nil {
 		clientErr = clientErrUserData
 		return
 	}
 
-	player := player.NewPlayer(entityId, l.gameInfo.shardManager, conn, l.username, l.gameInfo.worldStore.SpawnPosition, l.gameInfo.game.playerDisconnect, l.gameInfo.game)
+	player := player.NewPlayer(entityId, &w.World, conn, l.username, l.gameInfo.viewDistance, l.gameInfo.game.playerDisconnect, l.gameInfo.game)
 	if playerData != nil {
 		if err = player.UnmarshalNbt(playerData); err != nil {
 			// Don't let the player log in, as they will only have default inventory
@@ -1,22 +1,35 @@
 package entity
 
 import (
+	"math"
 	"sync"
 
 	. "chunkymonkey/types"
 )
 
 type EntityManager struct {
-	nextEntityId EntityId
-	entities     map[EntityId]bool
-	lock         sync.Mutex
+	nextEntityId  EntityId
+	firstEntityId EntityId
+	lastEntityId  EntityId
+	entities      map[EntityId]bool
+	releaseOther  func(entityId EntityId)
+	lock          sync.Mutex
 }
 
 func (mgr *EntityManager) Init() {
+	mgr.InitRange(0, math.MaxInt32)
+}
+
+// InitRange initializes the manager to create EntityIds from first to last
+// inclusive. Processes that share a world are given separate ranges, so that
+// their EntityIds are unique.
+func (mgr *EntityManager) InitRange(first, last EntityId) {
 	mgr.lock.Lock()
 	defer mgr.lock.Unlock()
 
-	mgr.nextEntityId = 0
+	mgr.nextEntityId = first
+	mgr.firstEntityId = first
+	mgr.lastEntityId = last
 	mgr.entities = make(map[EntityId]bool)
 }
 
@@ -25,7 +38,11 @@ func (mgr *EntityManager) createEntityId() EntityId {
 	entityId := mgr.nextEntityId
 	_, exists := mgr.entities[entityId]
 	for exists {
-		entityId++
+		if entityId == mgr.lastEntityId {
+			entityId = mgr.firstEntityId
+		} else {
+			entityId++
+		}
 		if entityId == mgr.nextEntityId {
 			// TODO Better handling of this? It shouldn't happen, realistically - but
 			// neither should it explode.
@@ -33,7 +50,11 @@ func (mgr *EntityManager) createEntityId() EntityId {
 		}
 		_, exists = mgr.entities[entityId]
 	}
-	mgr.nextEntityId = entityId + 1
+	if entityId == mgr.lastEntityId {
+		mgr.nextEntityId = mgr.firstEntityId
+	} else {
+		mgr.nextEntityId = entityId + 1
+	}
 
 	return entityId
 }
@@ -50,10 +71,25 @@ func (mgr *EntityManager) NewEntity() EntityId {
 	return entityId
 }
 
-// RemoveEntity removes an entity from the manager.
-func (mgr *EntityManager) RemoveEntityById(entityId EntityId) {
+// SetReleaseOther sets the function that RemoveEntityById passes EntityIds
+// outside of the manager's range to, so that they can be released by the
+// process that created them.
+func (mgr *EntityManager) SetReleaseOther(release func(entityId EntityId)) {
 	mgr.lock.Lock()
 	defer mgr.lock.Unlock()
 
+	mgr.releaseOther = release
+}
+
+// RemoveEntity removes an entity from the manager.
+func (mgr *EntityManager) RemoveEntityById(entityId EntityId) {
+	mgr.lock.Lock()
 	delete(mgr.entities, entityId)
+	release := mgr.releaseOther
+	other := entityId < mgr.firstEntityId || entityId > mgr.lastEntityId
+	mgr.lock.Unlock()
+
+	if other && release != nil {
+		release(entityId)
+	}
 }
@@ -0,0 +1,111 @@
+// The event package lets server extensions handle events in the game, and
+// cancel or modify them.
+//
+// Extensions register handlers with Register, normally from an init function,
+// and are built into the server by importing their package for its side
+// effects from cmd/chunkymonkey. Handlers must be registered before the server
+// starts.
+//
+// Handlers are called synchronously, in the order that they were registered,
+// on the goroutine that owns the state that the event is about:
+//
+//   - PlayerJoin and PlayerQuit on the Game goroutine.
+//   - Chat, Command and InventoryClick in the player's packet handling, with
+//     the player's lock held.
+//   - BlockBreak, BlockPlace, EntitySpawn, EntityDeath and ChunkLoad on the
+//     goroutine of the ChunkShard that holds the chunk. Shards served by a
+//     shard server dispatch these in the shard server's process, so
+//     extensions that handle them must also be built into cmd/shardserver.
+//
+// A handler must not block, and must not make calls that wait on the
+// goroutine that it is called on, as that would deadlock. For example, a
+// PlayerJoin handler must not call IGame.PlayerByName, and a Chat handler must
+// not call PositionLook on its player. Calls that only queue work, such as
+// IPlayerClient.EchoMessage, are safe from any handler.
+//
+// Once a handler cancels an event, the handlers after it are not called.
+// Fields that are documented as changeable may be changed by handlers, and the
+// server uses the changed values.
+package event
+
+import (
+	"sync"
+)
+
+// Kind identifies a type of event.
+type Kind int
+
+const (
+	KindPlayerJoin = Kind(iota)
+	KindPlayerQuit
+	KindChat
+	KindCommand
+	KindInventoryClick
+	KindBlockBreak
+	KindBlockPlace
+	KindEntitySpawn
+	KindEntityDeath
+	KindChunkLoad
+
+	numKinds
+)
+
+// Event is implemented by all events. Handlers type-assert it to the event
+// type for its Kind, such as *Chat for KindChat.
+type Event interface {
+	Kind() Kind
+}
+
+// ICancellable is implemented by events that handlers can cancel.
+type ICancellable interface {
+	Cancel()
+	Cancelled() bool
+}
+
+// Cancellable is embedded in events to implement ICancellable.
+type Cancellable struct {
+	cancelled bool
+}
+
+// Cancel stops the server from carrying out the event, and any later handlers
+// from being called.
+func (c *Cancellable) Cancel() {
+	c.cancelled = true
+}
+
+func (c *Cancellable) Cancelled() bool {
+	return c.cancelled
+}
+
+// Handler is called with events of the Kind that it was registered for.
+type Handler func(e Event)
+
+var (
+	lock     sync.RWMutex
+	handlers [numKinds][]Handler
+)
+
+// Register adds a handler for events of the given kind.
+func Register(kind Kind, handler Handler) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	handlers[kind] = append(handlers[kind], handler)
+}
+
+// Dispatch calls the handlers registered for the event's kind. It returns
+// true if a handler cancelled the event.
+func Dispatch(e Event) (cancelled bool) {
+	lock.RLock()
+	kindHandlers := handlers[e.Kind()]
+	lock.RUnlock()
+
+	cancellable, _ := e.(ICancellable)
+	for _, handler := range kindHandlers {
+		handler(e)
+		if cancellable != nil && cancellable.Cancelled() {
+			return true
+		}
+	}
+	return false
+}
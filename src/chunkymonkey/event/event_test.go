@@ -0,0 +1,62 @@
+package event
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	defer func() { handlers = [numKinds][]Handler{} }()
+
+	var calls []string
+	Register(KindChat, func(e Event) {
+		chat := e.(*Chat)
+		calls = append(calls, "censor")
+		if chat.Message == "spam" {
+			chat.Cancel()
+		}
+		if chat.Message == "darn" {
+			chat.Message = "****"
+		}
+	})
+	Register(KindChat, func(e Event) {
+		calls = append(calls, "log "+e.(*Chat).Message)
+	})
+	Register(KindPlayerQuit, func(e Event) {
+		calls = append(calls, "quit")
+	})
+
+	tests := []struct {
+		message   string
+		cancelled bool
+		want      string
+		calls     []string
+	}{
+		{"hello", false, "hello", []string{"censor", "log hello"}},
+		{"darn", false, "****", []string{"censor", "log ****"}},
+		// Handlers after the one that cancels are not called.
+		{"spam", true, "spam", []string{"censor"}},
+	}
+	for _, test := range tests {
+		calls = nil
+		chat := &Chat{Message: test.message}
+		if cancelled := Dispatch(chat); cancelled != test.cancelled {
+			t.Errorf("Dispatch(%q) = %t, want %t", test.message, cancelled, test.cancelled)
+		}
+		if chat.Message != test.want {
+			t.Errorf("Dispatch(%q) changed message to %q, want %q", test.message, chat.Message, test.want)
+		}
+		if !reflect.DeepEqual(calls, test.calls) {
+			t.Errorf("Dispatch(%q) called %q, want %q", test.message, calls, test.calls)
+		}
+	}
+
+	// Events without handlers are not cancelled.
+	calls = nil
+	if Dispatch(&Command{Line: "/help"}) {
+		t.Errorf("Command with no handlers was cancelled")
+	}
+	if Dispatch(&PlayerQuit{}) || !reflect.DeepEqual(calls, []string{"quit"}) {
+		t.Errorf("PlayerQuit called %q, want [quit]", calls)
+	}
+}
@@ -0,0 +1,107 @@
+package event
+
+import (
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// PlayerJoin is dispatched when a player has logged in. Cancelling it kicks
+// the player with Reason.
+type PlayerJoin struct {
+	Cancellable
+	Player gamerules.IPlayerClient
+	Reason string // Changeable.
+}
+
+func (e *PlayerJoin) Kind() Kind { return KindPlayerJoin }
+
+// PlayerQuit is dispatched when a player has disconnected.
+type PlayerQuit struct {
+	Player gamerules.IPlayerClient
+}
+
+func (e *PlayerQuit) Kind() Kind { return KindPlayerQuit }
+
+// Chat is dispatched when a player sends a chat message, other than a
+// command. Cancelling it stops the message from being sent.
+type Chat struct {
+	Cancellable
+	Player  gamerules.IPlayerClient
+	Message string // Changeable.
+}
+
+func (e *Chat) Kind() Kind { return KindChat }
+
+// Command is dispatched when a player sends a command, before it is checked
+// against their permissions. Cancelling it stops the command from running.
+type Command struct {
+	Cancellable
+	Player gamerules.IPlayerClient
+	Line   string // Changeable. It includes the command prefix.
+}
+
+func (e *Command) Kind() Kind { return KindCommand }
+
+// InventoryClick is dispatched when a player clicks on a slot of their
+// inventory or of an open window. Cancelling it rejects the click.
+type InventoryClick struct {
+	Cancellable
+	Player   gamerules.IPlayerClient
+	WindowId WindowId
+	Click    *gamerules.Click // Changeable.
+}
+
+func (e *InventoryClick) Kind() Kind { return KindInventoryClick }
+
+// BlockBreak is dispatched when a player has dug through a block, before it
+// is destroyed. Cancelling it leaves the block in place.
+type BlockBreak struct {
+	Cancellable
+	Player  gamerules.IPlayerClient
+	Block   BlockXyz
+	BlockId BlockId
+	Data    byte
+}
+
+func (e *BlockBreak) Kind() Kind { return KindBlockBreak }
+
+// BlockPlace is dispatched when a player places a block, before it is set.
+// Cancelling it gives the item back to the player.
+type BlockPlace struct {
+	Cancellable
+	Player  gamerules.IPlayerClient
+	Block   BlockXyz
+	BlockId BlockId // Changeable.
+	Data    byte    // Changeable.
+}
+
+func (e *BlockPlace) Kind() Kind { return KindBlockPlace }
+
+// EntitySpawn is dispatched when a mob or item is created in a chunk, before
+// it is given an entity ID. Cancelling it stops the entity from being
+// created. An item that is cancelled is destroyed, even if a player dropped
+// it, as it has already left their inventory.
+type EntitySpawn struct {
+	Cancellable
+	Entity gamerules.INonPlayerEntity
+	Chunk  ChunkXz
+}
+
+func (e *EntitySpawn) Kind() Kind { return KindEntitySpawn }
+
+// EntityDeath is dispatched when a mob is killed, by any cause.
+type EntityDeath struct {
+	Entity gamerules.INonPlayerEntity
+	Cause  DamageCause
+}
+
+func (e *EntityDeath) Kind() Kind { return KindEntityDeath }
+
+// ChunkLoad is dispatched when a chunk has been loaded from the chunk store.
+// Handlers may change the chunk's blocks through Chunk.
+type ChunkLoad struct {
+	Loc   ChunkXz
+	Chunk gamerules.IChunkBlock
+}
+
+func (e *ChunkLoad) Kind() Kind { return KindChunkLoad }
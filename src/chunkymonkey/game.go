@@ -4,32 +4,74 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"net"
 	"regexp"
+	"sync"
 	"time"
 
+	"chunkymonkey/access"
+	"chunkymonkey/chat"
 	"chunkymonkey/command"
+	"chunkymonkey/config"
 	. "chunkymonkey/entity"
+	"chunkymonkey/event"
 	"chunkymonkey/gamerules"
+	"chunkymonkey/permission"
 	"chunkymonkey/player"
 	"chunkymonkey/proto"
+	"chunkymonkey/rcon"
+	"chunkymonkey/remoteshard"
 	"chunkymonkey/server_auth"
-	"chunkymonkey/shardserver"
 	. "chunkymonkey/types"
-	"chunkymonkey/worldstore"
+	"chunkymonkey/weather"
 	"nbt"
 )
 
+// shutdownTimeoutNs is how long a shutdown waits for players to disconnect
+// before saving the world regardless.
+const shutdownTimeoutNs = 1e9 * 10
+
+// msgJoinRefused is the default reason for kicking a player whose PlayerJoin
+// event was cancelled.
+const msgJoinRefused = "You may not join this server."
+
+const (
+	// lightningChance is the chance, as one in lightningChance each tick, of
+	// lightning striking near each player during thunder.
+	lightningChance = 400
+	// lightningRange is the furthest that lightning strikes from the player
+	// along the x and z axes.
+	lightningRange = 48
+	// lightningViewRange is the furthest from lightning along the x and z
+	// axes that players in the same world see it.
+	lightningViewRange = 160
+)
+
+// playerSnapshotTimeoutNs is how long snapshotPlayers waits for the players to
+// answer. Players that are disconnecting might never answer.
+const playerSnapshotTimeoutNs = 1e9 * 2
+
 // We regard usernames as valid if they don't contain "dangerous" characters.
 // That is: characters that might be abused in filename components, etc.
 var validPlayerUsername = regexp.MustCompile(`^[\-a-zA-Z0-9_]+$`)
 
 type Game struct {
-	shardManager  *shardserver.LocalShardManager
 	entityManager EntityManager
-	worldStore    *worldstore.WorldStore
+	accessLists   *access.Lists
+	chat          *chat.Service
 	connHandler   *ConnHandler
+	shardServer   *remoteshard.Server // nil unless shard servers are in use.
+	rconServer    *rcon.Server        // nil unless the remote console is enabled.
+	commands      *command.CommandFramework
+
+	// The worlds hosted by the game, by name. The main world is the one that
+	// players first join. Its time and weather are shared by the other
+	// worlds.
+	worlds     map[string]*world
+	worldNames []string // The main world first, then in the order configured.
+	mainWorld  *world
 
 	// Mapping between entityId/name and player object
 	players     map[EntityId]*player.Player
@@ -40,21 +82,47 @@ type Game struct {
 	playerConnect    chan *player.Player
 	playerDisconnect chan EntityId
 
+	// Player slots. pendingLogins is the number of slots taken by players
+	// that are still logging in.
+	maxPlayers    int
+	reservedSlots int
+	pendingLogins int
+
 	// Server information
 	time           Ticks
+	weather        weather.State
+	rand           *rand.Rand
 	serverId       string
 	maintenanceMsg string // if set, logins are disallowed.
+
+	// Set once Shutdown has been called.
+	stopping    bool
+	stopMsg     string
+	stopTimeout <-chan time.Time
+
+	// saveLock is held while the worlds are saved, which is done outside of
+	// the Game goroutine so that it keeps ticking. worldsStopped is set once
+	// the worlds have been stopped, after which they can't be saved.
+	saveLock      sync.Mutex
+	worldsStopped bool
 }
 
-func NewGame(worldPath string, listener net.Listener, serverDesc, maintenanceMsg string, maxPlayerCount int) (game *Game, err error) {
-	worldStore, err := worldstore.LoadWorldStore(worldPath)
+func NewGame(config *config.Config, listener net.Listener) (game *Game, err error) {
+	accessLists, err := access.LoadLists(config.Access.BannedNames, config.Access.BannedIps, config.Access.Whitelist)
 	if err != nil {
 		return nil, err
 	}
 
-	authserver, err := server_auth.NewServerAuth("http://www.minecraft.net/game/checkserver.jsp")
+	chatService, err := chat.NewService(&config.Chat)
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	var authserver server_auth.IAuthenticator
+	if config.Auth.Enabled {
+		if authserver, err = server_auth.NewServerAuth(config.Auth.CheckServerUrl); err != nil {
+			return
+		}
 	}
 
 	game = &Game{
@@ -63,43 +131,116 @@ func NewGame(worldPath string, listener net.Listener, serverDesc, maintenanceMsg
 		workQueue:        make(chan func(*Game), 256),
 		playerConnect:    make(chan *player.Player),
 		playerDisconnect: make(chan EntityId),
-		time:             worldStore.Time,
-		worldStore:       worldStore,
+		worlds:           make(map[string]*world),
+		accessLists:      accessLists,
+		chat:             chatService,
+		maxPlayers:       config.Network.MaxPlayers,
+		reservedSlots:    config.Network.ReservedSlots,
+		rand:             rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	if len(config.Shards.Hosts) == 0 {
+		game.entityManager.Init()
+	} else {
+		game.entityManager.InitRange(config.Shards.EntityIdRange(config.Shards.Addr))
 	}
 
-	game.entityManager.Init()
+	if err = game.loadWorlds(config); err != nil {
+		return nil, err
+	}
+	mainStore := game.mainWorld.store
+
+	game.time = mainStore.Time
+	game.weather = weather.State{
+		Raining:     mainStore.Raining,
+		RainTime:    mainStore.RainTime,
+		Thundering:  mainStore.Thundering,
+		ThunderTime: mainStore.ThunderTime,
+	}
 
-	game.serverId = fmt.Sprintf("%016x", rand.NewSource(worldStore.Seed).Int63())
-	//game.serverId = "-"
+	if config.Auth.Enabled {
+		game.serverId = fmt.Sprintf("%016x", rand.NewSource(mainStore.Seed).Int63())
+	} else {
+		// Players are not authenticated.
+		game.serverId = "-"
+	}
+
+	if len(config.Shards.Hosts) > 0 {
+		// Shards of the main world that are hosted by shard servers are
+		// reached through RemoteShards, and the shard servers reach the shards
+		// hosted here through shardServer. The Nether and the other worlds are
+		// always hosted by this process.
+		shardManager := game.mainWorld.shardManager
+		shardManager.SetRemoteShards(remoteshard.NewRemoteShards(&config.Shards, config.Shards.Addr, &game.entityManager))
+
+		shardListener, err := net.Listen("tcp", config.Shards.Addr)
+		if err != nil {
+			return nil, err
+		}
+		game.shardServer = remoteshard.NewServer(shardListener, shardManager, &game.entityManager, config.Shards.Secret)
+	}
+	for _, w := range game.worlds {
+		w.shardManager.KeepLoaded(*w.SpawnBlock.ToChunkXz(), config.World.SpawnRadius)
+	}
 
-	game.shardManager = shardserver.NewLocalShardManager(worldStore.ChunkStore, &game.entityManager)
+	game.commands = command.NewCommandFramework(config.CommandPrefix)
+	gamerules.CommandFramework = game.commands
 
-	// TODO: Load the prefix from a config file
-	gamerules.CommandFramework = command.NewCommandFramework("/")
+	if config.Rcon.Addr != "" {
+		rconListener, err := net.Listen("tcp", config.Rcon.Addr)
+		if err != nil {
+			return nil, err
+		}
+		game.rconServer = rcon.NewServer(rconListener, config.Rcon.Password, game.RunConsoleCommand)
+	}
 
 	// Start accepting connections.
 	game.connHandler = NewConnHandler(listener, &GameInfo{
 		game:           game,
-		maxPlayerCount: maxPlayerCount,
-		serverDesc:     serverDesc,
-		maintenanceMsg: maintenanceMsg,
+		maxPlayerCount: config.Network.MaxPlayers,
+		serverDesc:     config.Network.ServerDesc,
+		maintenanceMsg: config.Network.MaintenanceMsg,
+		viewDistance:   config.Network.ViewDistance,
 		serverId:       game.serverId,
-		shardManager:   game.shardManager,
 		entityManager:  &game.entityManager,
-		worldStore:     game.worldStore,
 		authserver:     authserver,
 	})
 
 	return
 }
 
-// Fetch external events and respond appropriately.
-func (game *Game) Serve() {
-	defer game.connHandler.Stop()
+// loadWorlds loads the main world and the other worlds that are configured.
+func (game *Game) loadWorlds(config *config.Config) (err error) {
+	game.mainWorld, err = loadWorld(config.World.Name, config.World.Path, &config.World, &game.entityManager)
+	if err != nil {
+		return
+	}
+	game.addWorld(game.mainWorld)
 
+	for i := range config.Worlds {
+		extra := &config.Worlds[i]
+		w, err := loadWorld(extra.Name, extra.Path, &config.World, &game.entityManager)
+		if err != nil {
+			return fmt.Errorf("Failed to load world %q: %v", extra.Name, err)
+		}
+		game.addWorld(w)
+	}
+	return nil
+}
+
+func (game *Game) addWorld(w *world) {
+	game.worlds[w.Name] = w
+	game.worldNames = append(game.worldNames, w.Name)
+}
+
+// Fetch external events and respond appropriately. Serve returns once the
+// game has been shut down by Shutdown, and the world has been saved.
+func (game *Game) Serve() {
 	ticker := time.NewTicker(NanosecondsInSecond / TicksPerSecond)
+	defer ticker.Stop()
 
-	for {
+SERVELOOP:
+	for !game.stopping || len(game.players) > 0 {
 		select {
 		case f := <-game.workQueue:
 			f(game)
@@ -109,14 +250,190 @@ func (game *Game) Serve() {
 			game.onPlayerConnect(player)
 		case entityId := <-game.playerDisconnect:
 			game.onPlayerDisconnect(entityId)
+		case <-game.stopTimeout:
+			log.Printf("Timed out waiting for %d player(s) to disconnect.", len(game.players))
+			break SERVELOOP
+		}
+	}
+
+	game.saveWorld()
+}
+
+// Shutdown stops the game from accepting new players, and kicks connected
+// players with the given message. Once they have disconnected, the world is
+// saved and Serve returns.
+func (game *Game) Shutdown(message string) {
+	game.enqueue(func(_ *Game) {
+		if game.stopping {
+			return
+		}
+		log.Print("Shutting down.")
+
+		game.stopping = true
+		game.stopMsg = message
+		game.stopTimeout = time.After(shutdownTimeoutNs)
+		game.connHandler.Stop()
+		if game.rconServer != nil {
+			game.rconServer.Stop()
+		}
+
+		for _, player := range game.players {
+			player.Kick(message)
+		}
+	})
+}
+
+// saveWorld writes out everything that is only held in memory. It is called
+// once the players have disconnected.
+func (game *Game) saveWorld() {
+	// Players that didn't disconnect in time.
+	game.saveRemainingPlayers()
+
+	if game.shardServer != nil {
+		game.shardServer.Stop()
+	}
+
+	game.saveLock.Lock()
+	defer game.saveLock.Unlock()
+	game.worldsStopped = true
+
+	log.Print("Saving chunks.")
+	for _, w := range game.worlds {
+		w.stop()
+	}
+
+	game.writeLevelData(game.time, &game.weather)
+
+	if err := game.chat.Close(); err != nil {
+		log.Printf("Failed when closing chat log: %v", err)
+	}
+
+	log.Print("World saved.")
+}
+
+// saveRemainingPlayers saves the data of the players that are still connected
+// at shutdown. As their goroutines may still be running, each player is saved
+// within their own goroutine. Players that don't answer within
+// playerSnapshotTimeoutNs are logged and skipped.
+func (game *Game) saveRemainingPlayers() {
+	if len(game.players) == 0 {
+		return
+	}
+
+	var lock sync.Mutex
+	skipped := false
+	saved := make(chan *player.Player, len(game.players))
+	for _, p := range game.players {
+		p.Enqueue(func(p *player.Player) {
+			lock.Lock()
+			defer lock.Unlock()
+			if skipped {
+				return
+			}
+			game.savePlayerData(p)
+			saved <- p
+		})
+	}
+
+	unsaved := make(map[*player.Player]bool, len(game.players))
+	for _, p := range game.players {
+		unsaved[p] = true
+	}
+	timeout := time.After(playerSnapshotTimeoutNs)
+	for len(unsaved) > 0 {
+		select {
+		case p := <-saved:
+			delete(unsaved, p)
+		case <-timeout:
+			lock.Lock()
+			skipped = true
+			lock.Unlock()
+			// Take the saves that finished before the timeout.
+			for len(saved) > 0 {
+				delete(unsaved, <-saved)
+			}
+			for p := range unsaved {
+				log.Printf("Timed out saving the data of player %q.", p.Name())
+			}
+			return
+		}
+	}
+}
+
+// Save writes the chunks, the connected players' data and level.dat, without
+// stopping the game. It returns false if the game is shutting down, in which
+// case the world is saved once the players have disconnected.
+func (game *Game) Save() bool {
+	result := make(chan bool)
+	var now Ticks
+	var weatherState weather.State
+	game.enqueue(func(_ *Game) {
+		if game.stopping {
+			result <- false
+			return
+		}
+
+		// Player data is marshalled within each player's own goroutine.
+		for _, p := range game.players {
+			p.Enqueue(func(p *player.Player) {
+				game.savePlayerData(p)
+			})
 		}
+
+		now, weatherState = game.time, game.weather
+		result <- true
+	})
+	if !<-result {
+		return false
+	}
+
+	// The shards are saved and flushed here, rather than in the Game
+	// goroutine, so that the game keeps ticking meanwhile.
+	game.saveLock.Lock()
+	defer game.saveLock.Unlock()
+	if game.worldsStopped {
+		return false
 	}
+	log.Print("Saving the world.")
+
+	for _, w := range game.worlds {
+		w.save()
+	}
+
+	game.writeLevelData(now, &weatherState)
+	return true
+}
+
+// RunConsoleCommand runs the command line on behalf of an operator outside of
+// the game, with all permissions, and returns the messages that the command
+// echoed back. The name is given as the sender of the command.
+func (game *Game) RunConsoleCommand(name, line string) []string {
+	return command.NewConsole(name).Run(game.commands, game, line)
 }
 
 // A new player has connected to the server
 func (game *Game) onPlayerConnect(newPlayer *player.Player) {
+	game.pendingLogins--
 	game.players[newPlayer.GetEntityId()] = newPlayer
 	game.playerNames[newPlayer.Name()] = newPlayer
+
+	if game.stopping {
+		newPlayer.Kick(game.stopMsg)
+		return
+	}
+
+	join := &event.PlayerJoin{Player: newPlayer.Client(), Reason: msgJoinRefused}
+	if event.Dispatch(join) {
+		newPlayer.Kick(join.Reason)
+		return
+	}
+
+	if game.weather.Raining {
+		// This is queued through the player so that it follows their login.
+		newPlayer.Enqueue(func(p *player.Player) {
+			p.SetRaining(true)
+		})
+	}
 }
 
 // A player has disconnected from the server
@@ -126,15 +443,113 @@ func (game *Game) onPlayerDisconnect(entityId EntityId) {
 	delete(game.playerNames, oldPlayer.Name())
 	game.entityManager.RemoveEntityById(entityId)
 
+	event.Dispatch(&event.PlayerQuit{Player: oldPlayer.Client()})
+	game.chat.Forget(oldPlayer.Name())
+
+	game.savePlayerData(oldPlayer)
+}
+
+// loginWorld returns the world that the player returns to when they log in,
+// and their data in it. That is the world that their data was last written
+// to, unless they may no longer go to it, in which case it is the main world.
+// The worlds don't change once the game has started, so it can be called from
+// any goroutine.
+func (game *Game) loginWorld(name string, permissions permission.IUserPermissions) (w *world, playerData *nbt.Compound, err error) {
+	w = game.mainWorld
+	var lastTime time.Time
+	for _, worldName := range game.worldNames {
+		other := game.worlds[worldName]
+		if other != game.mainWorld && !permissions.Has(config.WorldPermission(other.Name)) {
+			continue
+		}
+		var modTime time.Time
+		if modTime, err = other.store.PlayerDataTime(name); err != nil {
+			return
+		}
+		if modTime.After(lastTime) {
+			w, lastTime = other, modTime
+		}
+	}
+
+	playerData, err = w.store.PlayerData(name)
+	return
+}
+
+// changeWorld moves the player to another world, and stores their data in the
+// world that they left. It is called within the player's goroutine.
+func (game *Game) changeWorld(p *player.Player, to *world) {
+	from := game.worlds[p.World().Name]
+	if from == to {
+		return
+	}
+
+	playerData, err := to.store.PlayerData(p.Name())
+	if err == nil {
+		if playerData, err = p.ChangeWorld(&to.World, playerData); err == nil {
+			from.writePlayerData(p.Name(), playerData)
+			log.Printf("Player %q went from world %q to %q", p.Name(), from.Name, to.Name)
+			return
+		}
+	}
+
+	log.Printf("Failed to move player %q to world %q: %v", p.Name(), to.Name, err)
+	p.Client().EchoMessage("Failed to go to world " + to.Name + ".")
+}
+
+// reserveSlot takes a player slot for a player that is logging in, returning
+// false if the server is full. Only players with reserved set may take the
+// reserved slots. The slot is given back by releaseSlot if the login fails, or
+// else when the player disconnects.
+func (game *Game) reserveSlot(reserved bool) bool {
+	result := make(chan bool)
+	game.enqueue(func(_ *Game) {
+		limit := game.maxPlayers
+		if !reserved {
+			limit -= game.reservedSlots
+		}
+		if len(game.players)+game.pendingLogins >= limit {
+			result <- false
+			return
+		}
+		game.pendingLogins++
+		result <- true
+	})
+	return <-result
+}
+
+func (game *Game) releaseSlot() {
+	game.enqueue(func(_ *Game) {
+		game.pendingLogins--
+	})
+}
+
+// writeLevelData writes the level.dat of each world, with the time and
+// weather in that of the main world. It must be called with game.saveLock
+// held.
+func (game *Game) writeLevelData(now Ticks, weatherState *weather.State) {
+	mainStore := game.mainWorld.store
+	mainStore.Time = now
+	mainStore.Raining = weatherState.Raining
+	mainStore.RainTime = weatherState.RainTime
+	mainStore.Thundering = weatherState.Thundering
+	mainStore.ThunderTime = weatherState.ThunderTime
+
+	for _, w := range game.worlds {
+		w.writeLevelData()
+	}
+}
+
+// savePlayerData writes the player's data to the world that they are in. It
+// must be called from within the player's goroutine, or once they have
+// disconnected.
+func (game *Game) savePlayerData(p *player.Player) {
 	playerData := nbt.NewCompound()
-	if err := oldPlayer.MarshalNbt(playerData); err != nil {
+	if err := p.MarshalNbt(playerData); err != nil {
 		log.Printf("Failed to marshal player data: %v", err)
 		return
 	}
 
-	if err := game.worldStore.WritePlayerData(oldPlayer.Name(), playerData); err != nil {
-		log.Printf("Failed when writing player data: %v", err)
-	}
+	game.worlds[p.World().Name].writePlayerData(p.Name(), playerData)
 }
 
 func (game *Game) onTick() {
@@ -142,10 +557,154 @@ func (game *Game) onTick() {
 	if game.time%TicksPerSecond == 0 {
 		game.sendTimeUpdate()
 	}
+
+	if game.weather.Tick(game.rand) {
+		game.sendRain()
+	}
+	if game.weather.Kind() == weather.Thunder {
+		game.strikeLightning()
+	}
+}
+
+// sendRain tells the players whether it is raining.
+func (game *Game) sendRain() {
+	raining := game.weather.Raining
+	for _, p := range game.players {
+		p.Enqueue(func(p *player.Player) {
+			p.SetRaining(raining)
+		})
+	}
+}
+
+// strikeLightning strikes lightning at random near some of the players in
+// DimensionNormal, where it is seen by the players nearby in the same world.
+// The lightning does not set fires or hurt anything.
+func (game *Game) strikeLightning() {
+	type strike struct {
+		near   *player.Player
+		dx, dz AbsCoord
+	}
+	var strikes []strike
+	for _, p := range game.players {
+		if game.rand.Intn(lightningChance) != 0 {
+			continue
+		}
+		strikes = append(strikes, strike{
+			near: p,
+			dx:   AbsCoord(game.rand.Intn(2*lightningRange+1) - lightningRange),
+			dz:   AbsCoord(game.rand.Intn(2*lightningRange+1) - lightningRange),
+		})
+	}
+	if len(strikes) == 0 {
+		return
+	}
+
+	// The players' positions are taken within their own goroutines, which
+	// the Game goroutine mustn't wait for.
+	players := game.playerList()
+	go func() {
+		snapshots := snapshotPlayers(players)
+		byPlayer := make(map[*player.Player]*playerSnapshot, len(snapshots))
+		for i := range snapshots {
+			byPlayer[snapshots[i].player] = &snapshots[i]
+		}
+
+		for _, s := range strikes {
+			near, ok := byPlayer[s.near]
+			if !ok || near.dimension != DimensionNormal {
+				continue
+			}
+			at := AbsXyz{near.position.X + s.dx, near.position.Y, near.position.Z + s.dz}
+
+			// The client removes the lightning by itself, so its EntityId is
+			// only needed while it is sent.
+			entityId := game.entityManager.NewEntity()
+			buf := new(bytes.Buffer)
+			proto.WriteWeather(buf, entityId, true, at.ToAbsIntXyz())
+			game.entityManager.RemoveEntityById(entityId)
+			packet := buf.Bytes()
+
+			for _, other := range snapshots {
+				if other.world == near.world && other.dimension == DimensionNormal &&
+					math.Abs(float64(other.position.X-at.X)) <= lightningViewRange &&
+					math.Abs(float64(other.position.Z-at.Z)) <= lightningViewRange {
+					other.player.TransmitPacket(packet)
+				}
+			}
+		}
+	}()
 }
 
 // Utility functions
 
+// playerSnapshot is the status of a player, as taken within their goroutine.
+type playerSnapshot struct {
+	player    *player.Player
+	world     string
+	dimension DimensionId
+	position  AbsXyz
+	latency   time.Duration
+}
+
+// snapshotPlayers takes the status of each of the players within their own
+// goroutines. Players that don't answer within playerSnapshotTimeoutNs are
+// left out. It must not be called from within the Game goroutine, as players
+// wait on it while holding their locks.
+func snapshotPlayers(players []*player.Player) []playerSnapshot {
+	answers := make([]chan playerSnapshot, len(players))
+	for i, p := range players {
+		answer := make(chan playerSnapshot, 1)
+		answers[i] = answer
+		p.Enqueue(func(p *player.Player) {
+			s := playerSnapshot{player: p}
+			s.world, s.dimension, s.position, s.latency = p.Status()
+			answer <- s
+		})
+	}
+
+	snapshots := make([]playerSnapshot, 0, len(players))
+	timeout := time.After(playerSnapshotTimeoutNs)
+	timedOut := false
+	for _, answer := range answers {
+		if !timedOut {
+			select {
+			case s := <-answer:
+				snapshots = append(snapshots, s)
+				continue
+			case <-timeout:
+				timedOut = true
+			}
+		}
+		// Take the answers that have already arrived.
+		select {
+		case s := <-answer:
+			snapshots = append(snapshots, s)
+		default:
+		}
+	}
+	return snapshots
+}
+
+// playerList returns the connected players. It must be called from within the
+// Game goroutine.
+func (game *Game) playerList() []*player.Player {
+	players := make([]*player.Player, 0, len(game.players))
+	for _, p := range game.players {
+		players = append(players, p)
+	}
+	return players
+}
+
+// connectedPlayers returns the connected players. It must not be called from
+// within the Game goroutine.
+func (game *Game) connectedPlayers() []*player.Player {
+	result := make(chan []*player.Player)
+	game.enqueue(func(_ *Game) {
+		result <- game.playerList()
+	})
+	return <-result
+}
+
 // Send a time/keepalive packet
 func (game *Game) sendTimeUpdate() {
 	buf := new(bytes.Buffer)
@@ -186,7 +745,94 @@ func (game *Game) BroadcastMessage(msg string) {
 
 func (game *Game) ItemTypeById(id int) (gamerules.ItemType, bool) {
 	itemType, ok := gamerules.Items[ItemTypeId(id)]
-	return *itemType, ok
+	if !ok {
+		return gamerules.ItemType{}, false
+	}
+	return *itemType, true
+}
+
+func (game *Game) BlockLog(client gamerules.IPlayerClient) gamerules.IBlockLog {
+	result := make(chan *player.Player)
+	game.enqueue(func(_ *Game) {
+		result <- game.players[client.GetEntityId()]
+	})
+	p := <-result
+	if p == nil {
+		// Such as the remote console, which is in no world.
+		return game.mainWorld.blockLog(DimensionNormal)
+	}
+
+	snapshots := snapshotPlayers([]*player.Player{p})
+	if len(snapshots) == 0 {
+		// The player is disconnecting.
+		return nil
+	}
+
+	// The worlds don't change once the game has started.
+	s := &snapshots[0]
+	return game.worlds[s.world].blockLog(s.dimension)
+}
+
+func (game *Game) AccessLists() *access.Lists {
+	return game.accessLists
+}
+
+func (game *Game) KickPlayer(name string, reason string) bool {
+	result := make(chan bool)
+	game.enqueue(func(_ *Game) {
+		player, ok := game.playerNames[name]
+		if ok {
+			player.Kick(reason)
+		}
+		result <- ok
+	})
+	return <-result
+}
+
+func (game *Game) KickBanned() {
+	game.enqueue(func(_ *Game) {
+		for _, player := range game.players {
+			if ban := game.accessLists.Banned(player.Name(), access.AddrIp(player.RemoteAddr())); ban != nil {
+				player.Kick(ban.Message())
+			}
+		}
+	})
+}
+
+func (game *Game) Chat() *chat.Service {
+	return game.chat
+}
+
+func (game *Game) SendChat(from string, line string) {
+	game.chat.Log(line)
+
+	buf := new(bytes.Buffer)
+	proto.WriteChatMessage(buf, line)
+	packet := buf.Bytes()
+
+	game.enqueue(func(_ *Game) {
+		for _, player := range game.players {
+			if !game.chat.Ignoring(player.Name(), from) {
+				player.TransmitPacket(packet)
+			}
+		}
+	})
+}
+
+func (game *Game) Weather() weather.Kind {
+	result := make(chan weather.Kind)
+	game.enqueue(func(_ *Game) {
+		result <- game.weather.Kind()
+	})
+	return <-result
+}
+
+func (game *Game) SetWeather(kind weather.Kind, duration Ticks) {
+	game.enqueue(func(_ *Game) {
+		if game.weather.Set(kind, duration) {
+			game.sendRain()
+		}
+	})
 }
 
 func (game *Game) PlayerCount() int {
@@ -224,3 +870,45 @@ func (game *Game) PlayerByName(name string) gamerules.IPlayerClient {
 	})
 	return <-result
 }
+
+func (game *Game) Worlds() []string {
+	return append([]string(nil), game.worldNames...)
+}
+
+func (game *Game) PlayerWorld(name string) (string, bool) {
+	result := make(chan *player.Player)
+	game.enqueue(func(_ *Game) {
+		result <- game.playerNames[name]
+	})
+	p := <-result
+	if p == nil {
+		return "", false
+	}
+
+	snapshots := snapshotPlayers([]*player.Player{p})
+	if len(snapshots) == 0 {
+		// The player is disconnecting.
+		return "", false
+	}
+	return snapshots[0].world, true
+}
+
+func (game *Game) SendToWorld(name string, worldName string) bool {
+	// The worlds don't change once the game has started.
+	to, ok := game.worlds[worldName]
+	if !ok {
+		return false
+	}
+
+	result := make(chan bool)
+	game.enqueue(func(_ *Game) {
+		p, ok := game.playerNames[name]
+		if ok {
+			p.Enqueue(func(p *player.Player) {
+				game.changeWorld(p, to)
+			})
+		}
+		result <- ok
+	})
+	return <-result
+}
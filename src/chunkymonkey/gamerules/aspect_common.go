@@ -24,6 +24,17 @@ func spawnItemInBlock(chunk IChunkBlock, blockLoc BlockXyz, itemTypeId ItemTypeI
 	)
 }
 
+// neighbourAt returns the block adjacent to the instance through the given
+// face.
+func neighbourAt(instance *BlockInstance, face Face) (neighbour BlockInstance, ok bool) {
+	dx, dy, dz := face.Dxyz()
+	blockLoc := instance.BlockLoc.AddXyz(dx, dy, dz)
+	if blockLoc == nil {
+		return
+	}
+	return instance.Chunk.BlockAt(blockLoc)
+}
+
 type blockDropItem struct {
 	DroppedItem ItemTypeId
 	Probability byte // Probabilities specified as a percentage
@@ -26,6 +26,61 @@ type IChunkBlock interface {
 
 	// AddActiveBlockIndex flags a block in the chunk itself as active by index.
 	AddActiveBlockIndex(blockIndex BlockIndex)
+
+	// AddDelayedActiveBlockIndex flags a block in the chunk itself as active
+	// by index after the given number of ticks.
+	AddDelayedActiveBlockIndex(blockIndex BlockIndex, delay Ticks)
+
+	// BlockAt returns the block at the given location, which may be in a
+	// neighbouring chunk. ok=false if the block is not available, such as when
+	// its chunk is not loaded or is served elsewhere. The returned instance
+	// belongs to the chunk that the block is in.
+	BlockAt(blockLoc *BlockXyz) (instance BlockInstance, ok bool)
+
+	// SpreadBlock spreads a block type into the given location, which may be in
+	// any chunk. The ISpreadingAspect of the block type decides what happens
+	// to the block at the location.
+	SpreadBlock(target *BlockXyz, blockTypeId BlockId, blockData byte)
+
+	// NeighbourPower returns the redstone power provided to the block at
+	// blockLoc by the adjacent block through the given face.
+	NeighbourPower(blockLoc *BlockXyz, face Face) Power
+
+	// IsBlockOccupied returns true if a player or mob is within the given
+	// block. Other entities (such as items) are also considered if anyEntity is
+	// true.
+	IsBlockOccupied(blockLoc *BlockXyz, anyEntity bool) bool
+
+	// MulticastPlayers sends a packet to all players subscribed to the chunk,
+	// except for the player with the excluded entity ID.
+	MulticastPlayers(exclude EntityId, packet []byte)
+
+	// LightLevels returns the sky and block light levels of the block in the
+	// chunk itself by index.
+	LightLevels(blockIndex BlockIndex) (skyLight, blockLight byte)
+
+	// NearestPlayer returns the nearest player to position, if there is one
+	// within maxDistance. Only players in the same shard are found.
+	NearestPlayer(position *AbsXyz, maxDistance AbsCoord) (player IPlayerClient, playerPos AbsXyz, ok bool)
+
+	// CountMobs returns the number of mobs of the given type within
+	// maxDistance of position. Only mobs in the same shard are counted.
+	CountMobs(mobType *MobType, position *AbsXyz, maxDistance AbsCoord) int
+}
+
+// ISpreadingAspect is implemented by the aspects of block types that spread
+// into neighbouring blocks, such as fluids.
+type ISpreadingAspect interface {
+	// SpreadInto is called to spread the aspect's block type into the target
+	// block. It is called within the goroutine of the target block's chunk.
+	SpreadInto(target *BlockInstance, blockData byte)
+}
+
+// BlockSpread describes a block type spreading into a location.
+type BlockSpread struct {
+	Target      BlockXyz
+	BlockTypeId BlockId
+	BlockData   byte
 }
 
 // IUnsubscribed is the interface by which blocks (and potentially other
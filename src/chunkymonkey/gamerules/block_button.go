@@ -0,0 +1,48 @@
+package gamerules
+
+import (
+	"fmt"
+
+	. "chunkymonkey/types"
+)
+
+func makeButtonAspect() (aspect IBlockAspect) {
+	return &ButtonAspect{}
+}
+
+// ButtonAspect is the behaviour of a button, which provides power for
+// PressTicks ticks after a player presses it.
+type ButtonAspect struct {
+	StandardAspect
+	PressTicks Ticks
+}
+
+func (aspect *ButtonAspect) Name() string {
+	return "Button"
+}
+
+func (aspect *ButtonAspect) Check() error {
+	if aspect.PressTicks < 1 {
+		return fmt.Errorf("block %q: button PressTicks must be at least 1", aspect.blockAttrs.Name)
+	}
+	return aspect.StandardAspect.Check()
+}
+
+func (aspect *ButtonAspect) Interact(instance *BlockInstance, player IPlayerClient) {
+	if instance.Data&switchOnBit != 0 {
+		return
+	}
+	instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, instance.Data|switchOnBit)
+	instance.Chunk.AddDelayedActiveBlockIndex(instance.Index, aspect.PressTicks)
+}
+
+func (aspect *ButtonAspect) Tick(instance *BlockInstance) bool {
+	if instance.Data&switchOnBit != 0 {
+		instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, instance.Data&^switchOnBit)
+	}
+	return false
+}
+
+func (aspect *ButtonAspect) PowerOutput(instance *BlockInstance, face Face) Power {
+	return switchPower(instance)
+}
@@ -1,13 +1,39 @@
 package gamerules
 
 import (
+	"bytes"
+	"math"
+
+	"chunkymonkey/proto"
 	. "chunkymonkey/types"
 )
 
+const (
+	// dispenserLaunchSpeed and dispenserLaunchLift are the speed of projectiles
+	// fired by dispensers, and the upwards part of their direction.
+	dispenserLaunchSpeed = 1.1
+	dispenserLaunchLift  = 0.1
+	// dispenserLaunchSpread is the standard deviation of the direction of
+	// projectiles.
+	dispenserLaunchSpread = 0.045
+	// dispenserEjectLift is the upwards speed of items ejected by dispensers.
+	dispenserEjectLift = 0.2
+)
+
+// dispenserProjectiles maps the items that dispensers fire to the objects that
+// they become.
+var dispenserProjectiles = map[ItemTypeId]ObjTypeId{
+	262: ObjTypeIdArrow,
+	332: ObjTypeIdThrownSnowball,
+	344: ObjTypeIdThrownEgg,
+}
+
 func makeDispenserAspect() (aspect IBlockAspect) {
-	return &InventoryAspect{
-		name:                 "Dispenser",
-		createBlockInventory: createDispenserInventory,
+	return &DispenserAspect{
+		InventoryAspect: InventoryAspect{
+			name:                 "Dispenser",
+			createBlockInventory: createDispenserInventory,
+		},
 	}
 }
 
@@ -24,4 +50,86 @@ func createDispenserInventory(instance *BlockInstance) *blockInventory {
 	)
 }
 
-// TODO behaviours for dispensers.
+// DispenserAspect is the behaviour of a dispenser, which dispenses an item
+// each time it becomes powered.
+type DispenserAspect struct {
+	InventoryAspect
+}
+
+func (aspect *DispenserAspect) PowerChanged(instance *BlockInstance, powered, wasPowered bool) {
+	if powered && !wasPowered {
+		aspect.dispense(instance)
+	}
+}
+
+// dispense takes a random item from the dispenser, and fires it out of the
+// front of the dispenser if it is a projectile, otherwise ejects it.
+func (aspect *DispenserAspect) dispense(instance *BlockInstance) {
+	rand := instance.Chunk.Rand()
+
+	var item Slot
+	var ok bool
+	if blkInv := aspect.blockInv(instance, false); blkInv != nil {
+		if dispenserInv, isDispenser := blkInv.inv.(*DispenserInventory); isDispenser {
+			item, ok = dispenserInv.TakeRandomItem(rand)
+		}
+	}
+	if !ok {
+		dispenserSound(instance, SoundEffectClick1, 0)
+		return
+	}
+
+	dx, _, dz := dispenserFront(instance.Data).Dxyz()
+	position := AbsXyz{
+		AbsCoord(instance.BlockLoc.X) + 0.5 + 0.6*AbsCoord(dx),
+		AbsCoord(instance.BlockLoc.Y) + 0.5,
+		AbsCoord(instance.BlockLoc.Z) + 0.5 + 0.6*AbsCoord(dz),
+	}
+
+	// Add the entity to the chunk that it starts in, if possible.
+	chunk := instance.Chunk
+	if front, ok := chunk.BlockAt(position.ToBlockXyz()); ok {
+		chunk = front.Chunk
+	}
+
+	if objTypeId, ok := dispenserProjectiles[item.ItemTypeId]; ok {
+		x, y, z := float64(dx), dispenserLaunchLift, float64(dz)
+		length := math.Sqrt(x*x + y*y + z*z)
+		velocity := AbsVelocity{
+			AbsVelocityCoord((x/length + rand.NormFloat64()*dispenserLaunchSpread) * dispenserLaunchSpeed),
+			AbsVelocityCoord((y/length + rand.NormFloat64()*dispenserLaunchSpread) * dispenserLaunchSpeed),
+			AbsVelocityCoord((z/length + rand.NormFloat64()*dispenserLaunchSpread) * dispenserLaunchSpeed),
+		}
+		projectile := NewObject(objTypeId)
+		projectile.PointObject.Init(&position, &velocity)
+		chunk.AddEntity(projectile)
+		dispenserSound(instance, SoundEffectBowFire, 0)
+	} else {
+		position.Y -= 0.3
+		speed := rand.Float64()*0.1 + 0.2
+		velocity := AbsVelocity{
+			AbsVelocityCoord(float64(dx)*speed + rand.NormFloat64()*dispenserLaunchSpread),
+			AbsVelocityCoord(dispenserEjectLift + rand.NormFloat64()*dispenserLaunchSpread),
+			AbsVelocityCoord(float64(dz)*speed + rand.NormFloat64()*dispenserLaunchSpread),
+		}
+		chunk.AddEntity(NewItem(item.ItemTypeId, item.Count, item.Data, &position, &velocity, 0))
+		dispenserSound(instance, SoundEffectClick2, 0)
+	}
+
+	// The smoke comes out of the side given by its data.
+	dispenserSound(instance, SoundEffectSmoke, int32(dx+1)+int32(dz+1)*3)
+}
+
+// dispenserFront returns the face that a dispenser fires items out of.
+func dispenserFront(blockData byte) Face {
+	if face := Face(blockData); face >= FaceEast && face <= FaceSouth {
+		return face
+	}
+	return FaceNorth
+}
+
+func dispenserSound(instance *BlockInstance, sound SoundEffect, data int32) {
+	buf := new(bytes.Buffer)
+	proto.WriteSoundEffect(buf, sound, instance.BlockLoc, data)
+	instance.Chunk.MulticastPlayers(-1, buf.Bytes())
+}
@@ -0,0 +1,85 @@
+package gamerules
+
+import (
+	. "chunkymonkey/types"
+)
+
+const (
+	doorOpenBit = 0x4
+	doorTopBit  = 0x8
+)
+
+func makeDoorAspect() (aspect IBlockAspect) {
+	return &DoorAspect{}
+}
+
+// DoorAspect is the behaviour of a door. A door is two blocks high, and opens
+// while either block is powered. Players can also open and close the door if
+// PlayerOpens is true.
+type DoorAspect struct {
+	StandardAspect
+	PlayerOpens bool
+}
+
+func (aspect *DoorAspect) Name() string {
+	return "Door"
+}
+
+func (aspect *DoorAspect) Interact(instance *BlockInstance, player IPlayerClient) {
+	if aspect.PlayerOpens {
+		aspect.setOpen(instance, instance.Data&doorOpenBit == 0)
+	}
+}
+
+func (aspect *DoorAspect) PowerChanged(instance *BlockInstance, powered, wasPowered bool) {
+	if powered == wasPowered {
+		return
+	}
+	if !powered {
+		// The door stays open if the other half is still powered.
+		if other, ok := aspect.otherHalf(instance); ok && receivedPower(&other) > 0 {
+			return
+		}
+	}
+	aspect.setOpen(instance, powered)
+}
+
+func (aspect *DoorAspect) Destroy(instance *BlockInstance) {
+	if other, ok := aspect.otherHalf(instance); ok {
+		other.Chunk.SetBlockByIndex(other.Index, BlockIdAir, 0)
+	}
+	// Only drop the door once, from the bottom half.
+	if instance.Data&doorTopBit == 0 {
+		aspect.StandardAspect.Destroy(instance)
+	}
+}
+
+func (aspect *DoorAspect) setOpen(instance *BlockInstance, open bool) {
+	if open == (instance.Data&doorOpenBit != 0) {
+		return
+	}
+	setDoorData(instance, open)
+	if other, ok := aspect.otherHalf(instance); ok {
+		setDoorData(&other, open)
+	}
+}
+
+// otherHalf returns the other block of the door.
+func (aspect *DoorAspect) otherHalf(instance *BlockInstance) (other BlockInstance, ok bool) {
+	face := Face(FaceTop)
+	if instance.Data&doorTopBit != 0 {
+		face = FaceBottom
+	}
+	if other, ok = neighbourAt(instance, face); ok {
+		ok = other.BlockType.Aspect == instance.BlockType.Aspect
+	}
+	return
+}
+
+func setDoorData(instance *BlockInstance, open bool) {
+	data := instance.Data &^ doorOpenBit
+	if open {
+		data |= doorOpenBit
+	}
+	instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, data)
+}
@@ -0,0 +1,317 @@
+package gamerules
+
+import (
+	"fmt"
+
+	. "chunkymonkey/types"
+)
+
+const (
+	// The lower 3 bits of fluid block data are the distance from the source
+	// block, where 0 is the source block itself.
+	fluidLevelMask = 0x7
+	// The falling bit is set when fluid is falling from the block above.
+	fluidFallingBit = 0x8
+)
+
+// fluidHorizontalFaces are the faces that fluids spread sideways through.
+var fluidHorizontalFaces = []Face{FaceEast, FaceWest, FaceNorth, FaceSouth}
+
+func makeFluidAspect() (aspect IBlockAspect) {
+	return &FluidAspect{}
+}
+
+// FluidAspect is the behaviour of a fluid such as water or lava. Fluid blocks
+// are either "flowing" or "still", sharing the same AspectArgs. A fluid spreads
+// downwards if it can, otherwise sideways, up to Range blocks from its source.
+// It steps every FlowDelay ticks, and becomes still once it has settled.
+type FluidAspect struct {
+	VoidAspect
+	blockAttrs *BlockAttrs
+	// Flowing and Still are the block types for the fluid in each state.
+	Flowing BlockId
+	Still   BlockId
+	// Range is the maximum distance that the fluid spreads sideways.
+	Range byte
+	// FlowDelay is the number of ticks between each step that the fluid takes.
+	FlowDelay Ticks
+	// Infinite fluids create a new source between two adjacent sources.
+	Infinite bool
+	// HardenSource and HardenFlowing are the block types that the fluid turns
+	// into when it meets a different fluid, for source and non-source blocks
+	// respectively. Zero values mean that the fluid does not harden.
+	HardenSource  BlockId
+	HardenFlowing BlockId
+}
+
+func (aspect *FluidAspect) setAttrs(blockAttrs *BlockAttrs) {
+	aspect.blockAttrs = blockAttrs
+}
+
+// PlayerEntered breaks the fall of players that move into the fluid.
+func (aspect *FluidAspect) PlayerEntered(instance *BlockInstance, player IPlayerClient) {
+	player.ResetFall()
+}
+
+func (aspect *FluidAspect) Name() string {
+	return "Fluid"
+}
+
+func (aspect *FluidAspect) Check() error {
+	for _, id := range []BlockId{aspect.Flowing, aspect.Still} {
+		blockType, ok := Blocks.Get(id)
+		if !ok {
+			return fmt.Errorf("block %q: fluid block type %d does not exist", aspect.blockAttrs.Name, id)
+		}
+		if other, ok := blockType.Aspect.(*FluidAspect); !ok || !aspect.isSameFluid(other) {
+			return fmt.Errorf("block %q: fluid block type %d is not the same fluid", aspect.blockAttrs.Name, id)
+		}
+	}
+
+	for _, id := range []BlockId{aspect.HardenSource, aspect.HardenFlowing} {
+		if _, ok := Blocks.Get(id); id != BlockIdAir && !ok {
+			return fmt.Errorf("block %q: hardened block type %d does not exist", aspect.blockAttrs.Name, id)
+		}
+	}
+
+	if aspect.Range < 1 || aspect.Range > fluidLevelMask {
+		return fmt.Errorf("block %q: fluid Range must be from 1 to %d", aspect.blockAttrs.Name, fluidLevelMask)
+	}
+
+	if aspect.FlowDelay < 1 {
+		return fmt.Errorf("block %q: fluid FlowDelay must be at least 1", aspect.blockAttrs.Name)
+	}
+
+	return nil
+}
+
+func (aspect *FluidAspect) Tick(instance *BlockInstance) bool {
+	if aspect.hardenNextToFluid(instance) {
+		return false
+	}
+
+	if aspect.settle(instance) {
+		// The level changed, so the block steps again after a delay.
+		return false
+	}
+
+	level, falling := fluidLevel(instance.Data)
+	aspect.spread(instance, level, falling)
+
+	if instance.BlockType.id != aspect.Still {
+		instance.Chunk.SetBlockByIndex(instance.Index, aspect.Still, instance.Data)
+	}
+
+	return false
+}
+
+func (aspect *FluidAspect) SpreadInto(target *BlockInstance, blockData byte) {
+	if other, ok := target.BlockType.Aspect.(*FluidAspect); ok {
+		if !aspect.isSameFluid(other) {
+			// The fluids meet.
+			if other.hardens() {
+				other.harden(target)
+			} else if aspect.hardens() {
+				target.Chunk.SetBlockByIndex(target.Index, aspect.HardenFlowing, 0)
+			}
+			return
+		}
+
+		targetLevel, targetFalling := fluidLevel(target.Data)
+		level, falling := fluidLevel(blockData)
+		if (targetLevel == 0 && !targetFalling) || targetFalling || (!falling && targetLevel <= level) {
+			// The target already has at least as much fluid.
+			return
+		}
+	} else if !target.BlockType.Replaceable {
+		return
+	} else {
+		// Wash away the block.
+		target.BlockType.Aspect.Destroy(target)
+	}
+
+	target.Chunk.SetBlockByIndex(target.Index, aspect.Flowing, blockData)
+	target.Chunk.AddDelayedActiveBlockIndex(target.Index, aspect.FlowDelay)
+}
+
+// settle updates the level of a non-source block from the blocks that feed it,
+// returning true if it changed.
+func (aspect *FluidAspect) settle(instance *BlockInstance) bool {
+	level, falling := fluidLevel(instance.Data)
+	if level == 0 && !falling {
+		// Source blocks never change level.
+		return false
+	}
+
+	var newData byte
+
+	if above, ok := neighbourAt(instance, FaceTop); ok && aspect.isFluidBlock(&above) {
+		newData = fluidFallingBit
+	} else {
+		var sources int
+		unknown := false
+		minLevel := aspect.Range + 1
+
+		for _, face := range fluidHorizontalFaces {
+			neighbour, ok := neighbourAt(instance, face)
+			if !ok {
+				unknown = true
+				continue
+			} else if !aspect.isFluidBlock(&neighbour) {
+				continue
+			}
+
+			neighbourLevel, neighbourFalling := fluidLevel(neighbour.Data)
+			if neighbourFalling {
+				neighbourLevel = 0
+			} else if neighbourLevel == 0 {
+				sources++
+			}
+			if neighbourLevel+1 < minLevel {
+				minLevel = neighbourLevel + 1
+			}
+		}
+
+		if aspect.Infinite && sources >= 2 && aspect.supportsSource(instance) {
+			newData = 0
+		} else if minLevel <= aspect.Range {
+			newData = minLevel
+		} else if unknown {
+			// The block might be fed from a block that isn't available.
+			return false
+		} else {
+			// Nothing feeds the block any more, so it dries up.
+			instance.Chunk.SetBlockByIndex(instance.Index, BlockIdAir, 0)
+			aspect.activateNeighbours(instance)
+			return true
+		}
+	}
+
+	if newData == instance.Data {
+		return false
+	}
+
+	instance.Chunk.SetBlockByIndex(instance.Index, aspect.Flowing, newData)
+	instance.Chunk.AddDelayedActiveBlockIndex(instance.Index, aspect.FlowDelay)
+	aspect.activateNeighbours(instance)
+
+	return true
+}
+
+// spread flows the fluid downwards if it can, otherwise sideways.
+func (aspect *FluidAspect) spread(instance *BlockInstance, level byte, falling bool) {
+	if below, ok := neighbourAt(instance, FaceBottom); ok {
+		if aspect.canSpreadInto(&below) {
+			instance.Chunk.SpreadBlock(&below.BlockLoc, aspect.Flowing, fluidFallingBit)
+			return
+		} else if aspect.isFluidBlock(&below) {
+			// Resting on the same fluid.
+			return
+		}
+	}
+
+	if falling {
+		level = 0
+	}
+	if level >= aspect.Range {
+		return
+	}
+
+	for _, face := range fluidHorizontalFaces {
+		neighbour, ok := neighbourAt(instance, face)
+		if ok && !aspect.canSpreadInto(&neighbour) {
+			continue
+		}
+		dx, dy, dz := face.Dxyz()
+		if target := instance.BlockLoc.AddXyz(dx, dy, dz); target != nil {
+			instance.Chunk.SpreadBlock(target, aspect.Flowing, level+1)
+		}
+	}
+}
+
+// hardenNextToFluid hardens the block if it touches a different fluid,
+// returning true if it did so.
+func (aspect *FluidAspect) hardenNextToFluid(instance *BlockInstance) bool {
+	if !aspect.hardens() {
+		return false
+	}
+
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		if face == FaceBottom {
+			// Fluid flowing into the block from below doesn't touch it.
+			continue
+		}
+		neighbour, ok := neighbourAt(instance, face)
+		if !ok {
+			continue
+		}
+		if other, ok := neighbour.BlockType.Aspect.(*FluidAspect); ok && !aspect.isSameFluid(other) {
+			aspect.harden(instance)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (aspect *FluidAspect) hardens() bool {
+	return aspect.HardenSource != BlockIdAir || aspect.HardenFlowing != BlockIdAir
+}
+
+func (aspect *FluidAspect) harden(instance *BlockInstance) {
+	hardened := aspect.HardenFlowing
+	if level, falling := fluidLevel(instance.Data); level == 0 && !falling {
+		hardened = aspect.HardenSource
+	}
+	instance.Chunk.SetBlockByIndex(instance.Index, hardened, 0)
+	aspect.activateNeighbours(instance)
+}
+
+// supportsSource returns true if the block below can hold up a new source
+// block.
+func (aspect *FluidAspect) supportsSource(instance *BlockInstance) bool {
+	below, ok := neighbourAt(instance, FaceBottom)
+	if !ok {
+		return false
+	}
+	if aspect.isFluidBlock(&below) {
+		level, falling := fluidLevel(below.Data)
+		return level == 0 && !falling
+	}
+	return below.BlockType.Solid
+}
+
+// canSpreadInto returns true if the fluid might be able to spread into the
+// block.
+func (aspect *FluidAspect) canSpreadInto(target *BlockInstance) bool {
+	if other, ok := target.BlockType.Aspect.(*FluidAspect); ok {
+		if !aspect.isSameFluid(other) {
+			return true
+		}
+		level, falling := fluidLevel(target.Data)
+		return level != 0 && !falling
+	}
+	return target.BlockType.Replaceable
+}
+
+func (aspect *FluidAspect) isSameFluid(other *FluidAspect) bool {
+	return aspect.Flowing == other.Flowing && aspect.Still == other.Still
+}
+
+func (aspect *FluidAspect) isFluidBlock(instance *BlockInstance) bool {
+	other, ok := instance.BlockType.Aspect.(*FluidAspect)
+	return ok && aspect.isSameFluid(other)
+}
+
+func (aspect *FluidAspect) activateNeighbours(instance *BlockInstance) {
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		dx, dy, dz := face.Dxyz()
+		if blockLoc := instance.BlockLoc.AddXyz(dx, dy, dz); blockLoc != nil {
+			instance.Chunk.AddActiveBlock(blockLoc)
+		}
+	}
+}
+
+func fluidLevel(blockData byte) (level byte, falling bool) {
+	return blockData & fluidLevelMask, blockData&fluidFallingBit != 0
+}
@@ -0,0 +1,260 @@
+package gamerules
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+const (
+	blockIdStone         = BlockId(1)
+	blockIdCobblestone   = BlockId(4)
+	blockIdWater         = BlockId(8)
+	blockIdStillWater    = BlockId(9)
+	blockIdLava          = BlockId(10)
+	blockIdStillLava     = BlockId(11)
+	testFluidGroundLevel = BlockYCoord(64)
+)
+
+type testFluidBlock struct {
+	blockId BlockId
+	data    byte
+}
+
+// testFluidChunk is an IChunkBlock that runs the blocks that become active,
+// as a shard does. Blocks that haven't been set are stone below
+// testFluidGroundLevel, and air above it.
+type testFluidChunk struct {
+	IChunkBlock
+	blocks  map[BlockXyz]testFluidBlock
+	active  map[BlockXyz]bool
+	delayed map[BlockXyz]Ticks
+	locs    []BlockXyz // The locations of the BlockIndex values given out.
+}
+
+func newTestFluidChunk() *testFluidChunk {
+	return &testFluidChunk{
+		blocks:  make(map[BlockXyz]testFluidBlock),
+		active:  make(map[BlockXyz]bool),
+		delayed: make(map[BlockXyz]Ticks),
+	}
+}
+
+func (chunk *testFluidChunk) block(loc BlockXyz) testFluidBlock {
+	if block, ok := chunk.blocks[loc]; ok {
+		return block
+	} else if loc.Y < testFluidGroundLevel {
+		return testFluidBlock{blockIdStone, 0}
+	}
+	return testFluidBlock{BlockIdAir, 0}
+}
+
+// place sets a block, and makes it active.
+func (chunk *testFluidChunk) place(loc BlockXyz, blockId BlockId, data byte) {
+	chunk.blocks[loc] = testFluidBlock{blockId, data}
+	chunk.active[loc] = true
+}
+
+func (chunk *testFluidChunk) BlockAt(loc *BlockXyz) (instance BlockInstance, ok bool) {
+	block := chunk.block(*loc)
+	blockType, ok := Blocks.Get(block.blockId)
+	chunk.locs = append(chunk.locs, *loc)
+	instance = BlockInstance{
+		Chunk:     chunk,
+		BlockLoc:  *loc,
+		Index:     BlockIndex(len(chunk.locs) - 1),
+		BlockType: blockType,
+		Data:      block.data,
+	}
+	return
+}
+
+func (chunk *testFluidChunk) SetBlockByIndex(index BlockIndex, blockId BlockId, blockData byte) {
+	chunk.blocks[chunk.locs[index]] = testFluidBlock{blockId, blockData}
+}
+
+func (chunk *testFluidChunk) SpreadBlock(target *BlockXyz, blockTypeId BlockId, blockData byte) {
+	blockType, _ := Blocks.Get(blockTypeId)
+	if instance, ok := chunk.BlockAt(target); ok {
+		blockType.Aspect.(ISpreadingAspect).SpreadInto(&instance, blockData)
+	}
+}
+
+func (chunk *testFluidChunk) AddActiveBlock(loc *BlockXyz) {
+	chunk.active[*loc] = true
+}
+
+func (chunk *testFluidChunk) AddDelayedActiveBlockIndex(index BlockIndex, delay Ticks) {
+	loc := chunk.locs[index]
+	if current, ok := chunk.delayed[loc]; !ok || delay < current {
+		chunk.delayed[loc] = delay
+	}
+}
+
+// run ticks the active blocks until none are left, or for at most the given
+// number of ticks.
+func (chunk *testFluidChunk) run(ticks int) {
+	for i := 0; i < ticks && (len(chunk.active) > 0 || len(chunk.delayed) > 0); i++ {
+		for loc, delay := range chunk.delayed {
+			if delay <= 1 {
+				chunk.active[loc] = true
+				delete(chunk.delayed, loc)
+			} else {
+				chunk.delayed[loc] = delay - 1
+			}
+		}
+
+		active := chunk.active
+		chunk.active = make(map[BlockXyz]bool)
+		for loc := range active {
+			if instance, ok := chunk.BlockAt(&loc); ok && instance.BlockType.Aspect.Tick(&instance) {
+				chunk.active[loc] = true
+			}
+		}
+	}
+}
+
+func TestFluid(t *testing.T) {
+	type block struct {
+		loc     BlockXyz
+		blockId BlockId
+		data    byte
+	}
+	ground := testFluidGroundLevel
+
+	tests := []struct {
+		desc   string
+		placed []block
+		want   []block
+	}{
+		{
+			"water spreads to its range",
+			[]block{{BlockXyz{0, ground, 0}, blockIdStillWater, 0}},
+			[]block{
+				{BlockXyz{0, ground, 0}, blockIdStillWater, 0},
+				{BlockXyz{1, ground, 0}, blockIdStillWater, 1},
+				{BlockXyz{0, ground, -3}, blockIdStillWater, 3},
+				{BlockXyz{2, ground, 2}, blockIdStillWater, 4},
+				{BlockXyz{7, ground, 0}, blockIdStillWater, 7},
+				{BlockXyz{8, ground, 0}, BlockIdAir, 0},
+				{BlockXyz{4, ground, 4}, BlockIdAir, 0},
+				{BlockXyz{0, ground + 1, 0}, BlockIdAir, 0},
+			},
+		},
+		{
+			"lava spreads to its range",
+			[]block{{BlockXyz{0, ground, 0}, blockIdStillLava, 0}},
+			[]block{
+				{BlockXyz{3, ground, 0}, blockIdStillLava, 3},
+				{BlockXyz{4, ground, 0}, BlockIdAir, 0},
+				{BlockXyz{2, ground, 2}, BlockIdAir, 0},
+			},
+		},
+		{
+			"water falls before it spreads",
+			[]block{{BlockXyz{0, ground + 5, 0}, blockIdStillWater, 0}},
+			[]block{
+				{BlockXyz{0, ground + 4, 0}, blockIdStillWater, fluidFallingBit},
+				{BlockXyz{0, ground, 0}, blockIdStillWater, fluidFallingBit},
+				{BlockXyz{1, ground + 5, 0}, BlockIdAir, 0},
+				{BlockXyz{1, ground + 1, 0}, BlockIdAir, 0},
+				{BlockXyz{1, ground, 0}, blockIdStillWater, 1},
+				{BlockXyz{7, ground, 0}, blockIdStillWater, 7},
+			},
+		},
+		{
+			"water doesn't spread through stone",
+			[]block{
+				{BlockXyz{0, ground, 0}, blockIdStillWater, 0},
+				{BlockXyz{1, ground, 0}, blockIdStone, 0},
+			},
+			[]block{
+				{BlockXyz{1, ground, 0}, blockIdStone, 0},
+				{BlockXyz{2, ground, 0}, blockIdStillWater, 4}, // Around the stone.
+			},
+		},
+		{
+			"two sources make another",
+			[]block{
+				{BlockXyz{0, ground, 0}, blockIdStillWater, 0},
+				{BlockXyz{2, ground, 0}, blockIdStillWater, 0},
+			},
+			[]block{{BlockXyz{1, ground, 0}, blockIdStillWater, 0}},
+		},
+		{
+			"lava doesn't make sources",
+			[]block{
+				{BlockXyz{0, ground, 0}, blockIdStillLava, 0},
+				{BlockXyz{2, ground, 0}, blockIdStillLava, 0},
+			},
+			[]block{{BlockXyz{1, ground, 0}, blockIdStillLava, 1}},
+		},
+		{
+			"water meets a lava source",
+			[]block{
+				{BlockXyz{0, ground, 0}, blockIdStillWater, 0},
+				{BlockXyz{1, ground, 0}, blockIdStillLava, 0},
+			},
+			[]block{{BlockXyz{1, ground, 0}, blockIdObsidian, 0}},
+		},
+		{
+			"water meets flowing lava",
+			[]block{
+				{BlockXyz{0, ground, 0}, blockIdStillWater, 0},
+				{BlockXyz{1, ground, 0}, blockIdLava, 1},
+			},
+			[]block{{BlockXyz{1, ground, 0}, blockIdCobblestone, 0}},
+		},
+		{
+			"lava flows into water",
+			[]block{
+				{BlockXyz{0, ground + 1, 0}, blockIdStillLava, 0},
+				{BlockXyz{0, ground, 0}, blockIdStillWater, 0},
+			},
+			[]block{{BlockXyz{0, ground, 0}, blockIdCobblestone, 0}},
+		},
+	}
+
+	for _, test := range tests {
+		chunk := newTestFluidChunk()
+		for _, b := range test.placed {
+			chunk.place(b.loc, b.blockId, b.data)
+		}
+		chunk.run(1000)
+		if len(chunk.active) > 0 || len(chunk.delayed) > 0 {
+			t.Errorf("%s: fluid still flowing after 1000 ticks", test.desc)
+		}
+
+		for _, want := range test.want {
+			if got := chunk.block(want.loc); got != (testFluidBlock{want.blockId, want.data}) {
+				t.Errorf("%s: at %v got block %d with data %#x, want %d with data %#x",
+					test.desc, want.loc, got.blockId, got.data, want.blockId, want.data)
+			}
+		}
+	}
+}
+
+func TestFluidDriesUp(t *testing.T) {
+	ground := testFluidGroundLevel
+	source := BlockXyz{0, ground + 2, 0}
+
+	chunk := newTestFluidChunk()
+	chunk.place(source, blockIdStillWater, 0)
+	chunk.run(1000)
+	if got := chunk.block(BlockXyz{3, ground, 0}); got.blockId != blockIdStillWater {
+		t.Fatalf("got block %d beside the fallen water, want water", got.blockId)
+	}
+
+	chunk.place(source, BlockIdAir, 0)
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		dx, dy, dz := face.Dxyz()
+		chunk.AddActiveBlock(source.AddXyz(dx, dy, dz))
+	}
+	chunk.run(1000)
+
+	for loc, block := range chunk.blocks {
+		if block.blockId == blockIdWater || block.blockId == blockIdStillWater {
+			t.Errorf("got water left at %v with data %#x once its source was removed", loc, block.data)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package gamerules
+
+import (
+	. "chunkymonkey/types"
+)
+
+// The bit of lever and button data that is set when they are switched on.
+const switchOnBit = 0x8
+
+func makeLeverAspect() (aspect IBlockAspect) {
+	return &LeverAspect{}
+}
+
+// LeverAspect is the behaviour of a lever, which players switch on and off to
+// provide power.
+type LeverAspect struct {
+	StandardAspect
+}
+
+func (aspect *LeverAspect) Name() string {
+	return "Lever"
+}
+
+func (aspect *LeverAspect) Interact(instance *BlockInstance, player IPlayerClient) {
+	instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, instance.Data^switchOnBit)
+}
+
+func (aspect *LeverAspect) PowerOutput(instance *BlockInstance, face Face) Power {
+	return switchPower(instance)
+}
+
+func switchPower(instance *BlockInstance) Power {
+	if instance.Data&switchOnBit == 0 {
+		return Power{}
+	}
+	return Power{Level: MaxPowerLevel}
+}
@@ -15,9 +15,11 @@ type aspectMakerFn func() (aspect IBlockAspect)
 
 var aspectMakers map[string]aspectMakerFn
 
-// Used specifically for json unmarshalling of block definitions.
+// Used specifically for json unmarshalling of block definitions. The tag on
+// BlockAttrs keeps the attributes in an object of their own, rather than
+// among the fields of the definition.
 type blockDef struct {
-	BlockAttrs
+	BlockAttrs `json:"BlockAttrs"`
 	Aspect     string
 	AspectArgs *aspectArgs
 }
@@ -194,17 +196,27 @@ func LoadBlocksFromFile(filename string) (blockTypes BlockTypeList, err error) {
 
 func init() {
 	aspectMakers = map[string]aspectMakerFn{
-		"Chest":        makeChestAspect,
-		"Dispenser":    makeDispenserAspect,
-		"Furnace":      makeFurnaceAspect,
-		"MobSpawner":   makeMobSpawnerAspect,
-		"Music":        makeMusicAspect,
-		"RecordPlayer": makeRecordPlayerAspect,
-		"Sapling":      makeSaplingAspect,
-		"Sign":         makeSignAspect,
-		"Standard":     makeStandardAspect,
-		"Todo":         makeTodoAspect,
-		"Void":         makeVoidAspect,
-		"Workbench":    makeWorkbenchAspect,
+		"Button":           makeButtonAspect,
+		"Chest":            makeChestAspect,
+		"Dispenser":        makeDispenserAspect,
+		"Door":             makeDoorAspect,
+		"Fluid":            makeFluidAspect,
+		"Furnace":          makeFurnaceAspect,
+		"Lever":            makeLeverAspect,
+		"MobSpawner":       makeMobSpawnerAspect,
+		"Music":            makeMusicAspect,
+		"Portal":           makePortalAspect,
+		"PressurePlate":    makePressurePlateAspect,
+		"RecordPlayer":     makeRecordPlayerAspect,
+		"RedstoneRepeater": makeRedstoneRepeaterAspect,
+		"RedstoneTorch":    makeRedstoneTorchAspect,
+		"RedstoneWire":     makeRedstoneWireAspect,
+		"Sapling":          makeSaplingAspect,
+		"Sign":             makeSignAspect,
+		"Standard":         makeStandardAspect,
+		"Todo":             makeTodoAspect,
+		"Tnt":              makeTntAspect,
+		"Void":             makeVoidAspect,
+		"Workbench":        makeWorkbenchAspect,
 	}
 }
@@ -1,12 +1,34 @@
 package gamerules
 
 import (
+	"bytes"
 	"errors"
 
+	"chunkymonkey/proto"
 	. "chunkymonkey/types"
 	"nbt"
 )
 
+const (
+	// mobSpawnerActivationRange is the distance within which a player must be
+	// for a mob spawner to count down and spawn mobs.
+	mobSpawnerActivationRange = 16
+	// mobSpawnerAttempts is the number of mobs that a spawner tries to spawn
+	// each time that its delay runs out.
+	mobSpawnerAttempts = 4
+	// mobSpawnerSpread is the furthest that mobs spawn from the spawner
+	// horizontally.
+	mobSpawnerSpread = 4
+	// mobSpawnerMaxMobs is the number of mobs of the spawner's type within
+	// mobSpawnerCapRange that stops it spawning more.
+	mobSpawnerMaxMobs  = 6
+	mobSpawnerCapRange = 8
+	// mobSpawnerMinDelay and mobSpawnerMaxDelay bound the time between a
+	// spawner spawning mobs.
+	mobSpawnerMinDelay = Ticks(200)
+	mobSpawnerMaxDelay = Ticks(600)
+)
+
 func makeMobSpawnerAspect() (aspect IBlockAspect) {
 	return &MobSpawnerAspect{}
 }
@@ -61,4 +83,83 @@ func (aspect MobSpawnerAspect) Name() string {
 	return "MobSpawner"
 }
 
-// TODO behaviours for mob spawners.
+func (aspect *MobSpawnerAspect) Tick(instance *BlockInstance) bool {
+	mobSpawner, ok := instance.Chunk.TileEntity(instance.Index).(*mobSpawnerTileEntity)
+	if !ok {
+		return false
+	}
+
+	return mobSpawner.tick(instance)
+}
+
+// tick counts down the spawner's delay while a player is nearby, and spawns
+// mobs when it runs out. The delay is reset once a mob has spawned, or when
+// there are already enough mobs around, otherwise the spawner tries again on
+// the next tick. It returns false if the spawner can never spawn anything.
+func (mobSpawner *mobSpawnerTileEntity) tick(instance *BlockInstance) bool {
+	create, ok := EntityCreateByName[mobSpawner.entityMobType]
+	if !ok {
+		return false
+	}
+
+	center := AbsXyz{
+		AbsCoord(instance.BlockLoc.X) + 0.5,
+		AbsCoord(instance.BlockLoc.Y) + 0.5,
+		AbsCoord(instance.BlockLoc.Z) + 0.5,
+	}
+	if _, _, ok := instance.Chunk.NearestPlayer(&center, mobSpawnerActivationRange); !ok {
+		return true
+	}
+
+	if mobSpawner.delay < 0 {
+		mobSpawner.resetDelay(instance.Chunk)
+	}
+	if mobSpawner.delay > 0 {
+		mobSpawner.delay--
+		return true
+	}
+
+	rand := instance.Chunk.Rand()
+	reset := false
+	for i := 0; i < mobSpawnerAttempts; i++ {
+		mob, ok := create().(IMob)
+		if !ok {
+			return false
+		}
+
+		if instance.Chunk.CountMobs(mob.MobType(), &center, mobSpawnerCapRange) >= mobSpawnerMaxMobs {
+			reset = true
+			break
+		}
+
+		position := AbsXyz{
+			center.X + AbsCoord((rand.Float64()-rand.Float64())*mobSpawnerSpread),
+			AbsCoord(instance.BlockLoc.Y + BlockYCoord(rand.Intn(3)-1)),
+			center.Z + AbsCoord((rand.Float64()-rand.Float64())*mobSpawnerSpread),
+		}
+		blockLoc := position.ToBlockXyz()
+		site, feet, ok := mobSpawnSiteAt(instance.Chunk, blockLoc)
+		if !ok || !site.suits(mob.MobType()) {
+			continue
+		}
+
+		mob.SetPosition(&position)
+		feet.Chunk.AddEntity(mob)
+
+		buf := new(bytes.Buffer)
+		proto.WriteSoundEffect(buf, SoundEffectMobSpawn, *blockLoc, 0)
+		instance.Chunk.MulticastPlayers(-1, buf.Bytes())
+		reset = true
+	}
+
+	if reset {
+		mobSpawner.resetDelay(instance.Chunk)
+	}
+
+	return true
+}
+
+// resetDelay chooses a random time until the spawner next spawns mobs.
+func (mobSpawner *mobSpawnerTileEntity) resetDelay(chunk IChunkBlock) {
+	mobSpawner.delay = mobSpawnerMinDelay + Ticks(chunk.Rand().Intn(int(mobSpawnerMaxDelay-mobSpawnerMinDelay)))
+}
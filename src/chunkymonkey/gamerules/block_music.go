@@ -1,8 +1,10 @@
 package gamerules
 
 import (
+	"bytes"
 	"errors"
 
+	"chunkymonkey/proto"
 	. "chunkymonkey/types"
 	"nbt"
 )
@@ -45,6 +47,8 @@ func (music *musicTileEntity) MarshalNbt(tag *nbt.Compound) (err error) {
 	return nil
 }
 
+// MusicAspect is the behaviour of a note block, which plays its note when it
+// becomes powered. Players change the note by interacting with it.
 type MusicAspect struct {
 	StandardAspect
 }
@@ -53,4 +57,36 @@ func (aspect MusicAspect) Name() string {
 	return "Music"
 }
 
-// TODO behaviours for music.
+func (aspect *MusicAspect) Interact(instance *BlockInstance, player IPlayerClient) {
+	music := aspect.tileEntity(instance)
+	music.note++
+	if music.note > NotePitchMax {
+		music.note = NotePitchMin
+	}
+	aspect.play(instance, music)
+}
+
+func (aspect *MusicAspect) PowerChanged(instance *BlockInstance, powered, wasPowered bool) {
+	if powered && !wasPowered {
+		aspect.play(instance, aspect.tileEntity(instance))
+	}
+}
+
+func (aspect *MusicAspect) play(instance *BlockInstance, music *musicTileEntity) {
+	// TODO choose the instrument from the block beneath the note block.
+	buf := new(bytes.Buffer)
+	proto.WriteNoteBlockPlay(buf, &instance.BlockLoc, InstrumentIdHarp, music.note)
+	instance.Chunk.MulticastPlayers(-1, buf.Bytes())
+}
+
+// tileEntity returns the block's tile entity, creating it if necessary.
+func (aspect *MusicAspect) tileEntity(instance *BlockInstance) *musicTileEntity {
+	music, ok := instance.Chunk.TileEntity(instance.Index).(*musicTileEntity)
+	if !ok {
+		music = &musicTileEntity{}
+		music.blockLoc = instance.BlockLoc
+		music.SetChunk(instance.Chunk)
+		instance.Chunk.SetTileEntity(instance.Index, music)
+	}
+	return music
+}
@@ -0,0 +1,294 @@
+package gamerules
+
+import (
+	. "chunkymonkey/types"
+)
+
+const (
+	blockIdObsidian = BlockId(49)
+	blockIdPortal   = BlockId(90)
+
+	// ItemIdFlintAndSteel is the item that lights portals.
+	ItemIdFlintAndSteel = ItemTypeId(259)
+
+	// The size of the inside of a portal frame.
+	portalWidth  = 2
+	portalHeight = 3
+
+	// portalSearchRadius is how far along the x and z axes that LinkPortal
+	// looks for an existing portal.
+	portalSearchRadius = 16
+)
+
+// portalAxes are the directions along which portals can lie, as dx, dz.
+var portalAxes = [][2]BlockCoord{{1, 0}, {0, 1}}
+
+// IPlayerEnteredAspect is implemented by the aspects of block types that react
+// to players moving into them.
+type IPlayerEnteredAspect interface {
+	// PlayerEntered is called when the player moves into the block. It is
+	// called within the goroutine of the chunk that the player is in.
+	PlayerEntered(instance *BlockInstance, player IPlayerClient)
+}
+
+func makePortalAspect() (aspect IBlockAspect) {
+	return &PortalAspect{}
+}
+
+// PortalAspect is the behaviour of the blocks inside a lit portal frame.
+// Players that walk into them are taken to the other dimension. The portal
+// breaks when its frame does.
+type PortalAspect struct {
+	VoidAspect
+}
+
+func (aspect *PortalAspect) Name() string {
+	return "Portal"
+}
+
+func (aspect *PortalAspect) PlayerEntered(instance *BlockInstance, player IPlayerClient) {
+	player.EnterPortal()
+}
+
+// Tick is called when a neighbouring block changes, and breaks the portal
+// block if it is no longer held within the frame.
+func (aspect *PortalAspect) Tick(instance *BlockInstance) bool {
+	loc := &instance.BlockLoc
+	held := isPortalOrFrame(instance.Chunk, loc.AddXyz(0, -1, 0)) &&
+		isPortalOrFrame(instance.Chunk, loc.AddXyz(0, 1, 0))
+	if held {
+		for _, axis := range portalAxes {
+			dx, dz := axis[0], axis[1]
+			if isPortalOrFrame(instance.Chunk, loc.AddXyz(-dx, 0, -dz)) &&
+				isPortalOrFrame(instance.Chunk, loc.AddXyz(dx, 0, dz)) {
+				return false
+			}
+		}
+	}
+
+	instance.Chunk.SetBlockByIndex(instance.Index, BlockIdAir, 0)
+	// The rest of the portal breaks in turn.
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		if neighbour := loc.AddXyz(face.Dxyz()); neighbour != nil {
+			instance.Chunk.AddActiveBlock(neighbour)
+		}
+	}
+	return false
+}
+
+// isPortalOrFrame returns true if the block is part of a portal or its frame.
+// Blocks that aren't available are assumed to be.
+func isPortalOrFrame(chunk IChunkBlock, loc *BlockXyz) bool {
+	if loc == nil {
+		return false
+	}
+	instance, ok := chunk.BlockAt(loc)
+	if !ok {
+		return true
+	}
+	return instance.BlockType.id == blockIdPortal || instance.BlockType.id == blockIdObsidian
+}
+
+// LightPortal fills an obsidian frame with portal blocks, if fireLoc is inside
+// an empty frame. This happens when flint and steel is used on the frame.
+func LightPortal(chunk IChunkBlock, fireLoc *BlockXyz) bool {
+	for _, axis := range portalAxes {
+		dx, dz := axis[0], axis[1]
+		// The fire can be in any block of the inside of the frame.
+		for i := BlockCoord(0); i < portalWidth; i++ {
+			for j := BlockYCoord(0); j < portalHeight; j++ {
+				corner := fireLoc.AddXyz(-dx*i, -j, -dz*i)
+				if corner == nil || !isEmptyPortalFrame(chunk, corner, dx, dz) {
+					continue
+				}
+				forPortal(corner, dx, dz, false, func(loc *BlockXyz, frame bool) bool {
+					if !frame {
+						setBlockAt(chunk, loc, blockIdPortal)
+					}
+					return true
+				})
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isEmptyPortalFrame returns true if there is an obsidian frame around the
+// inside of a portal that is full of air. corner is the lowest block of the
+// inside, which lies along dx, dz from it. The frame's corners needn't be
+// obsidian.
+func isEmptyPortalFrame(chunk IChunkBlock, corner *BlockXyz, dx, dz BlockCoord) bool {
+	return forPortal(corner, dx, dz, false, func(loc *BlockXyz, frame bool) bool {
+		instance, ok := chunk.BlockAt(loc)
+		if !ok {
+			return false
+		}
+		if frame {
+			return instance.BlockType.id == blockIdObsidian
+		}
+		return instance.BlockType.id == BlockIdAir
+	})
+}
+
+// forPortal calls fn for each block of the portal whose inside has its lowest
+// block at corner, and lies along dx, dz. frame is true for the blocks of the
+// frame. The corners of the frame are only included if corners is true. It
+// stops and returns false if fn returns false.
+func forPortal(corner *BlockXyz, dx, dz BlockCoord, corners bool, fn func(loc *BlockXyz, frame bool) bool) bool {
+	for i := BlockCoord(-1); i <= portalWidth; i++ {
+		for j := BlockYCoord(-1); j <= portalHeight; j++ {
+			isSide := i == -1 || i == portalWidth
+			isEnd := j == -1 || j == portalHeight
+			if isSide && isEnd && !corners {
+				continue
+			}
+			loc := corner.AddXyz(dx*i, j, dz*i)
+			if loc == nil || !fn(loc, isSide || isEnd) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func setBlockAt(chunk IChunkBlock, loc *BlockXyz, blockId BlockId) {
+	if instance, ok := chunk.BlockAt(loc); ok {
+		instance.Chunk.SetBlockByIndex(instance.Index, blockId, 0)
+	}
+}
+
+// LinkPortal finds the portal nearest to target, building one at target if
+// there is none nearby. It returns where a player that has come through a
+// portal from the other dimension arrives, which is beside the portal, facing
+// away from it.
+func LinkPortal(chunk IChunkBlock, target *BlockXyz) (position AbsXyz, look LookDegrees) {
+	corner, axis, ok := findPortal(chunk, target)
+	if !ok {
+		corner, axis = *target, portalAxes[0]
+		if corner.Y < 2 {
+			corner.Y = 2
+		} else if corner.Y > MaxYCoord-portalHeight-2 {
+			corner.Y = MaxYCoord - portalHeight - 2
+		}
+		buildPortal(chunk, &corner, axis[0], axis[1])
+	}
+
+	// The player arrives on whichever side of the portal is clear.
+	normalX, normalZ := axis[1], axis[0]
+	for _, side := range []BlockCoord{1, -1} {
+		dx, dz := normalX*side, normalZ*side
+		arrival := corner.AddXyz(dx, 0, dz)
+		if arrival == nil {
+			continue
+		}
+		position = AbsXyz{
+			X: AbsCoord(arrival.X) + 0.5,
+			Y: AbsCoord(arrival.Y),
+			Z: AbsCoord(arrival.Z) + 0.5,
+		}
+		look = LookDegrees{Yaw: yawFacing(dx, dz)}
+		if !isSolid(chunk, arrival) && !isSolid(chunk, arrival.AddXyz(0, 1, 0)) {
+			break
+		}
+	}
+	return
+}
+
+// findPortal finds the portal nearest to target, returning the lowest block
+// of its inside and the axis that it lies along.
+func findPortal(chunk IChunkBlock, target *BlockXyz) (corner BlockXyz, axis [2]BlockCoord, ok bool) {
+	bestDistSq := int64(-1)
+	var loc BlockXyz
+	for dx := BlockCoord(-portalSearchRadius); dx <= portalSearchRadius; dx++ {
+		for dz := BlockCoord(-portalSearchRadius); dz <= portalSearchRadius; dz++ {
+			loc.X, loc.Z = target.X+dx, target.Z+dz
+			for loc.Y = 1; loc.Y < MaxYCoord; loc.Y++ {
+				if !isBlock(chunk, &loc, blockIdPortal) || isBlock(chunk, loc.AddXyz(0, -1, 0), blockIdPortal) {
+					continue
+				}
+				dy := int64(loc.Y) - int64(target.Y)
+				distSq := int64(dx)*int64(dx) + dy*dy + int64(dz)*int64(dz)
+				if bestDistSq < 0 || distSq < bestDistSq {
+					bestDistSq, corner = distSq, loc
+				}
+			}
+		}
+	}
+	if bestDistSq < 0 {
+		return
+	}
+
+	for _, axis = range portalAxes {
+		dx, dz := axis[0], axis[1]
+		if isBlock(chunk, corner.AddXyz(-dx, 0, -dz), blockIdPortal) {
+			return *corner.AddXyz(-dx, 0, -dz), axis, true
+		}
+		if isBlock(chunk, corner.AddXyz(dx, 0, dz), blockIdPortal) {
+			return corner, axis, true
+		}
+	}
+	return corner, portalAxes[0], true
+}
+
+// buildPortal builds a lit portal whose inside has its lowest block at
+// corner, and lies along dx, dz. Space is cleared on either side of it, with
+// a floor to stand on.
+func buildPortal(chunk IChunkBlock, corner *BlockXyz, dx, dz BlockCoord) {
+	normalX, normalZ := dz, dx
+	for _, side := range []BlockCoord{1, -1} {
+		sideCorner := corner.AddXyz(normalX*side, 0, normalZ*side)
+		if sideCorner == nil {
+			continue
+		}
+		forPortal(sideCorner, dx, dz, true, func(loc *BlockXyz, frame bool) bool {
+			switch {
+			case loc.Y < sideCorner.Y:
+				if !isSolid(chunk, loc) {
+					setBlockAt(chunk, loc, blockIdObsidian)
+				}
+			case !frame && !isBlock(chunk, loc, BlockIdAir):
+				setBlockAt(chunk, loc, BlockIdAir)
+			}
+			return true
+		})
+	}
+
+	forPortal(corner, dx, dz, true, func(loc *BlockXyz, frame bool) bool {
+		if frame {
+			setBlockAt(chunk, loc, blockIdObsidian)
+		} else {
+			setBlockAt(chunk, loc, blockIdPortal)
+		}
+		return true
+	})
+}
+
+func isBlock(chunk IChunkBlock, loc *BlockXyz, blockId BlockId) bool {
+	if loc == nil {
+		return false
+	}
+	instance, ok := chunk.BlockAt(loc)
+	return ok && instance.BlockType.id == blockId
+}
+
+func isSolid(chunk IChunkBlock, loc *BlockXyz) bool {
+	if loc == nil {
+		return true
+	}
+	instance, ok := chunk.BlockAt(loc)
+	return !ok || instance.BlockType.Solid
+}
+
+// yawFacing returns the yaw of a player facing along dx, dz.
+func yawFacing(dx, dz BlockCoord) AngleDegrees {
+	switch {
+	case dz > 0:
+		return 0
+	case dx < 0:
+		return 90
+	case dz < 0:
+		return 180
+	}
+	return 270
+}
@@ -0,0 +1,133 @@
+package gamerules
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+// testPortalChunk is an IChunkBlock holding blocks at any location. Blocks
+// that haven't been set are air.
+type testPortalChunk struct {
+	IChunkBlock
+	blocks map[BlockXyz]BlockId
+	locs   []BlockXyz // The locations of the BlockIndex values given out.
+}
+
+func newTestPortalChunk() *testPortalChunk {
+	return &testPortalChunk{blocks: make(map[BlockXyz]BlockId)}
+}
+
+func (chunk *testPortalChunk) BlockAt(loc *BlockXyz) (instance BlockInstance, ok bool) {
+	blockType, ok := Blocks.Get(chunk.blocks[*loc])
+	chunk.locs = append(chunk.locs, *loc)
+	instance = BlockInstance{
+		Chunk:     chunk,
+		BlockLoc:  *loc,
+		Index:     BlockIndex(len(chunk.locs) - 1),
+		BlockType: blockType,
+	}
+	return
+}
+
+func (chunk *testPortalChunk) SetBlockByIndex(index BlockIndex, blockId BlockId, blockData byte) {
+	chunk.blocks[chunk.locs[index]] = blockId
+}
+
+// frame builds an obsidian frame around the inside of a portal, whose lowest
+// block is at corner and lies along the x axis.
+func (chunk *testPortalChunk) frame(corner BlockXyz) {
+	forPortal(&corner, 1, 0, false, func(loc *BlockXyz, frame bool) bool {
+		if frame {
+			chunk.blocks[*loc] = blockIdObsidian
+		}
+		return true
+	})
+}
+
+func (chunk *testPortalChunk) count(blockId BlockId) (n int) {
+	for _, id := range chunk.blocks {
+		if id == blockId {
+			n++
+		}
+	}
+	return
+}
+
+func TestLightPortal(t *testing.T) {
+	corner := BlockXyz{10, 64, -5}
+
+	tests := []struct {
+		name    string
+		fire    BlockXyz
+		blocked *BlockXyz
+		lit     bool
+	}{
+		{"corner", corner, nil, true},
+		{"top", BlockXyz{11, 66, -5}, nil, true},
+		{"outside", BlockXyz{10, 64, -4}, nil, false},
+		{"above", BlockXyz{10, 67, -5}, nil, false},
+		{"blocked", corner, &BlockXyz{11, 65, -5}, false},
+	}
+
+	for _, test := range tests {
+		chunk := newTestPortalChunk()
+		chunk.frame(corner)
+		if test.blocked != nil {
+			chunk.blocks[*test.blocked] = BlockId(1)
+		}
+
+		if lit := LightPortal(chunk, &test.fire); lit != test.lit {
+			t.Errorf("%s: LightPortal() = %t, want %t", test.name, lit, test.lit)
+		}
+		wantPortals := 0
+		if test.lit {
+			wantPortals = portalWidth * portalHeight
+		}
+		if n := chunk.count(blockIdPortal); n != wantPortals {
+			t.Errorf("%s: got %d portal blocks, want %d", test.name, n, wantPortals)
+		}
+	}
+}
+
+func TestLinkPortal(t *testing.T) {
+	// A portal is found near the target.
+	chunk := newTestPortalChunk()
+	corner := BlockXyz{20, 70, 5}
+	chunk.frame(corner)
+	LightPortal(chunk, &corner)
+
+	position, look := LinkPortal(chunk, &BlockXyz{24, 64, 8})
+	if want := (AbsXyz{20.5, 70, 6.5}); position != want || look.Yaw != 0 {
+		t.Errorf("LinkPortal() = %v, %v, want %v facing +z", position, look, want)
+	}
+	if n := chunk.count(blockIdPortal); n != portalWidth*portalHeight {
+		t.Errorf("got %d portal blocks, want %d", n, portalWidth*portalHeight)
+	}
+
+	// A portal is built at the target when there is none, and the player
+	// arrives beside it.
+	chunk = newTestPortalChunk()
+	target := BlockXyz{-100, 40, 100}
+	position, _ = LinkPortal(chunk, &target)
+	if want := (AbsXyz{-99.5, 40, 101.5}); position != want {
+		t.Errorf("LinkPortal() = %v, want %v", position, want)
+	}
+	if n := chunk.count(blockIdPortal); n != portalWidth*portalHeight {
+		t.Errorf("got %d portal blocks built, want %d", n, portalWidth*portalHeight)
+	}
+	if !isEmptyPortalFrame(newTestPortalChunkFrom(chunk, blockIdPortal), &target, 1, 0) {
+		t.Errorf("the built portal has no frame")
+	}
+}
+
+// newTestPortalChunkFrom copies the chunk, replacing blockId with air.
+func newTestPortalChunkFrom(chunk *testPortalChunk, blockId BlockId) *testPortalChunk {
+	copied := newTestPortalChunk()
+	for loc, id := range chunk.blocks {
+		if id != blockId {
+			copied.blocks[loc] = id
+		}
+	}
+	return copied
+}
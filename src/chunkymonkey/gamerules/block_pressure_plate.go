@@ -0,0 +1,45 @@
+package gamerules
+
+import (
+	. "chunkymonkey/types"
+)
+
+// The bit of pressure plate data that is set when it is pressed.
+const platePressedBit = 0x1
+
+func makePressurePlateAspect() (aspect IBlockAspect) {
+	return &PressurePlateAspect{}
+}
+
+// PressurePlateAspect is the behaviour of a pressure plate, which provides
+// power while players or mobs are on it. If AnyEntity is true then other
+// entities such as items also press it.
+type PressurePlateAspect struct {
+	StandardAspect
+	AnyEntity bool
+}
+
+func (aspect *PressurePlateAspect) Name() string {
+	return "PressurePlate"
+}
+
+func (aspect *PressurePlateAspect) Tick(instance *BlockInstance) bool {
+	pressed := instance.Chunk.IsBlockOccupied(&instance.BlockLoc, aspect.AnyEntity)
+	if pressed != (instance.Data&platePressedBit != 0) {
+		var data byte
+		if pressed {
+			data = platePressedBit
+		}
+		instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, data)
+	}
+
+	// Keep checking while pressed, to notice when it is released.
+	return pressed
+}
+
+func (aspect *PressurePlateAspect) PowerOutput(instance *BlockInstance, face Face) Power {
+	if instance.Data&platePressedBit == 0 {
+		return Power{}
+	}
+	return Power{Level: MaxPowerLevel}
+}
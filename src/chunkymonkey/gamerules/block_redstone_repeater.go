@@ -0,0 +1,84 @@
+package gamerules
+
+import (
+	. "chunkymonkey/types"
+)
+
+const (
+	repeaterFacingMask = 0x3
+	repeaterDelayShift = 2
+	repeaterDelayMask  = 0x3
+	// Ticks of delay for each delay setting of a repeater.
+	repeaterTicksPerDelay = 2
+)
+
+func makeRedstoneRepeaterAspect() (aspect IBlockAspect) {
+	return &RedstoneRepeaterAspect{}
+}
+
+// RedstoneRepeaterAspect is the behaviour of a redstone repeater. A repeater
+// takes power from behind it and provides full power in front of it, after a
+// delay set by the upper 2 bits of its data.
+type RedstoneRepeaterAspect struct {
+	StandardAspect
+	On  BlockId
+	Off BlockId
+}
+
+func (aspect *RedstoneRepeaterAspect) Name() string {
+	return "RedstoneRepeater"
+}
+
+func (aspect *RedstoneRepeaterAspect) Interact(instance *BlockInstance, player IPlayerClient) {
+	// Cycle through the delay settings.
+	delay := (instance.Data>>repeaterDelayShift + 1) & repeaterDelayMask
+	data := instance.Data&repeaterFacingMask | delay<<repeaterDelayShift
+	instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, data)
+}
+
+func (aspect *RedstoneRepeaterAspect) PowerOutput(instance *BlockInstance, face Face) Power {
+	if instance.BlockType.id != aspect.On || face != repeaterFront(instance.Data) {
+		return Power{}
+	}
+	return Power{Level: MaxPowerLevel}
+}
+
+func (aspect *RedstoneRepeaterAspect) PowerChanged(instance *BlockInstance, powered, wasPowered bool) {
+	if aspect.isOn(instance) != aspect.isInputPowered(instance) {
+		delay := Ticks(instance.Data>>repeaterDelayShift&repeaterDelayMask+1) * repeaterTicksPerDelay
+		instance.Chunk.AddDelayedActiveBlockIndex(instance.Index, delay)
+	}
+}
+
+func (aspect *RedstoneRepeaterAspect) Tick(instance *BlockInstance) bool {
+	if inputPowered := aspect.isInputPowered(instance); inputPowered != aspect.isOn(instance) {
+		blockTypeId := aspect.Off
+		if inputPowered {
+			blockTypeId = aspect.On
+		}
+		instance.Chunk.SetBlockByIndex(instance.Index, blockTypeId, instance.Data)
+	}
+	return false
+}
+
+func (aspect *RedstoneRepeaterAspect) isOn(instance *BlockInstance) bool {
+	return instance.BlockType.id == aspect.On
+}
+
+func (aspect *RedstoneRepeaterAspect) isInputPowered(instance *BlockInstance) bool {
+	back := repeaterFront(instance.Data).Opposite()
+	return instance.Chunk.NeighbourPower(&instance.BlockLoc, back).Level > 0
+}
+
+// repeaterFront returns the face of a repeater that provides power.
+func repeaterFront(blockData byte) Face {
+	switch blockData & repeaterFacingMask {
+	case 0:
+		return FaceEast
+	case 1:
+		return FaceSouth
+	case 2:
+		return FaceWest
+	}
+	return FaceNorth
+}
@@ -0,0 +1,65 @@
+package gamerules
+
+import (
+	"fmt"
+
+	. "chunkymonkey/types"
+)
+
+func makeRedstoneTorchAspect() (aspect IBlockAspect) {
+	return &RedstoneTorchAspect{}
+}
+
+// RedstoneTorchAspect is the behaviour of a redstone torch. A torch provides
+// power unless the block that it is attached to is powered, in which case it
+// turns off after Delay ticks.
+type RedstoneTorchAspect struct {
+	StandardAspect
+	On    BlockId
+	Off   BlockId
+	Delay Ticks
+}
+
+func (aspect *RedstoneTorchAspect) Name() string {
+	return "RedstoneTorch"
+}
+
+func (aspect *RedstoneTorchAspect) Check() error {
+	if aspect.Delay < 1 {
+		return fmt.Errorf("block %q: torch Delay must be at least 1", aspect.blockAttrs.Name)
+	}
+	return aspect.StandardAspect.Check()
+}
+
+func (aspect *RedstoneTorchAspect) PowerOutput(instance *BlockInstance, face Face) Power {
+	if instance.BlockType.id != aspect.On || face == attachedFace(instance.Data) {
+		return Power{}
+	}
+	return Power{Level: MaxPowerLevel}
+}
+
+func (aspect *RedstoneTorchAspect) PowerChanged(instance *BlockInstance, powered, wasPowered bool) {
+	if aspect.isOn(instance) != aspect.shouldBeOn(instance) {
+		instance.Chunk.AddDelayedActiveBlockIndex(instance.Index, aspect.Delay)
+	}
+}
+
+func (aspect *RedstoneTorchAspect) Tick(instance *BlockInstance) bool {
+	if shouldBeOn := aspect.shouldBeOn(instance); shouldBeOn != aspect.isOn(instance) {
+		blockTypeId := aspect.Off
+		if shouldBeOn {
+			blockTypeId = aspect.On
+		}
+		instance.Chunk.SetBlockByIndex(instance.Index, blockTypeId, instance.Data)
+	}
+	return false
+}
+
+func (aspect *RedstoneTorchAspect) isOn(instance *BlockInstance) bool {
+	return instance.BlockType.id == aspect.On
+}
+
+func (aspect *RedstoneTorchAspect) shouldBeOn(instance *BlockInstance) bool {
+	power := instance.Chunk.NeighbourPower(&instance.BlockLoc, attachedFace(instance.Data))
+	return power.Level == 0
+}
@@ -0,0 +1,47 @@
+package gamerules
+
+import (
+	. "chunkymonkey/types"
+)
+
+func makeRedstoneWireAspect() (aspect IBlockAspect) {
+	return &RedstoneWireAspect{}
+}
+
+// RedstoneWireAspect is the behaviour of redstone wire. The block data is the
+// level of power carried by the wire.
+type RedstoneWireAspect struct {
+	StandardAspect
+}
+
+func (aspect *RedstoneWireAspect) Name() string {
+	return "RedstoneWire"
+}
+
+func (aspect *RedstoneWireAspect) PowerOutput(instance *BlockInstance, face Face) Power {
+	if face == FaceTop {
+		return Power{}
+	}
+	return Power{Level: instance.Data, FromWire: true}
+}
+
+func (aspect *RedstoneWireAspect) PowerChanged(instance *BlockInstance, powered, wasPowered bool) {
+	var level byte
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		power := instance.Chunk.NeighbourPower(&instance.BlockLoc, face)
+		received := power.Level
+		if power.FromWire {
+			if power.Conducted || received == 0 {
+				continue
+			}
+			received--
+		}
+		if received > level {
+			level = received
+		}
+	}
+
+	if level != instance.Data {
+		instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, level)
+	}
+}
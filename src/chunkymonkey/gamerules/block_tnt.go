@@ -0,0 +1,104 @@
+package gamerules
+
+import (
+	"bytes"
+	"fmt"
+
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+const (
+	tntPrimedBit = 0x1
+	// The proportion of blocks destroyed by an explosion that drop items.
+	explosionDropChance = 0.3
+)
+
+func makeTntAspect() (aspect IBlockAspect) {
+	return &TntAspect{}
+}
+
+// TntAspect is the behaviour of TNT. TNT is primed by redstone power, and
+// explodes Fuse ticks later.
+type TntAspect struct {
+	StandardAspect
+	Fuse Ticks
+	// Power is the radius of the explosion in blocks.
+	Power float32
+}
+
+func (aspect *TntAspect) Name() string {
+	return "Tnt"
+}
+
+func (aspect *TntAspect) Check() error {
+	if aspect.Fuse < 1 {
+		return fmt.Errorf("block %q: TNT Fuse must be at least 1", aspect.blockAttrs.Name)
+	}
+	return aspect.StandardAspect.Check()
+}
+
+func (aspect *TntAspect) PowerChanged(instance *BlockInstance, powered, wasPowered bool) {
+	if powered && !wasPowered {
+		aspect.prime(instance, aspect.Fuse)
+	}
+}
+
+func (aspect *TntAspect) Tick(instance *BlockInstance) bool {
+	if instance.Data&tntPrimedBit != 0 {
+		instance.Chunk.SetBlockByIndex(instance.Index, BlockIdAir, 0)
+		explode(instance.Chunk, &instance.BlockLoc, aspect.Power)
+	}
+	return false
+}
+
+func (aspect *TntAspect) prime(instance *BlockInstance, fuse Ticks) {
+	if instance.Data&tntPrimedBit != 0 {
+		return
+	}
+	instance.Chunk.SetBlockByIndex(instance.Index, instance.BlockType.id, tntPrimedBit)
+	instance.Chunk.AddDelayedActiveBlockIndex(instance.Index, fuse)
+}
+
+// explode destroys the blocks within radius of center, priming any TNT that it
+// reaches. Blocks outside of the chunk's shard are left intact.
+func explode(chunk IChunkBlock, center *BlockXyz, radius float32) {
+	rand := chunk.Rand()
+	r := int32(radius)
+	var offsets []proto.ExplosionOffsetXyz
+
+	for dx := -r; dx <= r; dx++ {
+		for dy := -r; dy <= r; dy++ {
+			for dz := -r; dz <= r; dz++ {
+				if float32(dx*dx+dy*dy+dz*dz) > radius*radius {
+					continue
+				}
+				blockLoc := center.AddXyz(BlockCoord(dx), BlockYCoord(dy), BlockCoord(dz))
+				if blockLoc == nil {
+					continue
+				}
+				target, ok := chunk.BlockAt(blockLoc)
+				if !ok || target.BlockType.id == BlockIdAir || !target.BlockType.Destructable {
+					continue
+				}
+
+				if tnt, ok := target.BlockType.Aspect.(*TntAspect); ok {
+					// Chain reactions have a shorter fuse.
+					tnt.prime(&target, 1+Ticks(rand.Int31n(int32(tnt.Fuse/2)+1)))
+					continue
+				}
+
+				if rand.Float32() < explosionDropChance {
+					target.BlockType.Aspect.Destroy(&target)
+				}
+				target.Chunk.SetBlockByIndex(target.Index, BlockIdAir, 0)
+				offsets = append(offsets, proto.ExplosionOffsetXyz{int8(dx), int8(dy), int8(dz)})
+			}
+		}
+	}
+
+	position := center.MidPointToAbsXyz()
+	buf := new(bytes.Buffer)
+	proto.WriteExplosion(buf, &position, radius, offsets)
+	chunk.MulticastPlayers(-1, buf.Bytes())
+}
@@ -5,14 +5,21 @@ import (
 )
 
 type BlockAttrs struct {
-	id           BlockId
-	Name         string
-	Opacity      int8
-	defined      bool
-	Destructable bool
-	Solid        bool
-	Replaceable  bool
-	Attachable   bool
+	id            BlockId
+	Name          string
+	Opacity       int8
+	LightEmission int8
+	defined       bool
+	Destructable  bool
+	Solid         bool
+	Replaceable   bool
+	Attachable    bool
+	// Drowns is true if players breathing within the block drown.
+	Drowns bool
+	// ContactDamage is the damage done each second to players within the
+	// block, for the reason given by ContactCause.
+	ContactDamage Health
+	ContactCause  DamageCause
 }
 
 // The core information about any block type.
@@ -27,7 +34,7 @@ type BlockTypeList []BlockType
 // Get returns the requested BlockType by ID. ok = false if the block type does
 // not exist.
 func (btl *BlockTypeList) Get(id BlockId) (block *BlockType, ok bool) {
-	if id < 0 || int(id) > len(*btl) {
+	if id < 0 || int(id) >= len(*btl) {
 		ok = false
 		return
 	}
@@ -11,16 +11,26 @@ import (
 
 const twoBlocks = ("{\n" +
 	"  \"0\": {\n" +
+	"    \"BlockAttrs\": {\n" +
+	"      \"Name\": \"air\",\n" +
+	"      \"Opacity\": 0,\n" +
+	"      \"Destructable\": true,\n" +
+	"      \"Solid\": false,\n" +
+	"      \"Replaceable\": true,\n" +
+	"      \"Attachable\": false\n" +
+	"    },\n" +
 	"    \"Aspect\": \"Void\",\n" +
-	"    \"AspectArgs\": {},\n" +
-	"    \"Name\": \"air\",\n" +
-	"    \"Opacity\": 0,\n" +
-	"    \"Destructable\": true,\n" +
-	"    \"Solid\": false,\n" +
-	"    \"Replaceable\": true,\n" +
-	"    \"Attachable\": false\n" +
+	"    \"AspectArgs\": {}\n" +
 	"  },\n" +
 	"  \"1\": {\n" +
+	"    \"BlockAttrs\": {\n" +
+	"      \"Name\": \"stone\",\n" +
+	"      \"Opacity\": 15,\n" +
+	"      \"Destructable\": true,\n" +
+	"      \"Solid\": true,\n" +
+	"      \"Replaceable\": false,\n" +
+	"      \"Attachable\": true\n" +
+	"    },\n" +
 	"    \"Aspect\": \"Standard\",\n" +
 	"    \"AspectArgs\": {\n" +
 	"      \"DroppedItems\": [\n" +
@@ -31,29 +41,25 @@ const twoBlocks = ("{\n" +
 	"        }\n" +
 	"      ],\n" +
 	"      \"BreakOn\": 2\n" +
-	"    },\n" +
-	"    \"Name\": \"stone\",\n" +
-	"    \"Opacity\": 15,\n" +
-	"    \"Destructable\": true,\n" +
-	"    \"Solid\": true,\n" +
-	"    \"Replaceable\": false,\n" +
-	"    \"Attachable\": true\n" +
+	"    }\n" +
 	"  }\n" +
 	"}")
 
 const badAspect = ("{\n" +
 	"  \"0\": {\n" +
+	"    \"BlockAttrs\": {\n" +
+	"      \"Name\": \"air\",\n" +
+	"      \"Opacity\": 0,\n" +
+	"      \"Destructable\": true,\n" +
+	"      \"Solid\": false,\n" +
+	"      \"Replaceable\": true,\n" +
+	"      \"Attachable\": false\n" +
+	"    },\n" +
 	"    \"Aspect\": \"Standard\",\n" +
 	"    \"AspectArgs\": {\n" +
 	"      \"DroppedItems\": 5,\n" +
 	"      \"BreakOn\": \"foo\"\n" +
-	"    },\n" +
-	"    \"Name\": \"air\",\n" +
-	"    \"Opacity\": 0,\n" +
-	"    \"Destructable\": true,\n" +
-	"    \"Solid\": false,\n" +
-	"    \"Replaceable\": true,\n" +
-	"    \"Attachable\": false\n" +
+	"    }\n" +
 	"  }\n" +
 	"}")
 
@@ -0,0 +1,96 @@
+package gamerules
+
+import (
+	"bytes"
+	"math"
+
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+const (
+	// fistDamage is the damage done by hitting with an empty hand, or with an
+	// item that isn't a weapon or tool.
+	fistDamage = Health(1)
+
+	// knockbackSpeed and knockbackLift are the horizontal and vertical
+	// velocities given to entities that are hit. The lift keeps the fall
+	// within safe distance.
+	knockbackSpeed = 0.4
+	knockbackLift  = 0.4
+
+	// MaxAttackDistance is the furthest that players can hit entities from.
+	MaxAttackDistance = 6
+
+	// mobHurtTicks is the time after being hurt during which a mob cannot be
+	// hurt again.
+	mobHurtTicks = Ticks(10)
+	// mobDeathTicks is the time that a mob's body remains after it dies.
+	mobDeathTicks = Ticks(20)
+)
+
+// AttackDamage returns the damage done by hitting with the held item.
+func AttackDamage(held *Slot) Health {
+	if itemType := held.ItemType(); itemType != nil && itemType.Damage > 0 {
+		return itemType.Damage
+	}
+	return fistDamage
+}
+
+// Knockback returns the velocity given to an entity at target when hit by an
+// attacker at attacker.
+func Knockback(attacker, target *AbsXyz) AbsVelocity {
+	dx := float64(target.X - attacker.X)
+	dz := float64(target.Z - attacker.Z)
+	velocity := AbsVelocity{Y: knockbackLift}
+	if distance := math.Hypot(dx, dz); distance > 0 {
+		velocity.X = AbsVelocityCoord(dx / distance * knockbackSpeed)
+		velocity.Z = AbsVelocityCoord(dz / distance * knockbackSpeed)
+	}
+	return velocity
+}
+
+func (mob *Mob) Hurt(chunk IMobChunk, amount Health, cause DamageCause, knockback *AbsVelocity) (died bool) {
+	if mob.health <= 0 || mob.hurtTicks > 0 {
+		return false
+	}
+
+	mob.health -= amount
+	mob.hurtTicks = mobHurtTicks
+
+	velocity := *mob.Velocity()
+	velocity.X += knockback.X
+	velocity.Y += knockback.Y
+	velocity.Z += knockback.Z
+	mob.SetVelocity(&velocity)
+
+	status := EntityStatusHurt
+	if mob.health <= 0 {
+		status = EntityStatusDead
+		mob.behaviour = nil
+		mob.path = nil
+		mob.dropItems(chunk)
+	}
+
+	buf := new(bytes.Buffer)
+	proto.WriteEntityStatus(buf, mob.EntityId, status)
+	chunk.MulticastPlayers(-1, buf.Bytes())
+
+	if mob.health <= 0 {
+		chunk.MobDied(mob.EntityId, cause)
+		return true
+	}
+	return false
+}
+
+// dropItems creates the items that the mob drops when it dies.
+func (mob *Mob) dropItems(chunk IMobChunk) {
+	rand := chunk.Rand()
+	for _, drop := range mob.MobType().Drops {
+		count := drop.Min + ItemCount(rand.Intn(int(drop.Max-drop.Min)+1))
+		if count <= 0 {
+			continue
+		}
+		chunk.AddEntity(NewItem(drop.ItemTypeId, count, 0, mob.Position(), &AbsVelocity{}, 0))
+	}
+}
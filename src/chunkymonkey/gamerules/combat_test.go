@@ -0,0 +1,53 @@
+package gamerules
+
+import (
+	"math"
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestKnockback(t *testing.T) {
+	tests := []struct {
+		attacker, target AbsXyz
+		want             AbsVelocity
+	}{
+		{AbsXyz{0, 64, 0}, AbsXyz{2, 64, 0}, AbsVelocity{knockbackSpeed, knockbackLift, 0}},
+		{AbsXyz{0, 64, 0}, AbsXyz{0, 60, -3}, AbsVelocity{0, knockbackLift, -knockbackSpeed}},
+		{AbsXyz{1, 64, 1}, AbsXyz{0, 64, 0}, AbsVelocity{-knockbackSpeed / math.Sqrt2, knockbackLift, -knockbackSpeed / math.Sqrt2}},
+		{AbsXyz{5, 64, 5}, AbsXyz{5, 65, 5}, AbsVelocity{0, knockbackLift, 0}},
+	}
+
+	for _, test := range tests {
+		got := Knockback(&test.attacker, &test.target)
+		if math.Abs(float64(got.X-test.want.X)) > 1e-9 ||
+			math.Abs(float64(got.Y-test.want.Y)) > 1e-9 ||
+			math.Abs(float64(got.Z-test.want.Z)) > 1e-9 {
+			t.Errorf("Knockback(%v, %v) = %v, want %v", test.attacker, test.target, got, test.want)
+		}
+	}
+}
+
+func TestAttackDamage(t *testing.T) {
+	oldItems := Items
+	defer func() { Items = oldItems }()
+	Items = ItemTypeMap{
+		264: &ItemType{Id: 264, Name: "diamond"},
+		276: &ItemType{Id: 276, Name: "diamond sword", Damage: 7},
+	}
+
+	tests := []struct {
+		held Slot
+		want Health
+	}{
+		{Slot{}, fistDamage},
+		{Slot{ItemTypeId: 264, Count: 1}, fistDamage},
+		{Slot{ItemTypeId: 276, Count: 1}, 7},
+	}
+
+	for _, test := range tests {
+		if got := AttackDamage(&test.held); got != test.want {
+			t.Errorf("AttackDamage(%v) = %d, want %d", test.held, got, test.want)
+		}
+	}
+}
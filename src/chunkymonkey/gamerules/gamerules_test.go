@@ -1,7 +1,21 @@
 package gamerules
 
+import (
+	"path"
+)
+
+// testDataDir holds the game rules that the server is run with.
+const testDataDir = "../../.."
+
 func init() {
-	if err := LoadGameRules("blocks.json", "items.json", "recipes.json", "furnace.json", "users.json", "groups.json"); err != nil {
+	err := LoadGameRules(
+		path.Join(testDataDir, "blocks.json"),
+		path.Join(testDataDir, "items.json"),
+		path.Join(testDataDir, "recipes.json"),
+		path.Join(testDataDir, "furnace.json"),
+		path.Join(testDataDir, "users.json"),
+		path.Join(testDataDir, "groups.json"))
+	if err != nil {
 		panic(err)
 	}
 }
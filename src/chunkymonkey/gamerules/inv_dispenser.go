@@ -1,6 +1,9 @@
 package gamerules
 
 import (
+	"math/rand"
+
+	. "chunkymonkey/types"
 	"nbt"
 )
 
@@ -24,3 +27,25 @@ func (inv *DispenserInventory) MarshalNbt(tag *nbt.Compound) (err error) {
 	tag.Set("id", &nbt.String{"Trap"})
 	return inv.Inventory.MarshalNbt(tag)
 }
+
+// TakeRandomItem takes one item from a randomly chosen non-empty slot. ok=false
+// if the inventory is empty.
+func (inv *DispenserInventory) TakeRandomItem(rand *rand.Rand) (item Slot, ok bool) {
+	var chosen, candidates SlotId
+	for slotId := range inv.slots {
+		if inv.slots[slotId].IsEmpty() {
+			continue
+		}
+		candidates++
+		if rand.Intn(int(candidates)) == 0 {
+			chosen = SlotId(slotId)
+		}
+	}
+
+	if candidates == 0 {
+		return
+	}
+
+	inv.TakeOneItem(chosen, &item)
+	return item, true
+}
@@ -0,0 +1,46 @@
+package gamerules
+
+import (
+	"math/rand"
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestDispenserTakeRandomItem(t *testing.T) {
+	oldItems := Items
+	defer func() { Items = oldItems }()
+	Items = ItemTypeMap{
+		262: &ItemType{Id: 262, Name: "arrow", MaxStack: 64},
+		332: &ItemType{Id: 332, Name: "snowball", MaxStack: 16},
+	}
+
+	rand := rand.New(rand.NewSource(0))
+	inv := NewDispenserInventory()
+
+	if item, ok := inv.TakeRandomItem(rand); ok {
+		t.Fatalf("Expected nothing from an empty dispenser, got %v", item)
+	}
+
+	inv.slots[2] = Slot{ItemTypeId: 262, Count: 2}
+	inv.slots[7] = Slot{ItemTypeId: 332, Count: 1}
+
+	taken := make(map[ItemTypeId]ItemCount)
+	for i := 0; i < 3; i++ {
+		item, ok := inv.TakeRandomItem(rand)
+		if !ok {
+			t.Fatalf("Expected an item on take %d", i)
+		}
+		if item.Count != 1 {
+			t.Errorf("Expected to take a single item, got %v", item)
+		}
+		taken[item.ItemTypeId] += item.Count
+	}
+
+	if taken[262] != 2 || taken[332] != 1 {
+		t.Errorf("Expected to take 2 arrows and 1 snowball, got %v", taken)
+	}
+	if item, ok := inv.TakeRandomItem(rand); ok {
+		t.Errorf("Expected the dispenser to be empty, got %v", item)
+	}
+}
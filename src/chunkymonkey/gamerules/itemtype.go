@@ -16,6 +16,9 @@ type ItemType struct {
 	MaxStack ItemCount
 	ToolType ToolTypeId
 	ToolUses ItemData
+	// Damage is the damage done by hitting with the item. Zero means the same
+	// damage as an empty hand.
+	Damage Health
 }
 
 type ItemTypeMap map[ItemTypeId]*ItemType
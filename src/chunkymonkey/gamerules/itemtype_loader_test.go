@@ -88,3 +88,33 @@ func TestLoadItemDefs(t *testing.T) {
 		items[261],
 	)
 }
+
+const weaponItem = ("{\n" +
+	"  \"267\": {\n" +
+	"    \"Name\": \"iron sword\",\n" +
+	"    \"MaxStack\": 1,\n" +
+	"    \"ToolType\": 4,\n" +
+	"    \"ToolUses\": 251,\n" +
+	"    \"Damage\": 6\n" +
+	"  }\n" +
+	"}")
+
+func TestLoadItemDefsDamage(t *testing.T) {
+	items, err := LoadItemDefs(strings.NewReader(weaponItem))
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	assertItemTypeEq(
+		t,
+		&ItemType{
+			Id:       267,
+			Name:     "iron sword",
+			MaxStack: 1,
+			ToolType: 4,
+			ToolUses: 251,
+			Damage:   6,
+		},
+		items[267],
+	)
+}
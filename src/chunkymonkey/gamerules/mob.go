@@ -27,13 +27,29 @@ type Mob struct {
 	physics.PointObject
 	mobType EntityMobType
 	look    LookDegrees
+	health  Health
+	// hurtTicks counts down after the mob is hurt, and deathTicks counts up
+	// after it dies.
+	hurtTicks  Ticks
+	deathTicks Ticks
 	// TODO(nictuku): Move to a more structured form.
 	metadata map[byte]byte
 	// TODO: Change to an AABB object when we have that.
+
+	// behaviours are in order of priority, and behaviour is the one currently
+	// controlling the mob, if any.
+	behaviours []IMobBehaviour
+	behaviour  IMobBehaviour
+	// path is the blocks that the mob is walking through, and pathTicks is the
+	// number of ticks spent trying to reach the first of them.
+	path      []BlockXyz
+	pathTicks Ticks
 }
 
-func (mob *Mob) Init(id EntityMobType) {
+func (mob *Mob) Init(id EntityMobType, behaviours ...IMobBehaviour) {
 	mob.mobType = id
+	mob.behaviours = behaviours
+	mob.health = mob.MobType().MaxHealth
 	mob.metadata = map[byte]byte{
 		0:  byte(0),
 		16: byte(0),
@@ -51,13 +67,16 @@ func (mob *Mob) UnmarshalNbt(tag *nbt.Compound) (err error) {
 		return
 	}
 
+	if health, ok := tag.Lookup("Health").(*nbt.Short); ok && health.Value > 0 {
+		mob.health = Health(health.Value)
+	}
+
 	// TODO
 	_ = tag.Lookup("Air").(*nbt.Short).Value
 	_ = tag.Lookup("AttackTime").(*nbt.Short).Value
 	_ = tag.Lookup("DeathTime").(*nbt.Short).Value
 	_ = tag.Lookup("FallDistance").(*nbt.Float).Value
 	_ = tag.Lookup("Fire").(*nbt.Short).Value
-	_ = tag.Lookup("Health").(*nbt.Short).Value
 	_ = tag.Lookup("HurtTime").(*nbt.Short).Value
 
 	return nil
@@ -82,7 +101,7 @@ func (mob *Mob) MarshalNbt(tag *nbt.Compound) (err error) {
 	tag.Set("DeathTime", &nbt.Short{0})
 	tag.Set("FallDistance", &nbt.Float{0})
 	tag.Set("Fire", &nbt.Short{0})
-	tag.Set("Health", &nbt.Short{0})
+	tag.Set("Health", &nbt.Short{int16(mob.health)})
 	tag.Set("HurtTime", &nbt.Short{0})
 	return nil
 }
@@ -100,7 +119,6 @@ func (mob *Mob) SetBurning(burn bool) {
 }
 
 func (mob *Mob) Tick(blockQuerier physics.IBlockQuerier) (leftBlock bool) {
-	// TODO: Spontaneous mob movement.
 	return mob.PointObject.Tick(blockQuerier)
 }
 
@@ -159,7 +177,7 @@ var (
 
 func NewCreeper() INonPlayerEntity {
 	c := new(Creeper)
-	c.Mob.Init(CreeperType.Id)
+	c.Mob.Init(CreeperType.Id, &creeperBehaviour{}, newAttackBehaviour(0), &wanderBehaviour{})
 	c.Mob.metadata[17] = creeperNormal
 	c.Mob.metadata[16] = byte(255)
 	return c
@@ -179,7 +197,8 @@ type Skeleton struct {
 
 func NewSkeleton() INonPlayerEntity {
 	s := new(Skeleton)
-	s.Mob.Init(SkeletonType.Id)
+	// TODO Skeletons should shoot arrows rather than hitting players.
+	s.Mob.Init(SkeletonType.Id, newAttackBehaviour(3), &wanderBehaviour{})
 	return s
 }
 
@@ -189,7 +208,7 @@ type Spider struct {
 
 func NewSpider() INonPlayerEntity {
 	s := new(Spider)
-	s.Mob.Init(SpiderType.Id)
+	s.Mob.Init(SpiderType.Id, newAttackBehaviour(2), &wanderBehaviour{})
 	return s
 }
 
@@ -199,7 +218,7 @@ type Zombie struct {
 
 func NewZombie() INonPlayerEntity {
 	z := new(Zombie)
-	z.Mob.Init(ZombieType.Id)
+	z.Mob.Init(ZombieType.Id, newAttackBehaviour(4), &wanderBehaviour{})
 	return z
 }
 
@@ -211,7 +230,7 @@ type Pig struct {
 
 func NewPig() INonPlayerEntity {
 	p := new(Pig)
-	p.Mob.Init(PigType.Id)
+	p.Mob.Init(PigType.Id, &wanderBehaviour{})
 	return p
 }
 
@@ -221,7 +240,7 @@ type Sheep struct {
 
 func NewSheep() INonPlayerEntity {
 	s := new(Sheep)
-	s.Mob.Init(SheepType.Id)
+	s.Mob.Init(SheepType.Id, &wanderBehaviour{})
 	return s
 }
 
@@ -231,7 +250,7 @@ type Cow struct {
 
 func NewCow() INonPlayerEntity {
 	c := new(Cow)
-	c.Mob.Init(CowType.Id)
+	c.Mob.Init(CowType.Id, &wanderBehaviour{})
 	return c
 }
 
@@ -241,7 +260,7 @@ type Hen struct {
 
 func NewHen() INonPlayerEntity {
 	h := new(Hen)
-	h.Mob.Init(HenType.Id)
+	h.Mob.Init(HenType.Id, &wanderBehaviour{})
 	return h
 }
 
@@ -251,6 +270,7 @@ type Squid struct {
 
 func NewSquid() INonPlayerEntity {
 	s := new(Squid)
+	// TODO Squid should swim about.
 	s.Mob.Init(SquidType.Id)
 	return s
 }
@@ -261,7 +281,7 @@ type Wolf struct {
 
 func NewWolf() INonPlayerEntity {
 	w := new(Wolf)
-	w.Mob.Init(WolfType.Id)
+	w.Mob.Init(WolfType.Id, &wanderBehaviour{})
 	// TODO(nictuku): String with an optional owner's username.
 	w.Mob.metadata[17] = 0
 	w.Mob.metadata[16] = 0
@@ -0,0 +1,364 @@
+package gamerules
+
+import (
+	"bytes"
+	"math"
+
+	"chunkymonkey/physics"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+// Mobs are controlled by a list of behaviours in order of priority. Each tick,
+// a mob switches to the first behaviour that wants to start if it has a higher
+// priority than the current one, and then runs its current behaviour.
+// Behaviours keep their own state, so each mob has its own instances of them.
+
+const (
+	// mobWalkSpeed is the speed that mobs walk at, in blocks per tick.
+	mobWalkSpeed = 0.15
+	// mobJumpSpeed is the upwards velocity of a jump, enough to reach the top
+	// of the next block up.
+	mobJumpSpeed = 1.6
+	// mobStuckTicks is the number of ticks that a mob tries to reach the next
+	// block on its path before giving up.
+	mobStuckTicks = 40
+
+	// wanderChance is the inverse of the chance per tick that an idle mob
+	// starts wandering.
+	wanderChance = 120
+	// wanderDistance is the furthest that a wandering mob goes.
+	wanderDistance = 10
+
+	// mobFollowRange is the distance within which hostile mobs follow players.
+	mobFollowRange = 16
+	// mobAttackRange is the distance within which mobs hit players.
+	mobAttackRange = 1.5
+	// mobAttackCooldown is the number of ticks between hits.
+	mobAttackCooldown = TicksPerSecond
+	// mobRepathTicks is the number of ticks between finding new paths to a
+	// followed player, who is likely to have moved.
+	mobRepathTicks = TicksPerSecond
+
+	// creeperFuseRange is the distance within which creepers start their fuse.
+	creeperFuseRange = 3
+	// creeperCancelRange is the distance beyond which creepers stop their fuse.
+	creeperCancelRange = 7
+	creeperFuse        = Ticks(30)
+	creeperRadius      = 3
+	// creeperBlastRange is the distance within which a creeper's explosion
+	// hurts players and mobs.
+	creeperBlastRange = 2 * creeperRadius
+	// creeperDamage is the damage at the centre of the explosion, which falls
+	// off to nothing at creeperBlastRange.
+	creeperDamage = 20
+)
+
+// IMobChunk is the interface by which mobs interact with the chunk that they
+// are in.
+type IMobChunk interface {
+	IChunkBlock
+	physics.IBlockQuerier
+
+	// RemoveEntity removes the entity from the chunk.
+	RemoveEntity(entity INonPlayerEntity)
+
+	// MobDied tells the chunk that the mob with the given entity ID was
+	// killed, by cause.
+	MobDied(entityId EntityId, cause DamageCause)
+
+	// PlayersWithin calls f for each player within maxDistance of position.
+	// Players in chunks outside of the shard are left out.
+	PlayersWithin(position *AbsXyz, maxDistance AbsCoord, f func(player IPlayerClient, playerPos AbsXyz))
+
+	// MobsWithin calls f for each mob within maxDistance of position, with the
+	// chunk that the mob is in. Mobs in chunks outside of the shard are left
+	// out.
+	MobsWithin(position *AbsXyz, maxDistance AbsCoord, f func(mob IMob, mobChunk IMobChunk))
+}
+
+// IMob is the interface for mobs, which decide how to move each tick.
+type IMob interface {
+	INonPlayerEntity
+
+	MobType() *MobType
+
+	// SetPosition moves the mob to position, at rest.
+	SetPosition(position *AbsXyz)
+
+	// Think runs the mob's behaviours for a single tick, before its physics
+	// is run by Tick. The mob may remove itself from the chunk.
+	Think(chunk IMobChunk)
+
+	// Hurt damages the mob for the given cause and knocks it back, returning
+	// true if it died, in which case the chunk is told through MobDied. The
+	// mob removes itself from the chunk some time after it dies.
+	Hurt(chunk IMobChunk, amount Health, cause DamageCause, knockback *AbsVelocity) (died bool)
+
+	// Dead returns true once the mob has died.
+	Dead() bool
+}
+
+// IMobBehaviour is something that a mob does, such as wandering or attacking
+// players.
+type IMobBehaviour interface {
+	// ShouldStart returns true if the behaviour wants to take control of the
+	// mob.
+	ShouldStart(mob *Mob, chunk IMobChunk) bool
+
+	// Tick runs the behaviour for a tick, returning false when it has
+	// finished.
+	Tick(mob *Mob, chunk IMobChunk) (running bool)
+}
+
+func (mob *Mob) MobType() *MobType {
+	return Mobs[mob.mobType]
+}
+
+func (mob *Mob) Dead() bool {
+	return mob.health <= 0
+}
+
+func (mob *Mob) SetPosition(position *AbsXyz) {
+	mob.PointObject.Init(position, &AbsVelocity{})
+}
+
+func (mob *Mob) Think(chunk IMobChunk) {
+	if mob.health <= 0 {
+		if mob.deathTicks++; mob.deathTicks >= mobDeathTicks {
+			chunk.RemoveEntity(mob)
+		}
+		return
+	}
+	if mob.hurtTicks > 0 {
+		mob.hurtTicks--
+	}
+
+	for _, behaviour := range mob.behaviours {
+		if behaviour == mob.behaviour {
+			break
+		}
+		if behaviour.ShouldStart(mob, chunk) {
+			mob.behaviour = behaviour
+			break
+		}
+	}
+
+	if mob.behaviour != nil && !mob.behaviour.Tick(mob, chunk) {
+		mob.behaviour = nil
+		mob.path = nil
+		mob.stop()
+	}
+}
+
+// setPath finds a path for the mob to walk to target, returning false if there
+// is no way to get any closer.
+func (mob *Mob) setPath(chunk IMobChunk, target BlockXyz) bool {
+	mob.path = findPath(chunk, *mob.Position().ToBlockXyz(), target)
+	mob.pathTicks = 0
+	return len(mob.path) > 0
+}
+
+// followPath moves the mob along its path, returning false when it has reached
+// the end of the path or is stuck.
+func (mob *Mob) followPath() bool {
+	blockLoc := mob.Position().ToBlockXyz()
+	for len(mob.path) > 0 && mob.path[0] == *blockLoc {
+		mob.path = mob.path[1:]
+		mob.pathTicks = 0
+	}
+
+	if len(mob.path) == 0 {
+		mob.stop()
+		return false
+	}
+
+	mob.pathTicks++
+	if mob.pathTicks > mobStuckTicks {
+		mob.path = nil
+		mob.stop()
+		return false
+	}
+
+	next := &mob.path[0]
+	target := next.MidPointToAbsXyz()
+	mob.walkTowards(&target, next.Y > blockLoc.Y)
+
+	return true
+}
+
+// walkTowards sets the mob's velocity to walk horizontally towards target. If
+// jump is true, the mob jumps once it is on the ground next to the target.
+func (mob *Mob) walkTowards(target *AbsXyz, jump bool) {
+	position := mob.Position()
+	dx := float64(target.X - position.X)
+	dz := float64(target.Z - position.Z)
+	distance := math.Hypot(dx, dz)
+
+	velocity := *mob.Velocity()
+	if distance > 0 {
+		speed := math.Min(mobWalkSpeed, distance)
+		velocity.X = AbsVelocityCoord(dx / distance * speed)
+		velocity.Z = AbsVelocityCoord(dz / distance * speed)
+	}
+	if jump && mob.OnGround() && distance < 1.5 {
+		velocity.Y = mobJumpSpeed
+	}
+	mob.SetVelocity(&velocity)
+
+	mob.lookTowards(target)
+}
+
+// lookTowards turns the mob to face target horizontally.
+func (mob *Mob) lookTowards(target *AbsXyz) {
+	position := mob.Position()
+	dx := float64(target.X - position.X)
+	dz := float64(target.Z - position.Z)
+	if dx != 0 || dz != 0 {
+		mob.look.Yaw = AngleDegrees(math.Atan2(-dx, dz) * (180 / math.Pi))
+	}
+}
+
+// stop halts the mob's horizontal movement.
+func (mob *Mob) stop() {
+	velocity := *mob.Velocity()
+	velocity.X, velocity.Z = 0, 0
+	mob.SetVelocity(&velocity)
+}
+
+// sendMetadata tells players about a change to the mob's metadata.
+func (mob *Mob) sendMetadata(chunk IMobChunk) {
+	buf := new(bytes.Buffer)
+	proto.WriteEntityMetadata(buf, mob.EntityId, mob.FormatMetadata())
+	chunk.MulticastPlayers(-1, buf.Bytes())
+}
+
+// wanderBehaviour walks the mob to random nearby places from time to time.
+type wanderBehaviour struct{}
+
+func (b *wanderBehaviour) ShouldStart(mob *Mob, chunk IMobChunk) bool {
+	rand := chunk.Rand()
+	if !mob.OnGround() || rand.Intn(wanderChance) != 0 {
+		return false
+	}
+
+	target := *mob.Position().ToBlockXyz()
+	target.X += BlockCoord(rand.Intn(2*wanderDistance+1) - wanderDistance)
+	target.Z += BlockCoord(rand.Intn(2*wanderDistance+1) - wanderDistance)
+
+	return mob.setPath(chunk, target)
+}
+
+func (b *wanderBehaviour) Tick(mob *Mob, chunk IMobChunk) bool {
+	return mob.followPath()
+}
+
+// attackBehaviour follows the nearest player and hits them. Mobs with no
+// damage, such as creepers, only follow.
+type attackBehaviour struct {
+	damage   Health
+	repath   Ticks
+	cooldown Ticks
+}
+
+func newAttackBehaviour(damage Health) *attackBehaviour {
+	return &attackBehaviour{damage: damage}
+}
+
+func (b *attackBehaviour) ShouldStart(mob *Mob, chunk IMobChunk) bool {
+	_, _, ok := chunk.NearestPlayer(mob.Position(), mobFollowRange)
+	b.repath = 0
+	return ok
+}
+
+func (b *attackBehaviour) Tick(mob *Mob, chunk IMobChunk) bool {
+	if b.cooldown > 0 {
+		b.cooldown--
+	}
+
+	player, playerPos, ok := chunk.NearestPlayer(mob.Position(), mobFollowRange)
+	if !ok {
+		return false
+	}
+
+	if mob.Position().Distance(&playerPos) <= mobAttackRange {
+		mob.path = nil
+		mob.stop()
+		mob.lookTowards(&playerPos)
+		if b.cooldown == 0 && b.damage > 0 {
+			player.Hit(*mob.Position(), b.damage, DamageCauseMob)
+			b.cooldown = mobAttackCooldown
+		}
+		return true
+	}
+
+	if b.repath--; b.repath <= 0 || len(mob.path) == 0 {
+		mob.setPath(chunk, *playerPos.ToBlockXyz())
+		b.repath = mobRepathTicks
+	}
+	mob.followPath()
+
+	return true
+}
+
+// creeperBehaviour lights the creeper's fuse when a player is close, and
+// explodes unless the player gets away in time.
+type creeperBehaviour struct {
+	fuse Ticks
+}
+
+func (b *creeperBehaviour) ShouldStart(mob *Mob, chunk IMobChunk) bool {
+	_, _, ok := chunk.NearestPlayer(mob.Position(), creeperFuseRange)
+	return ok
+}
+
+func (b *creeperBehaviour) Tick(mob *Mob, chunk IMobChunk) bool {
+	_, playerPos, ok := chunk.NearestPlayer(mob.Position(), creeperCancelRange)
+	if !ok {
+		b.fuse = 0
+		mob.metadata[17] = creeperNormal
+		mob.sendMetadata(chunk)
+		return false
+	}
+
+	mob.stop()
+	mob.lookTowards(&playerPos)
+
+	if b.fuse == 0 {
+		b.fuse = creeperFuse
+		mob.metadata[17] = creeperBlueAura
+		mob.sendMetadata(chunk)
+		return true
+	}
+
+	if b.fuse--; b.fuse > 0 {
+		return true
+	}
+
+	center := *mob.Position()
+	chunk.PlayersWithin(&center, creeperBlastRange, func(player IPlayerClient, playerPos AbsXyz) {
+		if damage := creeperBlastDamage(&center, &playerPos); damage > 0 {
+			player.Damage(damage, DamageCauseExplosion)
+		}
+	})
+	chunk.MobsWithin(&center, creeperBlastRange, func(other IMob, otherChunk IMobChunk) {
+		if other.GetEntityId() == mob.EntityId {
+			return
+		}
+		if damage := creeperBlastDamage(&center, other.Position()); damage > 0 {
+			knockback := Knockback(&center, other.Position())
+			other.Hurt(otherChunk, damage, DamageCauseExplosion, &knockback)
+		}
+	})
+	explode(chunk, center.ToBlockXyz(), creeperRadius)
+	chunk.RemoveEntity(mob)
+
+	return false
+}
+
+// creeperBlastDamage returns the damage done by a creeper exploding at center
+// to something at target.
+func creeperBlastDamage(center, target *AbsXyz) Health {
+	distance := center.Distance(target)
+	return Health(creeperDamage * (1 - distance/creeperBlastRange))
+}
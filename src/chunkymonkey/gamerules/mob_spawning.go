@@ -0,0 +1,148 @@
+package gamerules
+
+import (
+	"math/rand"
+
+	. "chunkymonkey/types"
+)
+
+const (
+	// blockIdGrass is the block that passive mobs spawn on.
+	blockIdGrass = BlockId(2)
+
+	// maxHostileSpawnLight is the brightest light that hostile mobs spawn in.
+	maxHostileSpawnLight = 7
+	// minPassiveSpawnSkyLight is the dimmest sky light that passive mobs spawn
+	// in.
+	minPassiveSpawnSkyLight = 9
+)
+
+// mobSpawn is a mob that spawns naturally, with its chance of spawning
+// relative to others in the same list.
+type mobSpawn struct {
+	create func() INonPlayerEntity
+	weight int
+}
+
+var (
+	hostileSpawns = []mobSpawn{
+		{NewZombie, 10},
+		{NewSkeleton, 10},
+		{NewSpider, 10},
+		{NewCreeper, 10},
+	}
+	passiveSpawns = []mobSpawn{
+		{NewPig, 10},
+		{NewSheep, 12},
+		{NewCow, 8},
+		{NewHen, 10},
+	}
+	// Wolves also live in forests.
+	woodlandSpawns = append([]mobSpawn{{NewWolf, 2}}, passiveSpawns...)
+	waterSpawns    = []mobSpawn{
+		{NewSquid, 1},
+	}
+)
+
+// biomePassiveSpawns holds the passive mobs that spawn on land in each biome.
+// Biomes that aren't listed, including BiomeUnknown, have passiveSpawns.
+var biomePassiveSpawns = map[Biome][]mobSpawn{
+	BiomeForest: woodlandSpawns,
+	BiomeTaiga:  woodlandSpawns,
+	BiomeDesert: nil,
+}
+
+// IBiomeSource gives the biomes of the land in a world, as it was generated.
+type IBiomeSource interface {
+	// Biome returns the biome of the column of blocks at (x, z).
+	Biome(x, z BlockCoord) Biome
+}
+
+// MobSpawnSite describes a block that a mob might spawn with its feet in.
+type MobSpawnSite struct {
+	Feet, Head, Ground *BlockType
+	SkyLight           byte
+	BlockLight         byte
+	Biome              Biome
+}
+
+// mobSpawnSiteAt describes the site at blockLoc. feet is the block at blockLoc,
+// whose chunk the mob should be added to. ok=false if any of the blocks are not
+// available.
+func mobSpawnSiteAt(chunk IChunkBlock, blockLoc *BlockXyz) (site MobSpawnSite, feet BlockInstance, ok bool) {
+	if feet, ok = chunk.BlockAt(blockLoc); !ok {
+		return
+	}
+	head, ground := *blockLoc, *blockLoc
+	head.Y++
+	ground.Y--
+	var instance BlockInstance
+	if instance, ok = chunk.BlockAt(&head); !ok {
+		return
+	}
+	site.Head = instance.BlockType
+	if instance, ok = chunk.BlockAt(&ground); !ok {
+		return
+	}
+	site.Ground = instance.BlockType
+	site.Feet = feet.BlockType
+	site.SkyLight, site.BlockLight = feet.Chunk.LightLevels(feet.Index)
+	// Mob spawners spawn their mobs in any biome.
+	site.Biome = BiomeUnknown
+	return
+}
+
+// suits returns true if mobs of the given type can spawn at the site.
+func (site *MobSpawnSite) suits(mobType *MobType) bool {
+	switch {
+	case mobType.Aquatic:
+		return site.Feet.Drowns && site.Head.Drowns
+	case site.Feet.Solid || site.Head.Solid || site.Feet.Drowns || !site.Ground.Solid:
+		return false
+	case mobType.Hostile:
+		return site.SkyLight <= maxHostileSpawnLight && site.BlockLight <= maxHostileSpawnLight
+	}
+	return site.Ground.id == blockIdGrass && site.SkyLight >= minPassiveSpawnSkyLight
+}
+
+// NewNaturalMob chooses a mob to spawn naturally at the site, either a hostile
+// or a passive one. ok=false if no such mob can spawn there. Hostile mobs
+// spawn in any biome, and squid in water, but the animals on land depend on
+// the site's biome.
+//
+// TODO Sky light doesn't vary with the time of day yet, so hostile mobs only
+// spawn out of sight of the sky, such as in caves.
+func NewNaturalMob(rand *rand.Rand, site *MobSpawnSite, hostile bool) (mob IMob, ok bool) {
+	var spawns []mobSpawn
+
+	switch {
+	case hostile:
+		spawns = hostileSpawns
+	case site.Feet.Drowns:
+		spawns = waterSpawns
+	default:
+		var ok bool
+		if spawns, ok = biomePassiveSpawns[site.Biome]; !ok {
+			spawns = passiveSpawns
+		}
+	}
+
+	total := 0
+	for i := range spawns {
+		total += spawns[i].weight
+	}
+	if total == 0 {
+		return nil, false
+	}
+	choice := rand.Intn(total)
+	for i := range spawns {
+		if choice -= spawns[i].weight; choice < 0 {
+			if mob, ok = spawns[i].create().(IMob); ok && site.suits(mob.MobType()) {
+				return mob, true
+			}
+			break
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,126 @@
+package gamerules
+
+import (
+	"math/rand"
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestNewNaturalMob(t *testing.T) {
+	air := &BlockType{}
+	stone := &BlockType{BlockAttrs: BlockAttrs{Solid: true, id: 1}}
+	grass := &BlockType{BlockAttrs: BlockAttrs{Solid: true, id: blockIdGrass}}
+	water := &BlockType{BlockAttrs: BlockAttrs{Drowns: true, id: 9}}
+
+	tests := []struct {
+		name    string
+		site    MobSpawnSite
+		hostile bool
+		want    bool
+		wantMob *MobType
+	}{
+		{"hostile in the dark", MobSpawnSite{air, air, stone, 0, 0, BiomeUnknown}, true, true, nil},
+		{"hostile in sky light", MobSpawnSite{air, air, stone, 15, 0, BiomeUnknown}, true, false, nil},
+		{"hostile in block light", MobSpawnSite{air, air, stone, 0, 8, BiomeUnknown}, true, false, nil},
+		{"hostile with no headroom", MobSpawnSite{air, stone, stone, 0, 0, BiomeUnknown}, true, false, nil},
+		{"hostile in water", MobSpawnSite{water, water, stone, 0, 0, BiomeUnknown}, true, false, nil},
+		{"passive on grass", MobSpawnSite{air, air, grass, 15, 0, BiomeUnknown}, false, true, nil},
+		{"passive on stone", MobSpawnSite{air, air, stone, 15, 0, BiomeUnknown}, false, false, nil},
+		{"passive in the dark", MobSpawnSite{air, air, grass, 8, 0, BiomeUnknown}, false, false, nil},
+		{"passive in water", MobSpawnSite{water, water, stone, 0, 0, BiomeUnknown}, false, true, &SquidType},
+		{"passive in shallow water", MobSpawnSite{water, air, grass, 15, 0, BiomeUnknown}, false, false, nil},
+	}
+
+	rand := rand.New(rand.NewSource(0))
+	for _, test := range tests {
+		mob, ok := NewNaturalMob(rand, &test.site, test.hostile)
+		if ok != test.want {
+			t.Errorf("%s: got ok=%t, want %t", test.name, ok, test.want)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if mob.MobType().Hostile != test.hostile {
+			t.Errorf("%s: got %s, want hostile=%t", test.name, mob.MobType().Name, test.hostile)
+		}
+		if test.wantMob != nil && mob.MobType() != test.wantMob {
+			t.Errorf("%s: got %s, want %s", test.name, mob.MobType().Name, test.wantMob.Name)
+		}
+	}
+}
+
+func TestNewNaturalMobBiomes(t *testing.T) {
+	air := &BlockType{}
+	grass := &BlockType{BlockAttrs: BlockAttrs{Solid: true, id: blockIdGrass}}
+	water := &BlockType{BlockAttrs: BlockAttrs{Drowns: true, id: 9}}
+
+	tests := []struct {
+		biome    Biome
+		feet     *BlockType
+		want     []*MobType // The only mobs that may spawn.
+		wantWolf bool       // Whether a wolf must be among them.
+	}{
+		{BiomeForest, air, []*MobType{&PigType, &SheepType, &CowType, &HenType, &WolfType}, true},
+		{BiomeTaiga, air, []*MobType{&PigType, &SheepType, &CowType, &HenType, &WolfType}, true},
+		{BiomePlains, air, []*MobType{&PigType, &SheepType, &CowType, &HenType}, false},
+		{BiomeUnknown, air, []*MobType{&PigType, &SheepType, &CowType, &HenType}, false},
+		{BiomeDesert, air, nil, false},
+		{BiomeDesert, water, []*MobType{&SquidType}, false},
+	}
+
+	rand := rand.New(rand.NewSource(0))
+	for _, test := range tests {
+		site := MobSpawnSite{test.feet, test.feet, grass, 15, 0, test.biome}
+		sawWolf := false
+		for i := 0; i < 200; i++ {
+			mob, ok := NewNaturalMob(rand, &site, false)
+			if !ok {
+				continue
+			}
+			mobType := mob.MobType()
+			sawWolf = sawWolf || mobType == &WolfType
+			allowed := false
+			for _, want := range test.want {
+				allowed = allowed || mobType == want
+			}
+			if !allowed {
+				t.Errorf("%v: got %s spawning", test.biome, mobType.Name)
+			}
+		}
+		if sawWolf != test.wantWolf {
+			t.Errorf("%v: got wolves=%t in 200 spawns, want %t", test.biome, sawWolf, test.wantWolf)
+		}
+	}
+}
+
+func TestMobSpawnSiteSuits(t *testing.T) {
+	air := &BlockType{}
+	stone := &BlockType{BlockAttrs: BlockAttrs{Solid: true, id: 1}}
+	grass := &BlockType{BlockAttrs: BlockAttrs{Solid: true, id: blockIdGrass}}
+	water := &BlockType{BlockAttrs: BlockAttrs{Drowns: true, id: 9}}
+
+	tests := []struct {
+		site    MobSpawnSite
+		mobType *MobType
+		want    bool
+	}{
+		{MobSpawnSite{air, air, stone, 0, 0, BiomeUnknown}, &ZombieType, true},
+		{MobSpawnSite{air, air, stone, 0, 7, BiomeUnknown}, &ZombieType, true},
+		{MobSpawnSite{air, air, stone, 0, 8, BiomeUnknown}, &ZombieType, false},
+		{MobSpawnSite{air, air, air, 0, 0, BiomeUnknown}, &ZombieType, false},
+		{MobSpawnSite{air, air, grass, 15, 0, BiomeUnknown}, &PigType, true},
+		{MobSpawnSite{air, air, stone, 15, 0, BiomeUnknown}, &PigType, false},
+		{MobSpawnSite{air, air, grass, 0, 15, BiomeUnknown}, &PigType, false},
+		{MobSpawnSite{water, water, stone, 0, 0, BiomeUnknown}, &SquidType, true},
+		{MobSpawnSite{water, air, stone, 0, 0, BiomeUnknown}, &SquidType, false},
+		{MobSpawnSite{air, air, grass, 15, 0, BiomeUnknown}, &SquidType, false},
+	}
+
+	for i, test := range tests {
+		if got := test.site.suits(test.mobType); got != test.want {
+			t.Errorf("test %d: suits(%s) = %t, want %t", i, test.mobType.Name, got, test.want)
+		}
+	}
+}
@@ -7,6 +7,20 @@ import (
 type MobType struct {
 	Id   EntityMobType
 	Name string
+	// Hostile mobs attack players, and spawn in the dark.
+	Hostile bool
+	// Aquatic mobs live and spawn in water.
+	Aquatic   bool
+	MaxHealth Health
+	// Drops are the items that the mob drops when it dies.
+	Drops []MobDrop
+}
+
+// MobDrop is an item that a mob drops when it dies, between Min and Max of
+// them.
+type MobDrop struct {
+	ItemTypeId ItemTypeId
+	Min, Max   ItemCount
 }
 
 type MobTypeMap map[EntityMobType]*MobType
@@ -29,17 +43,113 @@ var Mobs = MobTypeMap{
 	MobTypeIdWolf:         &WolfType,
 }
 
-var CreeperType = MobType{MobTypeIdCreeper, "creeper"}
-var SkeletonType = MobType{MobTypeIdSkeleton, "skeleton"}
-var SpiderType = MobType{MobTypeIdSpider, "spider"}
-var GiantZombieType = MobType{MobTypeIdGiantZombie, "giantzombie"}
-var ZombieType = MobType{MobTypeIdZombie, "zombie"}
-var SlimeType = MobType{MobTypeIdSlime, "slime"}
-var GhastType = MobType{MobTypeIdGhast, "ghast"}
-var ZombiePigmanType = MobType{MobTypeIdZombiePigman, "zombiepigman"}
-var PigType = MobType{MobTypeIdPig, "pig"}
-var SheepType = MobType{MobTypeIdSheep, "sheep"}
-var CowType = MobType{MobTypeIdCow, "cow"}
-var HenType = MobType{MobTypeIdHen, "hen"}
-var SquidType = MobType{MobTypeIdSquid, "squid"}
-var WolfType = MobType{MobTypeIdWolf, "wolf"}
+var CreeperType = MobType{
+	Id:        MobTypeIdCreeper,
+	Name:      "creeper",
+	Hostile:   true,
+	MaxHealth: 20,
+	Drops:     []MobDrop{{289, 0, 2}},
+}
+
+var SkeletonType = MobType{
+	Id:        MobTypeIdSkeleton,
+	Name:      "skeleton",
+	Hostile:   true,
+	MaxHealth: 20,
+	Drops:     []MobDrop{{262, 0, 2}, {352, 0, 2}},
+}
+
+var SpiderType = MobType{
+	Id:        MobTypeIdSpider,
+	Name:      "spider",
+	Hostile:   true,
+	MaxHealth: 16,
+	Drops:     []MobDrop{{287, 0, 2}},
+}
+
+var GiantZombieType = MobType{
+	Id:        MobTypeIdGiantZombie,
+	Name:      "giantzombie",
+	Hostile:   true,
+	MaxHealth: 100,
+}
+
+var ZombieType = MobType{
+	Id:        MobTypeIdZombie,
+	Name:      "zombie",
+	Hostile:   true,
+	MaxHealth: 20,
+	Drops:     []MobDrop{{288, 0, 2}},
+}
+
+var SlimeType = MobType{
+	Id:        MobTypeIdSlime,
+	Name:      "slime",
+	Hostile:   true,
+	MaxHealth: 16,
+	Drops:     []MobDrop{{341, 0, 2}},
+}
+
+var GhastType = MobType{
+	Id:        MobTypeIdGhast,
+	Name:      "ghast",
+	Hostile:   true,
+	MaxHealth: 10,
+	Drops:     []MobDrop{{289, 0, 2}},
+}
+
+var ZombiePigmanType = MobType{
+	Id:        MobTypeIdZombiePigman,
+	Name:      "zombiepigman",
+	Hostile:   true,
+	MaxHealth: 20,
+	Drops:     []MobDrop{{320, 0, 2}},
+}
+
+var PigType = MobType{
+	Id:        MobTypeIdPig,
+	Name:      "pig",
+	Hostile:   false,
+	MaxHealth: 10,
+	Drops:     []MobDrop{{319, 0, 2}},
+}
+
+var SheepType = MobType{
+	Id:        MobTypeIdSheep,
+	Name:      "sheep",
+	Hostile:   false,
+	MaxHealth: 8,
+	Drops:     []MobDrop{{35, 1, 1}},
+}
+
+var CowType = MobType{
+	Id:        MobTypeIdCow,
+	Name:      "cow",
+	Hostile:   false,
+	MaxHealth: 10,
+	Drops:     []MobDrop{{334, 0, 2}},
+}
+
+var HenType = MobType{
+	Id:        MobTypeIdHen,
+	Name:      "hen",
+	Hostile:   false,
+	MaxHealth: 4,
+	Drops:     []MobDrop{{288, 0, 2}},
+}
+
+var SquidType = MobType{
+	Id:        MobTypeIdSquid,
+	Name:      "squid",
+	Hostile:   false,
+	Aquatic:   true,
+	MaxHealth: 10,
+	Drops:     []MobDrop{{351, 1, 3}},
+}
+
+var WolfType = MobType{
+	Id:        MobTypeIdWolf,
+	Name:      "wolf",
+	Hostile:   false,
+	MaxHealth: 8,
+}
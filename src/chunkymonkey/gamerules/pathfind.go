@@ -0,0 +1,182 @@
+package gamerules
+
+import (
+	"container/heap"
+
+	"chunkymonkey/physics"
+	. "chunkymonkey/types"
+)
+
+const (
+	// maxPathNodes limits the number of blocks examined by findPath.
+	maxPathNodes = 400
+	// maxPathDrop is the furthest that a path drops down in a single step.
+	maxPathDrop = 3
+)
+
+// pathFaces are the directions that a path moves in.
+var pathFaces = [4]struct{ dx, dz BlockCoord }{
+	{-1, 0}, {1, 0}, {0, -1}, {0, 1},
+}
+
+// findPath searches for a path that a mob can walk along between two blocks.
+// The blocks in the path are those that the mob's feet pass through, not
+// including from. If to cannot be reached, then the path leads to the closest
+// block to it that was found. nil is returned if no block closer to the
+// destination can be reached.
+func findPath(querier physics.IBlockQuerier, from, to BlockXyz) []BlockXyz {
+	open := &pathHeap{}
+	nodes := map[BlockXyz]*pathNode{}
+
+	start := &pathNode{loc: from, cost: 0, estimate: pathDistance(&from, &to)}
+	nodes[from] = start
+	heap.Push(open, start)
+	best := start
+
+	for examined := 0; open.Len() > 0 && examined < maxPathNodes; examined++ {
+		node := heap.Pop(open).(*pathNode)
+		node.closed = true
+
+		if node.loc == to {
+			best = node
+			break
+		}
+		if node.estimate < best.estimate {
+			best = node
+		}
+
+		for _, face := range pathFaces {
+			next, ok := pathStep(querier, &node.loc, face.dx, face.dz)
+			if !ok {
+				continue
+			}
+
+			cost := node.cost + 1
+			nextNode, seen := nodes[next]
+			if seen && (nextNode.closed || cost >= nextNode.cost) {
+				continue
+			}
+			if !seen {
+				nextNode = &pathNode{loc: next, estimate: pathDistance(&next, &to)}
+				nodes[next] = nextNode
+			}
+			nextNode.cost = cost
+			nextNode.parent = node
+			if seen {
+				heap.Fix(open, nextNode.index)
+			} else {
+				heap.Push(open, nextNode)
+			}
+		}
+	}
+
+	if best == start {
+		return nil
+	}
+
+	var path []BlockXyz
+	for node := best; node != start; node = node.parent {
+		path = append(path, node.loc)
+	}
+	// Reverse the path to start from the beginning.
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path
+}
+
+// pathStep returns the block that a mob standing at loc moves into when
+// walking one block horizontally. It steps up or drops down if necessary.
+func pathStep(querier physics.IBlockQuerier, loc *BlockXyz, dx, dz BlockCoord) (next BlockXyz, ok bool) {
+	next = BlockXyz{loc.X + dx, loc.Y, loc.Z + dz}
+
+	if isPathSolid(querier, next) {
+		// Step up onto the block, if there is room to jump.
+		above := BlockXyz{loc.X, loc.Y + 2, loc.Z}
+		next.Y++
+		if next.Y <= 0 || isPathSolid(querier, above) {
+			return next, false
+		}
+		return next, canStandIn(querier, next)
+	}
+
+	// Drop down until standing on something.
+	for drop := 0; drop <= maxPathDrop; drop++ {
+		if canStandIn(querier, next) {
+			return next, true
+		}
+		if next.Y <= 1 || isPathSolid(querier, next) {
+			return next, false
+		}
+		next.Y--
+	}
+
+	return next, false
+}
+
+// canStandIn returns true if a mob can stand with its feet in the block.
+func canStandIn(querier physics.IBlockQuerier, loc BlockXyz) bool {
+	if loc.Y <= 0 || loc.Y >= MaxYCoord {
+		return false
+	}
+	head := BlockXyz{loc.X, loc.Y + 1, loc.Z}
+	below := BlockXyz{loc.X, loc.Y - 1, loc.Z}
+	return !isPathSolid(querier, loc) && !isPathSolid(querier, head) && isPathSolid(querier, below)
+}
+
+func isPathSolid(querier physics.IBlockQuerier, loc BlockXyz) bool {
+	isSolid, _ := querier.BlockQuery(loc)
+	return isSolid
+}
+
+// pathDistance is the estimated cost of a path between two blocks.
+func pathDistance(a, b *BlockXyz) int {
+	return absInt(int(a.X-b.X)) + absInt(int(a.Y)-int(b.Y)) + absInt(int(a.Z-b.Z))
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+type pathNode struct {
+	loc      BlockXyz
+	parent   *pathNode
+	cost     int
+	estimate int
+	closed   bool
+	index    int // Index within pathHeap.
+}
+
+// pathHeap is a priority queue of pathNodes, implementing heap.Interface.
+type pathHeap []*pathNode
+
+func (h pathHeap) Len() int {
+	return len(h)
+}
+
+func (h pathHeap) Less(i, j int) bool {
+	return h[i].cost+h[i].estimate < h[j].cost+h[j].estimate
+}
+
+func (h pathHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *pathHeap) Push(x interface{}) {
+	node := x.(*pathNode)
+	node.index = len(*h)
+	*h = append(*h, node)
+}
+
+func (h *pathHeap) Pop() interface{} {
+	old := *h
+	node := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return node
+}
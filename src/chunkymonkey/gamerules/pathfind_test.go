@@ -0,0 +1,73 @@
+package gamerules
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+// testBlockQuerier is a world where the blocks at and below y=floor are solid,
+// along with any extra blocks.
+type testBlockQuerier struct {
+	floor BlockYCoord
+	solid map[BlockXyz]bool
+}
+
+func (q *testBlockQuerier) BlockQuery(blockLoc BlockXyz) (isSolid bool, isWithinChunk bool) {
+	return blockLoc.Y <= q.floor || q.solid[blockLoc], true
+}
+
+func newTestBlockQuerier(floor BlockYCoord, solid ...BlockXyz) *testBlockQuerier {
+	q := &testBlockQuerier{floor, map[BlockXyz]bool{}}
+	for _, loc := range solid {
+		q.solid[loc] = true
+	}
+	return q
+}
+
+func TestFindPath(t *testing.T) {
+	// A wall along x=2 from z=-3 to z=3, two blocks high.
+	var wall []BlockXyz
+	for z := BlockCoord(-3); z <= 3; z++ {
+		wall = append(wall, BlockXyz{2, 11, z}, BlockXyz{2, 12, z})
+	}
+
+	tests := []struct {
+		name      string
+		querier   *testBlockQuerier
+		from, to  BlockXyz
+		wantLen   int
+		wantReach bool
+	}{
+		{"same block", newTestBlockQuerier(10), BlockXyz{0, 11, 0}, BlockXyz{0, 11, 0}, 0, false},
+		{"straight", newTestBlockQuerier(10), BlockXyz{0, 11, 0}, BlockXyz{5, 11, 0}, 5, true},
+		{"diagonal", newTestBlockQuerier(10), BlockXyz{0, 11, 0}, BlockXyz{3, 11, -2}, 5, true},
+		{"step up", newTestBlockQuerier(10, BlockXyz{1, 11, 0}, BlockXyz{2, 11, 0}), BlockXyz{0, 11, 0}, BlockXyz{2, 12, 0}, 2, true},
+		{"drop down", newTestBlockQuerier(7, BlockXyz{0, 8, 0}, BlockXyz{0, 9, 0}, BlockXyz{0, 10, 0}), BlockXyz{0, 11, 0}, BlockXyz{2, 8, 0}, 2, true},
+		{"around wall", newTestBlockQuerier(10, wall...), BlockXyz{0, 11, 0}, BlockXyz{4, 11, 0}, 12, true},
+		{"walled in", newTestBlockQuerier(10,
+			BlockXyz{-1, 11, 0}, BlockXyz{-1, 12, 0},
+			BlockXyz{1, 11, 0}, BlockXyz{1, 12, 0},
+			BlockXyz{0, 11, -1}, BlockXyz{0, 12, -1},
+			BlockXyz{0, 11, 1}, BlockXyz{0, 12, 1},
+		), BlockXyz{0, 11, 0}, BlockXyz{4, 11, 0}, 0, false},
+	}
+
+	for _, test := range tests {
+		path := findPath(test.querier, test.from, test.to)
+		if len(path) != test.wantLen {
+			t.Errorf("%s: got path %v of length %d, want length %d", test.name, path, len(path), test.wantLen)
+			continue
+		}
+		if reached := len(path) > 0 && path[len(path)-1] == test.to; reached != test.wantReach {
+			t.Errorf("%s: got reached=%t, want %t", test.name, reached, test.wantReach)
+		}
+		prev := test.from
+		for _, loc := range path {
+			if pathDistance(&prev, &loc) > 1+maxPathDrop || !canStandIn(test.querier, loc) {
+				t.Errorf("%s: bad step from %v to %v", test.name, prev, loc)
+			}
+			prev = loc
+		}
+	}
+}
@@ -3,24 +3,24 @@
 package gamerules
 
 import (
-	"os"
+	"path"
 	"testing"
 )
 
 func loadRecipesAndItems() (recipes *RecipeSet, itemTypes ItemTypeMap, err error) {
-	blockTypes, err := LoadBlocksFromFile("blocks.json")
+	blockTypes, err := LoadBlocksFromFile(path.Join(testDataDir, "blocks.json"))
 	if err != nil {
 		return
 	}
 
-	itemTypes, err = LoadItemTypesFromFile("items.json")
+	itemTypes, err = LoadItemTypesFromFile(path.Join(testDataDir, "items.json"))
 	if err != nil {
 		return
 	}
 
 	blockTypes.CreateBlockItemTypes(itemTypes)
 
-	recipes, err = LoadRecipesFromFile("recipes.json", itemTypes)
+	recipes, err = LoadRecipesFromFile(path.Join(testDataDir, "recipes.json"), itemTypes)
 	if err != nil {
 		return
 	}
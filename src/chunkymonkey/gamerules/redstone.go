@@ -0,0 +1,80 @@
+package gamerules
+
+import (
+	. "chunkymonkey/types"
+)
+
+// Redstone power is provided by power sources (levers, buttons, pressure
+// plates, torches and repeaters) and carried by redstone wire, which loses one
+// level of power for each block of wire that it passes along. Solid opaque
+// blocks conduct power from adjacent power sources and wire to their other
+// neighbours, but power conducted from wire does not power other wire.
+//
+// Whenever a block changes, the chunk calls PowerChanged on the
+// IPoweredAspect of each block that might receive a different amount of power
+// as a result. Power changes at the edge of a shard are sent to the
+// neighbouring shard as PowerUpdate values.
+//
+// Unlike the Notchian server, wire does not climb up or down the sides of
+// blocks, and it provides power in all directions other than upwards.
+
+const MaxPowerLevel = 15
+
+// Power is the redstone power provided by a block to an adjacent block.
+type Power struct {
+	Level byte
+	// FromWire is true if the power comes from redstone wire.
+	FromWire bool
+	// Conducted is true if the power has been conducted through a solid block.
+	Conducted bool
+}
+
+// IPowerSourceAspect is implemented by the aspects of blocks that provide
+// redstone power.
+type IPowerSourceAspect interface {
+	// PowerOutput returns the power that the block provides to the adjacent
+	// block through the given face.
+	PowerOutput(instance *BlockInstance, face Face) Power
+}
+
+// IPoweredAspect is implemented by the aspects of blocks that react to
+// redstone power.
+type IPoweredAspect interface {
+	// PowerChanged is called when the power that the block receives might have
+	// changed. powered is true if the block receives any power, and wasPowered
+	// is true if it did when PowerChanged was last called.
+	PowerChanged(instance *BlockInstance, powered, wasPowered bool)
+}
+
+// ConductsPower returns true if the block conducts redstone power.
+func (blockType *BlockType) ConductsPower() bool {
+	return blockType.Solid && blockType.Opacity >= MaxPowerLevel
+}
+
+// receivedPower returns the greatest power level that the block receives
+// through any of its faces.
+func receivedPower(instance *BlockInstance) (level byte) {
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		power := instance.Chunk.NeighbourPower(&instance.BlockLoc, face)
+		if power.Level > level {
+			level = power.Level
+		}
+	}
+	return
+}
+
+// attachedFace returns the face of the block that a torch, lever or button is
+// attached to, from the lower 3 bits of its data.
+func attachedFace(blockData byte) Face {
+	switch blockData & 0x7 {
+	case 1:
+		return FaceNorth
+	case 2:
+		return FaceSouth
+	case 3:
+		return FaceEast
+	case 4:
+		return FaceWest
+	}
+	return FaceBottom
+}
@@ -1,8 +1,12 @@
 package gamerules
 
 import (
+	"chunkymonkey/access"
+	"chunkymonkey/blocklog"
+	"chunkymonkey/chat"
 	"chunkymonkey/proto"
 	. "chunkymonkey/types"
+	"chunkymonkey/weather"
 )
 
 // IShardConnecter is used to look up shards and connect to them.
@@ -31,7 +35,10 @@ type IPlayerShardClient interface {
 
 	ReqMulticastPlayers(chunkLoc ChunkXz, exclude EntityId, packet []byte)
 
-	ReqAddPlayerData(chunkLoc ChunkXz, name string, position AbsXyz, look LookBytes, held ItemTypeId)
+	// ReqAddPlayerData tells the chunk that the player is in it. air is the
+	// number of ticks that the player can still hold their breath for, and
+	// fire the number of ticks that they will keep burning for.
+	ReqAddPlayerData(chunkLoc ChunkXz, name string, position AbsXyz, look LookBytes, held ItemTypeId, air, fire Ticks)
 
 	ReqRemovePlayerData(chunkLoc ChunkXz, isDisconnect bool)
 
@@ -42,6 +49,10 @@ type IPlayerShardClient interface {
 	// ReqHitBlock requests that the targetted block be hit.
 	ReqHitBlock(held Slot, target BlockXyz, digStatus DigStatus, face Face)
 
+	// ReqHitEntity requests that the entity with the given ID is hit by the
+	// player at position, if it is within the shard and close enough.
+	ReqHitEntity(held Slot, position AbsXyz, target EntityId)
+
 	// ReqHitBlock requests that the targetted block be interacted with.
 	ReqInteractBlock(held Slot, target BlockXyz, face Face)
 
@@ -56,7 +67,8 @@ type IPlayerShardClient interface {
 	// item no longer exists).
 	ReqTakeItem(chunkLoc ChunkXz, entityId EntityId)
 
-	// ReqDropItem requests that an item be created.
+	// ReqDropItem requests that an item be created. The item is destroyed if
+	// an EntitySpawn event handler cancels its creation.
 	ReqDropItem(content Slot, position AbsXyz, velocity AbsVelocity, pickupImmunity Ticks)
 
 	// ReqInventoryClick requests that the given cursor be "clicked" onto the
@@ -68,6 +80,11 @@ type IPlayerShardClient interface {
 	// ReqInventoryUnsubscribed requests that the inventory for the block be
 	// unsubscribed to.
 	ReqInventoryUnsubscribed(block BlockXyz)
+
+	// ReqLinkPortal requests that the player, who has just come through a
+	// portal from the other dimension, is moved beside the portal nearest to
+	// target. A portal is built at target if there is none nearby.
+	ReqLinkPortal(target BlockXyz)
 }
 
 // IShardShardClient provides an interface for shards to make requests against
@@ -79,6 +96,45 @@ type IShardShardClient interface {
 	ReqSetActiveBlocks(blocks []BlockXyz)
 
 	ReqTransferEntity(loc ChunkXz, entity INonPlayerEntity)
+
+	// ReqSpreadBlocks requests that the given block types spread into blocks
+	// within the shard.
+	ReqSpreadBlocks(spreads []BlockSpread)
+
+	// ReqUpdateLight requests that light changes that have spread across the
+	// shard boundary are applied within the shard.
+	ReqUpdateLight(updates []LightUpdate)
+
+	// ReqUpdatePower requests that changes in redstone power provided by
+	// blocks in another shard are applied to the blocks they are adjacent to.
+	ReqUpdatePower(updates []PowerUpdate)
+}
+
+// LightUpdate describes a change of light spreading into a block from an
+// adjacent block in another shard.
+type LightUpdate struct {
+	// Block is the block that the light is spreading into.
+	Block BlockXyz
+	// SkyLight is true for a change in sky light, and false for a change in
+	// block light.
+	SkyLight bool
+	// Level is the light level of the adjacent block that the light is
+	// spreading from (or was spreading from, if Removed is true).
+	Level byte
+	// Removed is true if the light of the adjacent block has been removed. The
+	// receiving shard should remove light that depended on it, and send back
+	// updates for any light that it can still provide.
+	Removed bool
+}
+
+// PowerUpdate describes a change of redstone power provided to a block by an
+// adjacent block in another shard.
+type PowerUpdate struct {
+	// Block is the block that the power is provided to.
+	Block BlockXyz
+	// Face is the face of Block that the power is provided through.
+	Face  Face
+	Power Power
 }
 
 // IGame provide an interface for interacting with and taking action on the
@@ -99,6 +155,50 @@ type IGame interface {
 	// Return an ItemType from a numeric item. The boolean flag indicates
 	// whether or not 'id' was a valid item type.
 	ItemTypeById(id int) (ItemType, bool)
+
+	// BlockLog returns the log of the changes to blocks in the world and
+	// dimension that the player is in, or nil if they aren't logged there.
+	BlockLog(player IPlayerClient) IBlockLog
+
+	// AccessLists returns the ban lists and whitelist that are checked when
+	// players log in.
+	AccessLists() *access.Lists
+
+	// KickPlayer disconnects the named player, showing them the reason. It
+	// returns false if they aren't logged in.
+	KickPlayer(name string, reason string) bool
+
+	// KickBanned disconnects the players who are banned by name or IP
+	// address.
+	KickBanned()
+
+	// Chat returns the chat service, which checks and logs chat, and holds
+	// the mutes and players' ignore lists.
+	Chat() *chat.Service
+
+	// SendChat logs a line of chat from the named player, and sends it to
+	// every player that doesn't ignore them.
+	SendChat(from string, line string)
+
+	// Weather returns the weather that players see.
+	Weather() weather.Kind
+
+	// SetWeather changes the weather for the duration in ticks, or for a
+	// random duration if it is 0.
+	SetWeather(kind weather.Kind, duration Ticks)
+
+	// Worlds returns the names of the worlds hosted by the game, the main
+	// world first.
+	Worlds() []string
+
+	// PlayerWorld returns the name of the world that the named player is in.
+	// ok is false if they aren't logged in.
+	PlayerWorld(player string) (world string, ok bool)
+
+	// SendToWorld moves the named player to the named world, where they
+	// return to where they last were in it. It returns false if there is no
+	// such player or world.
+	SendToWorld(player string, world string) bool
 }
 
 // IShardClient is the interface by which shards communicate to players on
@@ -106,6 +206,9 @@ type IGame interface {
 type IPlayerClient interface {
 	GetEntityId() EntityId
 
+	// Name returns the player's name.
+	Name() string
+
 	TransmitPacket(packet []byte)
 
 	// NotifyChunkLoad informs Player that a chunk subscription request with
@@ -163,6 +266,40 @@ type IPlayerClient interface {
 
 	// EchoMessage displays a message to the player
 	EchoMessage(msg string)
+
+	// Damage reduces the player's health, killing them if it reaches zero.
+	Damage(amount Health, cause DamageCause)
+
+	// Hit damages the player as Damage does, and knocks them back away from
+	// the attacker's position.
+	Hit(attackerPos AbsXyz, amount Health, cause DamageCause)
+
+	// SetAir tells the player how many ticks they can still hold their breath
+	// for, so that it carries over to the next chunk that they enter.
+	SetAir(air Ticks)
+
+	// SetFire tells the player how many ticks they will keep burning for, so
+	// that it carries over to the next chunk that they enter.
+	SetFire(fire Ticks)
+
+	// ResetFall tells the player that their fall has been broken, such as by
+	// moving into water, so that they aren't damaged when they land.
+	ResetFall()
+
+	// EnterPortal informs the player that they have walked into a portal,
+	// which takes them to the other dimension.
+	EnterPortal()
+}
+
+// IBlockLog holds the changes made to the blocks of a dimension of a world.
+type IBlockLog interface {
+	// BlockChanges returns the logged changes to blocks that match the query,
+	// oldest first.
+	BlockChanges(query *blocklog.Query) ([]blocklog.Change, error)
+
+	// RevertBlockChanges undoes the changes, newest first, on behalf of the
+	// named player. Blocks that have changed again since are left alone.
+	RevertBlockChanges(player string, changes []blocklog.Change)
 }
 
 type ICommandFramework interface {
@@ -0,0 +1,102 @@
+package generation
+
+import (
+	. "chunkymonkey/types"
+)
+
+// treeKind is the kind of a tree, which is also the block data of its logs
+// and leaves.
+type treeKind byte
+
+const (
+	treeOak = treeKind(iota)
+	treeSpruce
+	treeBirch
+)
+
+// biomeInfo describes how the terrain of a biome is generated. The numbers of
+// trees and plants are out of 16 attempts to place them in each chunk.
+type biomeInfo struct {
+	// The land lies height blocks above SeaLevel, give or take variation.
+	height, variation float64
+
+	top, filler byte // The surface block, and the blocks just below it.
+
+	trees   int
+	tree    treeKind
+	birches int // Out of 16 trees, the number that are birches.
+	flowers int
+	grass   int
+	cacti   int
+
+	snow bool // Snow covers the ground and water freezes.
+}
+
+var biomes = [...]biomeInfo{
+	BiomeTundra: {
+		height: 2, variation: 3,
+		top: 2, filler: 3,
+		snow: true,
+	},
+	BiomeTaiga: {
+		height: 6, variation: 14,
+		top: 2, filler: 3,
+		trees: 8, tree: treeSpruce, grass: 1,
+		snow: true,
+	},
+	BiomePlains: {
+		height: 3, variation: 4,
+		top: 2, filler: 3,
+		flowers: 4, grass: 12,
+	},
+	BiomeForest: {
+		height: 5, variation: 10,
+		top: 2, filler: 3,
+		trees: 10, tree: treeOak, birches: 4, flowers: 2, grass: 2,
+	},
+	BiomeSwampland: {
+		height: -1, variation: 3,
+		top: 2, filler: 3,
+		trees: 2, tree: treeOak, grass: 4,
+	},
+	BiomeDesert: {
+		height: 2, variation: 5,
+		top: 12, filler: 12,
+		cacti: 3,
+	},
+	BiomeSavanna: {
+		height: 2, variation: 6,
+		top: 2, filler: 3,
+		trees: 1, tree: treeOak, flowers: 1, grass: 8,
+	},
+	BiomeRainforest: {
+		height: 8, variation: 20,
+		top: 2, filler: 3,
+		trees: 14, tree: treeOak, flowers: 2, grass: 6,
+	},
+}
+
+// biomeFor returns the biome with the given climate. Temperature and humidity
+// are between 0 and 1.
+func biomeFor(temperature, humidity float64) Biome {
+	switch {
+	case temperature < 0.25:
+		if humidity < 0.5 {
+			return BiomeTundra
+		}
+		return BiomeTaiga
+	case temperature < 0.65:
+		if humidity < 0.3 {
+			return BiomePlains
+		} else if humidity < 0.75 {
+			return BiomeForest
+		}
+		return BiomeSwampland
+	}
+	if humidity < 0.35 {
+		return BiomeDesert
+	} else if humidity < 0.6 {
+		return BiomeSavanna
+	}
+	return BiomeRainforest
+}
@@ -0,0 +1,287 @@
+package generation
+
+import (
+	"errors"
+	"math/rand"
+
+	"chunkymonkey/chunkstore"
+	. "chunkymonkey/types"
+	"perlin"
+)
+
+// biomeSmoothing is the distance over which the terrain blends from the shape
+// of one biome into the next.
+const biomeSmoothing = 4
+
+// Salts for the random number generators of each stage of generation, so that
+// each stage is independent of the others.
+const (
+	saltTerrain = int64(iota) * 0x5deece66d
+	saltCaves
+	saltOres
+	saltTrees
+	saltPlants
+)
+
+// BiomeGenerator implements chunkstore.IChunkStore. It generates terrain
+// whose shape and plants depend on the biome, which is chosen by the
+// temperature and humidity of the climate, and carves caves and ravines, and
+// veins of ore, through it.
+type BiomeGenerator struct {
+	seed        int64
+	temperature ISource
+	humidity    ISource
+	continent   ISource
+	terrain     ISource
+}
+
+// NewBiomeGenerator creates a BiomeGenerator. The chunks that it generates
+// depend only on the seed and their location.
+func NewBiomeGenerator(seed int64) *BiomeGenerator {
+	perlin := perlin.NewPerlinNoise(seed)
+
+	return &BiomeGenerator{
+		seed: seed,
+		temperature: &Add{
+			Value: 0.5,
+			Source: &Sum{
+				Inputs: []ISource{
+					&Scale{Wavelength: 300, Amplitude: 1.2, Source: &Offset{1000.1, 0, perlin}},
+					&Scale{Wavelength: 25, Amplitude: 0.1, Source: &Offset{1100.1, 0, perlin}},
+				},
+			},
+		},
+		humidity: &Add{
+			Value: 0.5,
+			Source: &Sum{
+				Inputs: []ISource{
+					&Scale{Wavelength: 250, Amplitude: 1.2, Source: &Offset{0, 2000.1, perlin}},
+					&Scale{Wavelength: 25, Amplitude: 0.1, Source: &Offset{0, 2100.1, perlin}},
+				},
+			},
+		},
+		continent: &Add{
+			Value:  4,
+			Source: &Scale{Wavelength: 400, Amplitude: 40, Source: &Offset{3000.1, 3000.1, perlin}},
+		},
+		terrain: &Sum{
+			Inputs: []ISource{
+				&Turbulence{
+					Dx:     &Scale{40, 1, &Offset{20.1, 0, perlin}},
+					Dy:     &Scale{40, 1, &Offset{10.1, 0, perlin}},
+					Factor: 10,
+					Source: &Scale{Wavelength: 80, Amplitude: 1.4, Source: perlin},
+				},
+				&Scale{Wavelength: 25, Amplitude: 0.5, Source: &Offset{40.1, 40.1, perlin}},
+				&Scale{Wavelength: 6, Amplitude: 0.1, Source: &Offset{50.1, 50.1, perlin}},
+			},
+		},
+	}
+}
+
+func (gen *BiomeGenerator) SupportsWrite() bool {
+	return false
+}
+
+func (gen *BiomeGenerator) Writer() chunkstore.IChunkWriter {
+	return nil
+}
+
+func (gen *BiomeGenerator) WriteChunk(writer chunkstore.IChunkWriter) error {
+	return errors.New("writes not supported by BiomeGenerator")
+}
+
+// Biome returns the biome of the column at (x, z).
+func (gen *BiomeGenerator) Biome(x, z BlockCoord) Biome {
+	xf, zf := float64(x), float64(z)
+	return biomeFor(gen.temperature.At2d(xf, zf), gen.humidity.At2d(xf, zf))
+}
+
+// SurfaceHeight returns the height of the ground in the column at (x, z),
+// before caves are carved into it.
+func (gen *BiomeGenerator) SurfaceHeight(x, z BlockCoord) BlockYCoord {
+	biomes := gen.biomeMap(x-biomeSmoothing, z-biomeSmoothing, 1+2*biomeSmoothing)
+	return BlockYCoord(gen.surfaceHeight(biomes, x, z))
+}
+
+// biomeMap holds the biomes of a square of columns.
+type biomeMap struct {
+	x, z   BlockCoord // The corner with the least coordinates.
+	width  int
+	biomes []Biome
+}
+
+func (gen *BiomeGenerator) biomeMap(x, z BlockCoord, width int) *biomeMap {
+	m := &biomeMap{
+		x:      x,
+		z:      z,
+		width:  width,
+		biomes: make([]Biome, width*width),
+	}
+	for dx := 0; dx < width; dx++ {
+		for dz := 0; dz < width; dz++ {
+			m.biomes[dx*width+dz] = gen.Biome(x+BlockCoord(dx), z+BlockCoord(dz))
+		}
+	}
+	return m
+}
+
+func (m *biomeMap) at(x, z BlockCoord) Biome {
+	return m.biomes[int(x-m.x)*m.width+int(z-m.z)]
+}
+
+// surfaceHeight returns the height of the ground in the column at (x, z),
+// shaped by the average of the biomes around it. m must hold the columns
+// within biomeSmoothing of it.
+func (gen *BiomeGenerator) surfaceHeight(m *biomeMap, x, z BlockCoord) int {
+	var height, variation float64
+	for dx := BlockCoord(-biomeSmoothing); dx <= biomeSmoothing; dx++ {
+		for dz := BlockCoord(-biomeSmoothing); dz <= biomeSmoothing; dz++ {
+			info := &biomes[m.at(x+dx, z+dz)]
+			height += info.height
+			variation += info.variation
+		}
+	}
+	const n = (1 + 2*biomeSmoothing) * (1 + 2*biomeSmoothing)
+	height /= n
+	variation /= n
+
+	xf, zf := float64(x), float64(z)
+	y := int(SeaLevel + gen.continent.At2d(xf, zf) + height + variation*gen.terrain.At2d(xf, zf))
+
+	// Leave room for bedrock below and trees above.
+	if y < 5 {
+		y = 5
+	} else if y > ChunkSizeY-16 {
+		y = ChunkSizeY - 16
+	}
+	return y
+}
+
+// chunkRand returns a random number generator for a stage of generating the
+// chunk at loc.
+func (gen *BiomeGenerator) chunkRand(loc ChunkXz, salt int64) *rand.Rand {
+	return rand.New(rand.NewSource(gen.seed ^ int64(loc.X)*341873128712 ^ int64(loc.Z)*132897987541 ^ salt))
+}
+
+// biomeChunk is a chunk being generated by a BiomeGenerator.
+type biomeChunk struct {
+	*ChunkData
+	corner  BlockXyz
+	biomes  *biomeMap
+	heights [ChunkSizeH * ChunkSizeH]int
+}
+
+// index returns the index of the block at (x, y, z), relative to the chunk's
+// corner, and whether it is within the chunk.
+func (chunk *biomeChunk) index(x, y, z int) (index BlockIndex, ok bool) {
+	if x < 0 || x >= ChunkSizeH || y < 0 || y >= ChunkSizeY || z < 0 || z >= ChunkSizeH {
+		return 0, false
+	}
+	subLoc := SubChunkXyz{SubChunkCoord(x), SubChunkCoord(y), SubChunkCoord(z)}
+	return subLoc.BlockIndex()
+}
+
+// setBlock sets the block at (x, y, z), relative to the chunk's corner, if it
+// is within the chunk and replace returns true for the block there now.
+func (chunk *biomeChunk) setBlock(x, y, z int, blockId, blockData byte, replace func(blockId byte) bool) {
+	index, ok := chunk.index(x, y, z)
+	if ok && replace(chunk.blocks[index]) {
+		chunk.blocks[index] = blockId
+		index.SetBlockData(chunk.blockData, blockData)
+	}
+}
+
+func (gen *BiomeGenerator) ReadChunk(chunkLoc ChunkXz) (reader chunkstore.IChunkReader, err error) {
+	corner := chunkLoc.ChunkCornerBlockXY()
+	chunk := &biomeChunk{
+		ChunkData: newChunkData(chunkLoc),
+		corner:    *corner,
+		biomes:    gen.biomeMap(corner.X-biomeSmoothing, corner.Z-biomeSmoothing, ChunkSizeH+2*biomeSmoothing),
+	}
+	rand := gen.chunkRand(chunkLoc, saltTerrain)
+
+	baseIndex := 0
+	column := 0
+	for x := 0; x < ChunkSizeH; x++ {
+		for z := 0; z < ChunkSizeH; z++ {
+			blockX, blockZ := corner.X+BlockCoord(x), corner.Z+BlockCoord(z)
+			height := gen.surfaceHeight(chunk.biomes, blockX, blockZ)
+			chunk.heights[column] = height
+
+			setBiomeBlockStack(
+				&biomes[chunk.biomes.at(blockX, blockZ)], height, rand,
+				chunk.blocks[baseIndex:baseIndex+ChunkSizeY])
+
+			column++
+			baseIndex += ChunkSizeY
+		}
+	}
+
+	gen.carveCaves(chunk)
+	gen.addOres(chunk)
+	gen.addTrees(chunk)
+	gen.addPlants(chunk)
+	addSnow(chunk)
+
+	setHeightMap(chunk.ChunkData)
+	setSkyLight(chunk.ChunkData)
+
+	return chunk.ChunkData, nil
+}
+
+// setBiomeBlockStack fills a column of blocks up to height, with the surface
+// of the biome on top and water up to SeaLevel.
+func setBiomeBlockStack(biome *biomeInfo, height int, rand *rand.Rand, blocks []byte) {
+	top, filler := biome.top, biome.filler
+	if height <= SeaLevel+1 && biome.top != 12 {
+		// Beaches and the sea bed.
+		top, filler = 12, 12
+		if height < SeaLevel-6 {
+			top, filler = 13, 3 // gravel and dirt
+		}
+	}
+
+	for y := SeaLevel; y > height; y-- {
+		blocks[y] = 9 // stationary water
+	}
+	blocks[height] = top
+	for y := height - 1; y > height-4 && y > 0; y-- {
+		blocks[y] = filler
+	}
+	if filler == 12 {
+		// Sand rests on sandstone.
+		for y := height - 4; y > height-6 && y > 0; y-- {
+			blocks[y] = 24 // sandstone
+		}
+	}
+	for y := 1; y < height && blocks[y] == 0; y++ {
+		blocks[y] = 1 // stone
+	}
+
+	// Bedrock is ragged at the bottom of the world.
+	blocks[0] = 7
+	for y := 1; y < 5; y++ {
+		if rand.Intn(5) < 5-y {
+			blocks[y] = 7
+		}
+	}
+}
+
+// setHeightMap sets the height map of the chunk to just above the highest
+// block in each column.
+func setHeightMap(data *ChunkData) {
+	baseIndex := 0
+	for column := range data.heightMap {
+		y := ChunkSizeY - 1
+		for y > 0 && data.blocks[baseIndex+y] == 0 {
+			y--
+		}
+		if y+1 < ChunkSizeY {
+			data.heightMap[column] = byte(y + 1)
+		} else {
+			data.heightMap[column] = byte(y)
+		}
+		baseIndex += ChunkSizeY
+	}
+}
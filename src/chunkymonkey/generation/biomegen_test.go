@@ -0,0 +1,126 @@
+package generation
+
+import (
+	"bytes"
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestBiomeFor(t *testing.T) {
+	tests := []struct {
+		temperature, humidity float64
+		want                  Biome
+	}{
+		{0, 0, BiomeTundra},
+		{0.1, 0.9, BiomeTaiga},
+		{0.5, 0.1, BiomePlains},
+		{0.5, 0.5, BiomeForest},
+		{0.5, 0.9, BiomeSwampland},
+		{0.9, 0.1, BiomeDesert},
+		{0.9, 0.5, BiomeSavanna},
+		{1, 1, BiomeRainforest},
+	}
+
+	for _, test := range tests {
+		if got := biomeFor(test.temperature, test.humidity); got != test.want {
+			t.Errorf("biomeFor(%v, %v) = %v, want %v", test.temperature, test.humidity, got, test.want)
+		}
+	}
+}
+
+func TestBiomeGenerator(t *testing.T) {
+	gen := NewBiomeGenerator(42)
+	loc := ChunkXz{5, -7}
+
+	reader, err := gen.ReadChunk(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := reader.Blocks()
+	heightMap := reader.HeightMap()
+
+	for column := 0; column < ChunkSizeH*ChunkSizeH; column++ {
+		stack := blocks[column*ChunkSizeY : (column+1)*ChunkSizeY]
+		if stack[0] != 7 {
+			t.Fatalf("column %d: got %d at the bottom, want bedrock", column, stack[0])
+		}
+		height := int(heightMap[column])
+		if stack[height-1] == 0 {
+			t.Errorf("column %d: got air below the height map at y=%d", column, height-1)
+		}
+		for y := height; y < ChunkSizeY; y++ {
+			if stack[y] != 0 {
+				t.Errorf("column %d: got %d above the height map at y=%d", column, stack[y], y)
+				break
+			}
+		}
+		// Veins of diamond begin below y=16, and are 7 blocks long.
+		for y := 16 + 7; y < ChunkSizeY; y++ {
+			if stack[y] == 56 {
+				t.Errorf("column %d: got diamond ore at y=%d", column, y)
+			}
+		}
+	}
+
+	// The same seed always generates the same chunk, whichever chunks were
+	// generated before it.
+	other := NewBiomeGenerator(42)
+	for x := loc.X - 1; x <= loc.X+1; x++ {
+		other.ReadChunk(ChunkXz{x, loc.Z + 1})
+	}
+	again, err := other.ReadChunk(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(blocks, again.Blocks()) || !bytes.Equal(reader.BlockData(), again.BlockData()) {
+		t.Errorf("chunks generated from the same seed differ")
+	}
+
+	different, err := NewBiomeGenerator(43).ReadChunk(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(blocks, different.Blocks()) {
+		t.Errorf("chunks generated from different seeds are the same")
+	}
+}
+
+func TestBiomeGeneratorSnow(t *testing.T) {
+	gen := NewBiomeGenerator(42)
+
+	// Find a cold column on dry land.
+	var x, z BlockCoord
+	found := false
+	for i := BlockCoord(0); i < 10000 && !found; i += 7 {
+		x, z = i, -i/2
+		found = gen.Biome(x, z) == BiomeTundra && gen.SurfaceHeight(x, z) > SeaLevel+1
+	}
+	if !found {
+		t.Fatal("found no tundra")
+	}
+
+	chunkLoc, subLoc := (&BlockXyz{x, 0, z}).ToChunkLocal()
+	reader, err := gen.ReadChunk(*chunkLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	column := int(subLoc.X)*ChunkSizeH + int(subLoc.Z)
+	top := reader.Blocks()[column*ChunkSizeY+int(reader.HeightMap()[column])-1]
+	if top != 78 {
+		t.Errorf("got block %d on top of tundra at (%d, %d), want snow", top, x, z)
+	}
+}
+
+func Benchmark_BiomeGenerator_generate(b *testing.B) {
+	gen := NewBiomeGenerator(0)
+	var loc ChunkXz
+
+	b.ResetTimer()
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		loc.X = ChunkCoord(i & 0xffff)
+		gen.ReadChunk(loc)
+	}
+}
@@ -0,0 +1,154 @@
+package generation
+
+import (
+	"math"
+	"math/rand"
+
+	. "chunkymonkey/types"
+)
+
+const (
+	// caveRange is the distance, in chunks, from which caves that begin in
+	// other chunks are carved into a chunk. No tunnel is long enough to reach
+	// further.
+	caveRange = 5
+
+	// Caves below this height fill with lava.
+	caveLavaLevel = 10
+)
+
+// carveCaves carves the tunnels and ravines that pass through the chunk.
+// Each begins in a chunk of its own, whose random number generator decides
+// its path, so that it is carved the same whichever chunk it passes through.
+func (gen *BiomeGenerator) carveCaves(chunk *biomeChunk) {
+	loc := chunk.loc
+	for cx := loc.X - caveRange; cx <= loc.X+caveRange; cx++ {
+		for cz := loc.Z - caveRange; cz <= loc.Z+caveRange; cz++ {
+			rand := gen.chunkRand(ChunkXz{cx, cz}, saltCaves)
+			originX := float64(cx) * ChunkSizeH
+			originZ := float64(cz) * ChunkSizeH
+
+			if rand.Intn(7) == 0 {
+				tunnels := 1 + rand.Intn(3)
+				for i := 0; i < tunnels; i++ {
+					t := tunnel{
+						x:      originX + rand.Float64()*ChunkSizeH,
+						y:      8 + rand.Float64()*64,
+						z:      originZ + rand.Float64()*ChunkSizeH,
+						yaw:    rand.Float64() * 2 * math.Pi,
+						pitch:  (rand.Float64() - 0.5) * 0.5,
+						radius: 1.5 + rand.Float64()*2,
+						length: 30 + rand.Intn(50),
+						twist:  0.4,
+					}
+					t.carve(chunk, rand)
+				}
+			}
+
+			if rand.Intn(50) == 0 {
+				ravine := tunnel{
+					x:       originX + rand.Float64()*ChunkSizeH,
+					y:       20 + rand.Float64()*30,
+					z:       originZ + rand.Float64()*ChunkSizeH,
+					yaw:     rand.Float64() * 2 * math.Pi,
+					pitch:   (rand.Float64() - 0.5) * 0.1,
+					radius:  1.5 + rand.Float64()*1.5,
+					stretch: 4 + rand.Float64()*2,
+					length:  50 + rand.Intn(30),
+					twist:   0.1,
+				}
+				ravine.carve(chunk, rand)
+			}
+		}
+	}
+}
+
+// tunnel is a winding cave, or a ravine when it is stretched vertically.
+type tunnel struct {
+	x, y, z    float64
+	yaw, pitch float64
+	radius     float64
+	stretch    float64 // Vertical stretch of the cross section, if > 1.
+	length     int
+	twist      float64 // How sharply the tunnel turns.
+}
+
+// carve carves the tunnel into the chunk. The random number generator is
+// used the same number of times, whether or not the tunnel reaches the chunk.
+func (t *tunnel) carve(chunk *biomeChunk, rand *rand.Rand) {
+	stretch := math.Max(t.stretch, 1)
+	var yawChange, pitchChange float64
+
+	for i := 0; i < t.length; i++ {
+		// Tunnels are widest in the middle.
+		radius := t.radius * (0.5 + math.Sin(float64(i)*math.Pi/float64(t.length)))
+
+		t.x += math.Cos(t.yaw) * math.Cos(t.pitch)
+		t.y += math.Sin(t.pitch)
+		t.z += math.Sin(t.yaw) * math.Cos(t.pitch)
+
+		t.pitch *= 0.7
+		t.pitch += pitchChange * 0.1
+		t.yaw += yawChange * 0.1
+		pitchChange = pitchChange*0.9 + (rand.Float64()-rand.Float64())*t.twist*4
+		yawChange = yawChange*0.75 + (rand.Float64()-rand.Float64())*t.twist*8
+
+		chunk.carveEllipsoid(t.x, t.y, t.z, radius, radius*stretch)
+	}
+}
+
+// carveEllipsoid clears the ground within the ellipsoid centered on (x, y, z)
+// in world coordinates, with the given horizontal and vertical radii.
+func (chunk *biomeChunk) carveEllipsoid(x, y, z, radius, height float64) {
+	x -= float64(chunk.corner.X)
+	z -= float64(chunk.corner.Z)
+	if x+radius < 0 || x-radius >= ChunkSizeH || z+radius < 0 || z-radius >= ChunkSizeH {
+		return
+	}
+
+	minX, maxX := clampInt(int(x-radius), 0, ChunkSizeH-1), clampInt(int(x+radius), 0, ChunkSizeH-1)
+	minY, maxY := clampInt(int(y-height), 1, ChunkSizeY-2), clampInt(int(y+height), 1, ChunkSizeY-2)
+	minZ, maxZ := clampInt(int(z-radius), 0, ChunkSizeH-1), clampInt(int(z+radius), 0, ChunkSizeH-1)
+
+	for bx := minX; bx <= maxX; bx++ {
+		dx := (float64(bx) + 0.5 - x) / radius
+		for bz := minZ; bz <= maxZ; bz++ {
+			dz := (float64(bz) + 0.5 - z) / radius
+			for by := maxY; by >= minY; by-- {
+				dy := (float64(by) + 0.5 - y) / height
+				if dx*dx+dy*dy+dz*dz < 1 {
+					chunk.carveBlock(bx, by, bz)
+				}
+			}
+		}
+	}
+}
+
+// carveBlock clears the ground at (x, y, z), relative to the chunk's corner.
+// The sea is left alone, as is the ground holding it up.
+func (chunk *biomeChunk) carveBlock(x, y, z int) {
+	index, _ := chunk.index(x, y, z)
+	switch chunk.blocks[index] {
+	case 1, 2, 3, 12, 13, 24: // stone, grass, dirt, sand, gravel, sandstone
+	default:
+		return
+	}
+	if above := chunk.blocks[index+1]; above == 8 || above == 9 {
+		return
+	}
+
+	if y < caveLavaLevel {
+		chunk.blocks[index] = 11 // stationary lava
+	} else {
+		chunk.blocks[index] = 0
+	}
+}
+
+func clampInt(n, min, max int) int {
+	if n < min {
+		return min
+	} else if n > max {
+		return max
+	}
+	return n
+}
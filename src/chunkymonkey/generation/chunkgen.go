@@ -14,6 +14,28 @@ import (
 
 const SeaLevel = 63
 
+// Names of generators, as given by generatorName in level.dat.
+const (
+	// DefaultGenerator generates new worlds.
+	DefaultGenerator = "default"
+
+	// UnnamedGenerator generates worlds whose level.dat names no generator.
+	// They were created before there was a choice of generator, so their
+	// stored chunks were generated by TestGenerator.
+	UnnamedGenerator = "test"
+)
+
+// Generators holds the constructors of the generators that a world's
+// level.dat may name, keyed by name.
+var Generators = map[string]func(seed int64) chunkstore.IChunkStoreForeground{
+	DefaultGenerator: func(seed int64) chunkstore.IChunkStoreForeground {
+		return NewBiomeGenerator(seed)
+	},
+	UnnamedGenerator: func(seed int64) chunkstore.IChunkStoreForeground {
+		return NewTestGenerator(seed)
+	},
+}
+
 // ChunkData implements chunkstore.IChunkReader.
 type ChunkData struct {
 	loc        ChunkXz
@@ -172,7 +194,7 @@ func (gen *TestGenerator) ReadChunk(chunkLoc ChunkXz) (reader chunkstore.IChunkR
 
 	// The chunk has been generated, now add some trees if appropriate
 	gen.addSaplings(data)
-	gen.setSkylight(data)
+	setSkyLight(data)
 
 	return data, nil
 }
@@ -212,7 +234,9 @@ func (gen *TestGenerator) setBlockStack(height int, blocks []byte) (skyLightHeig
 	return
 }
 
-func (gen *TestGenerator) setSkyLightStack(skyLightHeight int, blocks []byte, skyLight []byte) {
+// setSkyLightStack lights a column of blocks from the sky, which reaches
+// down to skyLightHeight at full strength.
+func setSkyLightStack(skyLightHeight int, blocks []byte, skyLight []byte) {
 	for y := ChunkSizeY - 1; y >= skyLightHeight; y-- {
 		BlockIndex(y).SetBlockData(skyLight, 15)
 	}
@@ -229,7 +253,8 @@ func (gen *TestGenerator) setSkyLightStack(skyLightHeight int, blocks []byte, sk
 	}
 }
 
-func (gen *TestGenerator) setSkylight(data *ChunkData) {
+// setSkyLight lights the chunk from the sky, according to its height map.
+func setSkyLight(data *ChunkData) {
 	baseIndex := 0
 	heightMapIndex := 0
 
@@ -237,7 +262,7 @@ func (gen *TestGenerator) setSkylight(data *ChunkData) {
 		for z := 0; z < ChunkSizeH; z++ {
 			lightBase := baseIndex >> 1
 
-			gen.setSkyLightStack(
+			setSkyLightStack(
 				int(data.heightMap[heightMapIndex]),
 				data.blocks[baseIndex:baseIndex+ChunkSizeY],
 				data.skyLight[lightBase:lightBase+ChunkSizeY/2])
@@ -245,7 +270,6 @@ func (gen *TestGenerator) setSkylight(data *ChunkData) {
 			baseIndex += ChunkSizeY
 		}
 	}
-
 }
 
 func (gen *TestGenerator) addSaplings(data *ChunkData) {
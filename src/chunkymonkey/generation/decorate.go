@@ -0,0 +1,273 @@
+package generation
+
+import (
+	. "chunkymonkey/types"
+)
+
+// ore describes the veins of an ore, or of dirt or gravel, that are found in
+// the stone of each chunk.
+type ore struct {
+	blockId    byte
+	veins      int // Number of veins in each chunk.
+	size       int // Number of blocks in each vein.
+	minY, maxY int // The veins lie between these heights.
+}
+
+// ores are ordered so that the rarer ores are placed last.
+var ores = []ore{
+	{3, 16, 24, 0, 128},  // dirt
+	{13, 8, 24, 0, 128},  // gravel
+	{16, 20, 14, 0, 128}, // coal
+	{15, 20, 8, 0, 64},   // iron
+	{14, 2, 8, 0, 32},    // gold
+	{73, 8, 7, 0, 16},    // redstone
+	{21, 1, 6, 8, 24},    // lapis lazuli
+	{56, 1, 7, 0, 16},    // diamond
+}
+
+// addOres places veins of ore in the stone of the chunk. Veins don't cross
+// into other chunks.
+func (gen *BiomeGenerator) addOres(chunk *biomeChunk) {
+	rand := gen.chunkRand(chunk.loc, saltOres)
+	isStone := func(blockId byte) bool { return blockId == 1 }
+
+	for i := range ores {
+		ore := &ores[i]
+		for vein := 0; vein < ore.veins; vein++ {
+			x := rand.Intn(ChunkSizeH)
+			y := ore.minY + rand.Intn(ore.maxY-ore.minY)
+			z := rand.Intn(ChunkSizeH)
+			for n := 0; n < ore.size; n++ {
+				chunk.setBlock(x, y, z, ore.blockId, 0, isStone)
+				switch rand.Intn(6) {
+				case 0:
+					x--
+				case 1:
+					x++
+				case 2:
+					y--
+				case 3:
+					y++
+				case 4:
+					z--
+				case 5:
+					z++
+				}
+			}
+		}
+	}
+}
+
+// treeAttempts is the number of places in each chunk where a tree may grow,
+// depending on the biome there.
+const treeAttempts = 16
+
+// addTrees grows trees in the chunk, including those that begin in the
+// chunks around it and overhang it.
+func (gen *BiomeGenerator) addTrees(chunk *biomeChunk) {
+	loc := chunk.loc
+	for cx := loc.X - 1; cx <= loc.X+1; cx++ {
+		for cz := loc.Z - 1; cz <= loc.Z+1; cz++ {
+			origin := ChunkXz{cx, cz}
+			rand := gen.chunkRand(origin, saltTrees)
+			corner := origin.ChunkCornerBlockXY()
+
+			for i := 0; i < treeAttempts; i++ {
+				x := corner.X + BlockCoord(rand.Intn(ChunkSizeH))
+				z := corner.Z + BlockCoord(rand.Intn(ChunkSizeH))
+				chance := rand.Intn(treeAttempts)
+				trunk := 4 + rand.Intn(3)
+				variant := rand.Intn(16)
+				shape := rand.Int63()
+
+				biome := &biomes[gen.Biome(x, z)]
+				if chance >= biome.trees {
+					continue
+				}
+				height := int(gen.SurfaceHeight(x, z))
+				if height <= SeaLevel+1 || biome.top != 2 {
+					continue
+				}
+
+				kind := biome.tree
+				if variant < biome.birches {
+					kind = treeBirch
+				}
+				chunk.addTree(
+					int(x-chunk.corner.X), height, int(z-chunk.corner.Z),
+					kind, trunk, shape)
+			}
+		}
+	}
+}
+
+func isAir(blockId byte) bool {
+	return blockId == 0
+}
+
+func isAirOrLeaves(blockId byte) bool {
+	return blockId == 0 || blockId == 18
+}
+
+func isGrass(blockId byte) bool {
+	return blockId == 2
+}
+
+// addTree grows a tree on the ground at (x, y, z), relative to the chunk's
+// corner, whose trunk is trunk blocks tall. The bits of shape decide which
+// corners of the leaves are missing.
+func (chunk *biomeChunk) addTree(x, y, z int, kind treeKind, trunk int, shape int64) {
+	top := y + trunk
+	data := byte(kind)
+
+	chunk.setBlock(x, y, z, 3, 0, isGrass) // dirt
+
+	if kind == treeSpruce {
+		// Spruce trees are cones of leaves, with alternately wider and
+		// narrower layers.
+		radius := 0
+		for ly := top + 1; ly > y+1; ly-- {
+			chunk.addLeaves(x, ly, z, radius, data, &shape)
+			if radius == 1 {
+				radius = 2
+			} else {
+				radius = 1
+			}
+		}
+	} else {
+		for ly := top - 3; ly <= top+1; ly++ {
+			radius := 2
+			if ly >= top {
+				radius = 1
+			}
+			chunk.addLeaves(x, ly, z, radius, data, &shape)
+		}
+	}
+
+	for ly := y + 1; ly <= top; ly++ {
+		chunk.setBlock(x, ly, z, 17, data, isAirOrLeaves) // log
+	}
+}
+
+// addLeaves places a square layer of leaves. Its corners may be missing,
+// depending on the next bit of shape.
+func (chunk *biomeChunk) addLeaves(x, y, z, radius int, data byte, shape *int64) {
+	for dx := -radius; dx <= radius; dx++ {
+		for dz := -radius; dz <= radius; dz++ {
+			if radius > 0 && (dx == -radius || dx == radius) && (dz == -radius || dz == radius) {
+				missing := *shape&1 == 0
+				*shape >>= 1
+				if missing {
+					continue
+				}
+			}
+			chunk.setBlock(x+dx, y, z+dz, 18, data, isAir) // leaves
+		}
+	}
+}
+
+// plantAttempts is the number of places in each chunk where each kind of
+// plant may grow, depending on the biome there.
+const plantAttempts = 16
+
+// addPlants places flowers, tall grass and cacti in the chunk. They lie
+// entirely within the chunk.
+func (gen *BiomeGenerator) addPlants(chunk *biomeChunk) {
+	rand := gen.chunkRand(chunk.loc, saltPlants)
+
+	for i := 0; i < plantAttempts; i++ {
+		x, z := rand.Intn(ChunkSizeH), rand.Intn(ChunkSizeH)
+		chance := rand.Intn(plantAttempts)
+		if flowers := chunk.biomeAt(x, z).flowers; chance < flowers {
+			flower := byte(37) // yellow flower
+			if chance%2 == 0 {
+				flower = 38 // red rose
+			}
+			chunk.addPlant(x, z, flower, 0, isGrass)
+		}
+	}
+
+	for i := 0; i < plantAttempts; i++ {
+		x, z := rand.Intn(ChunkSizeH), rand.Intn(ChunkSizeH)
+		if rand.Intn(plantAttempts) < chunk.biomeAt(x, z).grass {
+			chunk.addPlant(x, z, 31, 1, isGrass) // tall grass
+		}
+	}
+
+	for i := 0; i < plantAttempts; i++ {
+		// Cacti must not touch other blocks, so they are kept away from the
+		// edges of the chunk where the neighbouring blocks are unknown.
+		x, z := 1+rand.Intn(ChunkSizeH-2), 1+rand.Intn(ChunkSizeH-2)
+		chance := rand.Intn(plantAttempts)
+		height := 1 + rand.Intn(3)
+		if chance < chunk.biomeAt(x, z).cacti {
+			chunk.addCactus(x, z, height)
+		}
+	}
+}
+
+// biomeAt returns the biome of the column at (x, z), relative to the chunk's
+// corner.
+func (chunk *biomeChunk) biomeAt(x, z int) *biomeInfo {
+	return &biomes[chunk.biomes.at(chunk.corner.X+BlockCoord(x), chunk.corner.Z+BlockCoord(z))]
+}
+
+// addPlant places a plant on the ground of the column, if the ground is
+// suitable and hasn't been carved away.
+func (chunk *biomeChunk) addPlant(x, z int, blockId, blockData byte, ground func(blockId byte) bool) {
+	y := chunk.heights[x*ChunkSizeH+z]
+	index, ok := chunk.index(x, y, z)
+	if ok && ground(chunk.blocks[index]) {
+		chunk.setBlock(x, y+1, z, blockId, blockData, isAir)
+	}
+}
+
+func (chunk *biomeChunk) addCactus(x, z, height int) {
+	y := chunk.heights[x*ChunkSizeH+z]
+	index, _ := chunk.index(x, y, z)
+	if chunk.blocks[index] != 12 {
+		return
+	}
+	for ly := y + 1; ly <= y+height; ly++ {
+		if !chunk.isAirAround(x, ly, z) {
+			return
+		}
+		chunk.setBlock(x, ly, z, 81, 0, isAir) // cactus
+	}
+}
+
+// isAirAround returns true if the blocks on each side of (x, y, z) are air.
+func (chunk *biomeChunk) isAirAround(x, y, z int) bool {
+	for _, side := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		index, ok := chunk.index(x+side[0], y, z+side[1])
+		if !ok || chunk.blocks[index] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// addSnow covers the ground of cold biomes with snow, and freezes their water.
+func addSnow(chunk *biomeChunk) {
+	for x := 0; x < ChunkSizeH; x++ {
+		for z := 0; z < ChunkSizeH; z++ {
+			if !chunk.biomeAt(x, z).snow {
+				continue
+			}
+
+			y := ChunkSizeY - 2
+			index, _ := chunk.index(x, y, z)
+			for y > 0 && chunk.blocks[index] == 0 {
+				y--
+				index--
+			}
+
+			switch chunk.blocks[index] {
+			case 9: // stationary water
+				chunk.blocks[index] = 79 // ice
+			case 1, 2, 3, 12, 13, 17, 18, 24:
+				chunk.blocks[index+1] = 78 // snow
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package generation
+
+import (
+	"errors"
+	"math/rand"
+
+	"chunkymonkey/chunkstore"
+	. "chunkymonkey/types"
+	"perlin"
+)
+
+const (
+	// NetherLavaLevel is the height of the top of the lava sea in the Nether.
+	NetherLavaLevel = 31
+
+	// The floor and ceiling of the caverns vary around these heights.
+	netherFloor   = 36
+	netherCeiling = 100
+)
+
+// NetherGenerator implements chunkstore.IChunkStore. It generates the
+// caverns of the Nether: netherrack between floors and ceilings of bedrock,
+// with a sea of lava, patches of soul sand and glowstone hanging from the
+// ceiling.
+type NetherGenerator struct {
+	seed          int64
+	floorSource   ISource
+	ceilingSource ISource
+	soulSand      ISource
+}
+
+// NewNetherGenerator creates a NetherGenerator. The chunks that it generates
+// depend only on the seed and their location.
+func NewNetherGenerator(seed int64) *NetherGenerator {
+	perlin := perlin.NewPerlinNoise(seed)
+
+	return &NetherGenerator{
+		seed: seed,
+		floorSource: &Sum{
+			Inputs: []ISource{
+				&Scale{Wavelength: 60, Amplitude: 20, Source: perlin},
+				&Scale{Wavelength: 12, Amplitude: 4, Source: &Offset{30.1, 0, perlin}},
+			},
+		},
+		ceilingSource: &Sum{
+			Inputs: []ISource{
+				&Scale{Wavelength: 50, Amplitude: 20, Source: &Offset{0, 40.1, perlin}},
+				&Scale{Wavelength: 10, Amplitude: 5, Source: &Offset{50.1, 0, perlin}},
+			},
+		},
+		soulSand: &Scale{Wavelength: 30, Amplitude: 1, Source: &Offset{70.1, 70.1, perlin}},
+	}
+}
+
+func (gen *NetherGenerator) SupportsWrite() bool {
+	return false
+}
+
+func (gen *NetherGenerator) Writer() chunkstore.IChunkWriter {
+	return nil
+}
+
+func (gen *NetherGenerator) WriteChunk(writer chunkstore.IChunkWriter) error {
+	return errors.New("writes not supported by NetherGenerator")
+}
+
+func (gen *NetherGenerator) ReadChunk(chunkLoc ChunkXz) (reader chunkstore.IChunkReader, err error) {
+	baseBlockXyz := chunkLoc.ChunkCornerBlockXY()
+	baseX, baseZ := baseBlockXyz.X, baseBlockXyz.Z
+
+	data := newChunkData(chunkLoc)
+	rand := rand.New(rand.NewSource(gen.seed ^ int64(chunkLoc.X)*341873128712 ^ int64(chunkLoc.Z)*132897987541))
+
+	baseIndex := 0
+	heightMapIndex := 0
+	for x := 0; x < ChunkSizeH; x++ {
+		for z := 0; z < ChunkSizeH; z++ {
+			xf, zf := float64(x)+float64(baseX), float64(z)+float64(baseZ)
+			floor := int(netherFloor + gen.floorSource.At2d(xf, zf))
+			ceiling := int(netherCeiling + gen.ceilingSource.At2d(xf, zf))
+			soulSand := gen.soulSand.At2d(xf, zf) > 0.3
+
+			gen.setBlockStack(
+				floor, ceiling, soulSand, rand,
+				data.blocks[baseIndex:baseIndex+ChunkSizeY])
+
+			// There is no sky in the Nether, so the sky light stays at 0.
+			data.heightMap[heightMapIndex] = ChunkSizeY - 1
+
+			heightMapIndex++
+			baseIndex += ChunkSizeY
+		}
+	}
+
+	return data, nil
+}
+
+// setBlockStack fills a column of blocks. The cavern is open between the
+// floor and ceiling heights, and the lava sea fills it up to NetherLavaLevel.
+func (gen *NetherGenerator) setBlockStack(floor, ceiling int, soulSand bool, rand *rand.Rand, blocks []byte) {
+	for y := 1; y < ChunkSizeY-1; y++ {
+		switch {
+		case y <= floor || y >= ceiling:
+			blocks[y] = 87 // netherrack
+		case y <= NetherLavaLevel:
+			blocks[y] = 11 // stationary lava
+		}
+	}
+	blocks[0] = 7            // bedrock
+	blocks[ChunkSizeY-1] = 7 // bedrock
+
+	if floor >= ceiling || floor <= 0 || ceiling >= ChunkSizeY-1 {
+		return
+	}
+
+	if soulSand && floor > NetherLavaLevel {
+		for y := floor; y > floor-3; y-- {
+			blocks[y] = 88 // soul sand
+		}
+	}
+
+	// Glowstone hangs in clumps from the ceiling.
+	if rand.Intn(100) == 0 {
+		length := 1 + rand.Intn(4)
+		for y := ceiling - 1; y >= ceiling-length && y > floor; y-- {
+			blocks[y] = 89 // glowstone
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package generation
+
+import (
+	"bytes"
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestNetherGenerator(t *testing.T) {
+	loc := ChunkXz{3, -2}
+
+	reader, err := NewNetherGenerator(42).ReadChunk(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := reader.Blocks()
+
+	for column := 0; column < ChunkSizeH*ChunkSizeH; column++ {
+		stack := blocks[column*ChunkSizeY : (column+1)*ChunkSizeY]
+		if stack[0] != 7 || stack[ChunkSizeY-1] != 7 {
+			t.Fatalf("column %d: got %d and %d at the bottom and top, want bedrock", column, stack[0], stack[ChunkSizeY-1])
+		}
+		for y := 1; y <= NetherLavaLevel; y++ {
+			if stack[y] == 0 {
+				t.Fatalf("column %d: got air at y=%d, below the lava level", column, y)
+			}
+		}
+	}
+
+	// The same seed always generates the same chunk.
+	again, err := NewNetherGenerator(42).ReadChunk(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(blocks, again.Blocks()) {
+		t.Errorf("chunks generated from the same seed differ")
+	}
+}
@@ -0,0 +1,226 @@
+package chunkymonkey
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"chunkymonkey/chat"
+	. "chunkymonkey/types"
+)
+
+// maxBroadcastLength is the longest message, in characters, that can be
+// broadcast through the HTTP API. It leaves room for the color code within the
+// 119 characters that clients accept in a chat message.
+const maxBroadcastLength = 100
+
+// PlayerStatus describes a connected player in the HTTP API.
+type PlayerStatus struct {
+	Name      string
+	World     string
+	Dimension DimensionId
+	Position  AbsXyz
+	PingMs    int64 // The roundtrip time of the last keep-alive ping.
+}
+
+// ShardStatus describes a shard that is running in this process in the HTTP
+// API.
+type ShardStatus struct {
+	World     string
+	Dimension DimensionId
+	X, Z      ShardCoord
+	Chunks    int // The number of chunks loaded.
+}
+
+// WorldStatus describes a world in the HTTP API. The time is shared by all of
+// the worlds.
+type WorldStatus struct {
+	Name  string
+	Time  Ticks
+	Spawn BlockXyz
+}
+
+// httpApi serves the status of the game as JSON, and lets operators with the
+// token make changes to the game. The status is read from within the Game
+// goroutine, or the goroutines that own it.
+//
+//	GET  /api/players                 []PlayerStatus
+//	GET  /api/shards                  []ShardStatus
+//	GET  /api/world                   WorldStatus of the main world
+//	GET  /api/worlds                  []WorldStatus
+//	POST /api/broadcast message=...   Sends a chat message to all players.
+//	POST /api/kick name=...&reason=.. Kicks a player.
+//	POST /api/save                    Saves the world.
+//
+// POST requests must give the token in an "Authorization: Bearer <token>"
+// header.
+type httpApi struct {
+	game  *Game
+	token string
+}
+
+// HttpHandler returns the handler for the HTTP API, which serves under
+// "/api/". Requests that change the game must give token, and are refused if
+// it is empty.
+func (game *Game) HttpHandler(token string) http.Handler {
+	api := &httpApi{game: game, token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/players", api.get(api.players))
+	mux.HandleFunc("/api/shards", api.get(api.shards))
+	mux.HandleFunc("/api/world", api.get(api.world))
+	mux.HandleFunc("/api/worlds", api.get(api.worlds))
+	mux.HandleFunc("/api/broadcast", api.post(api.broadcast))
+	mux.HandleFunc("/api/kick", api.post(api.kick))
+	mux.HandleFunc("/api/save", api.post(api.save))
+	return mux
+}
+
+// get wraps a handler that returns the status to write as JSON.
+func (api *httpApi) get(handler func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJson(w, http.StatusOK, handler())
+	}
+}
+
+// post wraps a handler that changes the game, checking the token first. The
+// handler returns the HTTP status code and the message to write.
+func (api *httpApi) post(handler func(r *http.Request) (int, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		if !api.authorized(r) {
+			log.Printf("HTTP API: refused unauthorized %s from %s", r.URL.Path, r.RemoteAddr)
+			http.Error(w, "Bad or missing token", http.StatusForbidden)
+			return
+		}
+		code, msg := handler(r)
+		log.Printf("HTTP API: %s from %s: %s", r.URL.Path, r.RemoteAddr, msg)
+		writeJson(w, code, map[string]string{"Message": msg})
+	}
+}
+
+func (api *httpApi) authorized(r *http.Request) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if api.token == "" || !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(api.token)) == 1
+}
+
+func writeJson(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("HTTP API: failed to write response: %v", err)
+	}
+}
+
+func (api *httpApi) players() interface{} {
+	// The players' statuses are taken within their own goroutines.
+	snapshots := snapshotPlayers(api.game.connectedPlayers())
+	statuses := make([]PlayerStatus, 0, len(snapshots))
+	for _, s := range snapshots {
+		statuses = append(statuses, PlayerStatus{
+			Name:      s.player.Name(),
+			World:     s.world,
+			Dimension: s.dimension,
+			Position:  s.position,
+			PingMs:    int64(s.latency / 1e6),
+		})
+	}
+	sort.Sort(playerStatusesByName(statuses))
+	return statuses
+}
+
+type playerStatusesByName []PlayerStatus
+
+func (s playerStatusesByName) Len() int           { return len(s) }
+func (s playerStatusesByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s playerStatusesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (api *httpApi) shards() interface{} {
+	// The shards are asked through their own goroutines.
+	statuses := []ShardStatus{}
+	for _, name := range api.game.worldNames {
+		w := api.game.worlds[name]
+		for _, stat := range w.shardManager.Stats() {
+			statuses = append(statuses, ShardStatus{name, DimensionNormal, stat.Loc.X, stat.Loc.Z, stat.Chunks})
+		}
+		for _, stat := range w.netherManager.Stats() {
+			statuses = append(statuses, ShardStatus{name, DimensionNether, stat.Loc.X, stat.Loc.Z, stat.Chunks})
+		}
+	}
+	return statuses
+}
+
+func (api *httpApi) world() interface{} {
+	return api.worldStatuses()[0]
+}
+
+func (api *httpApi) worlds() interface{} {
+	return api.worldStatuses()
+}
+
+// worldStatuses returns the status of each world, the main world first.
+func (api *httpApi) worldStatuses() []WorldStatus {
+	game := api.game
+	result := make(chan []WorldStatus)
+	game.enqueue(func(_ *Game) {
+		statuses := make([]WorldStatus, 0, len(game.worldNames))
+		for _, name := range game.worldNames {
+			statuses = append(statuses, WorldStatus{
+				Name:  name,
+				Time:  game.time,
+				Spawn: game.worlds[name].SpawnBlock,
+			})
+		}
+		result <- statuses
+	})
+	return <-result
+}
+
+func (api *httpApi) broadcast(r *http.Request) (int, string) {
+	msg := r.FormValue("message")
+	if utf8.RuneCountInString(msg) > maxBroadcastLength {
+		return http.StatusBadRequest, fmt.Sprintf("The message is longer than %d characters", maxBroadcastLength)
+	}
+	// As with players' messages, "&" color codes are allowed.
+	msg = chat.Sanitize(msg, true)
+	if strings.TrimSpace(msg) == "" {
+		return http.StatusBadRequest, "No message given"
+	}
+	api.game.BroadcastMessage("§d" + msg)
+	return http.StatusOK, "Broadcast: " + msg
+}
+
+func (api *httpApi) kick(r *http.Request) (int, string) {
+	name := r.FormValue("name")
+	reason := r.FormValue("reason")
+	if reason == "" {
+		reason = "Kicked by an operator."
+	}
+	if !api.game.KickPlayer(name, reason) {
+		return http.StatusNotFound, "'" + name + "' is not logged in"
+	}
+	return http.StatusOK, "Kicked " + name
+}
+
+func (api *httpApi) save(r *http.Request) (int, string) {
+	if !api.game.Save() {
+		return http.StatusServiceUnavailable, "The server is shutting down"
+	}
+	return http.StatusOK, "Saved the world"
+}
@@ -0,0 +1,89 @@
+package chunkymonkey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHttpApiToken(t *testing.T) {
+	tests := []struct {
+		desc   string
+		token  string // The token that the API is served with.
+		auth   string // The Authorization header, if any.
+		method string
+		want   int
+	}{
+		{"no header", "secret", "", "POST", http.StatusForbidden},
+		{"wrong token", "secret", "Bearer wrong", "POST", http.StatusForbidden},
+		{"token prefix", "secret", "Bearer secre", "POST", http.StatusForbidden},
+		{"not bearer", "secret", "secret", "POST", http.StatusForbidden},
+		{"empty token refused", "", "Bearer ", "POST", http.StatusForbidden},
+		{"empty token without header", "", "", "POST", http.StatusForbidden},
+		{"GET refused", "secret", "Bearer secret", "GET", http.StatusMethodNotAllowed},
+		// The broadcast has no message, so is refused once the token is
+		// accepted, without reaching the game.
+		{"right token", "secret", "Bearer secret", "POST", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(new(Game).HttpHandler(test.token))
+
+		req, err := http.NewRequest(test.method, server.URL+"/api/broadcast", strings.NewReader(url.Values{}.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+		} else {
+			if resp.StatusCode != test.want {
+				t.Errorf("%s: got status %d, want %d", test.desc, resp.StatusCode, test.want)
+			}
+			resp.Body.Close()
+		}
+		server.Close()
+	}
+}
+
+func TestHttpApiBroadcastRefused(t *testing.T) {
+	tests := []struct {
+		desc    string
+		message string
+	}{
+		{"empty", ""},
+		{"spaces", "   "},
+		{"only codes", "§\x00\n"},
+		{"too long", strings.Repeat("a", maxBroadcastLength+1)},
+	}
+
+	server := httptest.NewServer(new(Game).HttpHandler("secret"))
+	defer server.Close()
+
+	for _, test := range tests {
+		body := url.Values{"message": {test.message}}.Encode()
+		req, err := http.NewRequest("POST", server.URL+"/api/broadcast", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer secret")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+			continue
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want %d", test.desc, resp.StatusCode, http.StatusBadRequest)
+		}
+		resp.Body.Close()
+	}
+}
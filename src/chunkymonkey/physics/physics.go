@@ -53,6 +53,23 @@ func (obj *PointObject) Position() *AbsXyz {
 	return &obj.position
 }
 
+func (obj *PointObject) Velocity() *AbsVelocity {
+	return &obj.velocity
+}
+
+// SetVelocity changes the object's velocity, such as when it decides to move.
+// An upwards velocity lifts the object off the ground.
+func (obj *PointObject) SetVelocity(velocity *AbsVelocity) {
+	obj.velocity = *velocity
+	if velocity.Y > 0 {
+		obj.onGround = false
+	}
+}
+
+func (obj *PointObject) OnGround() bool {
+	return obj.onGround
+}
+
 func (obj *PointObject) Init(position *AbsXyz, velocity *AbsVelocity) {
 	obj.LastSentPosition = *position.ToAbsIntXyz()
 	obj.LastSentVelocity = *velocity.ToVelocity()
@@ -156,8 +173,16 @@ func (obj *PointObject) Tick(blockQuerier IBlockQuerier) (leftChunk bool) {
 	p := &obj.position
 	v := &obj.velocity
 
-	// FIXME note that if the block under the item should become non-solid,
-	// then we need to turn off onGround to re-enable physics
+	if obj.onGround {
+		// The object falls if the block under it is no longer solid, or if it has
+		// moved off the edge of it.
+		if below := p.ToBlockXyz(); below.Y > 0 {
+			below.Y--
+			if isSolid, _ := blockQuerier.BlockQuery(*below); !isSolid {
+				obj.onGround = false
+			}
+		}
+	}
 	// TODO if the object has stopped moving (i.e is at rest on top of a solid
 	// block and not inside a flowing block), take the object out of a
 	// "physically active" list. Note that the object will have to be re-added
@@ -0,0 +1,76 @@
+package player
+
+import (
+	"bytes"
+	"log"
+
+	"chunkymonkey/gamerules"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+// permissionPvp is the permission that players need to hit and be hit by
+// other players.
+const permissionPvp = "pvp"
+
+// attackPlayer hits another player, if both players are allowed to fight each
+// other. It must be called without player.lock held, as it waits upon the
+// other player.
+func (player *Player) attackPlayer(other gamerules.IPlayerClient, position *AbsXyz, held *gamerules.Slot) {
+	if !player.hasPermission(permissionPvp) {
+		player.playerClient.EchoMessage("You are not allowed to attack other players.")
+		return
+	}
+
+	other.Hit(*position, gamerules.AttackDamage(held), DamageCausePlayer)
+}
+
+// attackEntity asks the shards that might contain the target entity to hit it.
+// It must be called with player.lock held.
+func (player *Player) attackEntity(held *gamerules.Slot, target EntityId) {
+	sent := make(map[gamerules.IPlayerShardClient]bool)
+
+	for _, dx := range []AbsCoord{-gamerules.MaxAttackDistance, gamerules.MaxAttackDistance} {
+		for _, dz := range []AbsCoord{-gamerules.MaxAttackDistance, gamerules.MaxAttackDistance} {
+			corner := player.position
+			corner.X += dx
+			corner.Z += dz
+			shardClient, _, ok := player.chunkSubs.ShardClientForBlockXyz(corner.ToBlockXyz())
+			if ok && !sent[shardClient] {
+				sent[shardClient] = true
+				shardClient.ReqHitEntity(*held, player.position, target)
+			}
+		}
+	}
+}
+
+// hit damages the player and knocks them away from the attacker. It must be
+// called with player.lock held.
+func (player *Player) hit(attackerPos *AbsXyz, amount Health, cause DamageCause) {
+	if player.dead {
+		return
+	}
+
+	if !player.position.IsWithinDistanceOf(attackerPos, gamerules.MaxAttackDistance) {
+		log.Printf("Player/hit: ignoring hit on %s from too far away", player.name)
+		return
+	}
+
+	if cause == DamageCausePlayer && !player.hasPermission(permissionPvp) {
+		return
+	}
+
+	knockback := gamerules.Knockback(attackerPos, &player.position)
+	buf := new(bytes.Buffer)
+	proto.WriteEntityVelocity(buf, player.EntityId, knockback.ToVelocity())
+	player.TransmitPacket(buf.Bytes())
+
+	// The knockback lifts the player, which mustn't add to a fall.
+	player.fallDistance = 0
+
+	player.damage(amount, cause)
+}
+
+func (player *Player) hasPermission(node string) bool {
+	return gamerules.Permissions.UserPermissions(player.name).Has(node)
+}
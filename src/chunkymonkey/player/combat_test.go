@@ -0,0 +1,22 @@
+package player
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestPlayer_hitResetsFall(t *testing.T) {
+	player := NewPlayer(1, &World{SpawnBlock: BlockXyz{0, 64, 0}}, nil, "test", ChunkRadius, nil, nil)
+	player.position = AbsXyz{0.5, 70, 0.5}
+
+	// The player was falling when hit, and lands after the knockback.
+	player.updateFall(66, false)
+	player.position.Y = 66
+	player.hit(&AbsXyz{1.5, 66, 0.5}, 1, DamageCauseMob)
+	player.updateFall(65, true)
+
+	if want := MaxHealth - 1; player.health != want {
+		t.Errorf("got health %d, want %d", player.health, want)
+	}
+}
@@ -0,0 +1,118 @@
+package player
+
+import (
+	"bytes"
+	"time"
+
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+const (
+	// netherScale is how much further apart places are in DimensionNormal
+	// than in DimensionNether.
+	netherScale = 8
+
+	// portalCooldown is how long after going through a portal before a
+	// player can go through one again.
+	portalCooldown = 3 * time.Second
+)
+
+// spawnPosition returns the position at the player's spawn block.
+func (player *Player) spawnPosition() AbsXyz {
+	return AbsXyz{
+		X: AbsCoord(player.world.SpawnBlock.X),
+		Y: AbsCoord(player.world.SpawnBlock.Y),
+		Z: AbsCoord(player.world.SpawnBlock.Z),
+	}
+}
+
+// checkDimension moves the player to the spawn if they are in a dimension
+// that isn't served in their world.
+func (player *Player) checkDimension() {
+	if _, ok := player.world.ShardConnecters[player.dimension]; !ok {
+		player.dimension = DimensionNormal
+		player.position = player.spawnPosition()
+	}
+}
+
+// enterPortal takes the player through a portal to the other dimension. They
+// arrive beside the portal nearest the same place, scaled between the
+// dimensions, and a portal is built there if there is none.
+func (player *Player) enterPortal() {
+	if !player.spawnComplete || player.dead || time.Since(player.portalTime) < portalCooldown {
+		return
+	}
+
+	dimension, scale := DimensionNether, AbsCoord(1)/netherScale
+	if player.dimension == DimensionNether {
+		dimension, scale = DimensionNormal, netherScale
+	}
+	if _, ok := player.world.ShardConnecters[dimension]; !ok {
+		return
+	}
+	player.portalTime = time.Now()
+
+	position := AbsXyz{
+		X: player.position.X * scale,
+		Y: player.position.Y,
+		Z: player.position.Z * scale,
+	}
+	player.changeDimension(dimension, position)
+
+	if shardClient, ok := player.chunkSubs.CurrentShardClient(); ok {
+		shardClient.ReqLinkPortal(*position.ToBlockXyz())
+	}
+}
+
+// changeDimension moves the player to the position in another dimension.
+func (player *Player) changeDimension(dimension DimensionId, position AbsXyz) {
+	player.closeCurrentWindow(true)
+	player.chunkSubs.Close()
+
+	player.dimension = dimension
+	player.position = position
+	player.fallDistance = 0
+	player.enterDimension()
+}
+
+// enterDimension tells the client to respawn in the player's dimension, and
+// subscribes to the chunks around the player there. The client drops the
+// chunks of the old dimension when it respawns in a new one, and the player's
+// position is sent once the chunk they arrive in has loaded.
+func (player *Player) enterDimension() {
+	player.shardConnecter = player.world.ShardConnecters[player.dimension]
+	player.spawnComplete = false
+
+	player.writeRespawn(player.dimension)
+	player.chunkSubs.Init(player)
+}
+
+// writeRespawn tells the client to respawn in the dimension. The client
+// forgets the weather when it respawns, so the rain is sent again.
+func (player *Player) writeRespawn(dimension DimensionId) {
+	buf := new(bytes.Buffer)
+	// TODO pass proper map seed, as at login.
+	proto.WriteRespawn(buf, dimension, 0, GameTypeSurvival, MaxYCoord+1, 0)
+	if dimension == DimensionNormal && player.raining {
+		proto.WriteState(buf, StateReasonBeginRaining, 0)
+	}
+	player.TransmitPacket(buf.Bytes())
+}
+
+// SetRaining tells the player that it has started or stopped raining. It
+// must be called from within the player's goroutine, such as through Enqueue.
+func (player *Player) SetRaining(raining bool) {
+	if raining == player.raining {
+		return
+	}
+	player.raining = raining
+
+	reason := byte(StateReasonEndRaining)
+	if raining {
+		reason = StateReasonBeginRaining
+	}
+	buf := new(bytes.Buffer)
+	proto.WriteState(buf, reason, 0)
+	player.TransmitPacket(buf.Bytes())
+}
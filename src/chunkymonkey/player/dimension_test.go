@@ -0,0 +1,38 @@
+package player
+
+import (
+	"bytes"
+	"testing"
+
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+func TestWriteRespawnRain(t *testing.T) {
+	respawn := func(dimension DimensionId) []byte {
+		buf := new(bytes.Buffer)
+		proto.WriteRespawn(buf, dimension, 0, GameTypeSurvival, MaxYCoord+1, 0)
+		return buf.Bytes()
+	}
+	rain := new(bytes.Buffer)
+	proto.WriteState(rain, StateReasonBeginRaining, 0)
+
+	tests := []struct {
+		raining   bool
+		dimension DimensionId
+		want      []byte
+	}{
+		{false, DimensionNormal, respawn(DimensionNormal)},
+		{true, DimensionNether, respawn(DimensionNether)},
+		{true, DimensionNormal, append(respawn(DimensionNormal), rain.Bytes()...)},
+	}
+
+	for _, test := range tests {
+		player := NewPlayer(1, newTestWorld("a", BlockXyz{0, 64, 0}), nil, "test", MinChunkRadius, nil, nil)
+		player.raining = test.raining
+		player.writeRespawn(test.dimension)
+		if got := <-player.txQueue; !bytes.Equal(got, test.want) {
+			t.Errorf("raining=%v, dimension=%d: sent %x, want %x", test.raining, test.dimension, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package player
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"chunkymonkey/physics"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+const (
+	// Players can fall this far without being damaged.
+	safeFallDistance = 3
+	// Speed at which items in a player's inventory are thrown when they die.
+	deathDropSpeed = 0.2
+	// The flag in entity metadata that shows an entity in flames.
+	metadataFlagOnFire = 0x01
+)
+
+// deathMessages are the formats for messages broadcast when a player dies, by
+// cause of death.
+var deathMessages = map[DamageCause]string{
+	DamageCauseFall:      "%s hit the ground too hard",
+	DamageCauseDrowning:  "%s drowned",
+	DamageCauseLava:      "%s tried to swim in lava",
+	DamageCauseFire:      "%s went up in flames",
+	DamageCauseContact:   "%s was pricked to death",
+	DamageCauseMob:       "%s was slain",
+	DamageCausePlayer:    "%s was killed by another player",
+	DamageCauseVoid:      "%s fell out of the world",
+	DamageCauseSuicide:   "%s died",
+	DamageCauseExplosion: "%s blew up",
+}
+
+// damage reduces the player's health, killing them if it reaches zero. It
+// must be called with player.lock held.
+func (player *Player) damage(amount Health, cause DamageCause) {
+	if player.dead || amount <= 0 {
+		return
+	}
+
+	player.health -= amount
+	if player.health < 0 {
+		player.health = 0
+	}
+
+	buf := new(bytes.Buffer)
+	proto.WriteUpdateHealth(buf, player.health, player.food, 0)
+	player.TransmitPacket(buf.Bytes())
+
+	if player.health == 0 {
+		player.die(cause)
+		return
+	}
+
+	buf = new(bytes.Buffer)
+	proto.WriteEntityStatus(buf, player.EntityId, EntityStatusHurt)
+	player.multicastPacket(buf.Bytes())
+}
+
+// die kills the player, dropping their inventory where they died. They remain
+// dead until the client asks to respawn.
+func (player *Player) die(cause DamageCause) {
+	player.dead = true
+	player.closeCurrentWindow(true)
+
+	if shardClient, ok := player.chunkSubs.CurrentShardClient(); ok {
+		items := player.inventory.TakeAllItems()
+		if !player.cursor.IsEmpty() {
+			items = append(items, player.cursor)
+			player.cursor.Clear()
+		}
+		for _, item := range items {
+			look := LookDegrees{
+				Yaw:   AngleDegrees(rand.Float32() * 360),
+				Pitch: AngleDegrees(-rand.Float32() * 90),
+			}
+			velocity := physics.VelocityFromLook(look, deathDropSpeed)
+			shardClient.ReqDropItem(item, player.position, velocity, TicksPerSecond)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	proto.WriteEntityStatus(buf, player.EntityId, EntityStatusDead)
+	player.multicastPacket(buf.Bytes())
+
+	format, ok := deathMessages[cause]
+	if !ok {
+		format = deathMessages[DamageCauseSuicide]
+	}
+	player.game.BroadcastMessage(fmt.Sprintf(format, player.name))
+}
+
+// setFire sets how many ticks the player keeps burning for, showing them in
+// flames to the other players while they burn. It must be called with
+// player.lock held.
+func (player *Player) setFire(fire Ticks) {
+	wasBurning := player.fire > 0
+	player.fire = int16(fire)
+	burning := player.fire > 0
+	if burning == wasBurning {
+		return
+	}
+
+	var flags byte
+	if burning {
+		flags = metadataFlagOnFire
+	}
+	buf := new(bytes.Buffer)
+	proto.WriteEntityMetadata(buf, player.EntityId, []proto.EntityMetadata{{0, 0, flags}})
+	player.multicastPacket(buf.Bytes())
+}
+
+// respawn brings a dead player back to life at the spawn position.
+func (player *Player) respawn() {
+	if !player.dead {
+		return
+	}
+
+	player.dead = false
+	player.health = MaxHealth
+	player.food = MaxFoodUnits
+	player.fallDistance = 0
+	player.air = int16(MaxAir)
+	player.fire = 0
+
+	player.height = StanceNormal
+
+	if player.dimension != DimensionNormal {
+		player.changeDimension(DimensionNormal, player.spawnPosition())
+		return
+	}
+	player.position = player.spawnPosition()
+
+	player.writeRespawn(DimensionNormal)
+
+	if player.chunkSubs.Respawn(&player.position) {
+		// The spawn chunk isn't loaded. notifyChunkLoad sends the position and
+		// health when it is.
+		player.spawnComplete = false
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	proto.ServerWritePlayerPositionLook(
+		buf,
+		&player.position, player.position.Y+player.height,
+		&player.look, false)
+	proto.WriteUpdateHealth(buf, player.health, player.food, 0)
+	player.TransmitPacket(buf.Bytes())
+}
+
+// updateFall tracks how far the player has fallen, damaging them when they
+// land.
+func (player *Player) updateFall(newY AbsCoord, onGround bool) {
+	if newY < player.position.Y {
+		player.fallDistance += float32(player.position.Y - newY)
+	}
+
+	// Fluids break the player's fall through ResetFall, which the chunk that
+	// they are in calls when they move into a fluid block.
+	if onGround {
+		if player.fallDistance > safeFallDistance {
+			damage := Health(math.Ceil(float64(player.fallDistance - safeFallDistance)))
+			player.damage(damage, DamageCauseFall)
+		}
+		player.fallDistance = 0
+	}
+}
+
+// multicastPacket sends a packet to the other players near the player.
+func (player *Player) multicastPacket(packet []byte) {
+	if shardClient, ok := player.chunkSubs.CurrentShardClient(); ok {
+		shardClient.ReqMulticastPlayers(player.chunkSubs.curChunkLoc, player.EntityId, packet)
+	}
+}
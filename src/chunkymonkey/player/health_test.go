@@ -0,0 +1,37 @@
+package player
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestPlayer_updateFall(t *testing.T) {
+	type step struct {
+		y        AbsCoord
+		onGround bool
+	}
+
+	tests := []struct {
+		steps      []step
+		wantHealth Health
+	}{
+		{[]step{{64, true}}, MaxHealth},
+		{[]step{{63, false}, {61, false}, {61, true}}, MaxHealth},
+		{[]step{{62, false}, {60, true}}, MaxHealth - 1},
+		{[]step{{60, false}, {54, true}}, MaxHealth - 7},
+		{[]step{{70, false}, {66, true}}, MaxHealth - 1},
+		{[]step{{63, true}, {61, false}, {58, true}}, MaxHealth - 2},
+	}
+
+	for i, test := range tests {
+		player := NewPlayer(1, &World{SpawnBlock: BlockXyz{0, 64, 0}}, nil, "test", ChunkRadius, nil, nil)
+		for _, s := range test.steps {
+			player.updateFall(s.y, s.onGround)
+			player.position.Y = s.y
+		}
+		if player.health != test.wantHealth {
+			t.Errorf("[%d] got health %d, want %d", i, player.health, test.wantHealth)
+		}
+	}
+}
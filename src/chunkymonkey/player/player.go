@@ -9,9 +9,12 @@ import (
 	"log"
 	"math/rand"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
+	"chunkymonkey/chat"
+	"chunkymonkey/event"
 	"chunkymonkey/gamerules"
 	"chunkymonkey/nbtutil"
 	"chunkymonkey/physics"
@@ -39,6 +42,7 @@ const (
 
 	PingTimeoutNs  = 1e9 * 60 // Player connection times out after 60 seconds.
 	PingIntervalNs = 1e9 * 20 // Time between receiving keep alive response from client and sending new request.
+	TxDrainNs      = 1e9 * 5  // Time allowed to send queued packets when disconnecting.
 )
 
 func init() {
@@ -52,7 +56,7 @@ type Player struct {
 
 	EntityId
 	playerClient   playerClient
-	shardConnecter gamerules.IShardConnecter
+	shardConnecter gamerules.IShardConnecter // For the current dimension.
 	conn           net.Conn
 	name           string
 	loginComplete  bool
@@ -68,6 +72,9 @@ type Player struct {
 		timestampNs int64       // Nanoseconds since epoch since last keep-alive sent.
 		timer       *time.Timer // Time until next ping, or timeout of current.
 	}
+	// latency is the roundtrip time of the last ping that was answered. It is
+	// guarded by lock.
+	latency time.Duration
 
 	// TODO remove this lock, packet handling shouldn't use a lock, it should use
 	// a channel instead (ideally).
@@ -83,17 +90,29 @@ type Player struct {
 
 	// The following attributes are game-logic related.
 
+	// viewDistance is the radius in chunks around the player that they
+	// receive.
+	viewDistance ChunkCoord
+
 	// Data entries that may change
-	spawnBlock BlockXyz
-	position   AbsXyz
-	height     AbsCoord
-	look       LookDegrees
-	chunkSubs  chunkSubscriptions
-	health     Health
-	food       FoodUnits
+	world     *World
+	dimension DimensionId
+	position  AbsXyz
+	height    AbsCoord
+	look      LookDegrees
+	chunkSubs chunkSubscriptions
+	health    Health
+	food      FoodUnits
+	dead      bool
+
+	// portalTime is when the player last went through a portal.
+	portalTime time.Time
+
+	// raining is set by the game, so that the rain can be shown again when
+	// the client respawns in DimensionNormal.
+	raining bool
 
 	// The following data fields are loaded, but not used yet
-	dimension    int32
 	onGround     int8
 	sleeping     int8
 	fallDistance float32
@@ -102,8 +121,14 @@ type Player struct {
 	deathTime    int16
 	hurtTime     int16
 	motion       AbsVelocity
-	air          int16
-	fire         int16
+
+	// fire is the number of ticks that the player keeps burning for. The
+	// chunk that the player is in keeps it up to date through SetFire.
+	fire int16
+
+	// air is the number of ticks that the player can hold their breath for.
+	// The chunk that the player is in keeps it up to date through SetAir.
+	air int16
 
 	cursor       gamerules.Slot // Item being moved by mouse cursor.
 	inventory    window.PlayerInventory
@@ -112,23 +137,22 @@ type Player struct {
 	remoteInv    *RemoteInventory
 }
 
-func NewPlayer(entityId EntityId, shardConnecter gamerules.IShardConnecter, conn net.Conn, name string, spawnBlock BlockXyz, onDisconnect chan<- EntityId, game gamerules.IGame) *Player {
+// NewPlayer creates a Player in the world. They start at the world's spawn in
+// DimensionNormal unless UnmarshalNbt says otherwise.
+func NewPlayer(entityId EntityId, world *World, conn net.Conn, name string, viewDistance ChunkCoord, onDisconnect chan<- EntityId, game gamerules.IGame) *Player {
 	player := &Player{
-		EntityId:       entityId,
-		shardConnecter: shardConnecter,
-		conn:           conn,
-		name:           name,
-		spawnBlock:     spawnBlock,
-		position: AbsXyz{
-			X: AbsCoord(spawnBlock.X),
-			Y: AbsCoord(spawnBlock.Y),
-			Z: AbsCoord(spawnBlock.Z),
-		},
-		height: StanceNormal,
-		look:   LookDegrees{0, 0},
+		EntityId:     entityId,
+		conn:         conn,
+		name:         name,
+		viewDistance: viewDistance,
+		world:        world,
+		dimension:    DimensionNormal,
+		height:       StanceNormal,
+		look:         LookDegrees{0, 0},
 
 		health: MaxHealth,
 		food:   MaxFoodUnits, // TODO: Check what initial level should be.
+		air:    int16(MaxAir),
 
 		curWindow:    nil,
 		nextWindowId: WindowIdFreeMin,
@@ -144,6 +168,7 @@ func NewPlayer(entityId EntityId, shardConnecter gamerules.IShardConnecter, conn
 		onDisconnect: onDisconnect,
 	}
 
+	player.position = player.spawnPosition()
 	player.playerClient.Init(player)
 	player.inventory.Init(player.EntityId, player)
 
@@ -154,6 +179,11 @@ func (player *Player) Name() string {
 	return player.name
 }
 
+// RemoteAddr returns the address of the player's client.
+func (player *Player) RemoteAddr() net.Addr {
+	return player.conn.RemoteAddr()
+}
+
 func (player *Player) String() string {
 	return fmt.Sprintf("Player(%q)", player.name)
 }
@@ -166,6 +196,13 @@ func (player *Player) SetPosition(pos AbsXyz) {
 	player.position = pos
 }
 
+// Status returns the name of the player's world, and their dimension,
+// position and latency. It must be called from within the player's goroutine,
+// such as by a function given to Enqueue.
+func (player *Player) Status() (world string, dimension DimensionId, position AbsXyz, latency time.Duration) {
+	return player.world.Name, player.dimension, player.position, player.latency
+}
+
 func (player *Player) Client() gamerules.IPlayerClient {
 	return &player.playerClient
 }
@@ -175,7 +212,8 @@ func (player *Player) Look() LookDegrees {
 }
 
 // UnmarshalNbt unpacks the player data from their persistantly stored NBT
-// data. It must only be called before Player.Run().
+// data. It must only be called before Player.Run(), or from within the
+// player's goroutine.
 func (player *Player) UnmarshalNbt(tag *nbt.Compound) (err error) {
 	if player.position, err = nbtutil.ReadAbsXyz(tag, "Pos"); err != nil {
 		return
@@ -190,6 +228,7 @@ func (player *Player) UnmarshalNbt(tag *nbt.Compound) (err error) {
 		return
 	}
 	player.health = Health(health)
+	player.dead = player.health <= 0
 
 	if err = player.inventory.UnmarshalNbt(tag.Lookup("Inventory")); err != nil {
 		return
@@ -199,9 +238,11 @@ func (player *Player) UnmarshalNbt(tag *nbt.Compound) (err error) {
 		return
 	}
 
-	if player.dimension, err = nbtutil.ReadInt(tag, "Dimension"); err != nil {
+	dimension, err := nbtutil.ReadInt(tag, "Dimension")
+	if err != nil {
 		return
 	}
+	player.dimension = DimensionId(dimension)
 
 	if player.sleeping, err = nbtutil.ReadByte(tag, "Sleeping"); err != nil {
 		return
@@ -250,7 +291,7 @@ func (player *Player) MarshalNbt(tag *nbt.Compound) (err error) {
 	}
 
 	tag.Set("OnGround", &nbt.Byte{player.onGround})
-	tag.Set("Dimension", &nbt.Int{player.dimension})
+	tag.Set("Dimension", &nbt.Int{int32(player.dimension)})
 	tag.Set("Sleeping", &nbt.Byte{player.sleeping})
 	tag.Set("FallDistance", &nbt.Float{player.fallDistance})
 	tag.Set("SleepTimer", &nbt.Short{player.sleepTimer})
@@ -288,14 +329,15 @@ func (player *Player) getHeldItemTypeId() ItemTypeId {
 }
 
 func (player *Player) Run() {
+	player.checkDimension()
+	player.shardConnecter = player.world.ShardConnecters[player.dimension]
+
 	buf := &bytes.Buffer{}
-	// TODO pass proper dimension. This is low priority, because we don't yet
-	// support multiple dimensions.
 	// TODO pass proper map seed.
 	// TODO pass proper values for the difficulty.
 	// TODO proper max number of players.
-	proto.ServerWriteLogin(buf, player.EntityId, 0, 0, DimensionNormal, GameDifficultyNormal, MaxYCoord+1, 8)
-	proto.WriteSpawnPosition(buf, &player.spawnBlock)
+	proto.ServerWriteLogin(buf, player.EntityId, 0, 0, player.dimension, GameDifficultyNormal, MaxYCoord+1, 8)
+	proto.WriteSpawnPosition(buf, &player.world.SpawnBlock)
 	player.TransmitPacket(buf.Bytes())
 
 	go player.receiveLoop()
@@ -312,6 +354,17 @@ func (player *Player) Stop() {
 	}
 }
 
+// Kick disconnects the player, giving them the reason.
+func (player *Player) Kick(reason string) {
+	log.Printf("Kicking player %s reason=%s", player.name, reason)
+
+	buf := new(bytes.Buffer)
+	proto.WriteDisconnect(buf, reason)
+	player.TransmitPacket(buf.Bytes())
+
+	player.Stop()
+}
+
 // Start of packet handling code
 // Note: any packet handlers that could change the player state or read a
 // changeable state must use player.lock
@@ -332,34 +385,88 @@ func (player *Player) PacketServerHandshake(username string) {
 
 func (player *Player) PacketChatMessage(message string) {
 	prefix := gamerules.CommandFramework.Prefix()
-	if message[0:len(prefix)] == prefix {
+	if strings.HasPrefix(message, prefix) {
+		cmd := &event.Command{Player: &player.playerClient, Line: message}
+		if player.dispatchEvent(cmd) {
+			return
+		}
 		// We pass the IPlayerClient to the command framework to avoid having
 		// to fetch it as the first part of every command.
-		gamerules.CommandFramework.Process(&player.playerClient, message, player.game)
+		gamerules.CommandFramework.Process(&player.playerClient, cmd.Line, player.game)
 	} else {
-		player.sendChatMessage(fmt.Sprintf("<%s> %s", player.name, message), true)
+		e := &event.Chat{Player: &player.playerClient, Message: message}
+		if player.dispatchEvent(e) {
+			return
+		}
+		text, err := player.game.Chat().Prepare(player.name, e.Message, player.hasPermission(chat.PermColor))
+		if err != nil {
+			player.playerClient.EchoMessage(err.Error())
+			return
+		}
+		player.game.SendChat(player.name, fmt.Sprintf("<%s> %s", player.name, text))
 	}
 }
 
+// dispatchEvent dispatches an event about the player with the player's lock
+// held, returning true if it was cancelled.
+func (player *Player) dispatchEvent(e event.Event) bool {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+	return event.Dispatch(e)
+}
+
 func (player *Player) PacketEntityAction(entityId EntityId, action EntityAction) {
 }
 
 func (player *Player) PacketUseEntity(user EntityId, target EntityId, leftClick bool) {
+	if !leftClick {
+		// TODO Right-click interaction with entities, such as shearing sheep.
+		return
+	}
+
+	player.lock.Lock()
+	if !player.spawnComplete || player.dead {
+		player.lock.Unlock()
+		return
+	}
+	position := player.position
+	held, _ := player.inventory.HeldItem()
+	player.lock.Unlock()
+
+	if other := player.game.PlayerByEntityId(target); other != nil {
+		player.attackPlayer(other, &position, &held)
+		return
+	}
+
+	player.lock.Lock()
+	defer player.lock.Unlock()
+
+	player.attackEntity(&held, target)
 }
 
 func (player *Player) PacketRespawn(dimension DimensionId, unknown int8, gameType GameType, worldHeight int16, mapSeed RandomSeed) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+
+	player.respawn()
 }
 
 func (player *Player) PacketPlayer(onGround bool) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+
+	if player.spawnComplete && !player.dead {
+		player.updateFall(player.position.Y, onGround)
+	}
 }
 
 func (player *Player) PacketPlayerPosition(position *AbsXyz, stance AbsCoord, onGround bool) {
 	player.lock.Lock()
 	defer player.lock.Unlock()
 
-	if !player.spawnComplete {
+	if !player.spawnComplete || player.dead {
 		// Ignore position packets from player until spawned at initial position
-		// with chunk loaded.
+		// with chunk loaded, and while dead.
 		return
 	}
 
@@ -368,6 +475,7 @@ func (player *Player) PacketPlayerPosition(position *AbsXyz, stance AbsCoord, on
 			position.X, position.Y, position.Z)
 		return
 	}
+	player.updateFall(position.Y, onGround)
 	player.position = *position
 	player.height = stance - position.Y
 	player.chunkSubs.Move(position)
@@ -386,6 +494,10 @@ func (player *Player) PacketPlayerLook(look *LookDegrees, onGround bool) {
 	// TODO input validation
 	player.look = *look
 
+	if player.spawnComplete && !player.dead {
+		player.updateFall(player.position.Y, onGround)
+	}
+
 	// Update playerData on current chunk.
 	if shard, ok := player.chunkSubs.CurrentShardClient(); ok {
 		shard.ReqSetPlayerLook(player.chunkSubs.curChunkLoc, *look.ToLookBytes())
@@ -516,7 +628,14 @@ func (player *Player) PacketWindowClick(windowId WindowId, slotId SlotId, rightC
 	click.ExpectedSlot.SetWindowSlot(expectedSlot)
 
 	if clickedWindow != nil {
-		txState = clickedWindow.Click(&click)
+		clickEvent := &event.InventoryClick{
+			Player:   &player.playerClient,
+			WindowId: windowId,
+			Click:    &click,
+		}
+		if !event.Dispatch(clickEvent) {
+			txState = clickedWindow.Click(&click)
+		}
 	}
 
 	switch txState {
@@ -668,6 +787,10 @@ func (player *Player) pingReceived(id int32) {
 	// Check that there wasn't an apparent time-shift on this before broadcasting
 	// this latency value.
 	if latencyNs >= 0 && latencyNs < PingTimeoutNs {
+		player.lock.Lock()
+		player.latency = latencyNs
+		player.lock.Unlock()
+
 		buf := new(bytes.Buffer)
 		proto.WriteUserListItem(buf, player.name, true, int16(latencyNs/1e6))
 		player.game.BroadcastPacket(buf.Bytes())
@@ -679,9 +802,19 @@ func (player *Player) pingReceived(id int32) {
 }
 
 func (player *Player) mainLoop() {
+	txRunning := true
+
 	defer func() {
-		// Close the transmitLoop and receiveLoop cleanly.
+		// Close the transmitLoop and receiveLoop cleanly, after giving the
+		// transmitLoop a chance to send any queued packets, such as the reason
+		// for a kick.
 		player.txQueue <- nil
+		if txRunning {
+			select {
+			case <-player.txErrChan:
+			case <-time.After(TxDrainNs):
+			}
+		}
 		player.conn.Close()
 
 		player.onDisconnect <- player.EntityId
@@ -727,6 +860,7 @@ MAINLOOP:
 
 		case err := <-player.txErrChan:
 			log.Printf("%v: send loop failed: %v", player, err)
+			txRunning = false
 			player.Stop()
 		}
 	}
@@ -922,6 +1056,7 @@ func (player *Player) setPositionLook(pos AbsXyz, look LookDegrees) {
 	player.position = pos
 	player.look = look
 	player.height = StanceNormal - pos.Y
+	player.fallDistance = 0
 
 	if player.chunkSubs.Move(&player.position) {
 		// The destination chunk isn't loaded. Wait for it.
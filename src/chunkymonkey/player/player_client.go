@@ -21,6 +21,10 @@ func (p *playerClient) GetEntityId() EntityId {
 	return p.player.EntityId
 }
 
+func (p *playerClient) Name() string {
+	return p.player.name
+}
+
 func (p *playerClient) TransmitPacket(packet []byte) {
 	p.player.TransmitPacket(packet)
 }
@@ -99,6 +103,18 @@ func (p *playerClient) EchoMessage(msg string) {
 	})
 }
 
+func (p *playerClient) Damage(amount Health, cause DamageCause) {
+	p.player.Enqueue(func(player *Player) {
+		player.damage(amount, cause)
+	})
+}
+
+func (p *playerClient) Hit(attackerPos AbsXyz, amount Health, cause DamageCause) {
+	p.player.Enqueue(func(player *Player) {
+		player.hit(&attackerPos, amount, cause)
+	})
+}
+
 func (p *playerClient) PositionLook() (AbsXyz, LookDegrees) {
 	posChan := make(chan AbsXyz)
 	lookChan := make(chan LookDegrees)
@@ -121,3 +137,27 @@ func (p *playerClient) SetPositionLook(pos AbsXyz, look LookDegrees) {
 		player.setPositionLook(pos, look)
 	})
 }
+
+func (p *playerClient) SetAir(air Ticks) {
+	p.player.Enqueue(func(player *Player) {
+		player.air = int16(air)
+	})
+}
+
+func (p *playerClient) SetFire(fire Ticks) {
+	p.player.Enqueue(func(player *Player) {
+		player.setFire(fire)
+	})
+}
+
+func (p *playerClient) ResetFall() {
+	p.player.Enqueue(func(player *Player) {
+		player.fallDistance = 0
+	})
+}
+
+func (p *playerClient) EnterPortal() {
+	p.player.Enqueue(func(player *Player) {
+		player.enterPortal()
+	})
+}
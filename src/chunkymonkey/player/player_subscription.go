@@ -27,6 +27,7 @@ type chunkSubscriptions struct {
 	curChunkLoc    ChunkXz                      // Chunk the player is currently in.
 	curShard       gamerules.IPlayerShardClient // Shard the player is hosted on.
 	shardClients   map[uint64]*shardRef         // Connections to shards.
+	radius         ChunkCoord                   // Radius of chunks subscribed to.
 }
 
 func (sub *chunkSubscriptions) Init(player *Player) {
@@ -37,8 +38,9 @@ func (sub *chunkSubscriptions) Init(player *Player) {
 	sub.curShardLoc = player.position.ToShardXz()
 	sub.curChunkLoc = player.position.ToChunkXz()
 	sub.shardClients = make(map[uint64]*shardRef)
+	sub.radius = player.viewDistance
 
-	initialChunkLocs := orderedChunkSquare(sub.curChunkLoc, ChunkRadius)
+	initialChunkLocs := orderedChunkSquare(sub.curChunkLoc, sub.radius)
 	sub.subscribeToChunks(sub.curChunkLoc, initialChunkLocs)
 
 	sub.curShard = sub.shardClients[sub.curShardLoc.Key()].shard
@@ -48,6 +50,8 @@ func (sub *chunkSubscriptions) Init(player *Player) {
 		player.position,
 		*player.look.ToLookBytes(),
 		player.getHeldItemTypeId(),
+		Ticks(player.air),
+		Ticks(player.fire),
 	)
 }
 
@@ -72,6 +76,28 @@ func (sub *chunkSubscriptions) Move(newLoc *AbsXyz) (notify bool) {
 	return
 }
 
+// Respawn moves the player to newLoc as a new entity, so that other players
+// stop seeing the player where they died. It returns the same as Move.
+func (sub *chunkSubscriptions) Respawn(newLoc *AbsXyz) (notify bool) {
+	sub.curShard.ReqRemovePlayerData(sub.curChunkLoc, true)
+
+	newChunkLoc := newLoc.ToChunkXz()
+	if newChunkLoc.X == sub.curChunkLoc.X && newChunkLoc.Z == sub.curChunkLoc.Z {
+		sub.curShard.ReqAddPlayerData(
+			sub.curChunkLoc,
+			sub.player.name,
+			*newLoc,
+			*sub.player.look.ToLookBytes(),
+			sub.player.getHeldItemTypeId(),
+			Ticks(sub.player.air),
+			Ticks(sub.player.fire),
+		)
+		return false
+	}
+
+	return sub.Move(newLoc)
+}
+
 // Close closes down all shard connections. Use when the player is
 // disconnected.
 func (sub *chunkSubscriptions) Close() {
@@ -92,6 +118,9 @@ func (sub *chunkSubscriptions) Close() {
 func (sub *chunkSubscriptions) CurrentShardClient() (conn gamerules.IPlayerShardClient, ok bool) {
 	curShardLoc := sub.curChunkLoc.ToShardXz()
 	shardRef, ok := sub.shardClients[curShardLoc.Key()]
+	if !ok {
+		return
+	}
 	return shardRef.shard, ok
 }
 
@@ -181,7 +210,7 @@ func (sub *chunkSubscriptions) unsubscribeFromChunks(chunkLocs []ChunkXz) {
 // moveToChunk subscribes to chunks that are newly in range, and unsubscribes
 // to those that have just left.
 func (sub *chunkSubscriptions) moveToChunk(newChunkLoc ChunkXz, newLoc *AbsXyz) (notify bool) {
-	addChunkLocs := squareDifference(newChunkLoc, sub.curChunkLoc, ChunkRadius)
+	addChunkLocs := squareDifference(newChunkLoc, sub.curChunkLoc, sub.radius)
 	notify = sub.subscribeToChunks(newChunkLoc, addChunkLocs)
 
 	newShardLoc := newChunkLoc.ToShardXz()
@@ -192,6 +221,8 @@ func (sub *chunkSubscriptions) moveToChunk(newChunkLoc ChunkXz, newLoc *AbsXyz)
 			sub.player.position,
 			*sub.player.look.ToLookBytes(),
 			sub.player.getHeldItemTypeId(),
+			Ticks(sub.player.air),
+			Ticks(sub.player.fire),
 		)
 	}
 
@@ -200,7 +231,7 @@ func (sub *chunkSubscriptions) moveToChunk(newChunkLoc ChunkXz, newLoc *AbsXyz)
 		ref.shard.ReqRemovePlayerData(sub.curChunkLoc, false)
 	}
 
-	delChunkLocs := squareDifference(sub.curChunkLoc, newChunkLoc, ChunkRadius)
+	delChunkLocs := squareDifference(sub.curChunkLoc, newChunkLoc, sub.radius)
 	sub.unsubscribeFromChunks(delChunkLocs)
 
 	sub.curChunkLoc = newChunkLoc
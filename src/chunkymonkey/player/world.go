@@ -0,0 +1,101 @@
+package player
+
+import (
+	"bytes"
+
+	"chunkymonkey/gamerules"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+	"nbt"
+)
+
+// World is one of the worlds hosted by the game, as seen by the players in
+// it.
+type World struct {
+	// Name is what the world is called by the /world command.
+	Name string
+	// ShardConnecters connect to the shards of each of the world's
+	// dimensions.
+	ShardConnecters map[DimensionId]gamerules.IShardConnecter
+	// SpawnBlock is where players start out in the world, and respawn.
+	SpawnBlock BlockXyz
+}
+
+// World returns the world that the player is in. It must be called from
+// within the player's goroutine, or once they have disconnected.
+func (player *Player) World() *World {
+	return player.world
+}
+
+// ChangeWorld moves the player to another world, where they return to the
+// position, inventory and health given by data. They start out afresh at the
+// world's spawn if data is nil, as they haven't been there before. It returns
+// the player's data from the world that they left, to be stored until they
+// go back to it. The player stays where they are if data can't be read.
+//
+// It must be called from within the player's goroutine, such as through
+// Enqueue.
+func (player *Player) ChangeWorld(world *World, data *nbt.Compound) (oldData *nbt.Compound, err error) {
+	player.closeCurrentWindow(true)
+
+	oldData = nbt.NewCompound()
+	if err = player.MarshalNbt(oldData); err != nil {
+		return nil, err
+	}
+	oldWorld, oldDimension := player.world, player.dimension
+
+	player.world = world
+	player.resetData()
+	if data != nil {
+		if err = player.UnmarshalNbt(data); err != nil {
+			player.world = oldWorld
+			player.resetData()
+			player.UnmarshalNbt(oldData)
+			return nil, err
+		}
+	}
+	player.checkDimension()
+
+	player.chunkSubs.Close()
+	if player.dimension == oldDimension {
+		// The client only drops the chunks that it has when it respawns in
+		// another dimension.
+		other := DimensionNether
+		if player.dimension == DimensionNether {
+			other = DimensionNormal
+		}
+		player.writeRespawn(other)
+	}
+	player.enterDimension()
+
+	buf := new(bytes.Buffer)
+	proto.WriteSpawnPosition(buf, &world.SpawnBlock)
+	player.TransmitPacket(buf.Bytes())
+
+	return oldData, nil
+}
+
+// resetData returns the player to how they start out in a world that they
+// haven't been in before.
+func (player *Player) resetData() {
+	player.dimension = DimensionNormal
+	player.position = player.spawnPosition()
+	player.height = StanceNormal
+	player.look = LookDegrees{0, 0}
+	player.health = MaxHealth
+	player.food = MaxFoodUnits
+	player.dead = false
+
+	player.onGround = 0
+	player.sleeping = 0
+	player.fallDistance = 0
+	player.sleepTimer = 0
+	player.attackTime = 0
+	player.deathTime = 0
+	player.hurtTime = 0
+	player.motion = AbsVelocity{}
+	player.air = int16(MaxAir)
+	player.fire = 0
+
+	player.inventory.Init(player.EntityId, player)
+}
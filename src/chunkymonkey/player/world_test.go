@@ -0,0 +1,76 @@
+package player
+
+import (
+	"testing"
+
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+	"nbt"
+)
+
+// testShardConnecter connects players to shards that ignore their requests.
+type testShardConnecter struct {
+	gamerules.IShardConnecter
+}
+
+func (connecter *testShardConnecter) PlayerShardConnect(entityId EntityId, player gamerules.IPlayerClient, shardLoc ShardXz) gamerules.IPlayerShardClient {
+	return &testPlayerShardClient{}
+}
+
+type testPlayerShardClient struct {
+	gamerules.IPlayerShardClient
+}
+
+func (shard *testPlayerShardClient) Disconnect()                                     {}
+func (shard *testPlayerShardClient) ReqSubscribeChunk(chunkLoc ChunkXz, notify bool) {}
+func (shard *testPlayerShardClient) ReqUnsubscribeChunk(chunkLoc ChunkXz)            {}
+func (shard *testPlayerShardClient) ReqRemovePlayerData(chunkLoc ChunkXz, isDisconnect bool) {
+}
+func (shard *testPlayerShardClient) ReqAddPlayerData(chunkLoc ChunkXz, name string, position AbsXyz, look LookBytes, held ItemTypeId, air, fire Ticks) {
+}
+
+func newTestWorld(name string, spawnBlock BlockXyz) *World {
+	return &World{
+		Name: name,
+		ShardConnecters: map[DimensionId]gamerules.IShardConnecter{
+			DimensionNormal: &testShardConnecter{},
+		},
+		SpawnBlock: spawnBlock,
+	}
+}
+
+func TestChangeWorld(t *testing.T) {
+	worldA := newTestWorld("a", BlockXyz{0, 64, 0})
+	worldB := newTestWorld("b", BlockXyz{100, 70, -100})
+
+	player := NewPlayer(1, worldA, nil, "test", MinChunkRadius, nil, nil)
+	player.position = AbsXyz{10, 65, 20}
+	player.health = 5
+	player.shardConnecter = worldA.ShardConnecters[DimensionNormal]
+	player.chunkSubs.Init(player)
+
+	// The player starts out afresh in a world that they haven't been in.
+	dataA, err := player.ChangeWorld(worldB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if player.World() != worldB || player.position != (AbsXyz{100, 70, -100}) || player.health != MaxHealth {
+		t.Errorf("in new world: got %s at %v with health %d, want b at the spawn with full health", player.World().Name, player.position, player.health)
+	}
+
+	// Data that can't be read leaves the player where they are.
+	if _, err = player.ChangeWorld(worldA, nbt.NewCompound()); err == nil {
+		t.Errorf("expected an error from bad player data")
+	}
+	if player.World() != worldB || player.position != (AbsXyz{100, 70, -100}) {
+		t.Errorf("after bad data: got %s at %v, want b at the spawn", player.World().Name, player.position)
+	}
+
+	// They return to where they were.
+	if _, err = player.ChangeWorld(worldA, dataA); err != nil {
+		t.Fatal(err)
+	}
+	if player.World() != worldA || player.position != (AbsXyz{10, 65, 20}) || player.health != 5 {
+		t.Errorf("on return: got %s at %v with health %d, want a at (10, 65, 20) with health 5", player.World().Name, player.position, player.health)
+	}
+}
@@ -0,0 +1,207 @@
+// Package rcon serves a remote console, which lets operators run commands
+// from outside of the game. It speaks the Source RCON protocol, as used by
+// existing RCON clients.
+package rcon
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// Packet types. Note that the type of an auth response is the same as that of
+// a command request.
+const (
+	packetTypeResponse     = 0
+	packetTypeCommand      = 2
+	packetTypeAuthResponse = 2
+	packetTypeAuth         = 3
+)
+
+const (
+	// maxRequestBody limits the size of the requests that are accepted.
+	maxRequestBody = 1446
+
+	// maxResponseBody is the most output sent in one response packet. Longer
+	// output is split over several packets with the same request ID.
+	maxResponseBody = 4096
+
+	// authFailedId is the request ID of the response to a failed login.
+	authFailedId = -1
+
+	// maxAuthFailures is the number of failed logins after which the
+	// connection is closed.
+	maxAuthFailures = 3
+)
+
+// authFailedDelay is how long the response to a failed login is held back,
+// to slow down guessing of the password.
+var authFailedDelay = time.Second
+
+var errPacketSize = errors.New("bad packet size")
+
+// packet is a request or response. On the wire it is the little-endian int32
+// length of the rest of the packet, followed by the int32 ID and type, the
+// body, and two null bytes.
+type packet struct {
+	Id   int32
+	Type int32
+	Body string
+}
+
+// readPacket reads a packet, refusing those with bodies longer than maxBody.
+func readPacket(reader io.Reader, maxBody int32) (pkt *packet, err error) {
+	var size int32
+	if err = binary.Read(reader, binary.LittleEndian, &size); err != nil {
+		return
+	}
+	if size < 10 || size > maxBody+10 {
+		return nil, errPacketSize
+	}
+
+	data := make([]byte, size)
+	if _, err = io.ReadFull(reader, data); err != nil {
+		return
+	}
+
+	pkt = &packet{
+		Id:   int32(binary.LittleEndian.Uint32(data[0:4])),
+		Type: int32(binary.LittleEndian.Uint32(data[4:8])),
+		Body: strings.TrimRight(string(data[8:]), "\x00"),
+	}
+	return
+}
+
+func writePacket(writer io.Writer, pkt *packet) error {
+	data := make([]byte, 12, 12+len(pkt.Body)+2)
+	binary.LittleEndian.PutUint32(data[0:4], uint32(len(pkt.Body)+10))
+	binary.LittleEndian.PutUint32(data[4:8], uint32(pkt.Id))
+	binary.LittleEndian.PutUint32(data[8:12], uint32(pkt.Type))
+	data = append(data, pkt.Body...)
+	data = append(data, 0, 0)
+
+	_, err := writer.Write(data)
+	return err
+}
+
+// RunFunc runs a command line for the named console, and returns the output.
+type RunFunc func(name, line string) []string
+
+// Server accepts RCON connections. Each connection must log in with the
+// password before it can run commands.
+type Server struct {
+	listener net.Listener
+	password string
+	run      RunFunc
+}
+
+// NewServer starts accepting RCON connections from listener. An empty
+// password is never accepted.
+func NewServer(listener net.Listener, password string, run RunFunc) *Server {
+	server := &Server{
+		listener: listener,
+		password: password,
+		run:      run,
+	}
+
+	go server.acceptLoop()
+
+	return server
+}
+
+// Stop stops accepting connections.
+func (server *Server) Stop() {
+	server.listener.Close()
+}
+
+func (server *Server) acceptLoop() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			log.Printf("Stopped accepting RCON connections: %v", err)
+			return
+		}
+		go server.serve(conn)
+	}
+}
+
+func (server *Server) checkPassword(password string) bool {
+	return server.password != "" && subtle.ConstantTimeCompare([]byte(password), []byte(server.password)) == 1
+}
+
+func (server *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// The name is logged as the player making changes, so has no spaces.
+	name := "rcon@" + conn.RemoteAddr().String()
+	reader := bufio.NewReader(conn)
+	authenticated := false
+	authFailures := 0
+
+	for {
+		request, err := readPacket(reader, maxRequestBody)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("%s: %v", name, err)
+			}
+			return
+		}
+
+		switch {
+		case request.Type == packetTypeAuth:
+			response := &packet{Id: request.Id, Type: packetTypeAuthResponse}
+			if authenticated = server.checkPassword(request.Body); !authenticated {
+				log.Printf("%s: failed login", name)
+				response.Id = authFailedId
+				authFailures++
+				time.Sleep(authFailedDelay)
+			}
+			if err = writePacket(conn, response); err != nil {
+				return
+			}
+			if authFailures >= maxAuthFailures {
+				log.Printf("%s: too many failed logins", name)
+				return
+			}
+
+		case !authenticated:
+			log.Printf("%s: command sent before logging in", name)
+			return
+
+		case request.Type == packetTypeCommand:
+			log.Printf("%s: %s", name, request.Body)
+			output := strings.Join(server.run(name, request.Body), "\n")
+			if err = writeResponse(conn, request.Id, output); err != nil {
+				return
+			}
+
+		default:
+			log.Printf("%s: unknown packet type %d", name, request.Type)
+			return
+		}
+	}
+}
+
+// writeResponse sends the output in as many packets as it needs.
+func writeResponse(writer io.Writer, id int32, output string) error {
+	for {
+		body := output
+		if len(body) > maxResponseBody {
+			body = body[:maxResponseBody]
+		}
+		output = output[len(body):]
+
+		if err := writePacket(writer, &packet{Id: id, Type: packetTypeResponse, Body: body}); err != nil {
+			return err
+		}
+		if len(output) == 0 {
+			return nil
+		}
+	}
+}
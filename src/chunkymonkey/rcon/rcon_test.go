@@ -0,0 +1,123 @@
+package rcon
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	authFailedDelay = time.Millisecond
+}
+
+func TestPacket(t *testing.T) {
+	tests := []packet{
+		{1, packetTypeAuth, "password"},
+		{-1, packetTypeAuthResponse, ""},
+		{7, packetTypeCommand, "say hello"},
+	}
+
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		if err := writePacket(buf, &test); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readPacket(buf, maxRequestBody)
+		if err != nil {
+			t.Errorf("%+v: %v", test, err)
+			continue
+		}
+		if *got != test {
+			t.Errorf("read %+v, want %+v", *got, test)
+		}
+	}
+
+	// Requests that are too large are refused.
+	buf := new(bytes.Buffer)
+	writePacket(buf, &packet{1, packetTypeCommand, strings.Repeat("x", maxRequestBody+1)})
+	if _, err := readPacket(buf, maxRequestBody); err != errPacketSize {
+		t.Errorf("reading large packet: got err=%v, want %v", err, errPacketSize)
+	}
+}
+
+func TestServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	longOutput := strings.Repeat("y", maxResponseBody+10)
+	server := NewServer(listener, "secret", func(name, line string) []string {
+		if line == "long" {
+			return []string{longOutput}
+		}
+		return []string{"ran " + line, "done"}
+	})
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	exchange := func(request packet, want ...packet) {
+		if err := writePacket(conn, &request); err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range want {
+			got, err := readPacket(conn, maxResponseBody)
+			if err != nil {
+				t.Fatalf("%+v: %v", request, err)
+			}
+			if *got != w {
+				t.Errorf("%+v: got response %+v, want %+v", request, *got, w)
+			}
+		}
+	}
+
+	exchange(packet{1, packetTypeAuth, "wrong"}, packet{authFailedId, packetTypeAuthResponse, ""})
+	exchange(packet{2, packetTypeAuth, "secret"}, packet{2, packetTypeAuthResponse, ""})
+	exchange(packet{3, packetTypeCommand, "say hi"}, packet{3, packetTypeResponse, "ran say hi\ndone"})
+	exchange(packet{4, packetTypeCommand, "long"},
+		packet{4, packetTypeResponse, longOutput[:maxResponseBody]},
+		packet{4, packetTypeResponse, longOutput[maxResponseBody:]})
+}
+
+func TestServerAuthFailures(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(listener, "secret", func(name, line string) []string {
+		return nil
+	})
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < maxAuthFailures; i++ {
+		if err = writePacket(conn, &packet{int32(i), packetTypeAuth, "wrong"}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readPacket(conn, maxResponseBody)
+		if err != nil {
+			t.Fatalf("login %d: %v", i, err)
+		}
+		if got.Id != authFailedId {
+			t.Errorf("login %d: got response %+v, want failure", i, *got)
+		}
+	}
+
+	// The connection is closed, so the right password is too late.
+	writePacket(conn, &packet{10, packetTypeAuth, "secret"})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if got, err := readPacket(conn, maxResponseBody); err == nil {
+		t.Errorf("after %d failed logins: got %+v, %v, want connection closed", maxAuthFailures, got, err)
+	}
+}
@@ -0,0 +1,336 @@
+package remoteshard
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"chunkymonkey/blocklog"
+	"chunkymonkey/config"
+	"chunkymonkey/entity"
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+const (
+	// dialTimeoutNs limits how long connecting to a host can hold up the
+	// player or shard making a request.
+	dialTimeoutNs = 1e9 * 5
+
+	// redialDelayNs is how long to wait after failing to connect to a host
+	// before trying again. Requests to the host are dropped in the meantime.
+	redialDelayNs = 1e9 * 5
+)
+
+// RemoteShards connects to the shards hosted by other processes, as set out in
+// the shards configuration. It implements shardserver.IRemoteShards.
+type RemoteShards struct {
+	config    *config.ShardsConfig
+	localAddr string
+	lock      sync.Mutex
+	conns     map[string]*clientConn
+	dialing   map[string]chan bool // Closed once the dial to the host is done.
+	dialFails map[string]time.Time
+}
+
+// NewRemoteShards creates a RemoteShards for the process that serves shards on
+// localAddr. The shards that the configuration routes elsewhere are remote.
+// Entities keep their IDs when they are transferred to remote shards, and the
+// IDs stay reserved by the process that created them until they are removed
+// from entityMgr by the process that has the entity.
+func NewRemoteShards(shardsConfig *config.ShardsConfig, localAddr string, entityMgr *entity.EntityManager) *RemoteShards {
+	remote := &RemoteShards{
+		config:    shardsConfig,
+		localAddr: localAddr,
+		conns:     make(map[string]*clientConn),
+		dialing:   make(map[string]chan bool),
+		dialFails: make(map[string]time.Time),
+	}
+	entityMgr.SetReleaseOther(remote.releaseEntityId)
+	return remote
+}
+
+func (remote *RemoteShards) IsRemote(shardLoc ShardXz) bool {
+	return remote.config.HostAddr(shardLoc) != remote.localAddr
+}
+
+func (remote *RemoteShards) PlayerShardConnect(entityId EntityId, player gamerules.IPlayerClient, shardLoc ShardXz) gamerules.IPlayerShardClient {
+	conn := remote.connForShard(shardLoc)
+	if conn == nil {
+		return &remotePlayerShardClient{}
+	}
+
+	session := conn.addPlayer(player)
+	conn.send(&reqPlayerConnect{session, entityId, player.Name(), shardLoc})
+
+	return &remotePlayerShardClient{conn, session}
+}
+
+// ShardShardConnect returns a client which looks up the connection to the
+// shard's host for each request, so that it remains usable if the connection
+// is lost and made again.
+func (remote *RemoteShards) ShardShardConnect(shardLoc ShardXz) gamerules.IShardShardClient {
+	return &remoteShardShardClient{remote, shardLoc}
+}
+
+func (remote *RemoteShards) RevertBlockChanges(shardLoc ShardXz, player string, changes []blocklog.Change) {
+	if conn := remote.connForShard(shardLoc); conn != nil {
+		conn.send(&reqRevertBlockChanges{player, changes})
+	}
+}
+
+// releaseEntityId releases the ID of an entity that was created by another
+// process, in that process.
+func (remote *RemoteShards) releaseEntityId(entityId EntityId) {
+	addr := remote.config.EntityIdHost(entityId)
+	if addr == "" || addr == remote.localAddr {
+		return
+	}
+	if conn := remote.connForAddr(addr); conn != nil {
+		conn.send(&reqReleaseEntityId{entityId})
+	}
+}
+
+// connForShard returns the connection to the host of the shard, connecting to
+// it if need be. It returns nil if the host can't be reached.
+func (remote *RemoteShards) connForShard(shardLoc ShardXz) *clientConn {
+	return remote.connForAddr(remote.config.HostAddr(shardLoc))
+}
+
+// connForAddr returns the connection to the host at addr, as connForShard
+// does.
+func (remote *RemoteShards) connForAddr(addr string) *clientConn {
+	remote.lock.Lock()
+	defer remote.lock.Unlock()
+
+	for {
+		if conn, ok := remote.conns[addr]; ok && !conn.isClosed() {
+			return conn
+		}
+		dialed, ok := remote.dialing[addr]
+		if !ok {
+			break
+		}
+		// Wait for the connection that is already being made.
+		remote.lock.Unlock()
+		<-dialed
+		remote.lock.Lock()
+	}
+	delete(remote.conns, addr)
+
+	if failed, ok := remote.dialFails[addr]; ok && time.Since(failed) < redialDelayNs {
+		return nil
+	}
+
+	// The lock isn't held while dialing, so that connections to other hosts
+	// aren't held up.
+	dialed := make(chan bool)
+	remote.dialing[addr] = dialed
+	remote.lock.Unlock()
+	netConn, err := net.DialTimeout("tcp", addr, dialTimeoutNs)
+	remote.lock.Lock()
+	delete(remote.dialing, addr)
+	close(dialed)
+
+	if err != nil {
+		log.Printf("Failed to connect to shard host %s: %v", addr, err)
+		remote.dialFails[addr] = time.Now()
+		return nil
+	}
+	delete(remote.dialFails, addr)
+
+	conn := newClientConn(netConn, remote.config.Secret)
+	remote.conns[addr] = conn
+	return conn
+}
+
+// clientConn is a connection to a Server, made by RemoteShards.
+type clientConn struct {
+	*msgConn
+	lock        sync.Mutex
+	nextSession sessionId
+	players     map[sessionId]gamerules.IPlayerClient
+}
+
+func newClientConn(netConn net.Conn, secret string) *clientConn {
+	conn := &clientConn{
+		msgConn: newMsgConn(netConn),
+		players: make(map[sessionId]gamerules.IPlayerClient),
+	}
+	conn.send(&hello{secret})
+
+	go conn.receiveLoop()
+
+	return conn
+}
+
+func (conn *clientConn) addPlayer(player gamerules.IPlayerClient) sessionId {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+
+	conn.nextSession++
+	conn.players[conn.nextSession] = player
+	return conn.nextSession
+}
+
+func (conn *clientConn) removePlayer(session sessionId) {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+
+	delete(conn.players, session)
+}
+
+// player returns the player for the session, or nil if the session has been
+// disconnected.
+func (conn *clientConn) player(session sessionId) gamerules.IPlayerClient {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+
+	return conn.players[session]
+}
+
+func (conn *clientConn) receiveLoop() {
+	for {
+		msg, err := conn.receive()
+		if err != nil {
+			conn.close(err)
+			return
+		}
+
+		playerMsg, ok := msg.(iPlayerMessage)
+		if !ok {
+			conn.close(fmt.Errorf("unexpected message %T", msg))
+			return
+		}
+		playerMsg.performOnClient(conn)
+	}
+}
+
+// remotePlayerShardClient implements IPlayerShardClient for RemoteShards. A
+// client with a nil conn is for a shard whose host couldn't be reached, and
+// drops all requests.
+type remotePlayerShardClient struct {
+	conn    *clientConn
+	session sessionId
+}
+
+func (client *remotePlayerShardClient) send(msg iShardMessage) {
+	if client.conn != nil {
+		client.conn.send(msg)
+	}
+}
+
+func (client *remotePlayerShardClient) Disconnect() {
+	if client.conn != nil {
+		client.conn.send(&reqPlayerDisconnect{client.session})
+		client.conn.removePlayer(client.session)
+	}
+}
+
+func (client *remotePlayerShardClient) ReqSubscribeChunk(chunkLoc ChunkXz, notify bool) {
+	client.send(&reqSubscribeChunk{client.session, chunkLoc, notify})
+}
+
+func (client *remotePlayerShardClient) ReqUnsubscribeChunk(chunkLoc ChunkXz) {
+	client.send(&reqUnsubscribeChunk{client.session, chunkLoc})
+}
+
+func (client *remotePlayerShardClient) ReqMulticastPlayers(chunkLoc ChunkXz, exclude EntityId, packet []byte) {
+	client.send(&reqMulticastPlayers{client.session, chunkLoc, exclude, packet})
+}
+
+func (client *remotePlayerShardClient) ReqAddPlayerData(chunkLoc ChunkXz, name string, position AbsXyz, look LookBytes, held ItemTypeId, air, fire Ticks) {
+	client.send(&reqAddPlayerData{client.session, chunkLoc, name, position, look, held, air, fire})
+}
+
+func (client *remotePlayerShardClient) ReqRemovePlayerData(chunkLoc ChunkXz, isDisconnect bool) {
+	client.send(&reqRemovePlayerData{client.session, chunkLoc, isDisconnect})
+}
+
+func (client *remotePlayerShardClient) ReqSetPlayerPosition(chunkLoc ChunkXz, position AbsXyz) {
+	client.send(&reqSetPlayerPosition{client.session, chunkLoc, position})
+}
+
+func (client *remotePlayerShardClient) ReqSetPlayerLook(chunkLoc ChunkXz, look LookBytes) {
+	client.send(&reqSetPlayerLook{client.session, chunkLoc, look})
+}
+
+func (client *remotePlayerShardClient) ReqHitBlock(held gamerules.Slot, target BlockXyz, digStatus DigStatus, face Face) {
+	client.send(&reqHitBlock{client.session, held, target, digStatus, face})
+}
+
+func (client *remotePlayerShardClient) ReqHitEntity(held gamerules.Slot, position AbsXyz, target EntityId) {
+	client.send(&reqHitEntity{client.session, held, position, target})
+}
+
+func (client *remotePlayerShardClient) ReqInteractBlock(held gamerules.Slot, target BlockXyz, face Face) {
+	client.send(&reqInteractBlock{client.session, held, target, face})
+}
+
+func (client *remotePlayerShardClient) ReqPlaceItem(target BlockXyz, slot gamerules.Slot) {
+	client.send(&reqPlaceItem{client.session, target, slot})
+}
+
+func (client *remotePlayerShardClient) ReqTakeItem(chunkLoc ChunkXz, entityId EntityId) {
+	client.send(&reqTakeItem{client.session, chunkLoc, entityId})
+}
+
+func (client *remotePlayerShardClient) ReqDropItem(content gamerules.Slot, position AbsXyz, velocity AbsVelocity, pickupImmunity Ticks) {
+	client.send(&reqDropItem{client.session, content, position, velocity, pickupImmunity})
+}
+
+func (client *remotePlayerShardClient) ReqInventoryClick(block BlockXyz, click gamerules.Click) {
+	client.send(&reqInventoryClick{client.session, block, click})
+}
+
+func (client *remotePlayerShardClient) ReqInventoryUnsubscribed(block BlockXyz) {
+	client.send(&reqInventoryUnsubscribed{client.session, block})
+}
+
+func (client *remotePlayerShardClient) ReqLinkPortal(target BlockXyz) {
+	client.send(&reqLinkPortal{client.session, target})
+}
+
+// remoteShardShardClient implements IShardShardClient for RemoteShards.
+type remoteShardShardClient struct {
+	remote   *RemoteShards
+	shardLoc ShardXz
+}
+
+func (client *remoteShardShardClient) send(msg iShardMessage) {
+	if conn := client.remote.connForShard(client.shardLoc); conn != nil {
+		conn.send(msg)
+	}
+}
+
+func (client *remoteShardShardClient) Disconnect() {
+}
+
+func (client *remoteShardShardClient) ReqSetActiveBlocks(blocks []BlockXyz) {
+	client.send(&reqSetActiveBlocks{client.shardLoc, blocks})
+}
+
+func (client *remoteShardShardClient) ReqTransferEntity(loc ChunkXz, e gamerules.INonPlayerEntity) {
+	data, err := marshalEntity(e)
+	if err != nil {
+		log.Printf("Dropping entity %d transferred to shard %v: %v", e.GetEntityId(), client.shardLoc, err)
+		return
+	}
+	// The entity keeps its ID, which stays reserved by the process that
+	// created it until the entity is removed.
+	client.send(&reqTransferEntity{client.shardLoc, loc, e.GetEntityId(), data})
+}
+
+func (client *remoteShardShardClient) ReqSpreadBlocks(spreads []gamerules.BlockSpread) {
+	client.send(&reqSpreadBlocks{client.shardLoc, spreads})
+}
+
+func (client *remoteShardShardClient) ReqUpdateLight(updates []gamerules.LightUpdate) {
+	client.send(&reqUpdateLight{client.shardLoc, updates})
+}
+
+func (client *remoteShardShardClient) ReqUpdatePower(updates []gamerules.PowerUpdate) {
+	client.send(&reqUpdatePower{client.shardLoc, updates})
+}
@@ -0,0 +1,123 @@
+// Package remoteshard connects players and shards to shards that are hosted
+// by other processes.
+//
+// Each process that hosts shards runs a Server. Other processes connect to it
+// through RemoteShards, which turns requests made upon the remote shards into
+// messages sent over TCP. Calls made by the remote shards upon players are
+// sent back over the same connection.
+package remoteshard
+
+import (
+	"bufio"
+	"encoding/gob"
+	"log"
+	"net"
+	"sync"
+)
+
+// sendQueueSize is the number of messages that can be waiting to be written
+// to a connection before senders block.
+const sendQueueSize = 1024
+
+// sessionId identifies a player's connection to a remote shard. Sessions are
+// numbered by the side of the connection that makes them.
+type sessionId uint32
+
+// hello is the first message sent on a connection to a Server, which closes
+// connections that don't give the shared secret.
+type hello struct {
+	Secret string
+}
+
+// envelope holds a single message on the wire. The message types are
+// registered with gob in messages.go.
+type envelope struct {
+	Msg interface{}
+}
+
+// msgConn sends and receives gob encoded messages over a network connection.
+// Messages are sent in the order that send is called.
+type msgConn struct {
+	netConn   net.Conn
+	decoder   *gob.Decoder
+	sendQueue chan interface{}
+	done      chan bool
+	closeOnce sync.Once
+}
+
+func newMsgConn(netConn net.Conn) *msgConn {
+	conn := &msgConn{
+		netConn:   netConn,
+		decoder:   gob.NewDecoder(bufio.NewReader(netConn)),
+		sendQueue: make(chan interface{}, sendQueueSize),
+		done:      make(chan bool),
+	}
+
+	go conn.sendLoop()
+
+	return conn
+}
+
+// send queues the message to be written. Messages sent after the connection
+// has closed are dropped.
+func (conn *msgConn) send(msg interface{}) {
+	select {
+	case conn.sendQueue <- msg:
+	case <-conn.done:
+	}
+}
+
+// receive reads the next message from the connection.
+func (conn *msgConn) receive() (msg interface{}, err error) {
+	var env envelope
+	if err = conn.decoder.Decode(&env); err != nil {
+		return
+	}
+	return env.Msg, nil
+}
+
+// close closes the connection, logging the reason it was closed for. Only the
+// first call has any effect.
+func (conn *msgConn) close(reason error) {
+	conn.closeOnce.Do(func() {
+		log.Printf("Closing shard connection with %v: %v", conn.netConn.RemoteAddr(), reason)
+		close(conn.done)
+		conn.netConn.Close()
+	})
+}
+
+func (conn *msgConn) isClosed() bool {
+	select {
+	case <-conn.done:
+		return true
+	default:
+	}
+	return false
+}
+
+func (conn *msgConn) sendLoop() {
+	writer := bufio.NewWriter(conn.netConn)
+	encoder := gob.NewEncoder(writer)
+
+	for {
+		var msg interface{}
+		select {
+		case msg = <-conn.sendQueue:
+		case <-conn.done:
+			return
+		}
+
+		if err := encoder.Encode(&envelope{msg}); err != nil {
+			conn.close(err)
+			return
+		}
+
+		// Batch up messages that are already waiting.
+		if len(conn.sendQueue) == 0 {
+			if err := writer.Flush(); err != nil {
+				conn.close(err)
+				return
+			}
+		}
+	}
+}
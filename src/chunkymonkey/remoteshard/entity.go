@@ -0,0 +1,48 @@
+package remoteshard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"chunkymonkey/gamerules"
+	"nbt"
+)
+
+// marshalEntity encodes the entity as NBT, as it would be stored in a chunk.
+func marshalEntity(entity gamerules.INonPlayerEntity) ([]byte, error) {
+	tag := nbt.NewCompound()
+	if err := entity.MarshalNbt(tag); err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := nbt.Write(buf, tag); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalEntity decodes an entity encoded by marshalEntity. The entity ID
+// is not part of the encoding, and must be set by the caller.
+func unmarshalEntity(data []byte) (gamerules.INonPlayerEntity, error) {
+	tag, err := nbt.Read(bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+
+	typeName, ok := tag.Lookup("id").(*nbt.String)
+	if !ok {
+		return nil, errors.New("missing entity type ID")
+	}
+
+	entity := gamerules.NewEntityByTypeName(typeName.Value)
+	if entity == nil {
+		return nil, fmt.Errorf("unhandled entity type %q", typeName.Value)
+	}
+
+	if err = entity.UnmarshalNbt(tag); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
@@ -0,0 +1,615 @@
+package remoteshard
+
+import (
+	"encoding/gob"
+	"log"
+
+	"chunkymonkey/blocklog"
+	"chunkymonkey/gamerules"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+// iShardMessage is a message sent to the process that hosts a shard.
+type iShardMessage interface {
+	performOnServer(conn *serverConn)
+}
+
+// iPlayerMessage is a message sent from a shard to a player in the process
+// that made the session.
+type iPlayerMessage interface {
+	performOnClient(conn *clientConn)
+}
+
+func init() {
+	for _, msg := range []interface{}{
+		&hello{},
+
+		// iShardMessage implementations.
+		&reqPlayerConnect{},
+		&reqPlayerDisconnect{},
+		&reqSubscribeChunk{},
+		&reqUnsubscribeChunk{},
+		&reqMulticastPlayers{},
+		&reqAddPlayerData{},
+		&reqRemovePlayerData{},
+		&reqSetPlayerPosition{},
+		&reqSetPlayerLook{},
+		&reqHitBlock{},
+		&reqHitEntity{},
+		&reqInteractBlock{},
+		&reqPlaceItem{},
+		&reqTakeItem{},
+		&reqDropItem{},
+		&reqInventoryClick{},
+		&reqInventoryUnsubscribed{},
+		&reqLinkPortal{},
+		&reqSetActiveBlocks{},
+		&reqTransferEntity{},
+		&reqSpreadBlocks{},
+		&reqUpdateLight{},
+		&reqUpdatePower{},
+		&reqRevertBlockChanges{},
+		&reqReleaseEntityId{},
+
+		// iPlayerMessage implementations.
+		&playerTransmitPacket{},
+		&playerNotifyChunkLoad{},
+		&playerInventorySubscribed{},
+		&playerInventorySlotUpdate{},
+		&playerInventoryProgressUpdate{},
+		&playerInventoryCursorUpdate{},
+		&playerInventoryTxState{},
+		&playerInventoryUnsubscribed{},
+		&playerPlaceHeldItem{},
+		&playerOfferItem{},
+		&playerGiveItemAtPosition{},
+		&playerGiveItem{},
+		&playerSetPositionLook{},
+		&playerEchoMessage{},
+		&playerDamage{},
+		&playerHit{},
+		&playerSetAir{},
+		&playerResetFall{},
+		&playerSetFire{},
+		&playerEnterPortal{},
+	} {
+		gob.Register(msg)
+	}
+}
+
+// Messages upon the shards hosted by a Server.
+
+type reqPlayerConnect struct {
+	Session  sessionId
+	EntityId EntityId
+	Name     string
+	ShardLoc ShardXz
+}
+
+func (msg *reqPlayerConnect) performOnServer(conn *serverConn) {
+	conn.connectPlayer(msg.Session, msg.EntityId, msg.Name, msg.ShardLoc)
+}
+
+type reqPlayerDisconnect struct {
+	Session sessionId
+}
+
+func (msg *reqPlayerDisconnect) performOnServer(conn *serverConn) {
+	conn.disconnectPlayer(msg.Session)
+}
+
+type reqSubscribeChunk struct {
+	Session  sessionId
+	ChunkLoc ChunkXz
+	Notify   bool
+}
+
+func (msg *reqSubscribeChunk) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqSubscribeChunk(msg.ChunkLoc, msg.Notify)
+	}
+}
+
+type reqUnsubscribeChunk struct {
+	Session  sessionId
+	ChunkLoc ChunkXz
+}
+
+func (msg *reqUnsubscribeChunk) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqUnsubscribeChunk(msg.ChunkLoc)
+	}
+}
+
+type reqMulticastPlayers struct {
+	Session  sessionId
+	ChunkLoc ChunkXz
+	Exclude  EntityId
+	Packet   []byte
+}
+
+func (msg *reqMulticastPlayers) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqMulticastPlayers(msg.ChunkLoc, msg.Exclude, msg.Packet)
+	}
+}
+
+type reqAddPlayerData struct {
+	Session  sessionId
+	ChunkLoc ChunkXz
+	Name     string
+	Position AbsXyz
+	Look     LookBytes
+	Held     ItemTypeId
+	Air      Ticks
+	Fire     Ticks
+}
+
+func (msg *reqAddPlayerData) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.player.setPosition(msg.Position)
+		session.player.setLook(msg.Look)
+		session.shardClient.ReqAddPlayerData(msg.ChunkLoc, msg.Name, msg.Position, msg.Look, msg.Held, msg.Air, msg.Fire)
+	}
+}
+
+type reqRemovePlayerData struct {
+	Session      sessionId
+	ChunkLoc     ChunkXz
+	IsDisconnect bool
+}
+
+func (msg *reqRemovePlayerData) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqRemovePlayerData(msg.ChunkLoc, msg.IsDisconnect)
+	}
+}
+
+type reqSetPlayerPosition struct {
+	Session  sessionId
+	ChunkLoc ChunkXz
+	Position AbsXyz
+}
+
+func (msg *reqSetPlayerPosition) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.player.setPosition(msg.Position)
+		session.shardClient.ReqSetPlayerPosition(msg.ChunkLoc, msg.Position)
+	}
+}
+
+type reqSetPlayerLook struct {
+	Session  sessionId
+	ChunkLoc ChunkXz
+	Look     LookBytes
+}
+
+func (msg *reqSetPlayerLook) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.player.setLook(msg.Look)
+		session.shardClient.ReqSetPlayerLook(msg.ChunkLoc, msg.Look)
+	}
+}
+
+type reqHitBlock struct {
+	Session   sessionId
+	Held      gamerules.Slot
+	Target    BlockXyz
+	DigStatus DigStatus
+	Face      Face
+}
+
+func (msg *reqHitBlock) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqHitBlock(msg.Held, msg.Target, msg.DigStatus, msg.Face)
+	}
+}
+
+type reqHitEntity struct {
+	Session  sessionId
+	Held     gamerules.Slot
+	Position AbsXyz
+	Target   EntityId
+}
+
+func (msg *reqHitEntity) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqHitEntity(msg.Held, msg.Position, msg.Target)
+	}
+}
+
+type reqInteractBlock struct {
+	Session sessionId
+	Held    gamerules.Slot
+	Target  BlockXyz
+	Face    Face
+}
+
+func (msg *reqInteractBlock) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqInteractBlock(msg.Held, msg.Target, msg.Face)
+	}
+}
+
+type reqPlaceItem struct {
+	Session sessionId
+	Target  BlockXyz
+	Slot    gamerules.Slot
+}
+
+func (msg *reqPlaceItem) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqPlaceItem(msg.Target, msg.Slot)
+	}
+}
+
+type reqTakeItem struct {
+	Session  sessionId
+	ChunkLoc ChunkXz
+	EntityId EntityId
+}
+
+func (msg *reqTakeItem) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqTakeItem(msg.ChunkLoc, msg.EntityId)
+	}
+}
+
+type reqDropItem struct {
+	Session        sessionId
+	Content        gamerules.Slot
+	Position       AbsXyz
+	Velocity       AbsVelocity
+	PickupImmunity Ticks
+}
+
+func (msg *reqDropItem) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqDropItem(msg.Content, msg.Position, msg.Velocity, msg.PickupImmunity)
+	}
+}
+
+type reqInventoryClick struct {
+	Session sessionId
+	Block   BlockXyz
+	Click   gamerules.Click
+}
+
+func (msg *reqInventoryClick) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqInventoryClick(msg.Block, msg.Click)
+	}
+}
+
+type reqInventoryUnsubscribed struct {
+	Session sessionId
+	Block   BlockXyz
+}
+
+func (msg *reqInventoryUnsubscribed) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqInventoryUnsubscribed(msg.Block)
+	}
+}
+
+type reqLinkPortal struct {
+	Session sessionId
+	Target  BlockXyz
+}
+
+func (msg *reqLinkPortal) performOnServer(conn *serverConn) {
+	if session := conn.session(msg.Session); session != nil {
+		session.shardClient.ReqLinkPortal(msg.Target)
+	}
+}
+
+type reqSetActiveBlocks struct {
+	ShardLoc ShardXz
+	Blocks   []BlockXyz
+}
+
+func (msg *reqSetActiveBlocks) performOnServer(conn *serverConn) {
+	conn.shardClient(msg.ShardLoc).ReqSetActiveBlocks(msg.Blocks)
+}
+
+// reqTransferEntity carries the entity as NBT, as it would be stored in a
+// chunk.
+type reqTransferEntity struct {
+	ShardLoc ShardXz
+	ChunkLoc ChunkXz
+	EntityId EntityId
+	Entity   []byte
+}
+
+func (msg *reqTransferEntity) performOnServer(conn *serverConn) {
+	entity, err := unmarshalEntity(msg.Entity)
+	if err != nil {
+		log.Printf("Dropping entity %d transferred from %v: %v", msg.EntityId, conn.netConn.RemoteAddr(), err)
+		return
+	}
+	entity.SetEntityId(msg.EntityId)
+	conn.shardClient(msg.ShardLoc).ReqTransferEntity(msg.ChunkLoc, entity)
+}
+
+type reqSpreadBlocks struct {
+	ShardLoc ShardXz
+	Spreads  []gamerules.BlockSpread
+}
+
+func (msg *reqSpreadBlocks) performOnServer(conn *serverConn) {
+	conn.shardClient(msg.ShardLoc).ReqSpreadBlocks(msg.Spreads)
+}
+
+type reqUpdateLight struct {
+	ShardLoc ShardXz
+	Updates  []gamerules.LightUpdate
+}
+
+func (msg *reqUpdateLight) performOnServer(conn *serverConn) {
+	conn.shardClient(msg.ShardLoc).ReqUpdateLight(msg.Updates)
+}
+
+type reqUpdatePower struct {
+	ShardLoc ShardXz
+	Updates  []gamerules.PowerUpdate
+}
+
+func (msg *reqUpdatePower) performOnServer(conn *serverConn) {
+	conn.shardClient(msg.ShardLoc).ReqUpdatePower(msg.Updates)
+}
+
+type reqRevertBlockChanges struct {
+	Player  string
+	Changes []blocklog.Change
+}
+
+func (msg *reqRevertBlockChanges) performOnServer(conn *serverConn) {
+	conn.host.RevertBlockChanges(msg.Player, msg.Changes)
+}
+
+// reqReleaseEntityId is sent to the process that created the entity's ID,
+// once the entity has been removed by another.
+type reqReleaseEntityId struct {
+	EntityId EntityId
+}
+
+func (msg *reqReleaseEntityId) performOnServer(conn *serverConn) {
+	conn.entityMgr.RemoveEntityById(msg.EntityId)
+}
+
+// Messages upon players in the process that made the session.
+
+type playerTransmitPacket struct {
+	Session sessionId
+	Packet  []byte
+}
+
+func (msg *playerTransmitPacket) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.TransmitPacket(msg.Packet)
+	}
+}
+
+type playerNotifyChunkLoad struct {
+	Session sessionId
+}
+
+func (msg *playerNotifyChunkLoad) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.NotifyChunkLoad()
+	}
+}
+
+type playerInventorySubscribed struct {
+	Session   sessionId
+	Block     BlockXyz
+	InvTypeId InvTypeId
+	Slots     []proto.WindowSlot
+}
+
+func (msg *playerInventorySubscribed) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.InventorySubscribed(msg.Block, msg.InvTypeId, msg.Slots)
+	}
+}
+
+type playerInventorySlotUpdate struct {
+	Session sessionId
+	Block   BlockXyz
+	Slot    gamerules.Slot
+	SlotId  SlotId
+}
+
+func (msg *playerInventorySlotUpdate) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.InventorySlotUpdate(msg.Block, msg.Slot, msg.SlotId)
+	}
+}
+
+type playerInventoryProgressUpdate struct {
+	Session  sessionId
+	Block    BlockXyz
+	PrgBarId PrgBarId
+	Value    PrgBarValue
+}
+
+func (msg *playerInventoryProgressUpdate) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.InventoryProgressUpdate(msg.Block, msg.PrgBarId, msg.Value)
+	}
+}
+
+type playerInventoryCursorUpdate struct {
+	Session sessionId
+	Block   BlockXyz
+	Cursor  gamerules.Slot
+}
+
+func (msg *playerInventoryCursorUpdate) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.InventoryCursorUpdate(msg.Block, msg.Cursor)
+	}
+}
+
+type playerInventoryTxState struct {
+	Session  sessionId
+	Block    BlockXyz
+	TxId     TxId
+	Accepted bool
+}
+
+func (msg *playerInventoryTxState) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.InventoryTxState(msg.Block, msg.TxId, msg.Accepted)
+	}
+}
+
+type playerInventoryUnsubscribed struct {
+	Session sessionId
+	Block   BlockXyz
+}
+
+func (msg *playerInventoryUnsubscribed) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.InventoryUnsubscribed(msg.Block)
+	}
+}
+
+type playerPlaceHeldItem struct {
+	Session sessionId
+	Target  BlockXyz
+	WasHeld gamerules.Slot
+}
+
+func (msg *playerPlaceHeldItem) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.PlaceHeldItem(msg.Target, msg.WasHeld)
+	}
+}
+
+type playerOfferItem struct {
+	Session   sessionId
+	FromChunk ChunkXz
+	EntityId  EntityId
+	Item      gamerules.Slot
+}
+
+func (msg *playerOfferItem) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.OfferItem(msg.FromChunk, msg.EntityId, msg.Item)
+	}
+}
+
+type playerGiveItemAtPosition struct {
+	Session    sessionId
+	AtPosition AbsXyz
+	Item       gamerules.Slot
+}
+
+func (msg *playerGiveItemAtPosition) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.GiveItemAtPosition(msg.AtPosition, msg.Item)
+	}
+}
+
+type playerGiveItem struct {
+	Session sessionId
+	Item    gamerules.Slot
+}
+
+func (msg *playerGiveItem) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.GiveItem(msg.Item)
+	}
+}
+
+type playerSetPositionLook struct {
+	Session  sessionId
+	Position AbsXyz
+	Look     LookDegrees
+}
+
+func (msg *playerSetPositionLook) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.SetPositionLook(msg.Position, msg.Look)
+	}
+}
+
+type playerEchoMessage struct {
+	Session sessionId
+	Msg     string
+}
+
+func (msg *playerEchoMessage) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.EchoMessage(msg.Msg)
+	}
+}
+
+type playerDamage struct {
+	Session sessionId
+	Amount  Health
+	Cause   DamageCause
+}
+
+func (msg *playerDamage) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.Damage(msg.Amount, msg.Cause)
+	}
+}
+
+type playerHit struct {
+	Session     sessionId
+	AttackerPos AbsXyz
+	Amount      Health
+	Cause       DamageCause
+}
+
+func (msg *playerHit) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.Hit(msg.AttackerPos, msg.Amount, msg.Cause)
+	}
+}
+
+type playerSetAir struct {
+	Session sessionId
+	Air     Ticks
+}
+
+func (msg *playerSetAir) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.SetAir(msg.Air)
+	}
+}
+
+type playerSetFire struct {
+	Session sessionId
+	Fire    Ticks
+}
+
+func (msg *playerSetFire) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.SetFire(msg.Fire)
+	}
+}
+
+type playerResetFall struct {
+	Session sessionId
+}
+
+func (msg *playerResetFall) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.ResetFall()
+	}
+}
+
+type playerEnterPortal struct {
+	Session sessionId
+}
+
+func (msg *playerEnterPortal) performOnClient(conn *clientConn) {
+	if player := conn.player(msg.Session); player != nil {
+		player.EnterPortal()
+	}
+}
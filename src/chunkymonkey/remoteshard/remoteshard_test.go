@@ -0,0 +1,361 @@
+package remoteshard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"chunkymonkey/blocklog"
+	"chunkymonkey/config"
+	"chunkymonkey/entity"
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// testHost records the requests that reach it as events. Unimplemented
+// methods of the embedded interfaces panic if called.
+type testHost struct {
+	events chan string
+}
+
+func (host *testHost) PlayerShardConnect(entityId EntityId, player gamerules.IPlayerClient, shardLoc ShardXz) gamerules.IPlayerShardClient {
+	host.events <- fmt.Sprintf("connect %d %s %v", entityId, player.Name(), shardLoc)
+	return &testPlayerShardClient{host: host, player: player}
+}
+
+func (host *testHost) ShardShardConnect(shardLoc ShardXz) gamerules.IShardShardClient {
+	return &testShardShardClient{host: host, shardLoc: shardLoc}
+}
+
+func (host *testHost) RevertBlockChanges(player string, changes []blocklog.Change) {
+	host.events <- fmt.Sprintf("revert %s %v", player, changes[0].Block)
+}
+
+type testPlayerShardClient struct {
+	gamerules.IPlayerShardClient
+	host   *testHost
+	player gamerules.IPlayerClient
+}
+
+func (client *testPlayerShardClient) Disconnect() {
+	client.host.events <- "disconnect"
+}
+
+func (client *testPlayerShardClient) ReqSubscribeChunk(chunkLoc ChunkXz, notify bool) {
+	client.host.events <- fmt.Sprintf("subscribe %v %t", chunkLoc, notify)
+	client.player.TransmitPacket([]byte("chunk data"))
+}
+
+func (client *testPlayerShardClient) ReqSetPlayerPosition(chunkLoc ChunkXz, position AbsXyz) {
+	position, _ = client.player.PositionLook()
+	client.host.events <- fmt.Sprintf("position %v", position)
+}
+
+type testShardShardClient struct {
+	gamerules.IShardShardClient
+	host     *testHost
+	shardLoc ShardXz
+}
+
+func (client *testShardShardClient) ReqSetActiveBlocks(blocks []BlockXyz) {
+	client.host.events <- fmt.Sprintf("active %v %v", client.shardLoc, blocks)
+}
+
+func (client *testShardShardClient) ReqTransferEntity(loc ChunkXz, e gamerules.INonPlayerEntity) {
+	client.host.events <- fmt.Sprintf("transfer %v %d", loc, e.GetEntityId())
+}
+
+type testPlayer struct {
+	gamerules.IPlayerClient
+	packets chan string
+}
+
+func (player *testPlayer) Name() string {
+	return "alice"
+}
+
+func (player *testPlayer) TransmitPacket(packet []byte) {
+	player.packets <- string(packet)
+}
+
+func expect(t *testing.T, events chan string, want string) {
+	select {
+	case got := <-events:
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %q", want)
+	}
+}
+
+func TestRemoteShards(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := &testHost{events: make(chan string, 16)}
+	var hostEntityMgr entity.EntityManager
+	hostEntityMgr.Init()
+	server := NewServer(listener, host, &hostEntityMgr, "secret")
+	defer server.Stop()
+
+	shardsConfig := &config.ShardsConfig{
+		Addr: "frontend",
+		Hosts: []config.ShardHostConfig{
+			{Addr: listener.Addr().String(), MinX: 0, MinZ: 0, MaxX: 1, MaxZ: 1},
+		},
+		Secret: "secret",
+	}
+	var entityMgr entity.EntityManager
+	entityMgr.Init()
+	remote := NewRemoteShards(shardsConfig, "frontend", &entityMgr)
+
+	tests := []struct {
+		loc  ShardXz
+		want bool
+	}{
+		{ShardXz{0, 0}, true},
+		{ShardXz{1, 1}, true},
+		{ShardXz{2, 0}, false},
+		{ShardXz{-1, 0}, false},
+	}
+	for _, test := range tests {
+		if got := remote.IsRemote(test.loc); got != test.want {
+			t.Errorf("IsRemote(%v) = %t, want %t", test.loc, got, test.want)
+		}
+	}
+
+	player := &testPlayer{packets: make(chan string, 16)}
+	client := remote.PlayerShardConnect(5, player, ShardXz{1, 0})
+	expect(t, host.events, "connect 5 alice {1 0}")
+
+	client.ReqSubscribeChunk(ChunkXz{16, 3}, true)
+	expect(t, host.events, "subscribe {16 3} true")
+	expect(t, player.packets, "chunk data")
+
+	client.ReqSetPlayerPosition(ChunkXz{16, 3}, AbsXyz{260, 64, 50})
+	expect(t, host.events, "position {260 64 50}")
+
+	remote.ShardShardConnect(ShardXz{0, 1}).ReqSetActiveBlocks([]BlockXyz{{1, 2, 20}})
+	expect(t, host.events, "active {0 1} [{1 2 20}]")
+
+	remote.RevertBlockChanges(ShardXz{0, 0}, "bob", []blocklog.Change{{Block: BlockXyz{3, 4, 5}}})
+	expect(t, host.events, "revert bob {3 4 5}")
+
+	client.Disconnect()
+	expect(t, host.events, "disconnect")
+}
+
+func TestServerWrongSecret(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := &testHost{events: make(chan string, 16)}
+	var hostEntityMgr entity.EntityManager
+	hostEntityMgr.Init()
+	server := NewServer(listener, host, &hostEntityMgr, "secret")
+	defer server.Stop()
+
+	shardsConfig := &config.ShardsConfig{
+		Addr: "frontend",
+		Hosts: []config.ShardHostConfig{
+			{Addr: listener.Addr().String(), MinX: 0, MinZ: 0, MaxX: 1, MaxZ: 1},
+		},
+		Secret: "guess",
+	}
+	var entityMgr entity.EntityManager
+	entityMgr.Init()
+	remote := NewRemoteShards(shardsConfig, "frontend", &entityMgr)
+
+	remote.PlayerShardConnect(5, &testPlayer{}, ShardXz{0, 0})
+	conn := remote.connForShard(ShardXz{0, 0})
+	select {
+	case <-conn.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection with the wrong secret wasn't closed")
+	}
+	select {
+	case event := <-host.events:
+		t.Errorf("got %q from connection with the wrong secret", event)
+	default:
+	}
+}
+
+// serverProcessEnv holds the secret when the test binary is run as a shard
+// server by startServerProcess.
+const serverProcessEnv = "REMOTESHARD_TEST_SECRET"
+
+// TestServerProcess serves the shards of a testHost for startServerProcess,
+// until its standard input is closed. It does nothing when run as a test.
+func TestServerProcess(t *testing.T) {
+	secret := os.Getenv(serverProcessEnv)
+	if secret == "" {
+		return
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := &testHost{events: make(chan string, 16)}
+	go func() {
+		for _ = range host.events {
+		}
+	}()
+	var entityMgr entity.EntityManager
+	entityMgr.Init()
+	NewServer(listener, host, &entityMgr, secret)
+	fmt.Println(listener.Addr())
+
+	io.Copy(ioutil.Discard, os.Stdin)
+	os.Exit(0)
+}
+
+// startServerProcess runs TestServerProcess in another process, and returns
+// the address that it serves on.
+func startServerProcess(t *testing.T, secret string) (addr string, stop func()) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestServerProcess$")
+	cmd.Env = append(os.Environ(), serverProcessEnv+"="+secret)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	stop = func() {
+		stdin.Close()
+		cmd.Wait()
+	}
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		stop()
+		t.Fatalf("starting shard server process: %v", err)
+	}
+	return strings.TrimSpace(line), stop
+}
+
+func TestRemoteShardsProcesses(t *testing.T) {
+	addr1, stop1 := startServerProcess(t, "secret")
+	defer stop1()
+	addr2, stop2 := startServerProcess(t, "secret")
+	defer stop2()
+
+	shardsConfig := &config.ShardsConfig{
+		Addr: "frontend",
+		Hosts: []config.ShardHostConfig{
+			{Addr: addr1, MinX: 0, MinZ: 0, MaxX: 1, MaxZ: 1},
+			{Addr: addr2, MinX: 2, MinZ: 0, MaxX: 3, MaxZ: 1},
+		},
+		Secret: "secret",
+	}
+	var entityMgr entity.EntityManager
+	entityMgr.Init()
+	remote := NewRemoteShards(shardsConfig, "frontend", &entityMgr)
+
+	// Players connect to each process at once, and get chunks back from it.
+	tests := []struct {
+		shardLoc ShardXz
+		chunkLoc ChunkXz
+	}{
+		{ShardXz{1, 0}, ChunkXz{16, 3}},
+		{ShardXz{2, 1}, ChunkXz{32, 20}},
+	}
+	players := make([]*testPlayer, len(tests))
+	done := make(chan bool)
+	for i, test := range tests {
+		players[i] = &testPlayer{packets: make(chan string, 16)}
+		go func(player *testPlayer, shardLoc ShardXz, chunkLoc ChunkXz) {
+			client := remote.PlayerShardConnect(5, player, shardLoc)
+			client.ReqSubscribeChunk(chunkLoc, true)
+			done <- true
+		}(players[i], test.shardLoc, test.chunkLoc)
+	}
+	for _ = range tests {
+		<-done
+	}
+	for _, player := range players {
+		expect(t, player.packets, "chunk data")
+	}
+}
+
+// newEntityId returns an EntityId from mgr, or false if all of its EntityIds
+// are in use.
+func newEntityId(mgr *entity.EntityManager) (entityId EntityId, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return mgr.NewEntity(), true
+}
+
+func TestEntityIdTransfer(t *testing.T) {
+	// Process a is the frontend, and process b hosts shard (0, 0).
+	var listeners [2]net.Listener
+	for i := range listeners {
+		var err error
+		if listeners[i], err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addrA, addrB := listeners[0].Addr().String(), listeners[1].Addr().String()
+	shardsConfig := &config.ShardsConfig{
+		Addr: addrA,
+		Hosts: []config.ShardHostConfig{
+			{Addr: addrB, MinX: 0, MinZ: 0, MaxX: 0, MaxZ: 0},
+		},
+		Secret: "secret",
+	}
+
+	// Each process has a single EntityId.
+	var entityMgrA, entityMgrB entity.EntityManager
+	firstA, _ := shardsConfig.EntityIdRange(addrA)
+	entityMgrA.InitRange(firstA, firstA)
+	firstB, _ := shardsConfig.EntityIdRange(addrB)
+	entityMgrB.InitRange(firstB, firstB)
+
+	hostA := &testHost{events: make(chan string, 16)}
+	hostB := &testHost{events: make(chan string, 16)}
+	serverA := NewServer(listeners[0], hostA, &entityMgrA, "secret")
+	defer serverA.Stop()
+	serverB := NewServer(listeners[1], hostB, &entityMgrB, "secret")
+	defer serverB.Stop()
+	remoteA := NewRemoteShards(shardsConfig, addrA, &entityMgrA)
+	remoteB := NewRemoteShards(shardsConfig, addrB, &entityMgrB)
+
+	pig := gamerules.NewPig()
+	pig.SetEntityId(entityMgrA.NewEntity())
+	remoteA.ShardShardConnect(ShardXz{0, 0}).ReqTransferEntity(ChunkXz{1, 1}, pig)
+	expect(t, hostB.events, fmt.Sprintf("transfer {1 1} %d", firstA))
+
+	// The pig's ID stays reserved in a while b has it.
+	if entityId, ok := newEntityId(&entityMgrA); ok {
+		t.Errorf("got EntityId %d while the pig has it in another process", entityId)
+	}
+
+	// Once b removes the pig, a can use its ID again. Messages between the
+	// processes are performed in order, so it has been released once the
+	// revert that follows it arrives.
+	entityMgrB.RemoveEntityById(firstA)
+	remoteB.RevertBlockChanges(ShardXz{1, 0}, "bob", []blocklog.Change{{Block: BlockXyz{40, 4, 5}}})
+	expect(t, hostA.events, "revert bob {40 4 5}")
+	if entityId, ok := newEntityId(&entityMgrA); !ok || entityId != firstA {
+		t.Errorf("got EntityId %d, %t after the pig was removed, want %d", entityId, ok, firstA)
+	}
+}
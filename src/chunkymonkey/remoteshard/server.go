@@ -0,0 +1,317 @@
+package remoteshard
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"chunkymonkey/blocklog"
+	"chunkymonkey/entity"
+	"chunkymonkey/gamerules"
+	"chunkymonkey/proto"
+	. "chunkymonkey/types"
+)
+
+// handshakeTimeoutNs is how long a Server waits for a new connection to give
+// the secret.
+const handshakeTimeoutNs = 1e9 * 10
+
+// IShardHost hosts the shards served by a Server. LocalShardManager
+// implements it.
+type IShardHost interface {
+	gamerules.IShardConnecter
+
+	// RevertBlockChanges undoes the changes, given oldest first, on behalf of
+	// the named player.
+	RevertBlockChanges(player string, changes []blocklog.Change)
+}
+
+// Server serves shards hosted by the process to players and shards in other
+// processes, which connect through RemoteShards.
+type Server struct {
+	listener  net.Listener
+	host      IShardHost
+	entityMgr *entity.EntityManager
+	secret    string
+}
+
+// NewServer starts serving the shards of host to connections accepted from
+// listener that give the secret. The IDs of entities that other processes
+// remove are released in entityMgr.
+func NewServer(listener net.Listener, host IShardHost, entityMgr *entity.EntityManager, secret string) *Server {
+	server := &Server{
+		listener:  listener,
+		host:      host,
+		entityMgr: entityMgr,
+		secret:    secret,
+	}
+
+	go server.acceptLoop()
+
+	return server
+}
+
+// Stop stops accepting connections.
+func (server *Server) Stop() {
+	server.listener.Close()
+}
+
+func (server *Server) acceptLoop() {
+	for {
+		netConn, err := server.listener.Accept()
+		if err != nil {
+			log.Printf("Stopped accepting shard connections: %v", err)
+			return
+		}
+		log.Printf("Shard connection from %v", netConn.RemoteAddr())
+
+		conn := newServerConn(netConn, server.host, server.entityMgr)
+		go conn.receiveLoop(server.secret)
+	}
+}
+
+// serverSession is a player's connection to a shard hosted by the Server.
+type serverSession struct {
+	shardClient gamerules.IPlayerShardClient
+	player      *playerProxy
+}
+
+// serverConn is a connection accepted by a Server. Its sessions and shard
+// clients are only used by its receive loop.
+type serverConn struct {
+	*msgConn
+	host         IShardHost
+	entityMgr    *entity.EntityManager
+	sessions     map[sessionId]*serverSession
+	shardClients map[uint64]gamerules.IShardShardClient
+}
+
+func newServerConn(netConn net.Conn, host IShardHost, entityMgr *entity.EntityManager) *serverConn {
+	return &serverConn{
+		msgConn:      newMsgConn(netConn),
+		host:         host,
+		entityMgr:    entityMgr,
+		sessions:     make(map[sessionId]*serverSession),
+		shardClients: make(map[uint64]gamerules.IShardShardClient),
+	}
+}
+
+func (conn *serverConn) receiveLoop(secret string) {
+	if err := conn.handshake(secret); err != nil {
+		conn.close(err)
+		return
+	}
+
+	defer func() {
+		// Players can't be reached once the connection has gone.
+		for session := range conn.sessions {
+			conn.disconnectPlayer(session)
+		}
+		for _, client := range conn.shardClients {
+			client.Disconnect()
+		}
+	}()
+
+	for {
+		msg, err := conn.receive()
+		if err != nil {
+			conn.close(err)
+			return
+		}
+
+		shardMsg, ok := msg.(iShardMessage)
+		if !ok {
+			conn.close(fmt.Errorf("unexpected message %T", msg))
+			return
+		}
+		shardMsg.performOnServer(conn)
+	}
+}
+
+// handshake checks that the first message on the connection gives the secret.
+func (conn *serverConn) handshake(secret string) error {
+	conn.netConn.SetReadDeadline(time.Now().Add(handshakeTimeoutNs))
+	defer conn.netConn.SetReadDeadline(time.Time{})
+
+	msg, err := conn.receive()
+	if err != nil {
+		return err
+	}
+	h, ok := msg.(*hello)
+	if !ok || subtle.ConstantTimeCompare([]byte(h.Secret), []byte(secret)) != 1 {
+		return errors.New("wrong secret")
+	}
+	return nil
+}
+
+// session returns the session, or nil if it isn't connected.
+func (conn *serverConn) session(session sessionId) *serverSession {
+	return conn.sessions[session]
+}
+
+func (conn *serverConn) connectPlayer(session sessionId, entityId EntityId, name string, shardLoc ShardXz) {
+	if _, ok := conn.sessions[session]; ok {
+		log.Printf("Ignoring repeated connection of session %d from %v", session, conn.netConn.RemoteAddr())
+		return
+	}
+
+	player := newPlayerProxy(conn, session, entityId, name)
+	conn.sessions[session] = &serverSession{
+		shardClient: conn.host.PlayerShardConnect(entityId, player, shardLoc),
+		player:      player,
+	}
+}
+
+func (conn *serverConn) disconnectPlayer(session sessionId) {
+	if s, ok := conn.sessions[session]; ok {
+		s.shardClient.Disconnect()
+		delete(conn.sessions, session)
+	}
+}
+
+func (conn *serverConn) shardClient(shardLoc ShardXz) gamerules.IShardShardClient {
+	shardKey := shardLoc.Key()
+	client, ok := conn.shardClients[shardKey]
+	if !ok {
+		client = conn.host.ShardShardConnect(shardLoc)
+		conn.shardClients[shardKey] = client
+	}
+	return client
+}
+
+// playerProxy implements IPlayerClient for a player connected to a shard
+// through a Server. Calls upon it are sent back to the process that the player
+// is connected to. The player's position and look are kept from the requests
+// that the player makes, so that PositionLook can answer without a round trip.
+type playerProxy struct {
+	conn     *serverConn
+	session  sessionId
+	entityId EntityId
+	name     string
+
+	lock     sync.Mutex
+	position AbsXyz
+	look     LookBytes
+}
+
+func newPlayerProxy(conn *serverConn, session sessionId, entityId EntityId, name string) *playerProxy {
+	return &playerProxy{
+		conn:     conn,
+		session:  session,
+		entityId: entityId,
+		name:     name,
+	}
+}
+
+func (player *playerProxy) setPosition(position AbsXyz) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+
+	player.position = position
+}
+
+func (player *playerProxy) setLook(look LookBytes) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+
+	player.look = look
+}
+
+func (player *playerProxy) GetEntityId() EntityId {
+	return player.entityId
+}
+
+func (player *playerProxy) Name() string {
+	return player.name
+}
+
+func (player *playerProxy) TransmitPacket(packet []byte) {
+	player.conn.send(&playerTransmitPacket{player.session, packet})
+}
+
+func (player *playerProxy) NotifyChunkLoad() {
+	player.conn.send(&playerNotifyChunkLoad{player.session})
+}
+
+func (player *playerProxy) InventorySubscribed(block BlockXyz, invTypeId InvTypeId, slots []proto.WindowSlot) {
+	player.conn.send(&playerInventorySubscribed{player.session, block, invTypeId, slots})
+}
+
+func (player *playerProxy) InventorySlotUpdate(block BlockXyz, slot gamerules.Slot, slotId SlotId) {
+	player.conn.send(&playerInventorySlotUpdate{player.session, block, slot, slotId})
+}
+
+func (player *playerProxy) InventoryProgressUpdate(block BlockXyz, prgBarId PrgBarId, value PrgBarValue) {
+	player.conn.send(&playerInventoryProgressUpdate{player.session, block, prgBarId, value})
+}
+
+func (player *playerProxy) InventoryCursorUpdate(block BlockXyz, cursor gamerules.Slot) {
+	player.conn.send(&playerInventoryCursorUpdate{player.session, block, cursor})
+}
+
+func (player *playerProxy) InventoryTxState(block BlockXyz, txId TxId, accepted bool) {
+	player.conn.send(&playerInventoryTxState{player.session, block, txId, accepted})
+}
+
+func (player *playerProxy) InventoryUnsubscribed(block BlockXyz) {
+	player.conn.send(&playerInventoryUnsubscribed{player.session, block})
+}
+
+func (player *playerProxy) PlaceHeldItem(target BlockXyz, wasHeld gamerules.Slot) {
+	player.conn.send(&playerPlaceHeldItem{player.session, target, wasHeld})
+}
+
+func (player *playerProxy) OfferItem(fromChunk ChunkXz, entityId EntityId, item gamerules.Slot) {
+	player.conn.send(&playerOfferItem{player.session, fromChunk, entityId, item})
+}
+
+func (player *playerProxy) GiveItemAtPosition(atPosition AbsXyz, item gamerules.Slot) {
+	player.conn.send(&playerGiveItemAtPosition{player.session, atPosition, item})
+}
+
+func (player *playerProxy) GiveItem(item gamerules.Slot) {
+	player.conn.send(&playerGiveItem{player.session, item})
+}
+
+func (player *playerProxy) PositionLook() (AbsXyz, LookDegrees) {
+	player.lock.Lock()
+	defer player.lock.Unlock()
+
+	return player.position, *player.look.ToLookDegrees()
+}
+
+func (player *playerProxy) SetPositionLook(position AbsXyz, look LookDegrees) {
+	player.conn.send(&playerSetPositionLook{player.session, position, look})
+}
+
+func (player *playerProxy) EchoMessage(msg string) {
+	player.conn.send(&playerEchoMessage{player.session, msg})
+}
+
+func (player *playerProxy) Damage(amount Health, cause DamageCause) {
+	player.conn.send(&playerDamage{player.session, amount, cause})
+}
+
+func (player *playerProxy) Hit(attackerPos AbsXyz, amount Health, cause DamageCause) {
+	player.conn.send(&playerHit{player.session, attackerPos, amount, cause})
+}
+
+func (player *playerProxy) SetAir(air Ticks) {
+	player.conn.send(&playerSetAir{player.session, air})
+}
+
+func (player *playerProxy) SetFire(fire Ticks) {
+	player.conn.send(&playerSetFire{player.session, fire})
+}
+
+func (player *playerProxy) ResetFall() {
+	player.conn.send(&playerResetFall{player.session})
+}
+
+func (player *playerProxy) EnterPortal() {
+	player.conn.send(&playerEnterPortal{player.session})
+}
@@ -0,0 +1,64 @@
+package shardserver
+
+import (
+	"log"
+	"time"
+
+	"chunkymonkey/blocklog"
+	. "chunkymonkey/types"
+)
+
+// logBlockChange records a change of block made on behalf of shard.actor, if
+// there is one.
+func (shard *ChunkShard) logBlockChange(blockLoc *BlockXyz, oldBlockId BlockId, oldData byte, newBlockId BlockId, newData byte) {
+	if shard.blockLog == nil || shard.actor == "" {
+		return
+	}
+
+	if oldBlockId == newBlockId && oldData == newData {
+		return
+	}
+
+	change := blocklog.Change{
+		Time:       time.Now(),
+		Player:     shard.actor,
+		Block:      *blockLoc,
+		OldBlockId: oldBlockId,
+		OldData:    oldData,
+		NewBlockId: newBlockId,
+		NewData:    newData,
+	}
+	if err := shard.blockLog.Record(&change); err != nil {
+		log.Printf("%v: failed to log block change: %v", shard, err)
+	}
+}
+
+// revertBlockChanges undoes the changes in the order given, on behalf of the
+// named player. A block is only changed back if it is still as the change left
+// it. The contents of tile entities, such as chests, aren't logged, so they are
+// restored empty.
+func (shard *ChunkShard) revertBlockChanges(player string, changes []blocklog.Change) {
+	shard.actor = player
+	defer func() { shard.actor = "" }()
+
+	for i := range changes {
+		change := &changes[i]
+		chunk := shard.chunkAt(*change.Block.ToChunkXz())
+		if chunk == nil {
+			continue
+		}
+
+		index, subLoc, ok := chunk.getBlockIndexByBlockXyz(&change.Block)
+		if !ok {
+			continue
+		}
+
+		if index.BlockId(chunk.blocks) != change.NewBlockId || index.BlockData(chunk.blockData) != change.NewData {
+			continue
+		}
+
+		chunk.setBlock(&change.Block, subLoc, index, change.OldBlockId, change.OldData)
+		chunk.AddActiveBlockIndex(index)
+		chunk.activateNeighbours(&change.Block)
+	}
+}
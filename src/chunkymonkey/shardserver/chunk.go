@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"chunkymonkey/chunkstore"
+	"chunkymonkey/event"
 	"chunkymonkey/gamerules"
 	"chunkymonkey/proto"
 	. "chunkymonkey/types"
@@ -30,10 +31,15 @@ type Chunk struct {
 	playersData  map[EntityId]*playerData               // Some player data for player(s) in the chunk.
 	onUnsub      map[EntityId][]gamerules.IUnsubscribed // Functions to be called when unsubscribed.
 	storeDirty   bool                                   // Is the chunk store copy of this chunk dirty?
+	unusedTicks  Ticks                                  // Ticks since the chunk was last in use.
 
-	activeBlocks    map[BlockIndex]bool // Blocks that need to "tick".
-	newActiveBlocks map[BlockIndex]bool // Blocks added as active for next "tick".
-	tickAll         bool                // Whether or not all blocks should be allowed to "tick" once
+	activeBlocks        map[BlockIndex]bool  // Blocks that need to "tick".
+	newActiveBlocks     map[BlockIndex]bool  // Blocks added as active for next "tick".
+	delayedActiveBlocks map[BlockIndex]Ticks // Blocks to become active after a number of ticks.
+	tickAll             bool                 // Whether or not all blocks should be allowed to "tick" once
+
+	poweredBlocks map[BlockIndex]bool                               // Powered blocks as last told to their IPoweredAspect.
+	externalPower map[BlockIndex]*[FaceMaxValid + 1]gamerules.Power // Power received from other shards.
 }
 
 func newChunkFromReader(reader chunkstore.IChunkReader, shard *ChunkShard) (chunk *Chunk) {
@@ -53,9 +59,13 @@ func newChunkFromReader(reader chunkstore.IChunkReader, shard *ChunkShard) (chun
 		onUnsub:      make(map[EntityId][]gamerules.IUnsubscribed),
 		storeDirty:   false,
 
-		activeBlocks:    make(map[BlockIndex]bool),
-		newActiveBlocks: make(map[BlockIndex]bool),
-		tickAll:         true,
+		activeBlocks:        make(map[BlockIndex]bool),
+		newActiveBlocks:     make(map[BlockIndex]bool),
+		delayedActiveBlocks: make(map[BlockIndex]Ticks),
+		tickAll:             true,
+
+		poweredBlocks: make(map[BlockIndex]bool),
+		externalPower: make(map[BlockIndex]*[FaceMaxValid + 1]gamerules.Power),
 	}
 
 	entities := reader.Entities()
@@ -112,16 +122,27 @@ func (chunk *Chunk) setBlock(blockLoc *BlockXyz, subLoc *SubChunkXyz, index Bloc
 	// Invalidate currently stored chunk data.
 	chunk.storeDirty = true
 
+	oldBlockType, oldBlockData := index.BlockId(chunk.blocks), index.BlockData(chunk.blockData)
+	if oldBlockType != blockType {
+		// The new block hasn't been told about power yet.
+		delete(chunk.poweredBlocks, index)
+	}
+	chunk.shard.logBlockChange(blockLoc, oldBlockType, oldBlockData, blockType, blockData)
+
 	index.SetBlockId(chunk.blocks, blockType)
 	index.SetBlockData(chunk.blockData, blockData)
 
 	delete(chunk.tileEntities, index)
 
+	chunk.relight(blockLoc, subLoc, index)
+
 	// Tell players that the block changed.
 	packet := new(bytes.Buffer)
 	proto.WriteBlockChange(packet, blockLoc, blockType, blockData)
 	chunk.reqMulticastPlayers(-1, packet.Bytes())
 
+	chunk.shard.notifyPowerChanged(blockLoc)
+
 	return
 }
 
@@ -157,8 +178,13 @@ func (chunk *Chunk) transferEntity(s gamerules.INonPlayerEntity) {
 }
 
 // AddEntity creates a mob or item in this chunk and notifies all chunk
-// subscribers of the new entity
+// subscribers of the new entity. If an EntitySpawn handler cancels it, the
+// entity is discarded.
 func (chunk *Chunk) AddEntity(s gamerules.INonPlayerEntity) {
+	if event.Dispatch(&event.EntitySpawn{Entity: s, Chunk: chunk.loc}) {
+		return
+	}
+
 	newEntityId := chunk.shard.entityMgr.NewEntity()
 	s.SetEntityId(newEntityId)
 	chunk.entities[newEntityId] = s
@@ -171,7 +197,16 @@ func (chunk *Chunk) AddEntity(s gamerules.INonPlayerEntity) {
 	chunk.storeDirty = true
 }
 
-func (chunk *Chunk) removeEntity(s gamerules.INonPlayerEntity) {
+// MobDied implements gamerules.IMobChunk.MobDied.
+func (chunk *Chunk) MobDied(entityId EntityId, cause DamageCause) {
+	// The mob is looked up so that handlers are given its own type, rather
+	// than the Mob embedded in it.
+	if e, ok := chunk.entities[entityId]; ok {
+		event.Dispatch(&event.EntityDeath{Entity: e, Cause: cause})
+	}
+}
+
+func (chunk *Chunk) RemoveEntity(s gamerules.INonPlayerEntity) {
 	e := s.GetEntityId()
 	chunk.shard.entityMgr.RemoveEntityById(e)
 	delete(chunk.entities, e)
@@ -259,6 +294,8 @@ func (chunk *Chunk) blockInstanceAndType(blockLoc *BlockXyz) (blockInstance *gam
 }
 
 func (chunk *Chunk) reqHitBlock(player gamerules.IPlayerClient, held gamerules.Slot, digStatus DigStatus, target *BlockXyz, face Face) {
+	chunk.shard.actor = player.Name()
+	defer func() { chunk.shard.actor = "" }()
 
 	blockInstance, blockType, ok := chunk.blockInstanceAndType(target)
 	if !ok {
@@ -266,8 +303,24 @@ func (chunk *Chunk) reqHitBlock(player gamerules.IPlayerClient, held gamerules.S
 	}
 
 	if blockType.Destructable && blockType.Aspect.Hit(blockInstance, player, digStatus) {
+		blockId := blockInstance.Index.BlockId(chunk.blocks)
+		breakEvent := &event.BlockBreak{
+			Player:  player,
+			Block:   *target,
+			BlockId: blockId,
+			Data:    blockInstance.Data,
+		}
+		if event.Dispatch(breakEvent) {
+			// The player's client has already removed the block.
+			buf := new(bytes.Buffer)
+			proto.WriteBlockChange(buf, target, blockId, blockInstance.Data)
+			player.TransmitPacket(buf.Bytes())
+			return
+		}
+
 		blockType.Aspect.Destroy(blockInstance)
 		chunk.setBlock(target, &blockInstance.SubLoc, blockInstance.Index, BlockIdAir, 0)
+		chunk.activateNeighbours(target)
 	}
 
 	return
@@ -296,6 +349,14 @@ func (chunk *Chunk) reqInteractBlock(player gamerules.IPlayerClient, held gameru
 		}
 
 		player.PlaceHeldItem(*destLoc, held)
+	} else if held.ItemTypeId == gamerules.ItemIdFlintAndSteel {
+		// TODO Set fire to blocks. Only portals are lit for now.
+		dx, dy, dz := againstFace.Dxyz()
+		if fireLoc := target.AddXyz(dx, dy, dz); fireLoc != nil {
+			chunk.shard.actor = player.Name()
+			gamerules.LightPortal(chunk, fireLoc)
+			chunk.shard.actor = ""
+		}
 	} else {
 		// Player is otherwise interacting with the block.
 		blockType.Aspect.Interact(blockInstance, player)
@@ -321,6 +382,9 @@ func (chunk *Chunk) reqPlaceItem(player gamerules.IPlayerClient, target *BlockXy
 		return
 	}
 
+	chunk.shard.actor = player.Name()
+	defer func() { chunk.shard.actor = "" }()
+
 	index, subLoc, ok := chunk.getBlockIndexByBlockXyz(target)
 	if !ok {
 		return
@@ -333,10 +397,22 @@ func (chunk *Chunk) reqPlaceItem(player gamerules.IPlayerClient, target *BlockXy
 		return
 	}
 
+	placeEvent := &event.BlockPlace{
+		Player:  player,
+		Block:   *target,
+		BlockId: heldBlockType,
+		Data:    byte(slot.Data),
+	}
+	if event.Dispatch(placeEvent) {
+		player.GiveItem(*slot)
+		return
+	}
+
 	// Safe to replace block.
-	chunk.setBlock(target, subLoc, index, heldBlockType, byte(slot.Data))
+	chunk.setBlock(target, subLoc, index, placeEvent.BlockId, placeEvent.Data)
 	// Allow this block to tick once
 	chunk.AddActiveBlockIndex(index)
+	chunk.activateNeighbours(target)
 
 	slot.Decrement()
 }
@@ -351,7 +427,7 @@ func (chunk *Chunk) reqTakeItem(player gamerules.IPlayerClient, entityId EntityI
 			buf := new(bytes.Buffer)
 			proto.WriteItemCollect(buf, entityId, player.GetEntityId())
 			chunk.reqMulticastPlayers(-1, buf.Bytes())
-			chunk.removeEntity(item)
+			chunk.RemoveEntity(item)
 		}
 	}
 }
@@ -454,10 +530,24 @@ func (chunk *Chunk) spawnTick() {
 	outgoingEntities := []gamerules.INonPlayerEntity{}
 
 	for _, e := range chunk.entities {
+		if mob, ok := e.(gamerules.IMob); ok {
+			wasDead := mob.Dead()
+			mob.Think(chunk)
+			if _, ok := chunk.entities[e.GetEntityId()]; !ok {
+				// The mob removed itself. If it was still alive then it exploded.
+				if !wasDead {
+					event.Dispatch(&event.EntityDeath{Entity: e, Cause: DamageCauseExplosion})
+				}
+				continue
+			}
+		}
 		if e.Tick(chunk) {
 			if e.Position().Y <= 0 {
 				// Item or mob fell out of the world.
-				chunk.removeEntity(e)
+				if _, ok := e.(gamerules.IMob); ok {
+					event.Dispatch(&event.EntityDeath{Entity: e, Cause: DamageCauseVoid})
+				}
+				chunk.RemoveEntity(e)
 			} else {
 				outgoingEntities = append(outgoingEntities, e)
 			}
@@ -488,6 +578,15 @@ func (chunk *Chunk) spawnTick() {
 
 // blockTick runs any blocks that need to do something each tick.
 func (chunk *Chunk) blockTick() {
+	for blockIndex, delay := range chunk.delayedActiveBlocks {
+		if delay <= 1 {
+			chunk.newActiveBlocks[blockIndex] = true
+			delete(chunk.delayedActiveBlocks, blockIndex)
+		} else {
+			chunk.delayedActiveBlocks[blockIndex] = delay - 1
+		}
+	}
+
 	if len(chunk.activeBlocks) == 0 && len(chunk.newActiveBlocks) == 0 {
 		return
 	}
@@ -506,6 +605,7 @@ func (chunk *Chunk) blockTick() {
 		if !ok {
 			// Invalid block.
 			delete(chunk.activeBlocks, blockIndex)
+			continue
 		}
 
 		blockInstance.SubLoc = blockIndex.ToSubChunkXyz()
@@ -556,6 +656,8 @@ func (chunk *Chunk) AddActiveBlock(blockXyz *BlockXyz) {
 		if index, ok := subLoc.BlockIndex(); ok {
 			chunk.newActiveBlocks[index] = true
 		}
+	} else {
+		chunk.shard.addActiveBlock(blockXyz)
 	}
 }
 
@@ -563,12 +665,111 @@ func (chunk *Chunk) AddActiveBlockIndex(blockIndex BlockIndex) {
 	chunk.newActiveBlocks[blockIndex] = true
 }
 
-func (chunk *Chunk) mobs() (s []*gamerules.Mob) {
-	s = make([]*gamerules.Mob, 0, 3)
+func (chunk *Chunk) AddDelayedActiveBlockIndex(blockIndex BlockIndex, delay Ticks) {
+	if current, ok := chunk.delayedActiveBlocks[blockIndex]; !ok || delay < current {
+		chunk.delayedActiveBlocks[blockIndex] = delay
+	}
+}
+
+// activateNeighbours flags the blocks adjacent to the given block as active,
+// so that they can react to it changing.
+func (chunk *Chunk) activateNeighbours(blockLoc *BlockXyz) {
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		dx, dy, dz := face.Dxyz()
+		if neighbourLoc := blockLoc.AddXyz(dx, dy, dz); neighbourLoc != nil {
+			chunk.AddActiveBlock(neighbourLoc)
+		}
+	}
+}
+
+func (chunk *Chunk) IsBlockOccupied(blockLoc *BlockXyz, anyEntity bool) bool {
+	for _, data := range chunk.playersData {
+		if *data.position.ToBlockXyz() == *blockLoc {
+			return true
+		}
+	}
+
+	for _, entity := range chunk.entities {
+		if _, isMob := entity.(gamerules.IMob); !isMob && !anyEntity {
+			continue
+		}
+		if *entity.Position().ToBlockXyz() == *blockLoc {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (chunk *Chunk) MulticastPlayers(exclude EntityId, packet []byte) {
+	chunk.reqMulticastPlayers(exclude, packet)
+}
+
+// BlockAt returns the block at the given location, provided that it is within
+// a loaded chunk in the same shard.
+func (chunk *Chunk) BlockAt(blockLoc *BlockXyz) (instance gamerules.BlockInstance, ok bool) {
+	chunkLoc, subLoc := blockLoc.ToChunkLocal()
+
+	blockChunk := chunk
+	if !chunk.isSameChunk(chunkLoc) {
+		if blockChunk = chunk.shard.loadedChunk(*chunkLoc); blockChunk == nil {
+			return
+		}
+	}
+
+	index, ok := subLoc.BlockIndex()
+	if !ok {
+		return
+	}
+
+	blockType, blockData, ok := blockChunk.blockTypeAndData(index)
+	if !ok {
+		return
+	}
+
+	instance = gamerules.BlockInstance{
+		Chunk:     blockChunk,
+		BlockLoc:  *blockLoc,
+		SubLoc:    *subLoc,
+		Index:     index,
+		BlockType: blockType,
+		Data:      blockData,
+	}
+
+	return
+}
+
+func (chunk *Chunk) SpreadBlock(target *BlockXyz, blockTypeId BlockId, blockData byte) {
+	chunk.shard.spreadBlock(gamerules.BlockSpread{*target, blockTypeId, blockData})
+}
+
+// reqSpreadBlock spreads a block type into a block within the chunk.
+func (chunk *Chunk) reqSpreadBlock(spread *gamerules.BlockSpread) {
+	blockType, ok := gamerules.Blocks.Get(spread.BlockTypeId)
+	if !ok {
+		return
+	}
+
+	spreadingAspect, ok := blockType.Aspect.(gamerules.ISpreadingAspect)
+	if !ok {
+		log.Printf("%v.reqSpreadBlock: block type %d does not spread", chunk, spread.BlockTypeId)
+		return
+	}
+
+	instance, ok := chunk.BlockAt(&spread.Target)
+	if !ok {
+		return
+	}
+
+	spreadingAspect.SpreadInto(&instance, spread.BlockData)
+}
+
+func (chunk *Chunk) mobs() (s []gamerules.IMob) {
+	s = make([]gamerules.IMob, 0, 3)
 	for _, e := range chunk.entities {
 		switch e.(type) {
-		case *gamerules.Mob:
-			s = append(s, e.(*gamerules.Mob))
+		case gamerules.IMob:
+			s = append(s, e.(gamerules.IMob))
 		}
 	}
 	return
@@ -686,7 +887,7 @@ func (chunk *Chunk) reqMulticastPlayers(exclude EntityId, packet []byte) {
 	}
 }
 
-func (chunk *Chunk) reqAddPlayerData(entityId EntityId, name string, pos AbsXyz, look LookBytes, held ItemTypeId) {
+func (chunk *Chunk) reqAddPlayerData(entityId EntityId, name string, pos AbsXyz, look LookBytes, held ItemTypeId, air, fire Ticks) {
 	// TODO add other initial data in here.
 	newPlayerData := &playerData{
 		entityId:   entityId,
@@ -694,6 +895,8 @@ func (chunk *Chunk) reqAddPlayerData(entityId EntityId, name string, pos AbsXyz,
 		position:   pos,
 		look:       look,
 		heldItemId: held,
+		air:        air,
+		fire:       fire,
 	}
 	chunk.playersData[entityId] = newPlayerData
 
@@ -724,6 +927,7 @@ func (chunk *Chunk) reqSetPlayerPosition(entityId EntityId, pos AbsXyz) {
 		return
 	}
 
+	oldBlockLoc := data.position.ToBlockXyz()
 	data.position = pos
 
 	// Update subscribers.
@@ -733,6 +937,14 @@ func (chunk *Chunk) reqSetPlayerPosition(entityId EntityId, pos AbsXyz) {
 
 	player, ok := chunk.subscribers[entityId]
 
+	// Blocks such as pressure plates react to players entering them.
+	if blockLoc := pos.ToBlockXyz(); *blockLoc != *oldBlockLoc {
+		chunk.AddActiveBlock(blockLoc)
+		if ok {
+			chunk.playerEntered(player, blockLoc)
+		}
+	}
+
 	if ok {
 		// Does the player overlap with any items?
 		for _, item := range chunk.items() {
@@ -749,6 +961,25 @@ func (chunk *Chunk) reqSetPlayerPosition(entityId EntityId, pos AbsXyz) {
 	}
 }
 
+// playerEntered tells the block that the player has moved into it, if the
+// block type reacts to that.
+func (chunk *Chunk) playerEntered(player gamerules.IPlayerClient, blockLoc *BlockXyz) {
+	instance, ok := chunk.BlockAt(blockLoc)
+	if !ok {
+		return
+	}
+	if aspect, ok := instance.BlockType.Aspect.(gamerules.IPlayerEnteredAspect); ok {
+		aspect.PlayerEntered(&instance, player)
+	}
+}
+
+// reqLinkPortal moves the player, who has come through a portal from the
+// other dimension, beside the portal nearest to target.
+func (chunk *Chunk) reqLinkPortal(player gamerules.IPlayerClient, target *BlockXyz) {
+	position, look := gamerules.LinkPortal(chunk, target)
+	player.SetPositionLook(position, look)
+}
+
 func (chunk *Chunk) reqSetPlayerLook(entityId EntityId, look LookBytes) {
 	data, ok := chunk.playersData[entityId]
 
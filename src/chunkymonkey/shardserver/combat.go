@@ -0,0 +1,46 @@
+package shardserver
+
+import (
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// reqHitEntity hits the mob with the given entity ID on behalf of a player at
+// position, if the mob is in the shard and within reach of the player.
+func (shard *ChunkShard) reqHitEntity(held *gamerules.Slot, position *AbsXyz, target EntityId) {
+	for _, chunk := range shard.loadedChunksWithin(position, gamerules.MaxAttackDistance) {
+		entity, ok := chunk.entities[target]
+		if !ok {
+			continue
+		}
+
+		mob, ok := entity.(gamerules.IMob)
+		if !ok || !mob.Position().IsWithinDistanceOf(position, gamerules.MaxAttackDistance) {
+			return
+		}
+
+		knockback := gamerules.Knockback(position, mob.Position())
+		mob.Hurt(chunk, gamerules.AttackDamage(held), DamageCausePlayer, &knockback)
+		chunk.storeDirty = true
+		return
+	}
+}
+
+// loadedChunksWithin returns the loaded chunks in the shard that are at least
+// partly within the square of the given distance around position.
+func (shard *ChunkShard) loadedChunksWithin(position *AbsXyz, distance AbsCoord) (chunks []*Chunk) {
+	minCorner := AbsXyz{position.X - distance, 0, position.Z - distance}
+	maxCorner := AbsXyz{position.X + distance, 0, position.Z + distance}
+	minLoc, maxLoc := minCorner.ToChunkXz(), maxCorner.ToChunkXz()
+
+	var chunkLoc ChunkXz
+	for chunkLoc.X = minLoc.X; chunkLoc.X <= maxLoc.X; chunkLoc.X++ {
+		for chunkLoc.Z = minLoc.Z; chunkLoc.Z <= maxLoc.Z; chunkLoc.Z++ {
+			if chunk := shard.loadedChunk(chunkLoc); chunk != nil {
+				chunks = append(chunks, chunk)
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,112 @@
+package shardserver
+
+import (
+	"testing"
+
+	"chunkymonkey/event"
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// testDeaths records the EntityDeath events dispatched while it is non-nil.
+var testDeaths *[]*event.EntityDeath
+
+func init() {
+	event.Register(event.KindEntityDeath, func(e event.Event) {
+		if testDeaths != nil {
+			*testDeaths = append(*testDeaths, e.(*event.EntityDeath))
+		}
+	})
+}
+
+func TestMobHurtDispatchesDeath(t *testing.T) {
+	var deaths []*event.EntityDeath
+	testDeaths = &deaths
+	defer func() { testDeaths = nil }()
+
+	mgr := newTestShardManager()
+	shard := newTestShard(mgr, ShardXz{0, 0})
+	chunk := newTestLightChunk(shard, ChunkXz{0, 0})
+
+	pig := gamerules.NewPig().(gamerules.IMob)
+	pig.SetPosition(&AbsXyz{5.5, 64, 5.5})
+	chunk.AddEntity(pig)
+
+	if pig.Hurt(chunk, 1, DamageCauseContact, &AbsVelocity{}) || len(deaths) != 0 {
+		t.Fatalf("got %d deaths from a hurt that left the pig alive, want none", len(deaths))
+	}
+
+	// The pig can't be hurt again until it recovers.
+	for i := 0; i < 20; i++ {
+		pig.Think(chunk)
+	}
+	if !pig.Hurt(chunk, 100, DamageCauseLava, &AbsVelocity{}) {
+		t.Fatal("got the pig alive after 100 damage")
+	}
+	if len(deaths) != 1 {
+		t.Fatalf("got %d deaths, want 1", len(deaths))
+	}
+	if deaths[0].Entity != pig || deaths[0].Cause != DamageCauseLava {
+		t.Errorf("got death of %T with cause %d, want the pig with cause %d",
+			deaths[0].Entity, deaths[0].Cause, DamageCauseLava)
+	}
+}
+
+func TestCreeperExplosion(t *testing.T) {
+	var deaths []*event.EntityDeath
+	testDeaths = &deaths
+	defer func() { testDeaths = nil }()
+
+	mgr := newTestShardManager()
+	shard := newTestShard(mgr, ShardXz{0, 0})
+	chunkA := newTestLightChunk(shard, ChunkXz{0, 0})
+	chunkB := newTestLightChunk(shard, ChunkXz{1, 0})
+
+	creeper := gamerules.NewCreeper().(gamerules.IMob)
+	creeper.SetPosition(&AbsXyz{14.5, 64, 5.5})
+	chunkA.AddEntity(creeper)
+	// The pig is in the next chunk.
+	pig := gamerules.NewPig().(gamerules.IMob)
+	pig.SetPosition(&AbsXyz{16.5, 64, 5.5})
+	chunkB.AddEntity(pig)
+
+	players := []struct {
+		position   AbsXyz
+		chunk      *Chunk
+		wantDamage bool
+	}{
+		{AbsXyz{12.5, 64, 5.5}, chunkA, true},
+		{AbsXyz{17.5, 64, 6.5}, chunkB, true},
+		{AbsXyz{14.5, 64, 12.5}, chunkA, false},
+	}
+	damaged := make([]*testDamagedPlayer, len(players))
+	for i, p := range players {
+		entityId := EntityId(100 + i)
+		damaged[i] = &testDamagedPlayer{air: MaxAir}
+		p.chunk.subscribers[entityId] = damaged[i]
+		p.chunk.reqAddPlayerData(entityId, "bystander", p.position, LookBytes{}, 0, MaxAir, 0)
+	}
+
+	// The creeper removes itself from its chunk when it explodes.
+	exploded := false
+	for i := 0; i < 40 && !exploded; i++ {
+		creeper.Think(chunkA)
+		_, inChunk := chunkA.entities[creeper.GetEntityId()]
+		exploded = !inChunk
+	}
+	if !exploded {
+		t.Fatal("got the creeper still in its chunk, want it to have exploded")
+	}
+
+	for i, p := range players {
+		gotDamage := len(damaged[i].damages) > 0
+		if gotDamage != p.wantDamage {
+			t.Errorf("player at %v: got damage %v, want damage=%t", p.position, damaged[i].damages, p.wantDamage)
+		} else if gotDamage && damaged[i].damages[0] != DamageCauseExplosion {
+			t.Errorf("player at %v: got damage %v, want explosion", p.position, damaged[i].damages)
+		}
+	}
+	if len(deaths) != 1 || deaths[0].Entity != pig || deaths[0].Cause != DamageCauseExplosion {
+		t.Errorf("got deaths %v, want the pig killed by the explosion", deaths)
+	}
+}
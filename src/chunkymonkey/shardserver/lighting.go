@@ -0,0 +1,427 @@
+package shardserver
+
+import (
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// Lighting is recomputed incrementally whenever a block changes. Each block
+// has two light levels - block light (from light emitting blocks) and sky
+// light. Light spreads to adjacent blocks, losing the opacity of the block it
+// enters (and always at least one level). Sky light is at full strength at and
+// above the height map of its column.
+//
+// Changes are applied with a pair of breadth-first searches. The first removes
+// light that depended upon the changed blocks, and the second spreads light
+// back in from any remaining sources. Light that spreads into a chunk in
+// another shard is sent to that shard as gamerules.LightUpdate values, and
+// light spreading into chunks that are not loaded is discarded.
+
+const maxLightLevel = 15
+
+type lightType byte
+
+const (
+	lightTypeBlock = lightType(iota)
+	lightTypeSky
+)
+
+// lightNode is a block visited while propagating light.
+type lightNode struct {
+	chunk *Chunk
+	loc   BlockXyz
+	index BlockIndex
+	level byte
+}
+
+// lightFaces are the offsets to the blocks adjacent to a block.
+var lightFaces = [6]struct {
+	dx BlockCoord
+	dy int
+	dz BlockCoord
+}{
+	{-1, 0, 0}, {1, 0, 0},
+	{0, -1, 0}, {0, 1, 0},
+	{0, 0, -1}, {0, 0, 1},
+}
+
+// lightPropagator recomputes one type of light after blocks have changed
+// within a shard.
+type lightPropagator struct {
+	shard       *ChunkShard
+	lightType   lightType
+	changed     []lightNode
+	removeQueue []lightNode
+	addQueue    []lightNode
+}
+
+func newLightPropagator(shard *ChunkShard, lightType lightType) *lightPropagator {
+	return &lightPropagator{
+		shard:     shard,
+		lightType: lightType,
+	}
+}
+
+// addChanged marks a block as having changed in a way that might affect its
+// light level, either through its opacity or its emitted light.
+func (p *lightPropagator) addChanged(chunk *Chunk, loc BlockXyz, index BlockIndex) {
+	p.changed = append(p.changed, lightNode{chunk, loc, index, 0})
+}
+
+// run recomputes light for the changed blocks and spreads the results.
+func (p *lightPropagator) run() {
+	for _, node := range p.changed {
+		if level := node.chunk.lightLevel(p.lightType, node.index); level > 0 {
+			node.chunk.setLightLevel(p.lightType, node.index, 0)
+			node.level = level
+			p.removeQueue = append(p.removeQueue, node)
+		}
+	}
+
+	p.spreadRemoval()
+
+	for _, node := range p.changed {
+		p.pull(&node)
+	}
+
+	p.spreadAddition()
+}
+
+// pull sets the light level of a block from its emitted light and the light
+// of its neighbours.
+func (p *lightPropagator) pull(node *lightNode) {
+	attenuation := node.chunk.lightAttenuation(node.index)
+	level := node.chunk.lightEmission(p.lightType, node.index)
+
+	for _, face := range lightFaces {
+		neighbour, ok, remote := p.shard.lightNodeAt(&node.loc, face.dx, face.dy, face.dz)
+		if remote {
+			// Ask the other shard to send back whatever light it has.
+			p.sendUpdate(neighbour.loc, 0, true)
+			continue
+		} else if !ok {
+			continue
+		}
+		neighbourLevel := neighbour.chunk.lightLevel(p.lightType, neighbour.index)
+		if neighbourLevel > attenuation && neighbourLevel-attenuation > level {
+			level = neighbourLevel - attenuation
+		}
+	}
+
+	if level > node.chunk.lightLevel(p.lightType, node.index) {
+		node.chunk.setLightLevel(p.lightType, node.index, level)
+		node.level = level
+		p.addQueue = append(p.addQueue, *node)
+	}
+}
+
+// spreadRemoval removes light from blocks that might have been lit by blocks
+// in the remove queue. Blocks that are at least as bright as their removed
+// neighbours are sources in their own right, and are queued to spread their
+// light back again.
+func (p *lightPropagator) spreadRemoval() {
+	for len(p.removeQueue) > 0 {
+		node := p.removeQueue[0]
+		p.removeQueue = p.removeQueue[1:]
+
+		for _, face := range lightFaces {
+			neighbour, ok, remote := p.shard.lightNodeAt(&node.loc, face.dx, face.dy, face.dz)
+			if remote {
+				p.sendUpdate(neighbour.loc, node.level, true)
+				continue
+			} else if !ok {
+				continue
+			}
+
+			neighbourLevel := neighbour.chunk.lightLevel(p.lightType, neighbour.index)
+			if neighbourLevel == 0 {
+				continue
+			} else if neighbourLevel < node.level {
+				neighbour.chunk.setLightLevel(p.lightType, neighbour.index, 0)
+				neighbour.level = neighbourLevel
+				p.removeQueue = append(p.removeQueue, neighbour)
+			} else {
+				neighbour.level = neighbourLevel
+				p.addQueue = append(p.addQueue, neighbour)
+			}
+		}
+	}
+}
+
+// spreadAddition spreads light outwards from blocks in the add queue.
+func (p *lightPropagator) spreadAddition() {
+	for len(p.addQueue) > 0 {
+		node := p.addQueue[0]
+		p.addQueue = p.addQueue[1:]
+
+		for _, face := range lightFaces {
+			neighbour, ok, remote := p.shard.lightNodeAt(&node.loc, face.dx, face.dy, face.dz)
+			if remote {
+				p.sendUpdate(neighbour.loc, node.level, false)
+				continue
+			} else if !ok {
+				continue
+			}
+
+			p.spreadInto(&neighbour, node.level)
+		}
+	}
+}
+
+// spreadInto lights the given block from an adjacent block of the given
+// level, queueing it to spread further if it became brighter.
+func (p *lightPropagator) spreadInto(node *lightNode, fromLevel byte) {
+	attenuation := node.chunk.lightAttenuation(node.index)
+	if fromLevel <= attenuation {
+		return
+	}
+	level := fromLevel - attenuation
+	if level > node.chunk.lightLevel(p.lightType, node.index) {
+		node.chunk.setLightLevel(p.lightType, node.index, level)
+		node.level = level
+		p.addQueue = append(p.addQueue, *node)
+	}
+}
+
+// applyUpdate applies a light change arriving from another shard.
+func (p *lightPropagator) applyUpdate(node lightNode, update *gamerules.LightUpdate) {
+	level := node.chunk.lightLevel(p.lightType, node.index)
+
+	if !update.Removed {
+		p.spreadInto(&node, update.Level)
+	} else if level != 0 && level < update.Level {
+		node.chunk.setLightLevel(p.lightType, node.index, 0)
+		node.level = level
+		p.removeQueue = append(p.removeQueue, node)
+	} else if level != 0 {
+		// The block is a source of light for the other shard.
+		node.level = level
+		p.addQueue = append(p.addQueue, node)
+	}
+}
+
+func (p *lightPropagator) sendUpdate(loc BlockXyz, level byte, removed bool) {
+	p.shard.addLightUpdate(gamerules.LightUpdate{
+		Block:    loc,
+		SkyLight: p.lightType == lightTypeSky,
+		Level:    level,
+		Removed:  removed,
+	})
+}
+
+// lightNodeAt looks up the block offset from the given block. ok is true if
+// the block is in a loaded chunk within this shard. remote is true if the block
+// is in another shard.
+func (shard *ChunkShard) lightNodeAt(loc *BlockXyz, dx BlockCoord, dy int, dz BlockCoord) (node lightNode, ok, remote bool) {
+	y := int(loc.Y) + dy
+	if y < 0 || y >= ChunkSizeY {
+		return
+	}
+
+	node.loc = BlockXyz{loc.X + dx, BlockYCoord(y), loc.Z + dz}
+	chunkLoc, subLoc := node.loc.ToChunkLocal()
+
+	chunkIndex, _, _, isThisShard := shard.chunkIndexAndRelLoc(*chunkLoc)
+	if !isThisShard {
+		remote = true
+		return
+	}
+
+	if node.chunk = shard.chunks[chunkIndex]; node.chunk == nil {
+		// Don't load chunks just to light them.
+		return
+	}
+
+	node.index, ok = subLoc.BlockIndex()
+	return
+}
+
+// relight recomputes the lighting around a block that has changed.
+func (chunk *Chunk) relight(blockLoc *BlockXyz, subLoc *SubChunkXyz, index BlockIndex) {
+	p := newLightPropagator(chunk.shard, lightTypeBlock)
+	p.addChanged(chunk, *blockLoc, index)
+	p.run()
+
+	// Blocks whose exposure to the sky changed along with the height map also
+	// need their sky light recomputing.
+	oldHeight, newHeight := chunk.updateHeightMap(subLoc)
+	if oldHeight > newHeight {
+		oldHeight, newHeight = newHeight, oldHeight
+	}
+
+	p = newLightPropagator(chunk.shard, lightTypeSky)
+	p.addChanged(chunk, *blockLoc, index)
+	columnLoc := *blockLoc
+	columnSubLoc := *subLoc
+	for y := oldHeight; y < newHeight && y < ChunkSizeY; y++ {
+		if y == int(subLoc.Y) {
+			continue
+		}
+		columnLoc.Y = BlockYCoord(y)
+		columnSubLoc.Y = SubChunkCoord(y)
+		if columnIndex, ok := columnSubLoc.BlockIndex(); ok {
+			p.addChanged(chunk, columnLoc, columnIndex)
+		}
+	}
+	p.run()
+}
+
+// reqUpdateLight applies light changes that have spread into the shard from
+// another shard.
+func (shard *ChunkShard) reqUpdateLight(updates []gamerules.LightUpdate) {
+	blockLight := newLightPropagator(shard, lightTypeBlock)
+	skyLight := newLightPropagator(shard, lightTypeSky)
+
+	for i := range updates {
+		update := &updates[i]
+
+		chunkLoc, subLoc := update.Block.ToChunkLocal()
+		chunkIndex, _, _, isThisShard := shard.chunkIndexAndRelLoc(*chunkLoc)
+		if !isThisShard {
+			continue
+		}
+		chunk := shard.chunks[chunkIndex]
+		if chunk == nil {
+			continue
+		}
+		index, ok := subLoc.BlockIndex()
+		if !ok {
+			continue
+		}
+
+		node := lightNode{chunk, update.Block, index, 0}
+		if update.SkyLight {
+			skyLight.applyUpdate(node, update)
+		} else {
+			blockLight.applyUpdate(node, update)
+		}
+	}
+
+	for _, p := range []*lightPropagator{blockLight, skyLight} {
+		p.spreadRemoval()
+		p.spreadAddition()
+	}
+}
+
+// addLightUpdate queues a light change to be sent to another shard at the end
+// of the tick.
+func (shard *ChunkShard) addLightUpdate(update gamerules.LightUpdate) {
+	shardLoc := update.Block.ToChunkXz().ToShardXz()
+	shardKey := shardLoc.Key()
+	lightShard, ok := shard.newLightShards[shardKey]
+	if !ok {
+		lightShard = &destLightShard{
+			loc: shardLoc,
+		}
+		shard.newLightShards[shardKey] = lightShard
+	}
+	lightShard.updates = append(lightShard.updates, update)
+}
+
+// transferLightUpdates sends light changes queued by addLightUpdate to their
+// destination shards.
+func (shard *ChunkShard) transferLightUpdates() {
+	for shardKey, lightShard := range shard.newLightShards {
+		if client := shard.clientForShard(lightShard.loc); client != nil {
+			client.ReqUpdateLight(lightShard.updates)
+		}
+		delete(shard.newLightShards, shardKey)
+	}
+}
+
+type destLightShard struct {
+	loc     ShardXz
+	updates []gamerules.LightUpdate
+}
+
+func (chunk *Chunk) lightArray(lightType lightType) []byte {
+	if lightType == lightTypeSky {
+		return chunk.skyLight
+	}
+	return chunk.blockLight
+}
+
+func (chunk *Chunk) lightLevel(lightType lightType, index BlockIndex) byte {
+	return index.BlockData(chunk.lightArray(lightType))
+}
+
+// LightLevels implements gamerules.IChunkBlock.LightLevels.
+func (chunk *Chunk) LightLevels(index BlockIndex) (skyLight, blockLight byte) {
+	return chunk.lightLevel(lightTypeSky, index), chunk.lightLevel(lightTypeBlock, index)
+}
+
+func (chunk *Chunk) setLightLevel(lightType lightType, index BlockIndex, level byte) {
+	index.SetBlockData(chunk.lightArray(lightType), level)
+	chunk.cachedPacket = nil
+	chunk.storeDirty = true
+}
+
+// lightAttenuation returns the amount that light is reduced by when entering
+// the block.
+func (chunk *Chunk) lightAttenuation(index BlockIndex) byte {
+	blockType, ok := gamerules.Blocks.Get(index.BlockId(chunk.blocks))
+	if !ok || blockType.Opacity > maxLightLevel {
+		return maxLightLevel
+	} else if blockType.Opacity < 1 {
+		return 1
+	}
+	return byte(blockType.Opacity)
+}
+
+// lightEmission returns the light level that the block has of its own accord.
+func (chunk *Chunk) lightEmission(lightType lightType, index BlockIndex) byte {
+	if lightType == lightTypeSky {
+		subLoc := index.ToSubChunkXyz()
+		if int(subLoc.Y) >= chunk.height(&subLoc) {
+			return maxLightLevel
+		}
+		return 0
+	}
+
+	blockType, ok := gamerules.Blocks.Get(index.BlockId(chunk.blocks))
+	if !ok || blockType.LightEmission < 0 {
+		return 0
+	} else if blockType.LightEmission > maxLightLevel {
+		return maxLightLevel
+	}
+	return byte(blockType.LightEmission)
+}
+
+// isOpaqueToSky returns true if the block stops sky light at full strength
+// from passing down through it.
+func (chunk *Chunk) isOpaqueToSky(index BlockIndex) bool {
+	blockType, ok := gamerules.Blocks.Get(index.BlockId(chunk.blocks))
+	return !ok || blockType.Opacity > 0
+}
+
+func heightMapIndex(subLoc *SubChunkXyz) int {
+	return int(subLoc.X)*ChunkSizeH + int(subLoc.Z)
+}
+
+// height returns the lowest level in the column at which sky light is at full
+// strength.
+func (chunk *Chunk) height(subLoc *SubChunkXyz) int {
+	return int(chunk.heightMap[heightMapIndex(subLoc)])
+}
+
+// updateHeightMap updates the height map for the column containing subLoc,
+// returning the height before and after the update.
+func (chunk *Chunk) updateHeightMap(subLoc *SubChunkXyz) (oldHeight, newHeight int) {
+	oldHeight = chunk.height(subLoc)
+
+	columnLoc := *subLoc
+	for newHeight = ChunkSizeY; newHeight > 0; newHeight-- {
+		columnLoc.Y = SubChunkCoord(newHeight - 1)
+		index, _ := columnLoc.BlockIndex()
+		if chunk.isOpaqueToSky(index) {
+			break
+		}
+	}
+
+	if newHeight != oldHeight {
+		chunk.heightMap[heightMapIndex(subLoc)] = byte(newHeight)
+		chunk.storeDirty = true
+	}
+
+	return
+}
@@ -0,0 +1,253 @@
+package shardserver
+
+import (
+	"testing"
+
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+const (
+	testAir       = BlockId(0)
+	testStone     = BlockId(1)
+	testGlass     = BlockId(20)
+	testGlowstone = BlockId(89)
+)
+
+// newTestLightChunk loads the chunk at loc into the shard, and empties it of
+// blocks, so that it is lit by the sky throughout.
+func newTestLightChunk(shard *ChunkShard, loc ChunkXz) *Chunk {
+	chunk := shard.chunkAt(loc)
+	for i := range chunk.blocks {
+		chunk.blocks[i] = byte(testAir)
+	}
+	for i := range chunk.blockData {
+		chunk.blockData[i] = 0
+		chunk.blockLight[i] = 0
+		chunk.skyLight[i] = maxLightLevel<<4 | maxLightLevel
+	}
+	for i := range chunk.heightMap {
+		chunk.heightMap[i] = 0
+	}
+	return chunk
+}
+
+// setTestBlock sets the block at loc, which must be in a loaded chunk.
+func setTestBlock(t *testing.T, shard *ChunkShard, loc BlockXyz, blockId BlockId) {
+	setTestBlockData(t, shard, loc, blockId, 0)
+}
+
+// setTestBlockData sets the block and its data at loc, which must be in a
+// loaded chunk.
+func setTestBlockData(t *testing.T, shard *ChunkShard, loc BlockXyz, blockId BlockId, data byte) {
+	chunkLoc, subLoc := loc.ToChunkLocal()
+	chunk := shard.loadedChunk(*chunkLoc)
+	index, ok := subLoc.BlockIndex()
+	if chunk == nil || !ok {
+		t.Fatalf("setting block at %v: chunk not loaded", loc)
+	}
+	chunk.setBlock(&loc, subLoc, index, blockId, data)
+}
+
+// testLightLevels returns the light levels of the block at loc, which must be
+// in a loaded chunk.
+func testLightLevels(t *testing.T, shard *ChunkShard, loc BlockXyz) (skyLight, blockLight byte) {
+	chunkLoc, subLoc := loc.ToChunkLocal()
+	chunk := shard.loadedChunk(*chunkLoc)
+	index, ok := subLoc.BlockIndex()
+	if chunk == nil || !ok {
+		t.Fatalf("getting light at %v: chunk not loaded", loc)
+	}
+	return chunk.LightLevels(index)
+}
+
+func TestLighting(t *testing.T) {
+	type light struct {
+		loc                  BlockXyz
+		skyLight, blockLight byte
+	}
+	tests := []struct {
+		desc   string
+		blocks []BlockXyz // Set in turn to the block types of changes.
+		ids    []BlockId
+		want   []light
+	}{
+		{
+			"light source placed",
+			[]BlockXyz{{5, 64, 5}},
+			[]BlockId{testGlowstone},
+			[]light{
+				{BlockXyz{5, 64, 5}, 0, 15},
+				{BlockXyz{6, 64, 5}, 15, 14},
+				{BlockXyz{5, 66, 5}, 15, 13},
+				{BlockXyz{2, 63, 4}, 15, 10},
+			},
+		},
+		{
+			"light source removed",
+			[]BlockXyz{{5, 64, 5}, {5, 64, 5}},
+			[]BlockId{testGlowstone, testAir},
+			[]light{
+				{BlockXyz{5, 64, 5}, 15, 0},
+				{BlockXyz{6, 64, 5}, 15, 0},
+				{BlockXyz{2, 63, 4}, 15, 0},
+			},
+		},
+		{
+			"light blocked by an opaque block",
+			[]BlockXyz{{5, 64, 5}, {6, 64, 5}},
+			[]BlockId{testGlowstone, testStone},
+			[]light{
+				{BlockXyz{6, 64, 5}, 0, 0},
+				{BlockXyz{7, 64, 5}, 15, 11}, // Around the stone.
+			},
+		},
+		{
+			"sky light cut by an opaque block",
+			[]BlockXyz{{5, 64, 5}},
+			[]BlockId{testStone},
+			[]light{
+				{BlockXyz{5, 64, 5}, 0, 0},
+				{BlockXyz{5, 65, 5}, 15, 0},
+				{BlockXyz{5, 63, 5}, 14, 0},
+				{BlockXyz{5, 10, 5}, 14, 0},
+				{BlockXyz{6, 63, 5}, 15, 0},
+			},
+		},
+		{
+			"sky light through glass",
+			[]BlockXyz{{5, 64, 5}},
+			[]BlockId{testGlass},
+			[]light{
+				{BlockXyz{5, 64, 5}, 15, 0},
+				{BlockXyz{5, 63, 5}, 15, 0},
+			},
+		},
+		{
+			"sky light restored",
+			[]BlockXyz{{5, 64, 5}, {5, 64, 5}},
+			[]BlockId{testStone, testAir},
+			[]light{
+				{BlockXyz{5, 64, 5}, 15, 0},
+				{BlockXyz{5, 63, 5}, 15, 0},
+			},
+		},
+		{
+			"light across a chunk boundary",
+			[]BlockXyz{{15, 64, 5}},
+			[]BlockId{testGlowstone},
+			[]light{
+				{BlockXyz{16, 64, 5}, 15, 14},
+				{BlockXyz{18, 64, 5}, 15, 12},
+			},
+		},
+		{
+			"light removed across a chunk boundary",
+			[]BlockXyz{{15, 64, 5}, {15, 64, 5}},
+			[]BlockId{testGlowstone, testAir},
+			[]light{
+				{BlockXyz{16, 64, 5}, 15, 0},
+				{BlockXyz{18, 64, 5}, 15, 0},
+			},
+		},
+	}
+
+	mgr := newTestShardManager()
+	for _, test := range tests {
+		shard := newTestShard(mgr, ShardXz{0, 0})
+		newTestLightChunk(shard, ChunkXz{0, 0})
+		newTestLightChunk(shard, ChunkXz{1, 0})
+
+		for i, loc := range test.blocks {
+			setTestBlock(t, shard, loc, test.ids[i])
+		}
+
+		for _, want := range test.want {
+			skyLight, blockLight := testLightLevels(t, shard, want.loc)
+			if skyLight != want.skyLight || blockLight != want.blockLight {
+				t.Errorf("%s: at %v got sky light %d and block light %d, want %d and %d",
+					test.desc, want.loc, skyLight, blockLight, want.skyLight, want.blockLight)
+			}
+		}
+	}
+}
+
+func TestHeightMap(t *testing.T) {
+	tests := []struct {
+		desc   string
+		blocks []BlockXyz
+		ids    []BlockId
+		want   int
+	}{
+		{"empty", nil, nil, 0},
+		{"opaque block", []BlockXyz{{3, 64, 4}}, []BlockId{testStone}, 65},
+		{"transparent block", []BlockXyz{{3, 64, 4}}, []BlockId{testGlass}, 0},
+		{"transparent block above", []BlockXyz{{3, 64, 4}, {3, 70, 4}}, []BlockId{testStone, testGlass}, 65},
+		{"opaque block above", []BlockXyz{{3, 64, 4}, {3, 70, 4}}, []BlockId{testStone, testStone}, 71},
+		{"top block removed", []BlockXyz{{3, 64, 4}, {3, 70, 4}, {3, 70, 4}}, []BlockId{testStone, testStone, testAir}, 65},
+		{"only block removed", []BlockXyz{{3, 64, 4}, {3, 64, 4}}, []BlockId{testStone, testAir}, 0},
+	}
+
+	mgr := newTestShardManager()
+	for _, test := range tests {
+		shard := newTestShard(mgr, ShardXz{0, 0})
+		chunk := newTestLightChunk(shard, ChunkXz{0, 0})
+
+		for i, loc := range test.blocks {
+			setTestBlock(t, shard, loc, test.ids[i])
+		}
+
+		subLoc := SubChunkXyz{3, 0, 4}
+		if got := chunk.height(&subLoc); got != test.want {
+			t.Errorf("%s: got height %d, want %d", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestLightingAcrossShards(t *testing.T) {
+	mgr := newTestShardManager()
+	edge := BlockCoord(ChunkSizeH*ShardSize - 1)
+	shardA := newTestShard(mgr, ShardXz{0, 0})
+	newTestLightChunk(shardA, ChunkXz{ShardSize - 1, 0})
+	shardB := newTestShard(mgr, ShardXz{1, 0})
+	newTestLightChunk(shardB, ChunkXz{ShardSize, 0})
+	keyB := shardB.loc.Key()
+
+	// Light reaching the edge of the shard is queued for the neighbouring
+	// shard.
+	setTestBlock(t, shardA, BlockXyz{edge, 64, 5}, testGlowstone)
+	lightShard, ok := shardA.newLightShards[keyB]
+	if !ok {
+		t.Fatalf("got no light updates queued for the neighbouring shard")
+	}
+	want := gamerules.LightUpdate{Block: BlockXyz{edge + 1, 64, 5}, SkyLight: false, Level: 15}
+	found := false
+	for _, update := range lightShard.updates {
+		found = found || update == want
+	}
+	if !found {
+		t.Fatalf("got updates %+v, want %+v among them", lightShard.updates, want)
+	}
+
+	shardB.reqUpdateLight(lightShard.updates)
+	for _, check := range []struct {
+		loc  BlockXyz
+		want byte
+	}{
+		{BlockXyz{edge + 1, 64, 5}, 14},
+		{BlockXyz{edge + 3, 64, 5}, 12},
+	} {
+		if _, blockLight := testLightLevels(t, shardB, check.loc); blockLight != check.want {
+			t.Errorf("at %v got block light %d, want %d", check.loc, blockLight, check.want)
+		}
+	}
+
+	// Removing the light source removes the light that it gave the other
+	// shard.
+	delete(shardA.newLightShards, keyB)
+	setTestBlock(t, shardA, BlockXyz{edge, 64, 5}, testAir)
+	shardB.reqUpdateLight(shardA.newLightShards[keyB].updates)
+	if _, blockLight := testLightLevels(t, shardB, BlockXyz{edge + 3, 64, 5}); blockLight != 0 {
+		t.Errorf("after removal got block light %d, want 0", blockLight)
+	}
+}
@@ -26,6 +26,7 @@ func (conn *localPlayerShardClient) Disconnect() {
 	conn.shard.enqueueAllChunks(func(chunk *Chunk) {
 		chunk.reqUnsubscribeChunk(conn.entityId, false)
 	})
+	conn.shard.mgr.playerShardDisconnect(conn.shard)
 }
 
 func (conn *localPlayerShardClient) ReqSubscribeChunk(chunkLoc ChunkXz, notify bool) {
@@ -46,9 +47,9 @@ func (conn *localPlayerShardClient) ReqMulticastPlayers(chunkLoc ChunkXz, exclud
 	})
 }
 
-func (conn *localPlayerShardClient) ReqAddPlayerData(chunkLoc ChunkXz, name string, position AbsXyz, look LookBytes, held ItemTypeId) {
+func (conn *localPlayerShardClient) ReqAddPlayerData(chunkLoc ChunkXz, name string, position AbsXyz, look LookBytes, held ItemTypeId, air, fire Ticks) {
 	conn.shard.enqueueOnChunk(chunkLoc, func(chunk *Chunk) {
-		chunk.reqAddPlayerData(conn.entityId, name, position, look, held)
+		chunk.reqAddPlayerData(conn.entityId, name, position, look, held, air, fire)
 	})
 }
 
@@ -78,6 +79,12 @@ func (conn *localPlayerShardClient) ReqHitBlock(held gamerules.Slot, target Bloc
 	})
 }
 
+func (conn *localPlayerShardClient) ReqHitEntity(held gamerules.Slot, position AbsXyz, target EntityId) {
+	conn.shard.enqueue(func() {
+		conn.shard.reqHitEntity(&held, &position, target)
+	})
+}
+
 func (conn *localPlayerShardClient) ReqInteractBlock(held gamerules.Slot, target BlockXyz, face Face) {
 	chunkLoc := target.ToChunkXz()
 
@@ -120,3 +127,10 @@ func (conn *localPlayerShardClient) ReqInventoryUnsubscribed(block BlockXyz) {
 		chunk.reqInventoryUnsubscribed(conn.player, &block)
 	})
 }
+
+func (conn *localPlayerShardClient) ReqLinkPortal(target BlockXyz) {
+	chunkLoc := target.ToChunkXz()
+	conn.shard.enqueueOnChunk(*chunkLoc, func(chunk *Chunk) {
+		chunk.reqLinkPortal(conn.player, &target)
+	})
+}
@@ -7,12 +7,14 @@ import (
 
 // localShardShardClient implements IShardShardClient for LocalShardManager.
 type localShardShardClient struct {
-	serverShard *ChunkShard
+	mgr      *LocalShardManager
+	shardLoc ShardXz
 }
 
-func newLocalShardShardClient(serverShard *ChunkShard) *localShardShardClient {
+func newLocalShardShardClient(mgr *LocalShardManager, shardLoc ShardXz) *localShardShardClient {
 	return &localShardShardClient{
-		serverShard: serverShard,
+		mgr:      mgr,
+		shardLoc: shardLoc,
 	}
 }
 
@@ -20,16 +22,35 @@ func (client *localShardShardClient) Disconnect() {
 }
 
 func (client *localShardShardClient) ReqSetActiveBlocks(blocks []BlockXyz) {
-	client.serverShard.enqueue(func() {
-		client.serverShard.reqSetBlocksActive(blocks)
+	client.mgr.enqueueOnShard(client.shardLoc, false, func(shard *ChunkShard) {
+		shard.reqSetBlocksActive(blocks)
 	})
 }
 
+// ReqTransferEntity starts the shard if needed, so that the entity isn't lost.
 func (client *localShardShardClient) ReqTransferEntity(loc ChunkXz, entity gamerules.INonPlayerEntity) {
-	client.serverShard.enqueue(func() {
-		chunk := client.serverShard.chunkAt(loc)
+	client.mgr.enqueueOnShard(client.shardLoc, true, func(shard *ChunkShard) {
+		chunk := shard.chunkAt(loc)
 		if chunk != nil {
 			chunk.transferEntity(entity)
 		}
 	})
 }
+
+func (client *localShardShardClient) ReqSpreadBlocks(spreads []gamerules.BlockSpread) {
+	client.mgr.enqueueOnShard(client.shardLoc, false, func(shard *ChunkShard) {
+		shard.reqSpreadBlocks(spreads)
+	})
+}
+
+func (client *localShardShardClient) ReqUpdateLight(updates []gamerules.LightUpdate) {
+	client.mgr.enqueueOnShard(client.shardLoc, false, func(shard *ChunkShard) {
+		shard.reqUpdateLight(updates)
+	})
+}
+
+func (client *localShardShardClient) ReqUpdatePower(updates []gamerules.PowerUpdate) {
+	client.mgr.enqueueOnShard(client.shardLoc, false, func(shard *ChunkShard) {
+		shard.reqUpdatePower(updates)
+	})
+}
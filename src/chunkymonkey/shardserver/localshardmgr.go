@@ -3,30 +3,72 @@ package shardserver
 import (
 	"sync"
 
+	"chunkymonkey/blocklog"
 	"chunkymonkey/chunkstore"
+	"chunkymonkey/config"
 	"chunkymonkey/entity"
 	"chunkymonkey/gamerules"
 	. "chunkymonkey/types"
 )
 
+// IRemoteShards connects to shards that are hosted by other processes.
+type IRemoteShards interface {
+	gamerules.IShardConnecter
+
+	// IsRemote returns true if the shard is hosted by another process.
+	IsRemote(shardLoc ShardXz) bool
+
+	// RevertBlockChanges undoes the changes, given oldest first, within the
+	// shard, as LocalShardManager.RevertBlockChanges does.
+	RevertBlockChanges(shardLoc ShardXz, player string, changes []blocklog.Change)
+}
+
 // LocalShardManager contains all chunk shards and can look them up. It
-// implements IShardConnecter and is for use in hosting all shards in the local
-// process.
+// implements IShardConnecter and is for use in hosting shards in the local
+// process. Connections to shards hosted by other processes are made through
+// the IRemoteShards given to SetRemoteShards.
 type LocalShardManager struct {
 	entityMgr  *entity.EntityManager
 	chunkStore chunkstore.IChunkStore
+	blockLog   *blocklog.BlockLog
+	config     *config.WorldConfig
+	remote     IRemoteShards
+	biomes     gamerules.IBiomeSource
 	shards     map[uint64]*ChunkShard
+	stopped    bool // Set by Stop, and guarded by lock.
 	lock       sync.Mutex
 }
 
-func NewLocalShardManager(chunkStore chunkstore.IChunkStore, entityMgr *entity.EntityManager) *LocalShardManager {
+// NewLocalShardManager creates a LocalShardManager. Changes that players make
+// to blocks are recorded in blockLog. Shards are run as configured by
+// worldConfig.
+func NewLocalShardManager(chunkStore chunkstore.IChunkStore, entityMgr *entity.EntityManager, blockLog *blocklog.BlockLog, worldConfig *config.WorldConfig) *LocalShardManager {
 	return &LocalShardManager{
 		entityMgr:  entityMgr,
 		chunkStore: chunkStore,
+		blockLog:   blockLog,
+		config:     worldConfig,
 		shards:     make(map[uint64]*ChunkShard),
 	}
 }
 
+// SetRemoteShards makes connections to shards for which remote.IsRemote
+// returns true go through remote. It must be called before the manager is
+// used.
+func (mgr *LocalShardManager) SetRemoteShards(remote IRemoteShards) {
+	mgr.remote = remote
+}
+
+// SetBiomes makes natural mob spawning depend on the biomes given by biomes.
+// It must be called before the manager is used.
+func (mgr *LocalShardManager) SetBiomes(biomes gamerules.IBiomeSource) {
+	mgr.biomes = biomes
+}
+
+func (mgr *LocalShardManager) isRemote(loc ShardXz) bool {
+	return mgr.remote != nil && mgr.remote.IsRemote(loc)
+}
+
 func (mgr *LocalShardManager) getShard(loc ShardXz, create bool) *ChunkShard {
 	shardKey := loc.Key()
 	if shard, ok := mgr.shards[shardKey]; ok {
@@ -38,8 +80,9 @@ func (mgr *LocalShardManager) getShard(loc ShardXz, create bool) *ChunkShard {
 		return nil
 	}
 
-	// Create shard.
-	shard := NewChunkShard(mgr, mgr.chunkStore, mgr.entityMgr, loc)
+	// Create shard. Shards created after Stop only discard their requests.
+	shard := NewChunkShard(mgr, mgr.chunkStore, mgr.entityMgr, mgr.blockLog, mgr.config, loc)
+	shard.stopped = mgr.stopped
 	mgr.shards[shardKey] = shard
 	go shard.serve()
 
@@ -47,44 +90,262 @@ func (mgr *LocalShardManager) getShard(loc ShardXz, create bool) *ChunkShard {
 }
 
 func (mgr *LocalShardManager) PlayerShardConnect(entityId EntityId, player gamerules.IPlayerClient, shardLoc ShardXz) gamerules.IPlayerShardClient {
+	if mgr.isRemote(shardLoc) {
+		return mgr.remote.PlayerShardConnect(entityId, player, shardLoc)
+	}
+
 	mgr.lock.Lock()
 	defer mgr.lock.Unlock()
 
 	shard := mgr.getShard(shardLoc, true)
+	shard.clients++
 	return newLocalPlayerShardClient(entityId, player, shard)
 }
 
-func (mgr *LocalShardManager) ShardShardConnect(shardLoc ShardXz) gamerules.IShardShardClient {
+// playerShardDisconnect is called when a player's connection to the shard is
+// closed.
+func (mgr *LocalShardManager) playerShardDisconnect(shard *ChunkShard) {
 	mgr.lock.Lock()
 	defer mgr.lock.Unlock()
 
-	shard := mgr.getShard(shardLoc, false)
+	shard.clients--
+}
+
+// ShardShardConnect returns a client for the shard at shardLoc. The shard is
+// looked up for each request, so the client remains usable when the shard is
+// stopped and started again.
+func (mgr *LocalShardManager) ShardShardConnect(shardLoc ShardXz) gamerules.IShardShardClient {
+	if mgr.isRemote(shardLoc) {
+		return mgr.remote.ShardShardConnect(shardLoc)
+	}
 
+	return newLocalShardShardClient(mgr, shardLoc)
+}
+
+// enqueueOnShard runs fn within the shard at shardLoc. If the shard is not
+// running, it is started if create is true, otherwise fn is not run. fn is
+// never run after Stop.
+func (mgr *LocalShardManager) enqueueOnShard(shardLoc ShardXz, create bool, fn func(shard *ChunkShard)) {
+	shard := mgr.acquireShard(shardLoc, create)
 	if shard == nil {
-		return nil
+		return
 	}
 
-	return newLocalShardShardClient(shard)
+	// The lock isn't held while enqueuing, as the shard's goroutine might be
+	// waiting for it.
+	shard.enqueue(func() {
+		fn(shard)
+	})
+
+	mgr.releaseShard(shard)
 }
 
-// TODO remove Enqueue* methods
+// acquireShard returns the shard at shardLoc, and keeps it from being removed
+// until it is given to releaseShard. If the shard is not running, it is
+// started if create is true, otherwise nil is returned.
+func (mgr *LocalShardManager) acquireShard(shardLoc ShardXz, create bool) *ChunkShard {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
 
-// EnqueueAllChunks runs a given function on all loaded chunks.
-func (mgr *LocalShardManager) EnqueueAllChunks(fn func(chunk *Chunk)) {
+	shard := mgr.getShard(shardLoc, create)
+	if shard != nil {
+		shard.pending++
+	}
+	return shard
+}
+
+// acquireShards returns all running shards, as acquireShard does. It returns
+// none after Stop.
+func (mgr *LocalShardManager) acquireShards() []*ChunkShard {
 	mgr.lock.Lock()
 	defer mgr.lock.Unlock()
 
+	return mgr.acquireShardsLocked()
+}
+
+// acquireShardsLocked is acquireShards for when mgr.lock is already held.
+func (mgr *LocalShardManager) acquireShardsLocked() []*ChunkShard {
+	if mgr.stopped {
+		return nil
+	}
+
+	shards := make([]*ChunkShard, 0, len(mgr.shards))
 	for _, shard := range mgr.shards {
+		shard.pending++
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+// releaseShard lets a shard from acquireShard or acquireShards be removed once
+// it is idle.
+func (mgr *LocalShardManager) releaseShard(shard *ChunkShard) {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+
+	shard.pending--
+}
+
+// removeIdleShard removes the shard if nothing is connected to it or sending
+// requests to it, returning true if it did. It is called from the shard's own
+// goroutine, which must stop if the shard was removed.
+func (mgr *LocalShardManager) removeIdleShard(shard *ChunkShard) bool {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+
+	if shard.clients > 0 || shard.pending > 0 || len(shard.requests) > 0 {
+		return false
+	}
+
+	delete(mgr.shards, shard.loc.Key())
+	return true
+}
+
+// KeepLoaded gives keep-loaded tickets to the chunks within radius chunks of
+// center, which loads them and stops them from being unloaded. Chunks in
+// remote shards are left to the processes that host them.
+func (mgr *LocalShardManager) KeepLoaded(center ChunkXz, radius ChunkCoord) {
+	shardChunks := make(map[uint64][]ChunkXz)
+	shardLocs := make(map[uint64]ShardXz)
+	var loc ChunkXz
+	for loc.X = center.X - radius; loc.X <= center.X+radius; loc.X++ {
+		for loc.Z = center.Z - radius; loc.Z <= center.Z+radius; loc.Z++ {
+			shardLoc := loc.ToShardXz()
+			if mgr.isRemote(shardLoc) {
+				continue
+			}
+			shardKey := shardLoc.Key()
+			shardChunks[shardKey] = append(shardChunks[shardKey], loc)
+			shardLocs[shardKey] = shardLoc
+		}
+	}
+
+	for shardKey, locs := range shardChunks {
+		locs := locs
+		mgr.enqueueOnShard(shardLocs[shardKey], true, func(shard *ChunkShard) {
+			shard.addTickets(locs)
+		})
+	}
+}
+
+// Stop saves the chunks in all shards, and stops them. It returns once the
+// chunks have been submitted to the chunk store. Requests made to the shards
+// afterwards are discarded.
+func (mgr *LocalShardManager) Stop() {
+	var wg sync.WaitGroup
+
+	// No shards are acquired once stopped is set, so these are all the shards
+	// that need stopping.
+	mgr.lock.Lock()
+	shards := mgr.acquireShardsLocked()
+	mgr.stopped = true
+	mgr.lock.Unlock()
+
+	// The lock isn't held while enqueuing or waiting, as the shards might need
+	// it to finish what they are doing.
+	for _, shard := range shards {
+		shard := shard
+		wg.Add(1)
+		shard.enqueue(func() {
+			shard.stop()
+			wg.Done()
+		})
+		mgr.releaseShard(shard)
+	}
+
+	wg.Wait()
+}
+
+// Save submits the changed chunks in all shards to the chunk store, without
+// stopping the shards. It returns once they have been submitted.
+func (mgr *LocalShardManager) Save() {
+	mgr.forEachShard(func(shard *ChunkShard) {
+		shard.save()
+	})
+}
+
+// ShardStats describes a shard that is running in this process.
+type ShardStats struct {
+	Loc    ShardXz
+	Chunks int // The number of chunks loaded.
+}
+
+// Stats returns the shards that are running in this process. Shards hosted by
+// other processes are not included.
+func (mgr *LocalShardManager) Stats() []ShardStats {
+	var lock sync.Mutex
+	var stats []ShardStats
+	mgr.forEachShard(func(shard *ChunkShard) {
+		lock.Lock()
+		defer lock.Unlock()
+		stats = append(stats, ShardStats{shard.loc, shard.loadedChunkCount()})
+	})
+	return stats
+}
+
+// forEachShard runs fn within each running shard, and returns once it has
+// run in all of them. After Stop, it returns without running fn.
+func (mgr *LocalShardManager) forEachShard(fn func(shard *ChunkShard)) {
+	var wg sync.WaitGroup
+
+	for _, shard := range mgr.acquireShards() {
+		shard := shard
+		wg.Add(1)
+		shard.enqueue(func() {
+			fn(shard)
+			wg.Done()
+		})
+		mgr.releaseShard(shard)
+	}
+
+	wg.Wait()
+}
+
+// TODO remove Enqueue* methods
+
+// EnqueueAllChunks runs a given function on all loaded chunks.
+func (mgr *LocalShardManager) EnqueueAllChunks(fn func(chunk *Chunk)) {
+	for _, shard := range mgr.acquireShards() {
 		shard.enqueueAllChunks(fn)
+		mgr.releaseShard(shard)
 	}
 }
 
 // EnqueueOnChunk runs a function on the chunk at the given location. If the
 // chunk does not exist, it does nothing.
 func (mgr *LocalShardManager) EnqueueOnChunk(loc ChunkXz, fn func(chunk *Chunk)) {
-	mgr.lock.Lock()
-	defer mgr.lock.Unlock()
-
-	shard := mgr.getShard(loc.ToShardXz(), true)
+	shard := mgr.acquireShard(loc.ToShardXz(), true)
 	shard.enqueueOnChunk(loc, fn)
+	mgr.releaseShard(shard)
+}
+
+// RevertBlockChanges undoes the changes, newest first, on behalf of the named
+// player. Blocks that have changed again since are left alone.
+func (mgr *LocalShardManager) RevertBlockChanges(player string, changes []blocklog.Change) {
+	// Changes within each shard are kept in order, so that the shards undo
+	// repeated changes to the same block correctly.
+	shardChanges := make(map[uint64][]blocklog.Change)
+	shardLocs := make(map[uint64]ShardXz)
+	for i := len(changes) - 1; i >= 0; i-- {
+		shardLoc := changes[i].Block.ToChunkXz().ToShardXz()
+		shardKey := shardLoc.Key()
+		shardChanges[shardKey] = append(shardChanges[shardKey], changes[i])
+		shardLocs[shardKey] = shardLoc
+	}
+
+	for shardKey, changes := range shardChanges {
+		shardLoc := shardLocs[shardKey]
+		if mgr.isRemote(shardLoc) {
+			oldestFirst := make([]blocklog.Change, len(changes))
+			for i := range changes {
+				oldestFirst[len(changes)-1-i] = changes[i]
+			}
+			mgr.remote.RevertBlockChanges(shardLoc, player, oldestFirst)
+			continue
+		}
+		changes := changes
+		mgr.enqueueOnShard(shardLoc, true, func(shard *ChunkShard) {
+			shard.revertBlockChanges(player, changes)
+		})
+	}
 }
@@ -0,0 +1,120 @@
+package shardserver
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"chunkymonkey/chunkstore"
+	"chunkymonkey/config"
+	"chunkymonkey/entity"
+	"chunkymonkey/generation"
+	. "chunkymonkey/types"
+)
+
+// testChunkStore generates chunks with a TestGenerator, and doesn't support
+// writes. The generator isn't safe for concurrent use, so the shards take
+// turns.
+type testChunkStore struct {
+	chunkstore.IChunkStore
+	lock sync.Mutex
+	gen  *generation.TestGenerator
+}
+
+func (store *testChunkStore) SupportsWrite() bool {
+	return false
+}
+
+func (store *testChunkStore) ReadChunk(loc ChunkXz) <-chan chunkstore.ChunkReadResult {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	result := make(chan chunkstore.ChunkReadResult, 1)
+	reader, err := store.gen.ReadChunk(loc)
+	result <- chunkstore.ChunkReadResult{reader, err}
+	return result
+}
+
+func newTestShardManager() *LocalShardManager {
+	entityMgr := new(entity.EntityManager)
+	entityMgr.Init()
+	worldConfig := &config.WorldConfig{SaveInterval: 60, UnloadDelay: 30}
+	return NewLocalShardManager(&testChunkStore{gen: generation.NewTestGenerator(0)}, entityMgr, nil, worldConfig)
+}
+
+// newTestShard creates a shard at loc, without starting its goroutine.
+func newTestShard(mgr *LocalShardManager, loc ShardXz) *ChunkShard {
+	shard := NewChunkShard(mgr, mgr.chunkStore, mgr.entityMgr, mgr.blockLog, mgr.config, loc)
+	mgr.shards[loc.Key()] = shard
+	return shard
+}
+
+func TestRemoveIdleShard(t *testing.T) {
+	tests := []struct {
+		clients, pending, queued int
+		want                     bool
+	}{
+		{0, 0, 0, true},
+		{1, 0, 0, false},
+		{0, 1, 0, false},
+		{0, 0, 1, false},
+	}
+
+	for _, test := range tests {
+		mgr := newTestShardManager()
+		shard := newTestShard(mgr, ShardXz{1, -2})
+		shard.clients, shard.pending = test.clients, test.pending
+		for i := 0; i < test.queued; i++ {
+			shard.enqueue(func() {})
+		}
+
+		if got := mgr.removeIdleShard(shard); got != test.want {
+			t.Errorf("%+v: removeIdleShard() = %t, want %t", test, got, test.want)
+		}
+		if _, running := mgr.shards[shard.loc.Key()]; running == test.want {
+			t.Errorf("%+v: got shard running=%t after removeIdleShard", test, running)
+		}
+	}
+}
+
+func TestLocalShardManagerStop(t *testing.T) {
+	mgr := newTestShardManager()
+	mgr.KeepLoaded(ChunkXz{0, 0}, 1)
+	if stats := mgr.Stats(); len(stats) == 0 {
+		t.Fatalf("got no shards running after KeepLoaded")
+	}
+
+	mgr.Stop()
+
+	// Requests made after Stop are discarded without blocking.
+	done := make(chan bool)
+	go func() {
+		mgr.Save()
+		if stats := mgr.Stats(); len(stats) != 0 {
+			t.Errorf("got %d shards running after Stop", len(stats))
+		}
+		mgr.enqueueOnShard(ShardXz{0, 0}, true, func(shard *ChunkShard) {
+			t.Errorf("ran request in %v after Stop", shard)
+		})
+		mgr.Stop()
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("requests after Stop blocked")
+	}
+
+	// The stopped shards are removed once nothing can send to them.
+	for i := 0; ; i++ {
+		mgr.lock.Lock()
+		running := len(mgr.shards)
+		mgr.lock.Unlock()
+		if running == 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("got %d shards still running after Stop", running)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,168 @@
+package shardserver
+
+import (
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+const (
+	// minHostileSpawnDistance is the closest to a player that hostile mobs
+	// spawn, so that they don't appear out of nowhere.
+	minHostileSpawnDistance = 24
+)
+
+// spawnMobs tries to spawn a hostile and a passive mob in each chunk that
+// players are subscribed to, while the shard has fewer mobs than its caps. It
+// is called once per second.
+func (shard *ChunkShard) spawnMobs() {
+	var hostile, passive int
+	for _, chunk := range shard.chunks {
+		if chunk == nil {
+			continue
+		}
+		for _, mob := range chunk.mobs() {
+			if mob.MobType().Hostile {
+				hostile++
+			} else {
+				passive++
+			}
+		}
+	}
+
+	for _, chunk := range shard.chunks {
+		if chunk == nil || len(chunk.subscribers) == 0 {
+			continue
+		}
+		if hostile < shard.maxHostileMobs && chunk.spawnMob(true) {
+			hostile++
+		}
+		if passive < shard.maxPassiveMobs && chunk.spawnMob(false) {
+			passive++
+		}
+	}
+}
+
+// spawnMob tries to spawn a mob at a random place in the chunk, returning true
+// if it did so. Hostile mobs might spawn anywhere, but passive mobs only spawn
+// on the surface.
+func (chunk *Chunk) spawnMob(hostile bool) bool {
+	subLoc := SubChunkXyz{
+		X: SubChunkCoord(chunk.rand.Intn(ChunkSizeH)),
+		Z: SubChunkCoord(chunk.rand.Intn(ChunkSizeH)),
+	}
+	height := chunk.height(&subLoc)
+	if hostile {
+		height = 1 + chunk.rand.Intn(height+1)
+	} else if chunk.rand.Intn(2) == 0 {
+		// Squid spawn beneath the surface of water.
+		height = 1 + chunk.rand.Intn(height+1)
+	}
+	if height < 1 || height >= ChunkSizeY-1 {
+		return false
+	}
+	subLoc.Y = SubChunkCoord(height)
+
+	var site gamerules.MobSpawnSite
+	var ok bool
+	index, _ := subLoc.BlockIndex()
+	if site.Feet, ok = gamerules.Blocks.Get(index.BlockId(chunk.blocks)); !ok {
+		return false
+	}
+	if site.Head, ok = gamerules.Blocks.Get((index + 1).BlockId(chunk.blocks)); !ok {
+		return false
+	}
+	if site.Ground, ok = gamerules.Blocks.Get((index - 1).BlockId(chunk.blocks)); !ok {
+		return false
+	}
+	site.SkyLight = chunk.lightLevel(lightTypeSky, index)
+	site.BlockLight = chunk.lightLevel(lightTypeBlock, index)
+
+	blockLoc := chunk.loc.ToBlockXyz(&subLoc)
+	site.Biome = BiomeUnknown
+	if biomes := chunk.shard.mgr.biomes; biomes != nil {
+		site.Biome = biomes.Biome(blockLoc.X, blockLoc.Z)
+	}
+
+	mob, ok := gamerules.NewNaturalMob(chunk.rand, &site, hostile)
+	if !ok {
+		return false
+	}
+
+	position := AbsXyz{
+		AbsCoord(blockLoc.X) + 0.5,
+		AbsCoord(blockLoc.Y),
+		AbsCoord(blockLoc.Z) + 0.5,
+	}
+	if hostile {
+		if _, _, ok := chunk.NearestPlayer(&position, minHostileSpawnDistance); ok {
+			return false
+		}
+	}
+
+	mob.SetPosition(&position)
+	chunk.AddEntity(mob)
+
+	return true
+}
+
+// NearestPlayer returns the nearest player within maxDistance of position.
+// Players in chunks outside of the shard are not found.
+func (chunk *Chunk) NearestPlayer(position *AbsXyz, maxDistance AbsCoord) (player gamerules.IPlayerClient, playerPos AbsXyz, ok bool) {
+	nearest := maxDistance
+
+	for _, other := range chunk.shard.loadedChunksWithin(position, maxDistance) {
+		for entityId, data := range other.playersData {
+			distance := position.Distance(&data.position)
+			if distance > nearest {
+				continue
+			}
+			// Players are always subscribed to the chunk that they are in.
+			if client, subscribed := other.subscribers[entityId]; subscribed {
+				player, playerPos, ok = client, data.position, true
+				nearest = distance
+			}
+		}
+	}
+
+	return
+}
+
+// PlayersWithin calls f for each player within maxDistance of position.
+// Players in chunks outside of the shard are left out.
+func (chunk *Chunk) PlayersWithin(position *AbsXyz, maxDistance AbsCoord, f func(player gamerules.IPlayerClient, playerPos AbsXyz)) {
+	for _, other := range chunk.shard.loadedChunksWithin(position, maxDistance) {
+		for entityId, data := range other.playersData {
+			if !position.IsWithinDistanceOf(&data.position, maxDistance) {
+				continue
+			}
+			if client, subscribed := other.subscribers[entityId]; subscribed {
+				f(client, data.position)
+			}
+		}
+	}
+}
+
+// MobsWithin calls f for each mob within maxDistance of position, with the
+// chunk that the mob is in. Mobs in chunks outside of the shard are left out.
+func (chunk *Chunk) MobsWithin(position *AbsXyz, maxDistance AbsCoord, f func(mob gamerules.IMob, mobChunk gamerules.IMobChunk)) {
+	for _, other := range chunk.shard.loadedChunksWithin(position, maxDistance) {
+		for _, mob := range other.mobs() {
+			if position.IsWithinDistanceOf(mob.Position(), maxDistance) {
+				f(mob, other)
+			}
+		}
+	}
+}
+
+// CountMobs returns the number of mobs of the given type within maxDistance of
+// position. Mobs in chunks outside of the shard are not counted.
+func (chunk *Chunk) CountMobs(mobType *gamerules.MobType, position *AbsXyz, maxDistance AbsCoord) (count int) {
+	for _, other := range chunk.shard.loadedChunksWithin(position, maxDistance) {
+		for _, mob := range other.mobs() {
+			if mob.MobType() == mobType && position.IsWithinDistanceOf(mob.Position(), maxDistance) {
+				count++
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,95 @@
+package shardserver
+
+import (
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+const (
+	// Height of a player's eyes above their feet.
+	playerEyeHeight = AbsCoord(1.62)
+	// Damage per second to players that are drowning.
+	drowningDamage = Health(2)
+	// Players below this height are falling out of the world.
+	voidHeight = AbsCoord(-64)
+	// Damage per second to players falling out of the world.
+	voidDamage = Health(4)
+	// Ticks that players keep burning for after leaving lava and fire.
+	lavaBurnTicks = Ticks(15 * TicksPerSecond)
+	fireBurnTicks = Ticks(8 * TicksPerSecond)
+	// Damage per second to players that are burning.
+	burnDamage = Health(1)
+)
+
+// damagePlayers hurts players within the chunk that are in harmful
+// surroundings. It is called once per second.
+func (chunk *Chunk) damagePlayers() {
+	for entityId, data := range chunk.playersData {
+		player, ok := chunk.subscribers[entityId]
+		if !ok {
+			continue
+		}
+
+		if data.position.Y < voidHeight {
+			player.Damage(voidDamage, DamageCauseVoid)
+			continue
+		}
+
+		feet, feetOk := chunk.BlockAt(data.position.ToBlockXyz())
+		eyePosition := data.position
+		eyePosition.Y += playerEyeHeight
+		eyes, eyesOk := chunk.BlockAt(eyePosition.ToBlockXyz())
+
+		if eyesOk && eyes.BlockType.Drowns {
+			if data.air > 0 {
+				data.air -= TicksPerSecond
+				if data.air < 0 {
+					data.air = 0
+				}
+				player.SetAir(data.air)
+			} else {
+				player.Damage(drowningDamage, DamageCauseDrowning)
+			}
+		} else if data.air != MaxAir {
+			data.air = MaxAir
+			player.SetAir(data.air)
+		}
+
+		// Take the worst damage from the blocks that the player is in.
+		var contact *gamerules.BlockType
+		if feetOk {
+			contact = feet.BlockType
+		}
+		if eyesOk && (contact == nil || eyes.BlockType.ContactDamage > contact.ContactDamage) {
+			contact = eyes.BlockType
+		}
+		inContact := contact != nil && contact.ContactDamage > 0
+		if inContact {
+			player.Damage(contact.ContactDamage, contact.ContactCause)
+		}
+
+		// Players keep burning after leaving lava or fire, until it runs out
+		// or they get into water.
+		fire := data.fire
+		switch {
+		case inContact && contact.ContactCause == DamageCauseLava:
+			fire = lavaBurnTicks
+		case inContact && contact.ContactCause == DamageCauseFire:
+			if fire < fireBurnTicks {
+				fire = fireBurnTicks
+			}
+		case (feetOk && feet.BlockType.Drowns) || (eyesOk && eyes.BlockType.Drowns):
+			fire = 0
+		case fire > 0:
+			player.Damage(burnDamage, DamageCauseFire)
+			fire -= TicksPerSecond
+			if fire < 0 {
+				fire = 0
+			}
+		}
+		if fire != data.fire {
+			data.fire = fire
+			player.SetFire(fire)
+		}
+	}
+}
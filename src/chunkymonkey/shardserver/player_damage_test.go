@@ -0,0 +1,153 @@
+package shardserver
+
+import (
+	"testing"
+
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+const (
+	testWater = BlockId(9)
+	testLava  = BlockId(11)
+)
+
+// testDamagedPlayer is an IPlayerClient that records the air, fire, damage
+// and broken falls that it is told of.
+type testDamagedPlayer struct {
+	gamerules.IPlayerClient
+	air        Ticks
+	fire       Ticks
+	damages    []DamageCause
+	resetFalls int
+}
+
+func (player *testDamagedPlayer) TransmitPacket(packet []byte) {}
+
+func (player *testDamagedPlayer) SetAir(air Ticks) {
+	player.air = air
+}
+
+func (player *testDamagedPlayer) SetFire(fire Ticks) {
+	player.fire = fire
+}
+
+func (player *testDamagedPlayer) Damage(amount Health, cause DamageCause) {
+	player.damages = append(player.damages, cause)
+}
+
+func (player *testDamagedPlayer) ResetFall() {
+	player.resetFalls++
+}
+
+func TestDrowningAcrossChunks(t *testing.T) {
+	mgr := newTestShardManager()
+	shard := newTestShard(mgr, ShardXz{0, 0})
+	chunkA := newTestLightChunk(shard, ChunkXz{0, 0})
+	chunkB := newTestLightChunk(shard, ChunkXz{1, 0})
+
+	// Water at the height of the player's eyes in both chunks.
+	for x := BlockCoord(14); x <= 17; x++ {
+		setTestBlock(t, shard, BlockXyz{x, 65, 5}, testWater)
+	}
+
+	const entityId = EntityId(1)
+	player := &testDamagedPlayer{air: MaxAir}
+	posA := AbsXyz{15.5, 64, 5.5}
+	posB := AbsXyz{16.5, 64, 5.5}
+
+	chunkA.subscribers[entityId] = player
+	chunkA.reqAddPlayerData(entityId, "swimmer", posA, LookBytes{}, 0, player.air, player.fire)
+	for i := 0; i < 10; i++ {
+		chunkA.damagePlayers()
+	}
+	if want := MaxAir - 10*TicksPerSecond; player.air != want {
+		t.Fatalf("got %d air after 10 seconds underwater, want %d", player.air, want)
+	}
+
+	// The player crosses into the next chunk, which is given their air.
+	chunkA.reqRemovePlayerData(entityId, false)
+	chunkB.subscribers[entityId] = player
+	chunkB.reqAddPlayerData(entityId, "swimmer", posB, LookBytes{}, 0, player.air, player.fire)
+	for i := 0; i < 5; i++ {
+		chunkB.damagePlayers()
+	}
+	if player.air != 0 || len(player.damages) != 0 {
+		t.Fatalf("got %d air and %d damage after 15 seconds underwater, want 0 air and no damage",
+			player.air, len(player.damages))
+	}
+
+	chunkB.damagePlayers()
+	if len(player.damages) != 1 || player.damages[0] != DamageCauseDrowning {
+		t.Errorf("got damage %v once out of air, want drowning", player.damages)
+	}
+
+	// Air is restored out of the water.
+	setTestBlock(t, shard, BlockXyz{16, 65, 5}, testAir)
+	chunkB.damagePlayers()
+	if player.air != MaxAir {
+		t.Errorf("got %d air out of the water, want %d", player.air, MaxAir)
+	}
+}
+
+func TestFallIntoWater(t *testing.T) {
+	mgr := newTestShardManager()
+	shard := newTestShard(mgr, ShardXz{0, 0})
+	chunk := newTestLightChunk(shard, ChunkXz{0, 0})
+	setTestBlock(t, shard, BlockXyz{5, 60, 5}, testWater)
+
+	const entityId = EntityId(1)
+	player := &testDamagedPlayer{air: MaxAir}
+	chunk.subscribers[entityId] = player
+	chunk.reqAddPlayerData(entityId, "diver", AbsXyz{5.5, 70, 5.5}, LookBytes{}, 0, player.air, player.fire)
+
+	chunk.reqSetPlayerPosition(entityId, AbsXyz{5.5, 65, 5.5})
+	if player.resetFalls != 0 {
+		t.Fatalf("got %d broken falls in the air, want none", player.resetFalls)
+	}
+	chunk.reqSetPlayerPosition(entityId, AbsXyz{5.5, 60.5, 5.5})
+	if player.resetFalls != 1 {
+		t.Errorf("got %d broken falls in the water, want 1", player.resetFalls)
+	}
+}
+
+func TestBurningAfterLava(t *testing.T) {
+	mgr := newTestShardManager()
+	shard := newTestShard(mgr, ShardXz{0, 0})
+	chunkA := newTestLightChunk(shard, ChunkXz{0, 0})
+	chunkB := newTestLightChunk(shard, ChunkXz{1, 0})
+	setTestBlock(t, shard, BlockXyz{15, 64, 5}, testLava)
+
+	const entityId = EntityId(1)
+	player := &testDamagedPlayer{air: MaxAir}
+	chunkA.subscribers[entityId] = player
+	chunkA.reqAddPlayerData(entityId, "stoker", AbsXyz{15.5, 64, 5.5}, LookBytes{}, 0, player.air, player.fire)
+	chunkA.damagePlayers()
+	if player.fire != lavaBurnTicks || len(player.damages) != 1 || player.damages[0] != DamageCauseLava {
+		t.Fatalf("got fire %d and damage %v in lava, want fire %d and lava damage",
+			player.fire, player.damages, lavaBurnTicks)
+	}
+
+	// The player keeps burning in the next chunk.
+	chunkA.reqRemovePlayerData(entityId, false)
+	chunkB.subscribers[entityId] = player
+	chunkB.reqAddPlayerData(entityId, "stoker", AbsXyz{16.5, 64, 5.5}, LookBytes{}, 0, player.air, player.fire)
+	player.damages = nil
+	for i := 0; i < 3; i++ {
+		chunkB.damagePlayers()
+	}
+	if want := lavaBurnTicks - 3*TicksPerSecond; player.fire != want {
+		t.Errorf("got fire %d after 3 seconds out of lava, want %d", player.fire, want)
+	}
+	if len(player.damages) != 3 || player.damages[0] != DamageCauseFire {
+		t.Errorf("got damage %v after 3 seconds out of lava, want 3 fire damage", player.damages)
+	}
+
+	// Water puts the fire out.
+	setTestBlock(t, shard, BlockXyz{16, 64, 5}, testWater)
+	player.damages = nil
+	chunkB.damagePlayers()
+	if player.fire != 0 || len(player.damages) != 0 {
+		t.Errorf("got fire %d and damage %v in water, want neither", player.fire, player.damages)
+	}
+}
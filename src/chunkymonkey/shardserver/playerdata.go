@@ -24,6 +24,14 @@ type playerData struct {
 	look       LookBytes
 	heldItemId ItemTypeId
 	// TODO Armor data.
+
+	// air is the number of ticks that the player can hold their breath for
+	// while in blocks that drown players. The player is told of changes to it,
+	// and gives it to the next chunk that they enter.
+	air Ticks
+	// fire is the number of ticks that the player keeps burning for after
+	// leaving lava or fire. It is carried between chunks as air is.
+	fire Ticks
 }
 
 func (player *playerData) sendSpawn(writer io.Writer) error {
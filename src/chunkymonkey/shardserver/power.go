@@ -0,0 +1,294 @@
+package shardserver
+
+import (
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+// Redstone power is not stored, but computed from the blocks that provide it
+// whenever it is needed. When a block changes, the blocks whose received power
+// might have changed are queued, and their IPoweredAspect is told in turn.
+// Power that crosses into another shard is sent to that shard as
+// gamerules.PowerUpdate values, which are cached by the receiving chunk.
+
+// NeighbourPower implements gamerules.IChunkBlock.NeighbourPower.
+func (chunk *Chunk) NeighbourPower(blockLoc *BlockXyz, face Face) gamerules.Power {
+	return chunk.shard.neighbourPower(blockLoc, face, true)
+}
+
+// neighbourPower returns the power provided to the block at blockLoc by the
+// adjacent block through face. Power conducted through solid blocks is only
+// included if conduct is true.
+func (shard *ChunkShard) neighbourPower(blockLoc *BlockXyz, face Face, conduct bool) (power gamerules.Power) {
+	dx, dy, dz := face.Dxyz()
+	neighbourLoc := blockLoc.AddXyz(dx, dy, dz)
+	if neighbourLoc == nil {
+		return
+	}
+
+	if shard.isRemoteBlock(neighbourLoc) {
+		if chunk, index, ok := shard.chunkAndIndex(blockLoc); ok {
+			if external, ok := chunk.externalPower[index]; ok {
+				power = external[face]
+			}
+		}
+		if power.Conducted && !conduct {
+			power = gamerules.Power{}
+		}
+		return
+	}
+
+	return shard.outputPower(neighbourLoc, face.Opposite(), conduct)
+}
+
+// outputPower returns the power provided by the block at blockLoc through the
+// given face. Solid blocks conduct power from adjacent power sources if
+// conduct is true.
+func (shard *ChunkShard) outputPower(blockLoc *BlockXyz, face Face, conduct bool) (power gamerules.Power) {
+	instance, ok := shard.blockAt(blockLoc)
+	if !ok {
+		return
+	}
+
+	if source, ok := instance.BlockType.Aspect.(gamerules.IPowerSourceAspect); ok {
+		return source.PowerOutput(&instance, face)
+	}
+
+	if !conduct || !instance.BlockType.ConductsPower() {
+		return
+	}
+
+	for inFace := Face(FaceMinValid); inFace <= FaceMaxValid; inFace++ {
+		if inFace == face {
+			continue
+		}
+		if input := shard.neighbourPower(blockLoc, inFace, false); input.Level > power.Level {
+			power = input
+		}
+	}
+	if power.Level > 0 {
+		power.Conducted = true
+	}
+
+	return
+}
+
+// notifyPowerChanged tells the blocks around blockLoc that the power they
+// receive might have changed, including those that receive power conducted
+// through adjacent solid blocks.
+func (shard *ChunkShard) notifyPowerChanged(blockLoc *BlockXyz) {
+	shard.queuePowerChange(*blockLoc)
+
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		dx, dy, dz := face.Dxyz()
+		neighbourLoc := blockLoc.AddXyz(dx, dy, dz)
+		if neighbourLoc == nil {
+			continue
+		}
+
+		if shard.isRemoteBlock(neighbourLoc) {
+			shard.sendPower(blockLoc, face)
+			continue
+		}
+
+		shard.queuePowerChange(*neighbourLoc)
+
+		if shard.conductsPower(neighbourLoc) {
+			shard.notifyConductorNeighbours(neighbourLoc, face.Opposite())
+		}
+	}
+
+	shard.updatePower()
+}
+
+// notifyConductorNeighbours queues the neighbours of a conducting block, other
+// than the one through the face that the change came from.
+func (shard *ChunkShard) notifyConductorNeighbours(blockLoc *BlockXyz, fromFace Face) {
+	for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+		if face == fromFace {
+			continue
+		}
+		dx, dy, dz := face.Dxyz()
+		neighbourLoc := blockLoc.AddXyz(dx, dy, dz)
+		if neighbourLoc == nil {
+			continue
+		}
+		if shard.isRemoteBlock(neighbourLoc) {
+			shard.sendPower(blockLoc, face)
+		} else {
+			shard.queuePowerChange(*neighbourLoc)
+		}
+	}
+}
+
+// sendPower queues the power provided by the block at blockLoc through face to
+// be sent to the shard on the other side.
+func (shard *ChunkShard) sendPower(blockLoc *BlockXyz, face Face) {
+	dx, dy, dz := face.Dxyz()
+	shard.addPowerUpdate(gamerules.PowerUpdate{
+		Block: *blockLoc.AddXyz(dx, dy, dz),
+		Face:  face.Opposite(),
+		Power: shard.outputPower(blockLoc, face, true),
+	})
+}
+
+func (shard *ChunkShard) queuePowerChange(blockLoc BlockXyz) {
+	if !shard.powerQueued[blockLoc] {
+		shard.powerQueued[blockLoc] = true
+		shard.powerQueue = append(shard.powerQueue, blockLoc)
+	}
+}
+
+// updatePower tells queued blocks about their received power. Blocks that
+// change as a result queue further blocks, which are handled by the same
+// call.
+func (shard *ChunkShard) updatePower() {
+	if shard.updatingPower {
+		return
+	}
+	shard.updatingPower = true
+	// Blocks reacting to power are not logged as changes by the player whose
+	// request caused them.
+	actor := shard.actor
+	shard.actor = ""
+	defer func() {
+		shard.updatingPower = false
+		shard.actor = actor
+	}()
+
+	for len(shard.powerQueue) > 0 {
+		blockLoc := shard.powerQueue[0]
+		shard.powerQueue = shard.powerQueue[1:]
+		delete(shard.powerQueued, blockLoc)
+
+		instance, ok := shard.blockAt(&blockLoc)
+		if !ok {
+			continue
+		}
+		aspect, ok := instance.BlockType.Aspect.(gamerules.IPoweredAspect)
+		if !ok {
+			continue
+		}
+
+		powered := false
+		for face := Face(FaceMinValid); face <= FaceMaxValid; face++ {
+			if shard.neighbourPower(&blockLoc, face, true).Level > 0 {
+				powered = true
+				break
+			}
+		}
+		chunk := instance.Chunk.(*Chunk)
+		wasPowered := chunk.poweredBlocks[instance.Index]
+		if powered {
+			chunk.poweredBlocks[instance.Index] = true
+		} else {
+			delete(chunk.poweredBlocks, instance.Index)
+		}
+
+		aspect.PowerChanged(&instance, powered, wasPowered)
+	}
+
+	shard.powerQueue = nil
+}
+
+// reqUpdatePower applies power changes that have crossed into the shard from
+// another shard.
+func (shard *ChunkShard) reqUpdatePower(updates []gamerules.PowerUpdate) {
+	for i := range updates {
+		update := &updates[i]
+		if update.Face < FaceMinValid || update.Face > FaceMaxValid {
+			continue
+		}
+		chunk, index, ok := shard.chunkAndIndex(&update.Block)
+		if !ok {
+			continue
+		}
+
+		external, ok := chunk.externalPower[index]
+		if update.Power.Level == 0 {
+			if !ok {
+				continue
+			}
+			external[update.Face] = update.Power
+			if *external == [FaceMaxValid + 1]gamerules.Power{} {
+				delete(chunk.externalPower, index)
+			}
+		} else {
+			if !ok {
+				external = new([FaceMaxValid + 1]gamerules.Power)
+				chunk.externalPower[index] = external
+			}
+			external[update.Face] = update.Power
+		}
+
+		shard.queuePowerChange(update.Block)
+		if shard.conductsPower(&update.Block) {
+			shard.notifyConductorNeighbours(&update.Block, update.Face)
+		}
+	}
+
+	shard.updatePower()
+}
+
+// addPowerUpdate queues a power change to be sent to another shard at the end
+// of the tick.
+func (shard *ChunkShard) addPowerUpdate(update gamerules.PowerUpdate) {
+	shardLoc := update.Block.ToChunkXz().ToShardXz()
+	shardKey := shardLoc.Key()
+	powerShard, ok := shard.newPowerShards[shardKey]
+	if !ok {
+		powerShard = &destPowerShard{
+			loc: shardLoc,
+		}
+		shard.newPowerShards[shardKey] = powerShard
+	}
+	powerShard.updates = append(powerShard.updates, update)
+}
+
+// transferPowerUpdates sends power changes queued by addPowerUpdate to their
+// destination shards.
+func (shard *ChunkShard) transferPowerUpdates() {
+	for shardKey, powerShard := range shard.newPowerShards {
+		if client := shard.clientForShard(powerShard.loc); client != nil {
+			client.ReqUpdatePower(powerShard.updates)
+		}
+		delete(shard.newPowerShards, shardKey)
+	}
+}
+
+type destPowerShard struct {
+	loc     ShardXz
+	updates []gamerules.PowerUpdate
+}
+
+// isRemoteBlock returns true if the block is in a chunk in another shard.
+func (shard *ChunkShard) isRemoteBlock(blockLoc *BlockXyz) bool {
+	_, _, _, ok := shard.chunkIndexAndRelLoc(*blockLoc.ToChunkXz())
+	return !ok
+}
+
+// chunkAndIndex returns the loaded chunk within the shard containing the
+// block, and the block's index in it.
+func (shard *ChunkShard) chunkAndIndex(blockLoc *BlockXyz) (chunk *Chunk, index BlockIndex, ok bool) {
+	chunkLoc, subLoc := blockLoc.ToChunkLocal()
+	if chunk = shard.loadedChunk(*chunkLoc); chunk == nil {
+		return
+	}
+	index, ok = subLoc.BlockIndex()
+	return
+}
+
+// blockAt returns the block at blockLoc if it is in a loaded chunk within the
+// shard.
+func (shard *ChunkShard) blockAt(blockLoc *BlockXyz) (instance gamerules.BlockInstance, ok bool) {
+	chunk := shard.loadedChunk(*blockLoc.ToChunkXz())
+	if chunk == nil {
+		return
+	}
+	return chunk.BlockAt(blockLoc)
+}
+
+func (shard *ChunkShard) conductsPower(blockLoc *BlockXyz) bool {
+	instance, ok := shard.blockAt(blockLoc)
+	return ok && instance.BlockType.ConductsPower()
+}
@@ -0,0 +1,162 @@
+package shardserver
+
+import (
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+const (
+	testWire        = BlockId(55)
+	testLever       = BlockId(69)
+	testTorchOff    = BlockId(75)
+	testTorchOn     = BlockId(76)
+	testRepeaterOff = BlockId(93)
+	testRepeaterOn  = BlockId(94)
+
+	testLeverOn = 0x8
+)
+
+// testBlock returns the type and data of the block at loc, which must be in a
+// loaded chunk.
+func testBlock(t *testing.T, shard *ChunkShard, loc BlockXyz) (BlockId, byte) {
+	chunk, index, ok := shard.chunkAndIndex(&loc)
+	if chunk == nil || !ok {
+		t.Fatalf("getting block at %v: chunk not loaded", loc)
+	}
+	return index.BlockId(chunk.blocks), index.BlockData(chunk.blockData)
+}
+
+// checkTestWire checks the power level of each wire along the x axis,
+// starting from loc.
+func checkTestWire(t *testing.T, desc string, shard *ChunkShard, loc BlockXyz, want []byte) {
+	for i, level := range want {
+		wireLoc := *loc.AddXyz(BlockCoord(i), 0, 0)
+		if blockId, data := testBlock(t, shard, wireLoc); blockId != testWire || data != level {
+			t.Errorf("%s: got block %d with level %d at %v, want wire with level %d",
+				desc, blockId, data, wireLoc, level)
+		}
+	}
+}
+
+func TestWireDecay(t *testing.T) {
+	mgr := newTestShardManager()
+	shard := newTestShard(mgr, ShardXz{0, 0})
+	newTestLightChunk(shard, ChunkXz{0, 0})
+
+	lever := BlockXyz{0, 64, 5}
+	for x := BlockCoord(1); x <= 15; x++ {
+		setTestBlock(t, shard, BlockXyz{x, 64, 5}, testWire)
+	}
+
+	setTestBlockData(t, shard, lever, testLever, testLeverOn)
+	checkTestWire(t, "lever on", shard, BlockXyz{1, 64, 5},
+		[]byte{15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1})
+
+	setTestBlockData(t, shard, lever, testLever, 0)
+	checkTestWire(t, "lever off", shard, BlockXyz{1, 64, 5},
+		[]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+}
+
+func TestTorchInversion(t *testing.T) {
+	mgr := newTestShardManager()
+	shard := newTestShard(mgr, ShardXz{0, 0})
+	chunk := newTestLightChunk(shard, ChunkXz{0, 0})
+
+	// The torch stands on the stone, which is powered by the lever beside it.
+	stone := BlockXyz{5, 64, 5}
+	torch := BlockXyz{5, 65, 5}
+	lever := BlockXyz{4, 64, 5}
+	wire := BlockXyz{6, 65, 5}
+	setTestBlock(t, shard, stone, testStone)
+	setTestBlock(t, shard, wire, testWire)
+	setTestBlock(t, shard, torch, testTorchOn)
+	checkTestWire(t, "torch placed", shard, wire, []byte{15})
+
+	setTestBlockData(t, shard, lever, testLever, testLeverOn)
+	chunk.blockTick()
+	if blockId, _ := testBlock(t, shard, torch); blockId != testTorchOn {
+		t.Errorf("got block %d after 1 tick, want the torch still on", blockId)
+	}
+	chunk.blockTick()
+	if blockId, _ := testBlock(t, shard, torch); blockId != testTorchOff {
+		t.Errorf("got block %d after 2 ticks, want the torch off", blockId)
+	}
+	checkTestWire(t, "stone powered", shard, wire, []byte{0})
+
+	setTestBlockData(t, shard, lever, testLever, 0)
+	chunk.blockTick()
+	chunk.blockTick()
+	if blockId, _ := testBlock(t, shard, torch); blockId != testTorchOn {
+		t.Errorf("got block %d after the lever was turned off, want the torch on", blockId)
+	}
+	checkTestWire(t, "stone unpowered", shard, wire, []byte{15})
+}
+
+func TestRepeaterDelay(t *testing.T) {
+	for delay := byte(0); delay <= 3; delay++ {
+		mgr := newTestShardManager()
+		shard := newTestShard(mgr, ShardXz{0, 0})
+		chunk := newTestLightChunk(shard, ChunkXz{0, 0})
+
+		// Facing data 1 sends power South, towards +x.
+		lever := BlockXyz{4, 64, 5}
+		repeater := BlockXyz{5, 64, 5}
+		wire := BlockXyz{6, 64, 5}
+		setTestBlockData(t, shard, repeater, testRepeaterOff, 1|delay<<2)
+		setTestBlock(t, shard, wire, testWire)
+		setTestBlockData(t, shard, lever, testLever, testLeverOn)
+
+		ticks := (int(delay) + 1) * 2
+		for i := 1; i < ticks; i++ {
+			chunk.blockTick()
+		}
+		if blockId, _ := testBlock(t, shard, repeater); blockId != testRepeaterOff {
+			t.Errorf("delay %d: got block %d after %d ticks, want the repeater still off",
+				delay, blockId, ticks-1)
+		}
+		checkTestWire(t, "repeater still off", shard, wire, []byte{0})
+
+		chunk.blockTick()
+		if blockId, _ := testBlock(t, shard, repeater); blockId != testRepeaterOn {
+			t.Errorf("delay %d: got block %d after %d ticks, want the repeater on",
+				delay, blockId, ticks)
+		}
+		checkTestWire(t, "repeater on", shard, wire, []byte{15})
+	}
+}
+
+func TestPowerAcrossShards(t *testing.T) {
+	mgr := newTestShardManager()
+	shardA := newTestShard(mgr, ShardXz{0, 0})
+	shardB := newTestShard(mgr, ShardXz{1, 0})
+	newTestLightChunk(shardA, ChunkXz{ShardSize - 1, 0})
+	newTestLightChunk(shardB, ChunkXz{ShardSize, 0})
+
+	// The lever is in the last block of shard A, and the wire starts in the
+	// first block of shard B.
+	edge := BlockCoord(ChunkSizeH*ShardSize - 1)
+	lever := BlockXyz{edge, 64, 5}
+	wire := BlockXyz{edge + 1, 64, 5}
+	for i := BlockCoord(0); i < 3; i++ {
+		setTestBlock(t, shardB, *wire.AddXyz(i, 0, 0), testWire)
+	}
+
+	deliver := func() {
+		powerShard, ok := shardA.newPowerShards[shardB.loc.Key()]
+		if !ok {
+			t.Fatalf("no power updates queued for shard %v", shardB.loc)
+		}
+		delete(shardA.newPowerShards, shardB.loc.Key())
+		shardB.reqUpdatePower(powerShard.updates)
+	}
+
+	setTestBlockData(t, shardA, lever, testLever, testLeverOn)
+	checkTestWire(t, "before the updates are sent", shardB, wire, []byte{0, 0, 0})
+	deliver()
+	checkTestWire(t, "lever on", shardB, wire, []byte{15, 14, 13})
+
+	setTestBlockData(t, shardA, lever, testLever, 0)
+	deliver()
+	checkTestWire(t, "lever off", shardB, wire, []byte{0, 0, 0})
+}
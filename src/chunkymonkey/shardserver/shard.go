@@ -5,17 +5,17 @@ import (
 	"log"
 	"time"
 
+	"chunkymonkey/blocklog"
 	"chunkymonkey/chunkstore"
+	"chunkymonkey/config"
 	"chunkymonkey/entity"
+	"chunkymonkey/event"
 	"chunkymonkey/gamerules"
 	. "chunkymonkey/types"
 )
 
 const chunksPerShard = ShardSize * ShardSize
 
-// TODO Allow configuration of this.
-const ticksBetweenSaves = TicksPerSecond * 60
-
 // chunkXzToChunkIndex assumes that locDelta is offset relative to the shard
 // origin.
 func chunkXzToChunkIndex(locDelta *ChunkXz) int {
@@ -25,6 +25,7 @@ func chunkXzToChunkIndex(locDelta *ChunkXz) int {
 // ChunkShard represents a square shard of chunks that share a master
 // goroutine.
 type ChunkShard struct {
+	mgr              *LocalShardManager
 	shardConnecter   gamerules.IShardConnecter
 	chunkStore       chunkstore.IChunkStore
 	entityMgr        *entity.EntityManager
@@ -35,17 +36,50 @@ type ChunkShard struct {
 	ticksSinceUpdate Ticks
 	ticksSinceSave   Ticks
 	saveChunks       bool
+	stopped          bool
+
+	ticksBetweenSaves Ticks
+	ticksBeforeUnload Ticks
+	maxHostileMobs    int
+	maxPassiveMobs    int
+
+	// tickets are set for chunks that hold a keep-loaded ticket, and so are
+	// never unloaded.
+	tickets [chunksPerShard]bool
+
+	// clients is the number of player connections to the shard, and pending is
+	// the number of requests that are on their way to it. Both are guarded by
+	// mgr.lock. The shard is only removed when both are zero.
+	clients int
+	pending int
+
+	blockLog *blocklog.BlockLog
+	// actor is the name of the player whose request is being performed, if
+	// any. Block changes are logged against them.
+	actor string
 
 	newActiveBlocks []BlockXyz
 	newActiveShards map[uint64]*destActiveShard
 
+	newLightShards map[uint64]*destLightShard
+
+	newSpreadShards map[uint64]*destSpreadShard
+
+	newPowerShards map[uint64]*destPowerShard
+	powerQueue     []BlockXyz
+	powerQueued    map[BlockXyz]bool
+	updatingPower  bool
+
 	shardClients map[uint64]gamerules.IShardShardClient
 	selfClient   shardSelfClient
 }
 
-func NewChunkShard(shardConnecter gamerules.IShardConnecter, chunkStore chunkstore.IChunkStore, entityMgr *entity.EntityManager, loc ShardXz) (shard *ChunkShard) {
+func NewChunkShard(mgr *LocalShardManager, chunkStore chunkstore.IChunkStore, entityMgr *entity.EntityManager, blockLog *blocklog.BlockLog, worldConfig *config.WorldConfig, loc ShardXz) (shard *ChunkShard) {
+	ticksBetweenSaves := worldConfig.SaveTicks()
+
 	shard = &ChunkShard{
-		shardConnecter:   shardConnecter,
+		mgr:              mgr,
+		shardConnecter:   mgr,
 		chunkStore:       chunkStore,
 		entityMgr:        entityMgr,
 		loc:              loc,
@@ -54,11 +88,24 @@ func NewChunkShard(shardConnecter gamerules.IShardConnecter, chunkStore chunksto
 		ticksSinceUpdate: 0,
 		saveChunks:       chunkStore.SupportsWrite(),
 
+		ticksBetweenSaves: ticksBetweenSaves,
+		ticksBeforeUnload: worldConfig.UnloadTicks(),
+		maxHostileMobs:    worldConfig.MaxHostileMobs,
+		maxPassiveMobs:    worldConfig.MaxPassiveMobs,
+		blockLog:          blockLog,
+
 		// Offset shard saves.
 		ticksSinceSave: (31 * Ticks(loc.Key())) % ticksBetweenSaves,
 
 		newActiveShards: make(map[uint64]*destActiveShard),
 
+		newLightShards: make(map[uint64]*destLightShard),
+
+		newSpreadShards: make(map[uint64]*destSpreadShard),
+
+		newPowerShards: make(map[uint64]*destPowerShard),
+		powerQueued:    make(map[BlockXyz]bool),
+
 		shardClients: make(map[uint64]gamerules.IShardShardClient),
 	}
 
@@ -67,19 +114,66 @@ func NewChunkShard(shardConnecter gamerules.IShardConnecter, chunkStore chunksto
 	return
 }
 
-// serve services shard requests in the foreground.
+// serve services shard requests in the foreground. It returns once the shard
+// has no chunks loaded, or has been stopped, and has been removed from its
+// LocalShardManager.
 func (shard *ChunkShard) serve() {
 	ticker := time.NewTicker(NanosecondsInSecond / TicksPerSecond)
+	defer ticker.Stop()
 
-	for {
+	for !shard.stopped {
 		select {
 		case <-ticker.C:
 			shard.tick()
+			if shard.ticksSinceUpdate == 0 && shard.isIdle() && shard.mgr.removeIdleShard(shard) {
+				return
+			}
 
 		case request := <-shard.requests:
 			request.perform(shard)
 		}
 	}
+
+	// Discard any requests that arrive after the shard was stopped, so that
+	// other shards and players don't block when sending them, until nothing
+	// can send more.
+	for {
+		select {
+		case <-ticker.C:
+			if shard.mgr.removeIdleShard(shard) {
+				return
+			}
+
+		case <-shard.requests:
+		}
+	}
+}
+
+// stop saves all the loaded chunks, and stops the shard.
+func (shard *ChunkShard) stop() {
+	shard.save()
+	shard.stopped = true
+}
+
+// save submits the loaded chunks that have changed to the chunk store.
+func (shard *ChunkShard) save() {
+	if shard.saveChunks && shard.chunkStore.SupportsWrite() {
+		for _, chunk := range shard.chunks {
+			if chunk != nil {
+				chunk.save(shard.chunkStore)
+			}
+		}
+	}
+}
+
+// loadedChunkCount returns the number of chunks loaded in the shard.
+func (shard *ChunkShard) loadedChunkCount() (count int) {
+	for _, chunk := range shard.chunks {
+		if chunk != nil {
+			count++
+		}
+	}
+	return
 }
 
 // tick runs the shard for a single tick.
@@ -96,31 +190,33 @@ func (shard *ChunkShard) tick() {
 		for _, chunk := range shard.chunks {
 			if chunk != nil {
 				chunk.sendUpdate()
+				chunk.damagePlayers()
 			}
 		}
+		shard.spawnMobs()
+		shard.unloadUnusedChunks(TicksPerSecond)
 		shard.ticksSinceUpdate = 0
 	}
 
 	if shard.saveChunks && shard.chunkStore.SupportsWrite() {
 		shard.ticksSinceSave++
-		if shard.ticksSinceSave > ticksBetweenSaves {
+		if shard.ticksSinceSave > shard.ticksBetweenSaves {
 			log.Printf("%s: Writing chunks.", shard)
 			// TODO Stagger the per-chunk saves over multiple ticks.
-			for _, chunk := range shard.chunks {
-				if chunk != nil {
-					chunk.save(shard.chunkStore)
-				}
-			}
+			shard.save()
 			shard.ticksSinceSave = 0
 		}
 	}
 
 	shard.transferActiveBlocks()
+	shard.transferLightUpdates()
+	shard.transferSpreads()
+	shard.transferPowerUpdates()
 }
 
 // clientForShard is used to get a IShardShardClient for a given shard, reusing
-// IShardShardClient connections for use within the shard. Returns nil if the
-// shard does not exist.
+// IShardShardClient connections for use within the shard. Requests made
+// through the client are discarded if the shard is not running at the time.
 func (shard *ChunkShard) clientForShard(shardLoc ShardXz) (client gamerules.IShardShardClient) {
 	var ok bool
 
@@ -169,10 +265,6 @@ func (shard *ChunkShard) blockQuery(chunkLoc ChunkXz, subLoc *SubChunkXyz) (bloc
 // transferActiveBlocks takes blocks marked as newly active by addActiveBlock,
 // and informs the chunk in the destination shards.
 func (shard *ChunkShard) transferActiveBlocks() {
-	if len(shard.newActiveBlocks) == 0 {
-		return
-	}
-
 	thisShardKey := shard.loc.Key()
 	for shardKey, activeShard := range shard.newActiveShards {
 		if shardKey == thisShardKey {
@@ -182,6 +274,7 @@ func (shard *ChunkShard) transferActiveBlocks() {
 				client.ReqSetActiveBlocks(activeShard.blocks)
 			}
 		}
+		delete(shard.newActiveShards, shardKey)
 	}
 }
 
@@ -209,7 +302,7 @@ func (shard *ChunkShard) addActiveBlock(block *BlockXyz) {
 	shardXz := chunkXz.ToShardXz()
 	shardKey := shardXz.Key()
 	activeShard, ok := shard.newActiveShards[shardKey]
-	if ok {
+	if !ok {
 		activeShard = &destActiveShard{
 			loc:    shardXz,
 			blocks: []BlockXyz{*block},
@@ -239,6 +332,59 @@ func (shard *ChunkShard) chunkIndexAndRelLoc(loc ChunkXz) (index int, x, z Chunk
 	return
 }
 
+// loadedChunk returns the Chunk at the given coordinates if it is within the
+// shard and already loaded, otherwise nil.
+func (shard *ChunkShard) loadedChunk(loc ChunkXz) *Chunk {
+	chunkIndex, _, _, ok := shard.chunkIndexAndRelLoc(loc)
+	if !ok {
+		return nil
+	}
+	return shard.chunks[chunkIndex]
+}
+
+// spreadBlock spreads a block type into a block that may be in any shard.
+// Spreads into other shards are sent at the end of the tick.
+func (shard *ChunkShard) spreadBlock(spread gamerules.BlockSpread) {
+	chunkLoc := spread.Target.ToChunkXz()
+	if _, _, _, ok := shard.chunkIndexAndRelLoc(*chunkLoc); ok {
+		shard.reqSpreadBlocks([]gamerules.BlockSpread{spread})
+		return
+	}
+
+	shardLoc := chunkLoc.ToShardXz()
+	shardKey := shardLoc.Key()
+	spreadShard, ok := shard.newSpreadShards[shardKey]
+	if !ok {
+		spreadShard = &destSpreadShard{
+			loc: shardLoc,
+		}
+		shard.newSpreadShards[shardKey] = spreadShard
+	}
+	spreadShard.spreads = append(spreadShard.spreads, spread)
+}
+
+// transferSpreads sends spreads queued by spreadBlock to their destination
+// shards.
+func (shard *ChunkShard) transferSpreads() {
+	for shardKey, spreadShard := range shard.newSpreadShards {
+		if client := shard.clientForShard(spreadShard.loc); client != nil {
+			client.ReqSpreadBlocks(spreadShard.spreads)
+		}
+		delete(shard.newSpreadShards, shardKey)
+	}
+}
+
+// reqSpreadBlocks spreads block types into blocks within the shard. Spreads
+// into chunks that are not loaded are discarded.
+func (shard *ChunkShard) reqSpreadBlocks(spreads []gamerules.BlockSpread) {
+	for i := range spreads {
+		spread := &spreads[i]
+		if chunk := shard.loadedChunk(*spread.Target.ToChunkXz()); chunk != nil {
+			chunk.reqSpreadBlock(spread)
+		}
+	}
+}
+
 // Get returns the Chunk at at given coordinates, loading it if it is not
 // already loaded.
 func (shard *ChunkShard) chunkAt(loc ChunkXz) *Chunk {
@@ -265,6 +411,8 @@ func (shard *ChunkShard) chunkAt(loc ChunkXz) *Chunk {
 
 	shard.chunks[chunkIndex] = chunk
 
+	event.Dispatch(&event.ChunkLoad{Loc: loc, Chunk: chunk})
+
 	return chunk
 }
 
@@ -313,6 +461,11 @@ type destActiveShard struct {
 	blocks []BlockXyz
 }
 
+type destSpreadShard struct {
+	loc     ShardXz
+	spreads []gamerules.BlockSpread
+}
+
 // shardSelfClient implements IShardShardClient for a shard to efficiently talk
 // to itself.
 type shardSelfClient struct {
@@ -332,3 +485,15 @@ func (client *shardSelfClient) ReqTransferEntity(loc ChunkXz, entity gamerules.I
 		chunk.transferEntity(entity)
 	}
 }
+
+func (client *shardSelfClient) ReqSpreadBlocks(spreads []gamerules.BlockSpread) {
+	client.shard.reqSpreadBlocks(spreads)
+}
+
+func (client *shardSelfClient) ReqUpdateLight(updates []gamerules.LightUpdate) {
+	client.shard.reqUpdateLight(updates)
+}
+
+func (client *shardSelfClient) ReqUpdatePower(updates []gamerules.PowerUpdate) {
+	client.shard.reqUpdatePower(updates)
+}
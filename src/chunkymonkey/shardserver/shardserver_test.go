@@ -0,0 +1,12 @@
+package shardserver
+
+import (
+	"chunkymonkey/gamerules"
+)
+
+func init() {
+	var err error
+	if gamerules.Blocks, err = gamerules.LoadBlocksFromFile("../../../blocks.json"); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,92 @@
+package shardserver
+
+import (
+	. "chunkymonkey/types"
+)
+
+// Chunks are saved and unloaded once they have gone unused for
+// ticksBeforeUnload ticks, unless they hold a keep-loaded ticket. A shard
+// without any loaded chunks or tickets is stopped, and removed from its
+// LocalShardManager, which creates it again when it is next needed.
+
+// iOnGround is implemented by entities that can rest on the ground.
+type iOnGround interface {
+	OnGround() bool
+}
+
+// inUse returns true if the chunk is needed by a player, or by blocks or
+// entities within it that are still changing.
+func (chunk *Chunk) inUse() bool {
+	if len(chunk.subscribers) > 0 || len(chunk.playersData) > 0 {
+		return true
+	}
+
+	if len(chunk.newActiveBlocks) > 0 || len(chunk.delayedActiveBlocks) > 0 {
+		return true
+	}
+
+	for _, e := range chunk.entities {
+		if obj, ok := e.(iOnGround); ok && !obj.OnGround() {
+			// Falling items and mobs, and projectiles in flight.
+			return true
+		}
+	}
+
+	return false
+}
+
+// unloadUnusedChunks saves and unloads chunks that have not been in use for
+// long enough. ticks is the number of ticks since it was last called.
+func (shard *ChunkShard) unloadUnusedChunks(ticks Ticks) {
+	for index, chunk := range shard.chunks {
+		if chunk == nil {
+			continue
+		}
+
+		if shard.tickets[index] || chunk.inUse() {
+			chunk.unusedTicks = 0
+			continue
+		}
+
+		chunk.unusedTicks += ticks
+		if chunk.unusedTicks >= shard.ticksBeforeUnload {
+			shard.unloadChunk(index)
+		}
+	}
+}
+
+// unloadChunk saves the chunk at index, and removes it from the shard.
+func (shard *ChunkShard) unloadChunk(index int) {
+	chunk := shard.chunks[index]
+
+	if shard.saveChunks && shard.chunkStore.SupportsWrite() {
+		chunk.save(shard.chunkStore)
+	}
+
+	for entityId := range chunk.entities {
+		shard.entityMgr.RemoveEntityById(entityId)
+	}
+
+	shard.chunks[index] = nil
+}
+
+// isIdle returns true if the shard has no chunks loaded and no tickets.
+func (shard *ChunkShard) isIdle() bool {
+	for index, chunk := range shard.chunks {
+		if chunk != nil || shard.tickets[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// addTickets gives keep-loaded tickets to the chunks, and loads them. Chunks
+// outside of the shard are ignored.
+func (shard *ChunkShard) addTickets(locs []ChunkXz) {
+	for _, loc := range locs {
+		if index, _, _, ok := shard.chunkIndexAndRelLoc(loc); ok {
+			shard.tickets[index] = true
+			shard.chunkAt(loc)
+		}
+	}
+}
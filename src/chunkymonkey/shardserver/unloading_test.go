@@ -0,0 +1,82 @@
+package shardserver
+
+import (
+	"testing"
+
+	"chunkymonkey/gamerules"
+	. "chunkymonkey/types"
+)
+
+type testEntity struct {
+	gamerules.INonPlayerEntity
+	onGround bool
+}
+
+func (e *testEntity) OnGround() bool {
+	return e.onGround
+}
+
+func TestChunkInUse(t *testing.T) {
+	tests := []struct {
+		desc string
+		use  func(chunk *Chunk)
+		want bool
+	}{
+		{"unused", func(chunk *Chunk) {}, false},
+		{"subscriber", func(chunk *Chunk) { chunk.subscribers[1] = nil }, true},
+		{"player", func(chunk *Chunk) { chunk.playersData[1] = nil }, true},
+		{"active block", func(chunk *Chunk) { chunk.newActiveBlocks[0] = true }, true},
+		{"delayed active block", func(chunk *Chunk) { chunk.delayedActiveBlocks[0] = 5 }, true},
+		{"falling entity", func(chunk *Chunk) { chunk.entities[1] = &testEntity{onGround: false} }, true},
+		{"resting entity", func(chunk *Chunk) { chunk.entities[1] = &testEntity{onGround: true} }, false},
+	}
+
+	mgr := newTestShardManager()
+	for _, test := range tests {
+		shard := newTestShard(mgr, ShardXz{0, 0})
+		chunk := shard.chunkAt(ChunkXz{0, 0})
+		test.use(chunk)
+		if got := chunk.inUse(); got != test.want {
+			t.Errorf("%s: inUse() = %t, want %t", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestUnloadUnusedChunks(t *testing.T) {
+	mgr := newTestShardManager()
+	shard := newTestShard(mgr, ShardXz{0, 0})
+	ticketLoc, usedLoc, unusedLoc := ChunkXz{0, 0}, ChunkXz{1, 0}, ChunkXz{2, 0}
+	shard.addTickets([]ChunkXz{ticketLoc})
+	shard.chunkAt(usedLoc).subscribers[1] = nil
+	shard.chunkAt(unusedLoc)
+
+	checkLoaded := func(when string, want map[ChunkXz]bool) {
+		for loc, wantLoaded := range want {
+			if loaded := shard.loadedChunk(loc) != nil; loaded != wantLoaded {
+				t.Errorf("%s: chunk %v loaded=%t, want %t", when, loc, loaded, wantLoaded)
+			}
+		}
+	}
+
+	shard.unloadUnusedChunks(shard.ticksBeforeUnload - 1)
+	checkLoaded("before the delay", map[ChunkXz]bool{ticketLoc: true, usedLoc: true, unusedLoc: true})
+
+	shard.unloadUnusedChunks(1)
+	checkLoaded("after the delay", map[ChunkXz]bool{ticketLoc: true, usedLoc: true, unusedLoc: false})
+
+	// The delay starts once the chunk is no longer in use.
+	delete(shard.chunkAt(usedLoc).subscribers, 1)
+	shard.unloadUnusedChunks(shard.ticksBeforeUnload - 1)
+	checkLoaded("once unused", map[ChunkXz]bool{usedLoc: true})
+	shard.unloadUnusedChunks(1)
+	checkLoaded("after the delay once unused", map[ChunkXz]bool{ticketLoc: true, usedLoc: false})
+
+	if shard.isIdle() {
+		t.Errorf("shard with a ticket is idle")
+	}
+	shard.tickets = [chunksPerShard]bool{}
+	shard.unloadUnusedChunks(shard.ticksBeforeUnload)
+	if !shard.isIdle() {
+		t.Errorf("shard without chunks or tickets is not idle")
+	}
+}
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
 )
 
@@ -14,6 +16,45 @@ const (
 	NanosecondsInSecond = 1e9
 )
 
+// Biome is the kind of land in a region of the world. It decides the shape of
+// the terrain, what grows on it and the animals that live there.
+type Biome byte
+
+const (
+	BiomeTundra = Biome(iota)
+	BiomeTaiga
+	BiomePlains
+	BiomeForest
+	BiomeSwampland
+	BiomeDesert
+	BiomeSavanna
+	BiomeRainforest
+
+	// BiomeUnknown is the biome of land whose generator has no biomes.
+	BiomeUnknown = Biome(0xff)
+)
+
+var biomeNames = [...]string{
+	BiomeTundra:     "Tundra",
+	BiomeTaiga:      "Taiga",
+	BiomePlains:     "Plains",
+	BiomeForest:     "Forest",
+	BiomeSwampland:  "Swampland",
+	BiomeDesert:     "Desert",
+	BiomeSavanna:    "Savanna",
+	BiomeRainforest: "Rainforest",
+}
+
+func (biome Biome) String() string {
+	if int(biome) < len(biomeNames) {
+		return biomeNames[biome]
+	}
+	return "Unknown"
+}
+
+// MaxAir is the number of ticks that a player can hold their breath for.
+const MaxAir = Ticks(15 * TicksPerSecond)
+
 // 1 "TickTime" is the duration of a server "tick". This value is intended for
 // use in sub-tick physics calculations.
 type TickTime float64
@@ -45,6 +86,14 @@ const (
 	GameTypeCreative = GameType(1)
 )
 
+// Reasons for a change of state sent to clients with PacketIdState.
+const (
+	StateReasonBedInvalid     = 0
+	StateReasonBeginRaining   = 1
+	StateReasonEndRaining     = 2
+	StateReasonChangeGameMode = 3
+)
+
 // Player/mob health.
 type Health int16
 
@@ -126,6 +175,75 @@ var MobNameByType = map[EntityMobType]string{}
 
 type EntityStatus byte
 
+const (
+	EntityStatusHurt = EntityStatus(2)
+	EntityStatusDead = EntityStatus(3)
+)
+
+// DamageCause is the reason that a player or mob was damaged.
+type DamageCause byte
+
+const (
+	DamageCauseUnknown   = DamageCause(0)
+	DamageCauseFall      = DamageCause(1)
+	DamageCauseDrowning  = DamageCause(2)
+	DamageCauseLava      = DamageCause(3)
+	DamageCauseFire      = DamageCause(4)
+	DamageCauseContact   = DamageCause(5)
+	DamageCauseMob       = DamageCause(6)
+	DamageCausePlayer    = DamageCause(7)
+	DamageCauseVoid      = DamageCause(8)
+	DamageCauseSuicide   = DamageCause(9)
+	DamageCauseExplosion = DamageCause(10)
+)
+
+var DamageCauseByName = map[string]DamageCause{
+	"Unknown":   DamageCauseUnknown,
+	"Fall":      DamageCauseFall,
+	"Drowning":  DamageCauseDrowning,
+	"Lava":      DamageCauseLava,
+	"Fire":      DamageCauseFire,
+	"Contact":   DamageCauseContact,
+	"Mob":       DamageCauseMob,
+	"Player":    DamageCausePlayer,
+	"Void":      DamageCauseVoid,
+	"Suicide":   DamageCauseSuicide,
+	"Explosion": DamageCauseExplosion,
+}
+
+// DamageCauseNameByCause reverses DamageCauseByName (initialized in init()).
+var DamageCauseNameByCause = map[DamageCause]string{}
+
+// MarshalJSON writes the cause by its name in DamageCauseByName.
+func (cause DamageCause) MarshalJSON() ([]byte, error) {
+	name, ok := DamageCauseNameByCause[cause]
+	if !ok {
+		return nil, fmt.Errorf("damage cause %d has no name", cause)
+	}
+	return json.Marshal(name)
+}
+
+// UnmarshalJSON reads the cause by its name in DamageCauseByName, or as a
+// number.
+func (cause *DamageCause) UnmarshalJSON(raw []byte) error {
+	var name string
+	if err := json.Unmarshal(raw, &name); err != nil {
+		var number byte
+		if err := json.Unmarshal(raw, &number); err != nil {
+			return fmt.Errorf("damage cause must be a name or a number, not %s", raw)
+		}
+		*cause = DamageCause(number)
+		return nil
+	}
+
+	value, ok := DamageCauseByName[name]
+	if !ok {
+		return fmt.Errorf("unknown damage cause %q", name)
+	}
+	*cause = value
+	return nil
+}
+
 type EntityAnimation byte
 
 const (
@@ -233,6 +351,7 @@ const (
 	SoundEffectRecordPlay = SoundEffect(1005)
 	SoundEffectSmoke      = SoundEffect(2000)
 	SoundEffectBlockBreak = SoundEffect(2001)
+	SoundEffectMobSpawn   = SoundEffect(2004)
 )
 
 // Block-related types
@@ -280,6 +399,25 @@ func (f Face) Dxyz() (dx BlockCoord, dy BlockYCoord, dz BlockCoord) {
 	return
 }
 
+// Opposite returns the face on the opposite side of a block.
+func (f Face) Opposite() Face {
+	switch f {
+	case FaceBottom:
+		return FaceTop
+	case FaceTop:
+		return FaceBottom
+	case FaceEast:
+		return FaceWest
+	case FaceWest:
+		return FaceEast
+	case FaceNorth:
+		return FaceSouth
+	case FaceSouth:
+		return FaceNorth
+	}
+	return FaceNull
+}
+
 // Action-related types and constants
 
 type DigStatus byte
@@ -418,6 +556,10 @@ func (d *AngleDegrees) ToAngleBytes() AngleBytes {
 	return AngleBytes(norm * DegreesToBytes)
 }
 
+func (b AngleBytes) ToAngleDegrees() AngleDegrees {
+	return AngleDegrees(float64(b) / DegreesToBytes)
+}
+
 type LookDegrees struct {
 	// Pitch is -ve when looking above the horizontal, and +ve below
 	Yaw, Pitch AngleDegrees
@@ -434,6 +576,15 @@ type LookBytes struct {
 	Yaw, Pitch AngleBytes
 }
 
+func (l *LookBytes) ToLookDegrees() *LookDegrees {
+	pitch := l.Pitch.ToAngleDegrees()
+	if pitch > 180 {
+		// Looking above the horizontal.
+		pitch -= 360
+	}
+	return &LookDegrees{l.Yaw.ToAngleDegrees(), pitch}
+}
+
 type OrientationDegrees struct {
 	Yaw, Pitch, Roll AngleDegrees
 }
@@ -458,6 +609,9 @@ const (
 	// The radius in which all chunks must be sent before completing a client's
 	// login process.
 	MinChunkRadius = 2
+	// The largest area within which a client may be configured to receive
+	// updates.
+	MaxChunkRadius = 15
 
 	// Sometimes it is useful to convert block coordinates to pixels
 	PixelShift     = 5
@@ -522,6 +676,14 @@ func (p *AbsXyz) IsWithinDistanceOf(other *AbsXyz, maxDistance AbsCoord) bool {
 	return (dx*dx + dy*dy + dz*dz) <= maxDistance*maxDistance
 }
 
+// Distance returns the straight line distance between two points.
+func (p *AbsXyz) Distance(other *AbsXyz) AbsCoord {
+	dx := p.X - other.X
+	dy := p.Y - other.Y
+	dz := p.Z - other.Z
+	return AbsCoord(math.Sqrt(float64(dx*dx + dy*dy + dz*dz)))
+}
+
 // Specifies approximate world distance in pixels (absolute / PixelsPerBlock)
 type AbsIntCoord int32
 
@@ -849,4 +1011,7 @@ func init() {
 	for name, type_ := range MobTypeByName {
 		MobNameByType[type_] = name
 	}
+	for name, cause := range DamageCauseByName {
+		DamageCauseNameByCause[cause] = name
+	}
 }
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"encoding/json"
+	"math"
 	"testing"
 )
 
@@ -50,6 +52,29 @@ func TestLookDegrees_ToLookBytes(t *testing.T) {
 	}
 }
 
+func TestLookBytes_ToLookDegrees(t *testing.T) {
+	type Test struct {
+		input    LookBytes
+		expected LookDegrees
+	}
+
+	var tests = []Test{
+		{LookBytes{0, 0}, LookDegrees{0, 0}},
+		{LookBytes{0, 64}, LookDegrees{0, 90}},
+		{LookBytes{0, 192}, LookDegrees{0, -90}},
+		{LookBytes{64, 0}, LookDegrees{90, 0}},
+		{LookBytes{192, 0}, LookDegrees{270, 0}},
+	}
+
+	for _, r := range tests {
+		result := r.input.ToLookDegrees()
+		if r.expected.Yaw != result.Yaw || r.expected.Pitch != result.Pitch {
+			t.Errorf("LookBytes%v expected LookDegrees%v got LookDegrees%v",
+				r.input, r.expected, result)
+		}
+	}
+}
+
 func TestAbsXyz_ToChunkXz(t *testing.T) {
 	type Test struct {
 		input    AbsXyz
@@ -157,6 +182,24 @@ func Test_AbsXyz_IsWithinDistanceOf(t *testing.T) {
 	}
 }
 
+func Test_AbsXyz_Distance(t *testing.T) {
+	tests := []struct {
+		a, b     AbsXyz
+		expected AbsCoord
+	}{
+		{AbsXyz{0, 0, 0}, AbsXyz{0, 0, 0}, 0},
+		{AbsXyz{0, 0, 0}, AbsXyz{0, 0, -2}, 2},
+		{AbsXyz{1, 2, 3}, AbsXyz{4, 6, 3}, 5},
+		{AbsXyz{-1, 64, 1}, AbsXyz{1, 65, -1}, 3},
+	}
+
+	for _, test := range tests {
+		if result := test.a.Distance(&test.b); math.Abs(float64(result-test.expected)) > 1e-9 {
+			t.Errorf("%v.Distance(%v)=>%f expected %f", test.a, test.b, result, test.expected)
+		}
+	}
+}
+
 func TestAbsIntXyz_ToChunkXz(t *testing.T) {
 	type Test struct {
 		input    AbsIntXyz
@@ -518,3 +561,61 @@ func TestBlockXyz_ToAbsIntXyz(t *testing.T) {
 		}
 	}
 }
+
+func TestFace_Opposite(t *testing.T) {
+	type Test struct {
+		input    Face
+		expected Face
+	}
+
+	var tests = []Test{
+		{FaceBottom, FaceTop},
+		{FaceTop, FaceBottom},
+		{FaceEast, FaceWest},
+		{FaceWest, FaceEast},
+		{FaceNorth, FaceSouth},
+		{FaceSouth, FaceNorth},
+		{FaceNull, FaceNull},
+	}
+
+	for _, r := range tests {
+		result := r.input.Opposite()
+		if r.expected != result {
+			t.Errorf("Face(%d).Opposite() expected %d got %d", r.input, r.expected, result)
+		}
+	}
+}
+
+func TestDamageCause_JSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    DamageCause
+		wantErr bool
+	}{
+		{`"Lava"`, DamageCauseLava, false},
+		{`"Drowning"`, DamageCauseDrowning, false},
+		{`5`, DamageCauseContact, false},
+		{`"Quicksand"`, 0, true},
+		{`true`, 0, true},
+	}
+
+	for _, test := range tests {
+		var cause DamageCause
+		err := json.Unmarshal([]byte(test.json), &cause)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: got cause %d, want an error", test.json, cause)
+			}
+			continue
+		}
+		if err != nil || cause != test.want {
+			t.Errorf("%s: got cause %d and error %v, want %d", test.json, cause, err, test.want)
+			continue
+		}
+
+		raw, err := json.Marshal(cause)
+		if err != nil || (test.json[0] == '"' && string(raw) != test.json) {
+			t.Errorf("%d: marshalled as %s with error %v, want %s", cause, raw, err, test.json)
+		}
+	}
+}
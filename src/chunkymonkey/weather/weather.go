@@ -0,0 +1,117 @@
+// The weather package runs the cycle of rain and thunder in the world, in the
+// same way as the Notchian server, so that the times stored in level.dat mean
+// the same to both.
+package weather
+
+import (
+	"math"
+	"math/rand"
+
+	. "chunkymonkey/types"
+)
+
+// The random durations of each spell of weather are from the minimum, up to
+// the range more.
+const (
+	minClear     = 12000
+	rangeClear   = 168000
+	minRain      = 12000
+	rangeRain    = 12000
+	minThunder   = 3600
+	rangeThunder = 12000
+)
+
+// Kind is the weather as seen by players.
+type Kind int
+
+const (
+	Clear = Kind(iota)
+	Rain
+	Thunder
+)
+
+var kindNames = []string{"clear", "rain", "thunder"}
+
+func (kind Kind) String() string {
+	return kindNames[kind]
+}
+
+// ParseKind returns the Kind named by the result of Kind.String.
+func ParseKind(name string) (kind Kind, ok bool) {
+	for i, kindName := range kindNames {
+		if name == kindName {
+			return Kind(i), true
+		}
+	}
+	return Clear, false
+}
+
+// State is the state of the weather, as stored in level.dat. Whether it is
+// raining and whether it is thundering change separately, and it is only
+// seen to thunder while it is also raining.
+type State struct {
+	Raining bool
+	// RainTime is the number of ticks until Raining changes. When it is 0, a
+	// random duration is chosen on the next tick.
+	RainTime    Ticks
+	Thundering  bool
+	ThunderTime Ticks
+}
+
+// Kind returns the weather as seen by players.
+func (state *State) Kind() Kind {
+	switch {
+	case !state.Raining:
+		return Clear
+	case state.Thundering:
+		return Thunder
+	}
+	return Rain
+}
+
+// Tick advances the weather by one tick. It returns true if it started or
+// stopped raining, which players must be told about.
+func (state *State) Tick(rand *rand.Rand) (rainChanged bool) {
+	if state.ThunderTime <= 0 {
+		if state.Thundering {
+			state.ThunderTime = Ticks(minThunder + rand.Intn(rangeThunder))
+		} else {
+			state.ThunderTime = Ticks(minClear + rand.Intn(rangeClear))
+		}
+	} else if state.ThunderTime--; state.ThunderTime <= 0 {
+		state.Thundering = !state.Thundering
+	}
+
+	if state.RainTime <= 0 {
+		if state.Raining {
+			state.RainTime = Ticks(minRain + rand.Intn(rangeRain))
+		} else {
+			state.RainTime = Ticks(minClear + rand.Intn(rangeClear))
+		}
+	} else if state.RainTime--; state.RainTime <= 0 {
+		state.Raining = !state.Raining
+		return true
+	}
+	return false
+}
+
+// Set changes the weather to kind for the duration in ticks, or for a random
+// duration if it is 0. The duration is limited to what level.dat can store.
+// It returns true if it started or stopped raining.
+func (state *State) Set(kind Kind, duration Ticks) (rainChanged bool) {
+	if duration > math.MaxInt32 {
+		duration = math.MaxInt32
+	}
+
+	wasRaining := state.Raining
+	state.Raining = kind != Clear
+	state.Thundering = kind == Thunder
+	state.RainTime = duration
+	// Thunder ends with the rain. Otherwise the time until it next thunders
+	// is chosen at random.
+	state.ThunderTime = 0
+	if kind == Thunder {
+		state.ThunderTime = duration
+	}
+	return state.Raining != wasRaining
+}
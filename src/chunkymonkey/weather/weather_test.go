@@ -0,0 +1,79 @@
+package weather
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "chunkymonkey/types"
+)
+
+func TestTick(t *testing.T) {
+	rand := rand.New(rand.NewSource(1))
+
+	// Without a time, a random duration is chosen for the current weather.
+	state := State{}
+	if state.Tick(rand) {
+		t.Errorf("Tick() = true on choosing durations, want false")
+	}
+	if state.RainTime < minClear || state.RainTime >= minClear+rangeClear {
+		t.Errorf("RainTime = %d, want a clear duration", state.RainTime)
+	}
+	if state.ThunderTime < minClear || state.ThunderTime >= minClear+rangeClear {
+		t.Errorf("ThunderTime = %d, want a clear duration", state.ThunderTime)
+	}
+
+	tests := []struct {
+		before      State
+		rainChanged bool
+		after       State
+	}{
+		{State{false, 10, false, 10}, false, State{false, 9, false, 9}},
+		{State{false, 1, false, 5}, true, State{true, 0, false, 4}},
+		{State{true, 1, false, 1}, true, State{false, 0, true, 0}},
+		{State{true, 5, true, 1}, false, State{true, 4, false, 0}},
+	}
+	for _, test := range tests {
+		state := test.before
+		if rainChanged := state.Tick(rand); rainChanged != test.rainChanged || state != test.after {
+			t.Errorf("%+v.Tick() = %t, %+v, want %t, %+v", test.before, rainChanged, state, test.rainChanged, test.after)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		before      State
+		kind        Kind
+		duration    Ticks
+		rainChanged bool
+		after       State
+	}{
+		{State{false, 100, false, 100}, Rain, 0, true, State{true, 0, false, 0}},
+		{State{false, 100, true, 100}, Rain, 50, true, State{true, 50, false, 0}},
+		{State{true, 100, false, 100}, Thunder, 50, false, State{true, 50, true, 50}},
+		{State{true, 100, true, 100}, Clear, 0, true, State{false, 0, false, 0}},
+		{State{false, 100, false, 100}, Clear, 50, false, State{false, 50, false, 0}},
+		{State{false, 100, false, 100}, Thunder, 1 << 40, true, State{true, math.MaxInt32, true, math.MaxInt32}},
+	}
+	for _, test := range tests {
+		state := test.before
+		if rainChanged := state.Set(test.kind, test.duration); rainChanged != test.rainChanged || state != test.after {
+			t.Errorf("%+v.Set(%v, %d) = %t, %+v, want %t, %+v", test.before, test.kind, test.duration, rainChanged, state, test.rainChanged, test.after)
+		}
+		if kind := state.Kind(); kind != test.kind {
+			t.Errorf("Kind() = %v after Set(%v), want %v", kind, test.kind, test.kind)
+		}
+	}
+}
+
+func TestParseKind(t *testing.T) {
+	for _, kind := range []Kind{Clear, Rain, Thunder} {
+		if parsed, ok := ParseKind(kind.String()); !ok || parsed != kind {
+			t.Errorf("ParseKind(%q) = %v, %t, want %v", kind.String(), parsed, ok, kind)
+		}
+	}
+	if _, ok := ParseKind("snow"); ok {
+		t.Errorf("ParseKind(\"snow\") = ok, want not ok")
+	}
+}
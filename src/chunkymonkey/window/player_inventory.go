@@ -134,6 +134,16 @@ func (w *PlayerInventory) CanTakeItem(item *gamerules.Slot) bool {
 	return w.holding.CanTakeItem(item) || w.main.CanTakeItem(item)
 }
 
+// TakeAllItems empties the player's inventory, including armor and crafting
+// slots, and returns the items that were in it.
+func (w *PlayerInventory) TakeAllItems() (items []gamerules.Slot) {
+	items = append(items, w.crafting.TakeAllItems()...)
+	items = append(items, w.armor.TakeAllItems()...)
+	items = append(items, w.main.TakeAllItems()...)
+	items = append(items, w.holding.TakeAllItems()...)
+	return
+}
+
 func (w *PlayerInventory) UnmarshalNbt(tag nbt.ITag) (err error) {
 	if tag == nil {
 		return
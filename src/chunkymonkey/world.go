@@ -0,0 +1,124 @@
+package chunkymonkey
+
+import (
+	"log"
+
+	"chunkymonkey/blocklog"
+	"chunkymonkey/config"
+	. "chunkymonkey/entity"
+	"chunkymonkey/gamerules"
+	"chunkymonkey/player"
+	"chunkymonkey/shardserver"
+	. "chunkymonkey/types"
+	"chunkymonkey/worldstore"
+	"nbt"
+)
+
+// world is one of the worlds hosted by the game, with the shards of each of
+// its dimensions.
+type world struct {
+	player.World
+	store         *worldstore.WorldStore
+	shardManager  *shardserver.LocalShardManager
+	netherManager *shardserver.LocalShardManager
+	blockLogs     map[DimensionId]*dimensionLog
+}
+
+// loadWorld loads the world stored at worldPath, and creates the shard
+// managers that host it. Changes to blocks in each dimension are logged in
+// the world's directory.
+func loadWorld(name, worldPath string, worldConfig *config.WorldConfig, entityManager *EntityManager) (w *world, err error) {
+	store, err := worldstore.LoadWorldStore(worldPath)
+	if err != nil {
+		return nil, err
+	}
+
+	blockLogs := make(map[DimensionId]*blocklog.BlockLog)
+	for _, dimension := range []DimensionId{DimensionNormal, DimensionNether} {
+		blockLog, err := blocklog.Open(blocklog.Dir(worldPath, dimension))
+		if err != nil {
+			for _, opened := range blockLogs {
+				opened.Close()
+			}
+			return nil, err
+		}
+		blockLogs[dimension] = blockLog
+	}
+
+	w = &world{
+		store:         store,
+		shardManager:  shardserver.NewLocalShardManager(store.ChunkStore, entityManager, blockLogs[DimensionNormal], worldConfig),
+		netherManager: shardserver.NewLocalShardManager(store.NetherChunkStore, entityManager, blockLogs[DimensionNether], worldConfig),
+	}
+	w.shardManager.SetBiomes(store.Biomes)
+	w.blockLogs = map[DimensionId]*dimensionLog{
+		DimensionNormal: &dimensionLog{blockLogs[DimensionNormal], w.shardManager},
+		DimensionNether: &dimensionLog{blockLogs[DimensionNether], w.netherManager},
+	}
+	w.World = player.World{
+		Name: name,
+		ShardConnecters: map[DimensionId]gamerules.IShardConnecter{
+			DimensionNormal: w.shardManager,
+			DimensionNether: w.netherManager,
+		},
+		SpawnBlock: store.SpawnPosition,
+	}
+	return w, nil
+}
+
+// blockLog returns the log of the changes to blocks in the dimension, or nil
+// if the world has no such dimension.
+func (w *world) blockLog(dimension DimensionId) gamerules.IBlockLog {
+	if dimLog, ok := w.blockLogs[dimension]; ok {
+		return dimLog
+	}
+	return nil
+}
+
+// stop stops the world's shards, once they have saved their chunks.
+func (w *world) stop() {
+	w.shardManager.Stop()
+	w.netherManager.Stop()
+	w.store.ChunkStore.Flush()
+	w.store.NetherChunkStore.Flush()
+
+	for _, dimLog := range w.blockLogs {
+		if err := dimLog.blockLog.Close(); err != nil {
+			log.Printf("Failed when closing block log of world %q: %v", w.Name, err)
+		}
+	}
+}
+
+// save saves the chunks of the world's shards, without stopping them.
+func (w *world) save() {
+	w.shardManager.Save()
+	w.netherManager.Save()
+	w.store.ChunkStore.Flush()
+	w.store.NetherChunkStore.Flush()
+}
+
+func (w *world) writeLevelData() {
+	if err := w.store.WriteLevelData(); err != nil {
+		log.Printf("Failed when writing level data of world %q: %v", w.Name, err)
+	}
+}
+
+func (w *world) writePlayerData(name string, playerData *nbt.Compound) {
+	if err := w.store.WritePlayerData(name, playerData); err != nil {
+		log.Printf("Failed when writing player data: %v", err)
+	}
+}
+
+// dimensionLog implements gamerules.IBlockLog for a dimension of a world.
+type dimensionLog struct {
+	blockLog     *blocklog.BlockLog
+	shardManager *shardserver.LocalShardManager
+}
+
+func (dimLog *dimensionLog) BlockChanges(query *blocklog.Query) ([]blocklog.Change, error) {
+	return dimLog.blockLog.Search(query)
+}
+
+func (dimLog *dimensionLog) RevertBlockChanges(player string, changes []blocklog.Change) {
+	dimLog.shardManager.RevertBlockChanges(player, changes)
+}
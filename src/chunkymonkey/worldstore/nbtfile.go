@@ -0,0 +1,111 @@
+package worldstore
+
+import (
+	"compress/gzip"
+	"log"
+	"os"
+	"path"
+
+	"nbt"
+)
+
+// Files such as level.dat and the player files are gzipped NBT. They are
+// written to a temporary file which then replaces the original, so that a
+// crash part way through a write doesn't leave a corrupt file behind. The
+// previous version of each file is kept with a .bak suffix, and is read
+// instead if the file itself is missing or can't be read.
+
+const (
+	tmpSuffix    = ".tmp"
+	backupSuffix = ".bak"
+)
+
+// readNbtFile reads the gzipped NBT file, falling back to its backup if it
+// can't be read. The error from reading the file itself is returned if neither
+// can be read.
+func readNbtFile(filename string) (tag *nbt.Compound, err error) {
+	if tag, err = readNbtFileOnly(filename); err == nil {
+		return
+	}
+
+	backupTag, backupErr := readNbtFileOnly(filename + backupSuffix)
+	if backupErr != nil {
+		return nil, err
+	}
+
+	if !os.IsNotExist(err) {
+		log.Printf("Error reading %s, using its backup instead: %v", filename, err)
+	}
+	return backupTag, nil
+}
+
+func readNbtFileOnly(filename string) (tag *nbt.Compound, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return
+	}
+	defer gzipReader.Close()
+
+	return nbt.Read(gzipReader)
+}
+
+// writeNbtFile writes the tag as a gzipped NBT file, replacing any existing
+// file, which becomes the backup. An existing file that can't be read is
+// discarded instead, so that it doesn't replace a good backup.
+func writeNbtFile(filename string, tag *nbt.Compound) (err error) {
+	tmpFilename := filename + tmpSuffix
+	if err = writeNbtFileOnly(tmpFilename, tag); err != nil {
+		os.Remove(tmpFilename)
+		return
+	}
+
+	if _, readErr := readNbtFileOnly(filename); readErr == nil {
+		if err = os.Rename(filename, filename+backupSuffix); err != nil {
+			return
+		}
+	} else if !os.IsNotExist(readErr) {
+		log.Printf("Replacing unreadable %s, keeping its backup: %v", filename, readErr)
+	}
+
+	if err = os.Rename(tmpFilename, filename); err != nil {
+		return
+	}
+
+	syncDir(path.Dir(filename))
+	return nil
+}
+
+// writeNbtFileOnly writes the tag to the file, and waits until it is stored
+// on disk.
+func writeNbtFileOnly(filename string, tag *nbt.Compound) (err error) {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	if err = nbt.Write(gzipWriter, tag); err != nil {
+		return
+	}
+	if err = gzipWriter.Close(); err != nil {
+		return
+	}
+
+	return file.Sync()
+}
+
+// syncDir stores changes to the directory's entries on disk. Not all systems
+// support this, so errors are ignored.
+func syncDir(dirPath string) {
+	if dir, err := os.Open(dirPath); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+}
@@ -0,0 +1,123 @@
+package worldstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"nbt"
+)
+
+func testCompound(value string) *nbt.Compound {
+	tag := nbt.NewCompound()
+	tag.Set("Value", &nbt.String{value})
+	return tag
+}
+
+func compoundValue(tag *nbt.Compound) string {
+	if value, ok := tag.Lookup("Value").(*nbt.String); ok {
+		return value.Value
+	}
+	return ""
+}
+
+func TestNbtFile(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "nbtfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+
+	filename := path.Join(dirPath, "test.dat")
+
+	if _, err = readNbtFile(filename); !os.IsNotExist(err) {
+		t.Errorf("reading missing file: got err=%v, want not exist", err)
+	}
+
+	// The second write is shorter than the first, and must not leave any of
+	// the first behind.
+	for _, value := range []string{"a much longer first value", "second"} {
+		if err = writeNbtFile(filename, testCompound(value)); err != nil {
+			t.Fatal(err)
+		}
+		tag, err := readNbtFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := compoundValue(tag); got != value {
+			t.Errorf("read %q, want %q", got, value)
+		}
+	}
+
+	if _, err = os.Stat(filename + tmpSuffix); !os.IsNotExist(err) {
+		t.Errorf("temporary file was left behind")
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func() error
+		want    string
+	}{
+		{
+			"truncated",
+			func() error { return ioutil.WriteFile(filename, []byte{0x1f, 0x8b}, 0666) },
+			"a much longer first value",
+		},
+		{
+			"missing",
+			func() error { return os.Remove(filename) },
+			"a much longer first value",
+		},
+	}
+
+	for _, test := range tests {
+		if err = test.corrupt(); err != nil {
+			t.Fatal(err)
+		}
+		tag, err := readNbtFile(filename)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got := compoundValue(tag); got != test.want {
+			t.Errorf("%s: read %q, want %q from backup", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNbtFileKeepsBackup(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "nbtfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+
+	filename := path.Join(dirPath, "test.dat")
+	for _, value := range []string{"first", "second"} {
+		if err = writeNbtFile(filename, testCompound(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The file is corrupt, so writing it again keeps the good backup.
+	if err = ioutil.WriteFile(filename, []byte{0x1f, 0x8b}, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeNbtFile(filename, testCompound("third")); err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := readNbtFileOnly(filename + backupSuffix)
+	if err != nil {
+		t.Fatalf("reading backup: %v", err)
+	}
+	if got := compoundValue(tag); got != "first" {
+		t.Errorf("read %q from backup, want %q", got, "first")
+	}
+	if tag, err = readNbtFile(filename); err != nil {
+		t.Fatal(err)
+	} else if got := compoundValue(tag); got != "third" {
+		t.Errorf("read %q, want %q", got, "third")
+	}
+}
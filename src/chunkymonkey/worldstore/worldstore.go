@@ -1,9 +1,7 @@
-// Responsible for reading the overall world persistent state.
-// Eventually this should also be responsible for writing it as well.
+// Responsible for reading and writing the overall world persistent state.
 package worldstore
 
 import (
-	"compress/gzip"
 	"errors"
 	"fmt"
 	"log"
@@ -13,6 +11,7 @@ import (
 	"time"
 
 	"chunkymonkey/chunkstore"
+	"chunkymonkey/gamerules"
 	"chunkymonkey/generation"
 	. "chunkymonkey/types"
 	"nbt"
@@ -24,8 +23,27 @@ type WorldStore struct {
 	Seed int64
 	Time Ticks
 
-	LevelData     nbt.ITag
-	ChunkStore    chunkstore.IChunkStore
+	// Weather, as stored in level.dat.
+	Raining     bool
+	RainTime    Ticks
+	Thundering  bool
+	ThunderTime Ticks
+
+	LevelData nbt.ITag
+
+	// Generator names the generator of chunks that haven't been stored, as
+	// given by generatorName in level.dat.
+	Generator string
+
+	// The chunk stores of each dimension. Chunks that haven't been stored are
+	// generated.
+	ChunkStore       chunkstore.IChunkStore
+	NetherChunkStore chunkstore.IChunkStore
+
+	// Biomes gives the biomes of DimensionNormal, or is nil if its generator
+	// has no biomes.
+	Biomes gamerules.IBiomeSource
+
 	SpawnPosition BlockXyz
 }
 
@@ -56,14 +74,10 @@ func LoadWorldStore(worldPath string) (world *WorldStore, err error) {
 		timeTicks = Ticks(timeTag.Value)
 	}
 
-	var chunkStores []chunkstore.IChunkStore
-	persistantChunkStore, err := chunkstore.ChunkStoreForLevel(worldPath, levelData, DimensionNormal)
-	if err != nil {
-		return nil, err
-	}
-
-	persistantChunkService := chunkstore.NewChunkService(persistantChunkStore)
-	chunkStores = append(chunkStores, persistantChunkService)
+	raining, _ := levelData.Lookup("Data/raining").(*nbt.Byte)
+	rainTime, _ := levelData.Lookup("Data/rainTime").(*nbt.Int)
+	thundering, _ := levelData.Lookup("Data/thundering").(*nbt.Byte)
+	thunderTime, _ := levelData.Lookup("Data/thunderTime").(*nbt.Int)
 
 	var seed int64
 	if seedNbt, ok := levelData.Lookup("Data/RandomSeed").(*nbt.Long); ok {
@@ -72,47 +86,114 @@ func LoadWorldStore(worldPath string) (world *WorldStore, err error) {
 		seed = rand.NewSource(time.Now().UnixNano()).Int63()
 	}
 
-	chunkStores = append(chunkStores, chunkstore.NewChunkService(generation.NewTestGenerator(seed)))
+	generatorName := generation.UnnamedGenerator
+	if nameTag, ok := levelData.Lookup("Data/generatorName").(*nbt.String); ok {
+		generatorName = nameTag.Value
+	}
+	newGenerator, ok := generation.Generators[generatorName]
+	if !ok {
+		// Vanilla worlds name generators, such as "flat", that aren't
+		// implemented here.
+		log.Printf("Unknown generator %q in level data, using %q instead.", generatorName, generation.DefaultGenerator)
+		generatorName = generation.DefaultGenerator
+		newGenerator = generation.Generators[generatorName]
+	}
 
-	for _, store := range chunkStores {
-		go store.Serve()
+	generator := newGenerator(seed)
+	chunkStore, err := serveDimension(worldPath, levelData, DimensionNormal, generator)
+	if err != nil {
+		return nil, err
+	}
+	biomes, _ := generator.(gamerules.IBiomeSource)
+
+	netherChunkStore, err := serveDimension(worldPath, levelData, DimensionNether, generation.NewNetherGenerator(seed))
+	if err != nil {
+		return nil, err
 	}
 
 	world = &WorldStore{
-		WorldPath:     worldPath,
-		Seed:          seed,
-		Time:          timeTicks,
-		LevelData:     levelData,
-		ChunkStore:    chunkstore.NewChunkService(chunkstore.NewMultiStore(chunkStores, persistantChunkService)),
-		SpawnPosition: spawnPosition,
+		WorldPath:        worldPath,
+		Seed:             seed,
+		Time:             timeTicks,
+		Raining:          raining != nil && raining.Value != 0,
+		Thundering:       thundering != nil && thundering.Value != 0,
+		LevelData:        levelData,
+		Generator:        generatorName,
+		ChunkStore:       chunkStore,
+		NetherChunkStore: netherChunkStore,
+		Biomes:           biomes,
+		SpawnPosition:    spawnPosition,
 	}
 
-	go world.ChunkStore.Serve()
+	if rainTime != nil {
+		world.RainTime = Ticks(rainTime.Value)
+	}
+	if thunderTime != nil {
+		world.ThunderTime = Ticks(thunderTime.Value)
+	}
 
 	return
 }
 
-func loadLevelData(worldPath string) (levelData nbt.ITag, err error) {
-	filename := path.Join(worldPath, "level.dat")
-	file, err := os.Open(filename)
+// serveDimension serves the chunks of the dimension, which are read from the
+// world's chunk store or else generated by generator.
+func serveDimension(worldPath string, levelData nbt.ITag, dimension DimensionId, generator chunkstore.IChunkStoreForeground) (store chunkstore.IChunkStore, err error) {
+	persistantChunkStore, err := chunkstore.ChunkStoreForLevel(worldPath, levelData, dimension)
 	if err != nil {
-		return
+		return nil, err
 	}
-	defer file.Close()
 
-	gzipReader, err := gzip.NewReader(file)
-	if err != nil {
-		return
+	persistantChunkService := chunkstore.NewChunkService(persistantChunkStore)
+	chunkStores := []chunkstore.IChunkStore{
+		persistantChunkService,
+		chunkstore.NewChunkService(generator),
+	}
+	for _, store := range chunkStores {
+		go store.Serve()
 	}
-	defer gzipReader.Close()
 
-	levelData, err = nbt.Read(gzipReader)
+	store = chunkstore.NewChunkService(chunkstore.NewMultiStore(chunkStores, persistantChunkService))
+	go store.Serve()
 
-	return
+	return store, nil
 }
 
-// NOTE: ChunkStoreForDimension shouldn't really be used in the server just
-// yet.
+// WriteLevelData updates level.dat with the world's time, spawn position and
+// weather. The previous level.dat is kept as a backup.
+func (world *WorldStore) WriteLevelData() (err error) {
+	root, rootOk := world.LevelData.(*nbt.Compound)
+	data, dataOk := world.LevelData.Lookup("Data").(*nbt.Compound)
+	if !rootOk || !dataOk {
+		return BadType("Data")
+	}
+
+	data.Set("Time", &nbt.Long{int64(world.Time)})
+	data.Set("SpawnX", &nbt.Int{int32(world.SpawnPosition.X)})
+	data.Set("SpawnY", &nbt.Int{int32(world.SpawnPosition.Y)})
+	data.Set("SpawnZ", &nbt.Int{int32(world.SpawnPosition.Z)})
+	data.Set("raining", boolByte(world.Raining))
+	data.Set("rainTime", &nbt.Int{int32(world.RainTime)})
+	data.Set("thundering", boolByte(world.Thundering))
+	data.Set("thunderTime", &nbt.Int{int32(world.ThunderTime)})
+	data.Set("LastPlayed", &nbt.Long{time.Now().UnixNano() / 1e6})
+
+	return writeLevelData(world.WorldPath, root)
+}
+
+func boolByte(value bool) *nbt.Byte {
+	if value {
+		return &nbt.Byte{1}
+	}
+	return &nbt.Byte{0}
+}
+
+func loadLevelData(worldPath string) (levelData nbt.ITag, err error) {
+	return readNbtFile(path.Join(worldPath, "level.dat"))
+}
+
+// ChunkStoreForDimension opens the chunks that have been stored for the
+// dimension, without generating those that haven't. It is for tools that
+// inspect worlds; the server uses ChunkStore and NetherChunkStore.
 func (world *WorldStore) ChunkStoreForDimension(dimension DimensionId) (store chunkstore.IChunkStore, err error) {
 	fgStore, err := chunkstore.ChunkStoreForLevel(world.WorldPath, world.LevelData, dimension)
 	if err != nil {
@@ -124,47 +205,37 @@ func (world *WorldStore) ChunkStoreForDimension(dimension DimensionId) (store ch
 }
 
 func (world *WorldStore) PlayerData(user string) (playerData *nbt.Compound, err error) {
-	file, err := os.Open(path.Join(world.WorldPath, "players", user+".dat"))
-	if err != nil {
-		if os.IsNotExist(err) {
-			// Player data simply doesn't exist. Not an error, playerData = nil is
-			// the result.
-			return nil, nil
-		}
-		return
+	playerData, err = readNbtFile(path.Join(world.WorldPath, "players", user+".dat"))
+	if os.IsNotExist(err) {
+		// Player data simply doesn't exist. Not an error, playerData = nil is
+		// the result.
+		return nil, nil
 	}
-	defer file.Close()
 
-	gzipReader, err := gzip.NewReader(file)
-	if err != nil {
+	return
+}
+
+// PlayerDataTime returns when the player's data was last written, or the zero
+// time if it never has been.
+func (world *WorldStore) PlayerDataTime(user string) (modTime time.Time, err error) {
+	fi, err := os.Stat(path.Join(world.WorldPath, "players", user+".dat"))
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	} else if err != nil {
 		return
 	}
-	defer gzipReader.Close()
-
-	playerData, err = nbt.Read(gzipReader)
-
-	return
+	return fi.ModTime(), nil
 }
 
+// WritePlayerData replaces the player's data file. The previous data is kept
+// as a backup.
 func (world *WorldStore) WritePlayerData(user string, data *nbt.Compound) (err error) {
 	playerDir := path.Join(world.WorldPath, "players")
 	if err = os.MkdirAll(playerDir, 0777); err != nil {
 		return
 	}
 
-	filename := path.Join(world.WorldPath, "players", user+".dat")
-	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE, 0666)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	gzipWriter := gzip.NewWriter(file)
-
-	err = nbt.Write(gzipWriter, data)
-	gzipWriter.Close()
-
-	return
+	return writeNbtFile(path.Join(playerDir, user+".dat"), data)
 }
 
 // Creates a new world at 'worldPath'
@@ -172,23 +243,30 @@ func CreateWorld(worldPath string) (err error) {
 	source := rand.NewSource(time.Now().UnixNano())
 	seed := source.Int63()
 
+	// Spawn players on the ground, or on the sea.
+	spawnY := generation.NewBiomeGenerator(seed).SurfaceHeight(0, 0) + 1
+	if spawnY <= generation.SeaLevel {
+		spawnY = generation.SeaLevel + 1
+	}
+
 	data := &nbt.Compound{
 		map[string]nbt.ITag{
 			"Data": &nbt.Compound{
 				map[string]nbt.ITag{
-					"Time":        &nbt.Long{0},
-					"rainTime":    &nbt.Int{0},
-					"thunderTime": &nbt.Int{0},
-					"version":     &nbt.Int{19132}, // TODO: What should this be?
-					"thundering":  &nbt.Byte{0},
-					"raining":     &nbt.Byte{0},
-					"LevelName":   &nbt.String{"world"}, // TODO: Should be specifyable
-					"SpawnX":      &nbt.Int{0},          // TODO: Figure this out from chunk generator?
-					"SpawnY":      &nbt.Int{75},         // TODO: Figure this out from chunk generator?
-					"SpawnZ":      &nbt.Int{0},          // TODO: Figure this out from chunk generator?
-					"LastPlayed":  &nbt.Long{0},
-					"SizeOnDisk":  &nbt.Long{0}, // Needs to be accurate?
-					"RandomSeed":  &nbt.Long{seed},
+					"Time":          &nbt.Long{0},
+					"rainTime":      &nbt.Int{0},
+					"thunderTime":   &nbt.Int{0},
+					"version":       &nbt.Int{19132}, // TODO: What should this be?
+					"thundering":    &nbt.Byte{0},
+					"raining":       &nbt.Byte{0},
+					"LevelName":     &nbt.String{"world"}, // TODO: Should be specifyable
+					"SpawnX":        &nbt.Int{0},
+					"SpawnY":        &nbt.Int{int32(spawnY)},
+					"SpawnZ":        &nbt.Int{0},
+					"LastPlayed":    &nbt.Long{0},
+					"SizeOnDisk":    &nbt.Long{0}, // Needs to be accurate?
+					"RandomSeed":    &nbt.Long{seed},
+					"generatorName": &nbt.String{generation.DefaultGenerator},
 				},
 			},
 		},
@@ -198,19 +276,11 @@ func CreateWorld(worldPath string) (err error) {
 		return
 	}
 
-	filename := path.Join(worldPath, "level.dat")
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		return err
-	}
-
-	gzipWriter := gzip.NewWriter(file)
-
-	err = nbt.Write(gzipWriter, data)
-	gzipWriter.Close()
-	file.Close()
+	return writeLevelData(worldPath, data)
+}
 
-	return nil
+func writeLevelData(worldPath string, levelData *nbt.Compound) error {
+	return writeNbtFile(path.Join(worldPath, "level.dat"), levelData)
 }
 
 func absXyzFromNbt(tag nbt.ITag, path string) (pos AbsXyz, err error) {
@@ -0,0 +1,136 @@
+package worldstore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"chunkymonkey/generation"
+	. "chunkymonkey/types"
+	"nbt"
+)
+
+func TestWriteLevelData(t *testing.T) {
+	worldPath, err := ioutil.TempDir("", "worldstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(worldPath)
+
+	if err = CreateWorld(worldPath); err != nil {
+		t.Fatal(err)
+	}
+	world, err := LoadWorldStore(worldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	world.Time = 12345
+	world.SpawnPosition = BlockXyz{10, 64, -20}
+	world.Raining = true
+	world.RainTime = 600
+	world.Thundering = true
+	world.ThunderTime = 300
+	if err = world.WriteLevelData(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadWorldStore(worldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded.Seed != world.Seed {
+		t.Errorf("Seed = %d, want %d", reloaded.Seed, world.Seed)
+	}
+	if reloaded.Time != world.Time {
+		t.Errorf("Time = %d, want %d", reloaded.Time, world.Time)
+	}
+	if reloaded.SpawnPosition != world.SpawnPosition {
+		t.Errorf("SpawnPosition = %v, want %v", reloaded.SpawnPosition, world.SpawnPosition)
+	}
+	if reloaded.Raining != world.Raining || reloaded.RainTime != world.RainTime {
+		t.Errorf("rain = %t/%d, want %t/%d", reloaded.Raining, reloaded.RainTime, world.Raining, world.RainTime)
+	}
+	if reloaded.Thundering != world.Thundering || reloaded.ThunderTime != world.ThunderTime {
+		t.Errorf("thunder = %t/%d, want %t/%d", reloaded.Thundering, reloaded.ThunderTime, world.Thundering, world.ThunderTime)
+	}
+}
+
+func TestPlayerDataTime(t *testing.T) {
+	worldPath, err := ioutil.TempDir("", "worldstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(worldPath)
+
+	world := &WorldStore{WorldPath: worldPath}
+	if modTime, err := world.PlayerDataTime("alice"); err != nil || !modTime.IsZero() {
+		t.Errorf("PlayerDataTime() before writing = %v, %v, want zero time", modTime, err)
+	}
+
+	if err = world.WritePlayerData("alice", nbt.NewCompound()); err != nil {
+		t.Fatal(err)
+	}
+	if modTime, err := world.PlayerDataTime("alice"); err != nil || modTime.IsZero() {
+		t.Errorf("PlayerDataTime() after writing = %v, %v, want the time written", modTime, err)
+	}
+}
+
+func TestLoadWorldStoreGenerator(t *testing.T) {
+	worldPath, err := ioutil.TempDir("", "worldstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(worldPath)
+
+	if err = CreateWorld(worldPath); err != nil {
+		t.Fatal(err)
+	}
+	levelData, err := loadLevelData(worldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := levelData.Lookup("Data/generatorName").(*nbt.String); !ok || name.Value != generation.DefaultGenerator {
+		t.Errorf("CreateWorld() wrote generatorName %v, want %q", levelData.Lookup("Data/generatorName"), generation.DefaultGenerator)
+	}
+
+	// An empty generatorName removes the tag. Unknown generators fall back to
+	// the default.
+	tests := []struct {
+		generatorName string
+		want          string
+		wantBiomes    bool
+	}{
+		{generation.DefaultGenerator, generation.DefaultGenerator, true},
+		{"test", "test", false},
+		{"", generation.UnnamedGenerator, false},
+		{"flat", generation.DefaultGenerator, true},
+		{"largeBiomes", generation.DefaultGenerator, true},
+	}
+
+	for _, test := range tests {
+		levelData, err := loadLevelData(worldPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := levelData.Lookup("Data").(*nbt.Compound)
+		if test.generatorName == "" {
+			delete(data.Tags, "generatorName")
+		} else {
+			data.Set("generatorName", &nbt.String{test.generatorName})
+		}
+		if err = writeLevelData(worldPath, levelData.(*nbt.Compound)); err != nil {
+			t.Fatal(err)
+		}
+
+		world, err := LoadWorldStore(worldPath)
+		if err != nil {
+			t.Errorf("%s: LoadWorldStore() = %v", test.generatorName, err)
+		} else if world.Generator != test.want {
+			t.Errorf("%s: got generator %q, want %q", test.generatorName, world.Generator, test.want)
+		} else if (world.Biomes != nil) != test.wantBiomes {
+			t.Errorf("%s: got biomes %v, want biomes=%t", test.generatorName, world.Biomes, test.wantBiomes)
+		}
+	}
+}
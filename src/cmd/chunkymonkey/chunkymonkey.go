@@ -1,67 +1,34 @@
 package main
 
 import (
+	"bufio"
 	_ "expvar"
 	"flag"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"chunkymonkey"
+	"chunkymonkey/config"
 	"chunkymonkey/gamerules"
 	"chunkymonkey/worldstore"
 )
 
-var addr = flag.String(
-	"addr", ":25565",
-	"Serves on the given address:port.")
+var configFile = flag.String(
+	"config", "server.json",
+	"The JSON file containing the server configuration.")
 
-var httpAddr = flag.String(
-	"http_addr", ":25566",
-	"Serves HTTP diagnostics on the given address:port.")
-
-var blockDefs = flag.String(
-	"blocks", "blocks.json",
-	"The JSON file containing block type definitions.")
-
-var itemDefs = flag.String(
-	"items", "items.json",
-	"The JSON file containing item type definitions.")
-
-var recipeDefs = flag.String(
-	"recipes", "recipes.json",
-	"The JSON file containing recipe definitions.")
-
-var furnaceDefs = flag.String(
-	"furnace", "furnace.json",
-	"The JSON file containing furnace fuel and reaction definitions.")
-
-var serverDesc = flag.String(
-	"server_desc", "Chunkymonkey Minecraft server",
-	"The server description.")
-
-var maintenanceMsg = flag.String(
-	"maintenance_msg", "",
-	"If set, all logins will be denied and this message will be given as reason.")
-
-var userDefs = flag.String(
-	"users", "users.json",
-	"The JSON file container user permissions.")
-
-var groupDefs = flag.String(
-	"groups", "groups.json",
-	"The JSON file containing group permissions.")
-
-// TODO Implement max player count enforcement. Probably would have to be
-// implemented atomically at the game level.
-var maxPlayerCount = flag.Int(
-	"max_player_count", 16,
-	"Maximum number of players to allow concurrently. (Does not work yet)")
+var console = flag.Bool(
+	"console", false,
+	"Run commands typed on standard input, with all permissions.")
 
 func usage() {
-	os.Stderr.WriteString("usage: " + os.Args[0] + " [flags] <world>\n")
+	os.Stderr.WriteString("usage: " + os.Args[0] + " [flags] [<world>]\n")
 	flag.PrintDefaults()
 }
 
@@ -74,24 +41,20 @@ func startHttpServer(addr string) (err error) {
 	return
 }
 
-func main() {
-	var err error
-
-	flag.Usage = usage
-	flag.Parse()
-
-	if flag.NArg() != 1 {
-		flag.Usage()
-		os.Exit(1)
-	}
-
-	err = gamerules.LoadGameRules(*blockDefs, *itemDefs, *recipeDefs, *furnaceDefs, *userDefs, *groupDefs)
-	if err != nil {
-		log.Print("Error loading game rules: ", err)
-		os.Exit(1)
+// runConsole runs the commands read from standard input, and prints their
+// output.
+func runConsole(game *chunkymonkey.Game) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		for _, line := range game.RunConsoleCommand("console", scanner.Text()) {
+			fmt.Println(line)
+		}
 	}
+}
 
-	worldPath := flag.Arg(0)
+// openWorld checks that there is a world at worldPath, creating a new world
+// there if there is nothing.
+func openWorld(worldPath string) {
 	fi, err := os.Stat(worldPath)
 	if err != nil {
 		log.Printf("Could not load world from directory %v: %v", worldPath, err)
@@ -108,21 +71,69 @@ func main() {
 		log.Printf("Error loading world %v: Not a directory", worldPath)
 		os.Exit(1)
 	}
+}
 
-	listener, err := net.Listen("tcp", *addr)
+func main() {
+	var err error
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	serverConfig, err := config.LoadFromFile(*configFile)
 	if err != nil {
-		log.Fatal(err)
+		log.Print("Error loading server config: ", err)
+		os.Exit(1)
+	}
+	if flag.NArg() == 1 {
+		serverConfig.World.Path = flag.Arg(0)
 	}
 
-	game, err := chunkymonkey.NewGame(worldPath, listener, *serverDesc, *maintenanceMsg, *maxPlayerCount)
+	rules, perms := &serverConfig.Rules, &serverConfig.Permissions
+	err = gamerules.LoadGameRules(rules.Blocks, rules.Items, rules.Recipes, rules.Furnace, perms.Users, perms.Groups)
+	if err != nil {
+		log.Print("Error loading game rules: ", err)
+		os.Exit(1)
+	}
+
+	openWorld(serverConfig.World.Path)
+	for i := range serverConfig.Worlds {
+		openWorld(serverConfig.Worlds[i].Path)
+	}
+
+	listener, err := net.Listen("tcp", serverConfig.Network.Addr)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = startHttpServer(*httpAddr)
+	game, err := chunkymonkey.NewGame(serverConfig, listener)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if serverConfig.Network.HttpAddr != "" {
+		http.Handle("/api/", game.HttpHandler(serverConfig.Network.HttpToken))
+		err = startHttpServer(serverConfig.Network.HttpAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		sig := <-signals
+		log.Printf("Received %v.", sig)
+		game.Shutdown("Server shutting down.")
+	}()
+
+	if *console {
+		go runConsole(game)
+	}
+
 	game.Serve()
 }
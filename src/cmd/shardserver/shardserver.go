@@ -0,0 +1,122 @@
+// The shardserver command hosts some of the shards of a world for a
+// chunkymonkey server. Which shards it hosts is set out by the Shards section
+// of the server configuration, which must be the same for every process that
+// shares the world.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"chunkymonkey/blocklog"
+	"chunkymonkey/config"
+	"chunkymonkey/entity"
+	"chunkymonkey/gamerules"
+	"chunkymonkey/remoteshard"
+	"chunkymonkey/shardserver"
+	. "chunkymonkey/types"
+	"chunkymonkey/worldstore"
+)
+
+var configFile = flag.String(
+	"config", "server.json",
+	"The JSON file containing the server configuration.")
+
+var addr = flag.String(
+	"addr", "",
+	"The address:port to serve shards on. It must be the Addr of one of the Shards.Hosts.")
+
+func usage() {
+	os.Stderr.WriteString("usage: " + os.Args[0] + " [flags] [<world>]\n")
+	flag.PrintDefaults()
+}
+
+func isHost(shards *config.ShardsConfig, addr string) bool {
+	for i := range shards.Hosts {
+		if shards.Hosts[i].Addr == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	serverConfig, err := config.LoadFromFile(*configFile)
+	if err != nil {
+		log.Print("Error loading server config: ", err)
+		os.Exit(1)
+	}
+	if flag.NArg() == 1 {
+		serverConfig.World.Path = flag.Arg(0)
+	}
+
+	if !isHost(&serverConfig.Shards, *addr) {
+		log.Printf("-addr=%q is not the Addr of any of the Shards.Hosts", *addr)
+		os.Exit(1)
+	}
+
+	rules, perms := &serverConfig.Rules, &serverConfig.Permissions
+	err = gamerules.LoadGameRules(rules.Blocks, rules.Items, rules.Recipes, rules.Furnace, perms.Users, perms.Groups)
+	if err != nil {
+		log.Print("Error loading game rules: ", err)
+		os.Exit(1)
+	}
+
+	// The world is created by the frontend server, which must have been run
+	// at least once.
+	worldPath := serverConfig.World.Path
+	worldStore, err := worldstore.LoadWorldStore(worldPath)
+	if err != nil {
+		log.Printf("Error loading world %v: %v", worldPath, err)
+		os.Exit(1)
+	}
+
+	blockLog, err := blocklog.Open(blocklog.Dir(worldPath, DimensionNormal))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var entityMgr entity.EntityManager
+	entityMgr.InitRange(serverConfig.Shards.EntityIdRange(*addr))
+
+	shardManager := shardserver.NewLocalShardManager(worldStore.ChunkStore, &entityMgr, blockLog, &serverConfig.World)
+	shardManager.SetRemoteShards(remoteshard.NewRemoteShards(&serverConfig.Shards, *addr, &entityMgr))
+	shardManager.SetBiomes(worldStore.Biomes)
+	shardManager.KeepLoaded(*worldStore.SpawnPosition.ToChunkXz(), serverConfig.World.SpawnRadius)
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := remoteshard.NewServer(listener, shardManager, &entityMgr, serverConfig.Shards.Secret)
+	log.Printf("Serving shards on %s.", *addr)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	sig := <-signals
+	log.Printf("Received %v.", sig)
+
+	server.Stop()
+
+	log.Print("Saving chunks.")
+	shardManager.Stop()
+	worldStore.ChunkStore.Flush()
+
+	if err := blockLog.Close(); err != nil {
+		log.Printf("Failed when closing block log: %v", err)
+	}
+
+	log.Print("Shards saved.")
+}